@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/bootimg"
+)
+
+// bootimgCmd represents the bootimg command
+var bootimgCmd = &cobra.Command{
+	Use:   "bootimg filename",
+	Short: "Decode and unpack Android boot and vendor_boot images",
+	Long: `Print header of Android boot image v0-v4 or vendor_boot image v3-v4: load addresses, os version, cmdline
+	and sections with offsets. With --extract kernel, ramdisk, second, dtb, vendor ramdisks, bootconfig and
+	cmdline are written in output directory. Sections are copied from image without loading it in memory. Example:
+
+	fw-tools bootimg boot.img
+	fw-tools bootimg --extract -o vendor_boot vendor_boot.img
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		t := bootimg.New(cfg.BootImg)
+		err := t.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer t.Close()
+		err = t.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	bootimgCmd.Flags().Int64VarP(&cfg.BootImg.Offset, "offset", "", 0, "Offset of boot image in file")
+	bootimgCmd.Flags().BoolVarP(&cfg.BootImg.Extract, "extract", "x", false, "Extract sections of image")
+	bootimgCmd.Flags().StringVarP(&cfg.BootImg.Output, "output", "o", "", "Directory for extracted sections, default is name of image with -bootimg suffix")
+	rootCmd.AddCommand(bootimgCmd)
+}
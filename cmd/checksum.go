@@ -0,0 +1,70 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/checksum"
+)
+
+// checksumCmd represents the checksum command
+var checksumCmd = &cobra.Command{
+	Use:   "checksum filename",
+	Short: "Calculate and search checksums and hashes",
+	Long: `Calculate checksums of range of file with algorithms from catalogue: CRC-8/16/24/32/64 models
+	with reveng names, additive sums, Adler-32, Fletcher, MD5 and SHA-1/2, --list prints catalogue.
+	With --search checksums of ranges from start to every end are compared with value in both byte orders,
+	value is set as hex or read at offset of stored checksum. Example:
+
+	fw-tools checksum -a crc32 -a crc-16/modbus --start 0x40 firmware.bin
+	fw-tools checksum --search --at 0xfffc --end 0xfffc firmware.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cfg.Checksum.List {
+			return nil
+		}
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		c := checksum.New(cfg.Checksum)
+		input := ""
+		if len(cfg.Inputs) > 0 {
+			input = cfg.Inputs[0]
+		}
+		err := c.Open(input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		err = c.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	checksumCmd.Flags().StringArrayVarP(&cfg.Checksum.Algorithms, "algorithm", "a", nil, "Algorithm from catalogue, all by default, can be repeated")
+	checksumCmd.Flags().Int64VarP(&cfg.Checksum.Start, "start", "", 0, "Start of range")
+	checksumCmd.Flags().Int64VarP(&cfg.Checksum.End, "end", "", 0, "End of range, end of file by default")
+	checksumCmd.Flags().BoolVarP(&cfg.Checksum.List, "list", "l", false, "Print catalogue of algorithms")
+	checksumCmd.Flags().BoolVarP(&cfg.Checksum.Search, "search", "", false, "Search algorithm and end of range for known checksum")
+	checksumCmd.Flags().StringVarP(&cfg.Checksum.Value, "value", "", "", "Known checksum in hex for search")
+	checksumCmd.Flags().Int64VarP(&cfg.Checksum.At, "at", "", -1, "Offset of known checksum for search")
+	checksumCmd.Flags().Int64VarP(&cfg.Checksum.Step, "step", "", 1, "Step of end of range for search")
+	rootCmd.AddCommand(checksumCmd)
+}
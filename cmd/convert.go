@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/convert"
+)
+
+// convertCmd represents the convert command
+var convertCmd = &cobra.Command{
+	Use:   "convert filename",
+	Short: "Convert between flat binary, Intel HEX, Motorola S-Record and UF2",
+	Long: `Convert Intel HEX, Motorola S-Record or UF2 to flat binary or flat binary to these formats.
+	Format of input is detected by content, format of output is set by --to or extension of output.
+	Gaps between records are filled with --fill byte, flat image begins at --base or at the lowest address.
+	Checksums of records are validated, extended segment and linear addresses are supported.
+	With --sparse chunks of binary filled with fill byte are dropped. Example:
+
+	fw-tools convert firmware.hex
+	fw-tools convert --to uf2 --base 0x10000000 --family rp2040 firmware.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		c := convert.New(cfg.Convert)
+		err := c.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		err = c.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	convertCmd.Flags().StringVarP(&cfg.Convert.From, "from", "", "", "Format of input: bin, ihex, srec or uf2, detected by default")
+	convertCmd.Flags().StringVarP(&cfg.Convert.To, "to", "t", "", "Format of output: bin, ihex, srec or uf2")
+	convertCmd.Flags().Int64VarP(&cfg.Convert.Base, "base", "b", -1, "Address of flat binary, the lowest address by default")
+	convertCmd.Flags().Uint8VarP(&cfg.Convert.Fill, "fill", "", 0xff, "Byte for gaps between records")
+	convertCmd.Flags().IntVarP(&cfg.Convert.Record, "record", "r", 16, "Bytes in record of Intel HEX and S-Record")
+	convertCmd.Flags().StringVarP(&cfg.Convert.Family, "family", "", "", "Family of UF2 by name or ID, e.g. rp2040 or 0xe48bff56")
+	convertCmd.Flags().BoolVarP(&cfg.Convert.Sparse, "sparse", "", false, "Drop chunks filled with fill byte from output")
+	convertCmd.Flags().StringVarP(&cfg.Convert.Output, "output", "o", "", "Output file")
+	rootCmd.AddCommand(convertCmd)
+}
@@ -45,7 +45,25 @@ var cutCmd = &cobra.Command{
 }
 
 func init() {
-	cutCmd.Flags().IntVarP(&cfg.Cut.PageSize, "page", "p", 0x400, "Page size, which will writed")
-	cutCmd.Flags().IntVarP(&cfg.Cut.SkipSize, "skip", "s", 0x20, "Metainfo size, which will skipped")
+	geometryFlags(cutCmd)
 	rootCmd.AddCommand(cutCmd)
 }
+
+// geometryFlags binds page geometry, all commands must share the same defaults
+func geometryFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&cfg.Cut.PageSize, "page", "p", 0x400, "Page size, which will writed")
+	cmd.Flags().IntVarP(&cfg.Cut.SkipSize, "skip", "s", 0x20, "Metainfo size, which will skipped")
+}
+
+// blockFlag binds number of pages in erase block for commands, which work with blocks
+func blockFlag(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&cfg.Cut.PagesPerBlock, "block-pages", "", 64, "Number of pages in erase block")
+}
+
+// ftlFlags binds positions of FTL fields in spare area
+func ftlFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&cfg.Cut.LBAOffset, "lba-offset", "", 2, "Offset of logical block number in spare area")
+	cmd.Flags().IntVarP(&cfg.Cut.LBASize, "lba-size", "", 2, "Size of logical block number")
+	cmd.Flags().IntVarP(&cfg.Cut.VersionOffset, "version-offset", "", 0, "Offset of version or wear counter in spare area")
+	cmd.Flags().IntVarP(&cfg.Cut.VersionSize, "version-size", "", 0, "Size of version or wear counter, 0 if there isn't one")
+}
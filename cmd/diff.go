@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/diff"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff reference filename [filename2]...",
+	Short: "Compare dumps with reference",
+	Long: `Compare dumps with reference page by page and print differing ranges, bit flips per page and block
+	and histogram of bit flips per page. Page is a page with spare area as for cut command.
+	With --swap dumps are swapped before comparison, with --merge dumps are merged and then compared,
+	so it's possible to check, that dumps are the same after transformation. Example:
+
+	fw-tools diff --swap bytes read1.bin read2.bin
+	fw-tools diff --merge word full.bin chip0.bin chip1.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return errors.New("set reference and filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		d := diff.New(cfg.Diff, cfg.Cut)
+		err := d.Open(cfg.Inputs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+		err = d.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	geometryFlags(diffCmd)
+	blockFlag(diffCmd)
+	diffCmd.Flags().StringArrayVarP(&cfg.Diff.Swap, "swap", "", nil, "Swap dumps before comparison: bits, halfs, bytes, words or dwords, can be repeated")
+	diffCmd.Flags().StringVarP(&cfg.Diff.Merge, "merge", "", "", "Merge dumps before comparison: bit, byte, word or dword")
+	diffCmd.Flags().IntVarP(&cfg.Diff.MaxRanges, "max", "m", 100, "Maximum of printed ranges and pages")
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/dtb"
+)
+
+// dtbCmd represents the dtb command
+var dtbCmd = &cobra.Command{
+	Use:   "dtb filename",
+	Short: "Decode flattened device tree and FIT images",
+	Long: `Print device tree as DTS-like text or JSON. With --images sub-images of FIT are listed and their hashes
+	(crc32, md5, sha1, sha256, sha384, sha512) are verified, --extract writes them in output directory.
+	With --partitions MTD partitions from tree are printed as mtdparts. Example:
+
+	fw-tools dtb --images --extract -o kernel-images image.itb
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		d := dtb.New(cfg.DTB)
+		err := d.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+		err = d.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	dtbCmd.Flags().Int64VarP(&cfg.DTB.Offset, "offset", "", 0, "Offset of tree in file")
+	dtbCmd.Flags().BoolVarP(&cfg.DTB.JSON, "json", "", false, "Print tree as JSON")
+	dtbCmd.Flags().BoolVarP(&cfg.DTB.Images, "images", "", false, "List and verify sub-images of FIT")
+	dtbCmd.Flags().BoolVarP(&cfg.DTB.Extract, "extract", "x", false, "Extract sub-images of FIT")
+	dtbCmd.Flags().BoolVarP(&cfg.DTB.Partitions, "partitions", "", false, "Print MTD partitions as mtdparts")
+	dtbCmd.Flags().StringVarP(&cfg.DTB.Output, "output", "o", "", "Output directory of extracted sub-images")
+	rootCmd.AddCommand(dtbCmd)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/emmc"
+)
+
+// emmcCmd represents the emmc command
+var emmcCmd = &cobra.Command{
+	Use:   "emmc [container]",
+	Short: "Assemble dumps of eMMC partitions in one container",
+	Long: `Assemble dumps of user area, boot and RPMB partitions of eMMC in one tar container with manifest,
+	manifest has sizes and SHA256 of parts and decoded EXT_CSD register. Sizes of parts are checked by
+	EXT_CSD, mismatches are errors without --force. EXT_CSD can be binary or hex text from debugfs,
+	alone it's only decoded. With --extract parts of container are restored and verified. Example:
+
+	fw-tools emmc --ext-csd ext_csd --user user.bin --boot0 boot0.bin --boot1 boot1.bin --rpmb rpmb.bin -o emmc.tar
+	fw-tools emmc --ext-csd ext_csd
+	fw-tools emmc --extract emmc.tar
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cfg.EMMC.Extract {
+			if len(args) != 1 {
+				return errors.New("set container")
+			}
+			cfg.Inputs = append(cfg.Inputs, args...)
+			return nil
+		}
+		if len(args) != 0 {
+			return errors.New("container is used only with --extract")
+		}
+		cfg.Inputs = append(cfg.Inputs, "")
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		a := emmc.New(cfg.EMMC)
+		err := a.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer a.Close()
+		err = a.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.User, "user", "", "", "Dump of user area")
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.Boot0, "boot0", "", "", "Dump of boot0 partition")
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.Boot1, "boot1", "", "", "Dump of boot1 partition")
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.RPMB, "rpmb", "", "", "Dump of RPMB partition")
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.ExtCSD, "ext-csd", "", "", "EXT_CSD register in binary or hex")
+	emmcCmd.Flags().BoolVarP(&cfg.EMMC.Extract, "extract", "x", false, "Extract parts of container")
+	emmcCmd.Flags().BoolVarP(&cfg.EMMC.Force, "force", "", false, "Pack parts with mismatched sizes")
+	emmcCmd.Flags().StringVarP(&cfg.EMMC.Output, "output", "o", "", "Container, emmc.tar by default, or directory for --extract")
+	emmcCmd.MarkFlagsMutuallyExclusive("extract", "user")
+	emmcCmd.MarkFlagsMutuallyExclusive("extract", "ext-csd")
+	rootCmd.AddCommand(emmcCmd)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/entropy"
+)
+
+// entropyCmd represents the entropy command
+var entropyCmd = &cobra.Command{
+	Use:   "entropy filename",
+	Short: "Compute entropy map of dump",
+	Long: `Compute Shannon entropy (bits per byte) of every block of dump for finding compressed or encrypted regions
+	and empty areas. Formats of output:
+
+	csv 		# offset and entropy of every block
+	json 		# blocks and ranges
+	ranges 	# only ranges of high entropy and erased (all 0xFF or 0x00) blocks
+	none 		# only sparkline or plot
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		m := entropy.New(cfg.Entropy)
+		err := m.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer m.Close()
+		err = m.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	entropyCmd.Flags().IntVarP(&cfg.Entropy.BlockSize, "block", "", 0x1000, "Size of block for entropy")
+	entropyCmd.Flags().StringVarP(&cfg.Entropy.Format, "format", "f", "csv", "Output format: csv, json, ranges or none")
+	entropyCmd.Flags().BoolVarP(&cfg.Entropy.Sparkline, "sparkline", "", false, "Print ASCII sparkline")
+	entropyCmd.Flags().IntVarP(&cfg.Entropy.Width, "width", "", 64, "Blocks per line of sparkline")
+	entropyCmd.Flags().StringVarP(&cfg.Entropy.PNG, "png", "", "", "Write plot in PNG file")
+	entropyCmd.Flags().Float64VarP(&cfg.Entropy.High, "high", "", 7.5, "Threshold of high entropy in bits per byte")
+	rootCmd.AddCommand(entropyCmd)
+}
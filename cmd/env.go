@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/env"
+)
+
+// envCmd represents the env command
+var envCmd = &cobra.Command{
+	Use:   "env filename",
+	Short: "Find, print and edit U-Boot environment in dump",
+	Long: `Find U-Boot environment blocks by CRC32 at aligned offsets and print their variables. Redundant copies
+	with flag byte are found too, variables of active copy are printed. With --set and --delete variables are
+	changed with new CRC, redundant environment is written in inactive copy with the next flag as U-Boot does,
+	the previous copy stays as fallback. Patched dump is written in output. Example:
+
+	fw-tools env --set "bootargs=console=ttyS0,115200 init=/bin/sh" --delete bootdelay flash.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := env.New(cfg.Env)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	envCmd.Flags().IntVarP(&cfg.Env.Align, "align", "a", 0x200, "Alignment of environment in dump")
+	envCmd.Flags().IntVarP(&cfg.Env.Size, "size", "s", 0, "Size of environment, usual sizes are tried by default")
+	envCmd.Flags().StringArrayVarP(&cfg.Env.Set, "set", "", nil, "Set variable as name=value, can be repeated")
+	envCmd.Flags().StringArrayVarP(&cfg.Env.Delete, "delete", "", nil, "Delete variable, can be repeated")
+	envCmd.Flags().StringVarP(&cfg.Env.Output, "output", "o", "", "Output of patched dump")
+	rootCmd.AddCommand(envCmd)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/ext4"
+)
+
+// ext4Cmd represents the ext4 command
+var ext4Cmd = &cobra.Command{
+	Use:   "ext4 filename",
+	Short: "List, print and extract files of ext2/3/4 without mounting",
+	Long: `List, print and extract files of ext2, ext3 and ext4 filesystem read-only, filesystem can be at offset
+	of image, e.g. partition from partition command. Extents, flex_bg, 64-bit block numbers and inline data
+	are supported, journal isn't replayed. Devices, pipes and sockets are only listed. Example:
+
+	fw-tools ext4 --list rootfs.ext4
+	fw-tools ext4 --cat /etc/passwd rootfs.ext4
+	fw-tools ext4 --offset 0x100000 -o rootfs emmc.img
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := ext4.New(cfg.Ext4)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	ext4Cmd.Flags().Int64VarP(&cfg.Ext4.Offset, "offset", "", 0, "Offset of filesystem in image")
+	ext4Cmd.Flags().BoolVarP(&cfg.Ext4.List, "list", "l", false, "Only list files")
+	ext4Cmd.Flags().StringVarP(&cfg.Ext4.Cat, "cat", "", "", "Print file with path to stdout")
+	ext4Cmd.Flags().StringVarP(&cfg.Ext4.Output, "output", "o", "", "Directory for extracted files, default is name of image with -ext4 suffix")
+	rootCmd.AddCommand(ext4Cmd)
+}
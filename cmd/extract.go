@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/extract"
+)
+
+// extractCmd represents the extract command
+var extractCmd = &cobra.Command{
+	Use:   "extract filename",
+	Short: "Extract ranges of file by expressions",
+	Long: `Extract ranges of file like dd, every range is written in own file <output>-<start>-<end>.bin or all ranges
+	are concatenated with --concat. Range is <start>:<end>, <start>+<size> or <start> till end of file, without ':'
+	the last '+' separates size. Start, end and size are sums and differences of numbers, "end" of file and fields
+	of file "@<offset>:<type>", type is u8, u16le, u16be, u32le, u32be, u64le or u64be. Example:
+
+	fw-tools extract -r 0x20000+0x1000 -r end-0x100:end firmware.bin
+	fw-tools extract -r 0x40+@0x0c:u32be --concat -o kernel.bin firmware.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := extract.New(cfg.Extract)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	extractCmd.Flags().StringArrayVarP(&cfg.Extract.Ranges, "range", "r", nil, "Range of file, can be repeated")
+	extractCmd.Flags().BoolVarP(&cfg.Extract.Concat, "concat", "", false, "Concatenate ranges in one file")
+	extractCmd.Flags().StringVarP(&cfg.Extract.Output, "output", "o", "", "Output file with --concat or prefix of files, default is name of input")
+	rootCmd.AddCommand(extractCmd)
+}
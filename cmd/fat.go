@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/fat"
+)
+
+// fatCmd represents the fat command
+var fatCmd = &cobra.Command{
+	Use:   "fat filename",
+	Short: "List and extract files of FAT12/16/32 and exFAT",
+	Long: `List and extract files of FAT12, FAT16, FAT32 and exFAT volume with long names, volume can be at offset
+	of image, e.g. partition from partition command. With --deleted deleted files and directories are recovered,
+	if their clusters are still free. Deleted files of FAT lose the first char of short name, it's restored by
+	long name or replaced by '_'. Example:
+
+	fw-tools fat --list boot.img
+	fw-tools fat --offset 0x100000 --deleted -o sdcard-files sdcard.img
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := fat.New(cfg.FAT)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	fatCmd.Flags().Int64VarP(&cfg.FAT.Offset, "offset", "", 0, "Offset of volume in image")
+	fatCmd.Flags().BoolVarP(&cfg.FAT.List, "list", "l", false, "Only list files")
+	fatCmd.Flags().BoolVarP(&cfg.FAT.Deleted, "deleted", "d", false, "Recover deleted files")
+	fatCmd.Flags().StringVarP(&cfg.FAT.Output, "output", "o", "", "Directory for extracted files, default is name of image with -fat suffix")
+	rootCmd.AddCommand(fatCmd)
+}
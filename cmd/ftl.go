@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/ftl"
+)
+
+// ftlCmd represents the ftl command
+var ftlCmd = &cobra.Command{
+	Use:   "ftl filename",
+	Short: "Reconstruct logical image of NAND with FTL of controller",
+	Long: `Read raw NAND dump of USB flash drive or SD card by blocks and map them to logical blocks by FTL fields
+	in spare areas. Page geometry is the same as for cut command, --lba-* and --version-* are positions of fields
+	in spare area. When logical block has some copies, copy with the highest version or wear counter is used, then
+	copy with more programmed pages. Missing logical blocks are filled with 0xff. Mapper "lba" is a generic
+	FTL with logical number in spare area. Example:
+
+	fw-tools ftl -p 0x200 -s 0x10 --block-pages 32 --lba-offset 6 --map nand.bin
+	fw-tools ftl -p 0x800 -s 0x40 --lba-offset 2 --version-offset 4 --version-size 4 -o disk.img nand.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		r := ftl.New(cfg.FTL, cfg.Cut)
+		err := r.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer r.Close()
+		err = r.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	geometryFlags(ftlCmd)
+	blockFlag(ftlCmd)
+	ftlFlags(ftlCmd)
+	ftlCmd.Flags().StringVarP(&cfg.FTL.Mapper, "mapper", "m", "lba", "Mapper of FTL")
+	ftlCmd.Flags().BoolVarP(&cfg.FTL.BigEndian, "big-endian", "", false, "Fields of spare area are big-endian")
+	ftlCmd.Flags().BoolVarP(&cfg.FTL.Map, "map", "", false, "Only print map of logical blocks")
+	ftlCmd.Flags().StringVarP(&cfg.FTL.Output, "output", "o", "", "Logical image, default is name of dump with -logical suffix")
+	rootCmd.AddCommand(ftlCmd)
+}
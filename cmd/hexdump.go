@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/hexdump"
+)
+
+// hexdumpCmd represents the hexdump command
+var hexdumpCmd = &cobra.Command{
+	Use:   "hexdump filename",
+	Short: "Print hexdump of raw dump with page and spare boundaries",
+	Long: `Print range of raw dump as hex and ASCII, lines are split and annotated at boundaries of pages,
+	sub-pages and spare areas, spare area is coloured. Values are printed as words of --word bytes,
+	which are swapped by --swap options, e.g. --word 4 --swap bytes --swap words prints little-endian dwords.
+	With --plain dump is printed without annotations as plain hexdump. Example:
+
+	fw-tools hexdump -p 0x800 -s 0x40 --start 0x20000 -n 0x1000 dump.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		d := hexdump.New(cfg.Hexdump, cfg.Cut)
+		err := d.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+		err = d.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	geometryFlags(hexdumpCmd)
+	blockFlag(hexdumpCmd)
+	hexdumpCmd.Flags().Int64VarP(&cfg.Hexdump.Start, "start", "", 0, "Offset of start of dump")
+	hexdumpCmd.Flags().Int64VarP(&cfg.Hexdump.Length, "length", "n", 0, "Length of dump, to end of file by default")
+	hexdumpCmd.Flags().IntVarP(&cfg.Hexdump.Width, "width", "w", 16, "Bytes per line")
+	hexdumpCmd.Flags().IntVarP(&cfg.Hexdump.Word, "word", "", 1, "Size of printed values: 1, 2, 4 or 8 bytes")
+	hexdumpCmd.Flags().IntVarP(&cfg.Hexdump.SubPage, "subpage", "", 0, "Size of sub-page, sub-pages aren't marked by default")
+	hexdumpCmd.Flags().StringArrayVarP(&cfg.Hexdump.Swap, "swap", "", nil, "Swap values: bits, halfs, bytes, words or dwords, can be repeated")
+	hexdumpCmd.Flags().StringVarP(&cfg.Hexdump.Color, "color", "", "auto", "Colour mode: auto, always or never")
+	hexdumpCmd.Flags().BoolVarP(&cfg.Hexdump.Plain, "plain", "", false, "Plain dump without page boundaries")
+	rootCmd.AddCommand(hexdumpCmd)
+}
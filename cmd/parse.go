@@ -0,0 +1,69 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/parse"
+)
+
+// parseCmd represents the parse command
+var parseCmd = &cobra.Command{
+	Use:   "parse filename",
+	Short: "Decode headers by templates to JSON and build them back",
+	Long: `Decode struct at offset of file by YAML or JSON template and print it as JSON, with --build JSON document
+	is serialized back to bytes. Types of fields are u8, i8, u16, i16, u32, i32, u64, i64 with optional suffix le
+	or be, string, bytes and struct with own fields. Size, count and offset of field are sums of numbers, values of
+	previous fields and their offsets &name, offset is relative to struct. Field with value is checked by parse and
+	is set by build, if JSON doesn't have it. Example:
+
+	name: header
+	endian: be
+	fields:
+	  - {name: magic, type: string, size: 4, value: HDR0}
+	  - {name: count, type: u16}
+	  - {name: size, type: u32le}
+	  - {name: parts, type: struct, count: count, fields: [{name: offset, type: u32}, {name: length, type: u32}]}
+	  - {name: payload, type: bytes, size: size, offset: 0x40}
+
+	fw-tools parse -t header.yaml --offset 0x200 firmware.bin > header.json
+	fw-tools parse -t header.yaml --build -o header.bin header.json
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		p := parse.New(cfg.Parse)
+		err := p.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer p.Close()
+		err = p.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	parseCmd.Flags().StringVarP(&cfg.Parse.Template, "template", "t", "", "Template of struct in YAML or JSON")
+	parseCmd.Flags().Int64VarP(&cfg.Parse.Offset, "offset", "", 0, "Offset of struct in file")
+	parseCmd.Flags().BoolVarP(&cfg.Parse.Build, "build", "", false, "Serialize JSON document to bytes")
+	parseCmd.Flags().StringVarP(&cfg.Parse.Output, "output", "o", "", "Output file, JSON is printed by default, default for --build is <input>-built.bin")
+	rootCmd.AddCommand(parseCmd)
+}
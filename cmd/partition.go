@@ -0,0 +1,64 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/partition"
+)
+
+// partitionCmd represents the partition command
+var partitionCmd = &cobra.Command{
+	Use:   "partition filename",
+	Short: "Split image in partitions by mtdparts or partition table and join them back",
+	Long: `Split linear image of flash in named files by layout in mtdparts syntax, layout can be taken from bootargs
+	or U-Boot env as is. Files are named as <index>-<name>.bin. With --join files from directory are written back
+	at their offsets, gaps and tails of partitions are filled with 0xFF.
+	Images of eMMC and SD cards are split by MBR with logical partitions or GPT with --table, CRCs of primary
+	and backup GPT are checked and their differences are reported. Example:
+
+	fw-tools partition -m "mtdparts=spi0.0:256k(u-boot)ro,64k(env),-(firmware)" flash.bin
+	fw-tools partition --join -m "mtdparts=spi0.0:256k(u-boot)ro,64k(env),-(firmware)" flash-parts
+	fw-tools partition --table auto emmc.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		s := partition.New(cfg.Partition)
+		err := s.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer s.Close()
+		err = s.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	partitionCmd.Flags().StringVarP(&cfg.Partition.MTDParts, "mtdparts", "m", "", "Layout in mtdparts syntax")
+	partitionCmd.Flags().StringVarP(&cfg.Partition.Device, "device", "d", "", "Device of layout, if mtdparts has many")
+	partitionCmd.Flags().BoolVarP(&cfg.Partition.Join, "join", "j", false, "Join partitions from directory in image")
+	partitionCmd.Flags().StringVarP(&cfg.Partition.Output, "output", "o", "", "Output directory of partitions or joined image")
+	partitionCmd.Flags().StringVarP(&cfg.Partition.Table, "table", "t", "", "Partition table of image: mbr, gpt or auto")
+	partitionCmd.Flags().Int64VarP(&cfg.Partition.Sector, "sector", "", 0, "Size of sector, detected by GPT or 512 by default")
+	partitionCmd.MarkFlagsMutuallyExclusive("mtdparts", "table")
+	rootCmd.AddCommand(partitionCmd)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/patch"
+)
+
+// patchCmd represents the patch command
+var patchCmd = &cobra.Command{
+	Use:   "patch filename",
+	Short: "Apply byte patches and fix checksums of image",
+	Long: `Apply patch in IPS, BPS or text format to image, then compute checksums over ranges and write them
+	at offsets. Text patch has lines "<offset>: <hex bytes>" and "fixup <fixup>", # starts comment.
+	Fixup is <algorithm>:<start>-[<end>]@<offset>[:le|be], empty end is end of image, checksum is
+	little-endian by default. Algorithms are listed by "checksum --list", e.g. crc32, crc16-ccitt,
+	sum8, sum16, sum32 and sum8-2c, sum16-2c, sum32-2c (two's complement). Example:
+
+	fw-tools patch -p bootargs.patch --fixup crc32:0x0-0xfffc@0xfffc:be firmware.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		p := patch.New(cfg.Patch)
+		err := p.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer p.Close()
+		err = p.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	patchCmd.Flags().StringVarP(&cfg.Patch.Patches, "patch", "p", "", "File with patch in IPS, BPS or text format")
+	patchCmd.Flags().StringArrayVarP(&cfg.Patch.Fixups, "fixup", "f", nil, "Checksum fixup, applied after patch, can be repeated")
+	patchCmd.Flags().StringVarP(&cfg.Patch.Output, "output", "o", "", "Output of patched image")
+	rootCmd.AddCommand(patchCmd)
+}
@@ -0,0 +1,53 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/rootfs"
+)
+
+// rootfsCmd represents the rootfs command
+var rootfsCmd = &cobra.Command{
+	Use:   "rootfs filename",
+	Short: "Detect and extract SquashFS, CramFS and RomFS",
+	Long: `Look for superblocks of SquashFS (both endiannesses), CramFS and RomFS at any offset of linear image.
+	Every found filesystem is extracted in own directory named by offset and type.
+	Only gzip (zlib) compressor is supported, filesystems with xz, lzma, lzo and others are reported.
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := rootfs.New(cfg.RootFS)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	rootfsCmd.Flags().StringVarP(&cfg.RootFS.Output, "output", "o", "", "Directory for extracted filesystems, default is name of image with -rootfs suffix")
+	rootfsCmd.Flags().BoolVarP(&cfg.RootFS.DetectOnly, "detect", "", false, "Only print found superblocks")
+	rootCmd.AddCommand(rootfsCmd)
+}
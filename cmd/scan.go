@@ -0,0 +1,56 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/scan"
+)
+
+// scanCmd represents the scan command
+var scanCmd = &cobra.Command{
+	Use:   "scan filename",
+	Short: "Find known headers in dump",
+	Long: `Look for signatures of known headers: uImage, FIT/DTB, ELF, gzip, zlib, LZMA, xz, bzip2, LZ4, zstd, SquashFS, UBI, JFFS2,
+	CPIO, TAR, PEM/DER certificates and bootloaders. Offsets, sizes and decoded fields of headers are printed.
+	With --carve every hit is written in own file, hits with unknown size end on the next hit. Example:
+
+	0x00040000 uImage       size=0x1f4a40 name="Linux-4.14" load=0x80008000 entry=0x80008000 ...
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		s := scan.New(cfg.Scan)
+		err := s.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer s.Close()
+		err = s.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	scanCmd.Flags().BoolVarP(&cfg.Scan.JSON, "json", "", false, "Print hits as JSON")
+	scanCmd.Flags().BoolVarP(&cfg.Scan.Carve, "carve", "c", false, "Write every hit in own file")
+	scanCmd.Flags().StringVarP(&cfg.Scan.Output, "output", "o", "", "Directory for carved files, default is name of dump with -carved suffix")
+	rootCmd.AddCommand(scanCmd)
+}
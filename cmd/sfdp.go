@@ -0,0 +1,57 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/sfdp"
+)
+
+// sfdpCmd represents the sfdp command
+var sfdpCmd = &cobra.Command{
+	Use:   "sfdp filename",
+	Short: "Decode SFDP tables of SPI NOR flash",
+	Long: `Decode SFDP header and JEDEC basic flash parameter table of SPI NOR flash: density, erase types with
+	sizes and times, fast reads, page program and chip erase times, 4-byte addressing and quad enable
+	requirements. 4-byte address instruction table is decoded, vendor tables are printed as dwords.
+	Geometry with the largest erase type as block is suggested for other commands. Example:
+
+	fw-tools sfdp sfdp.bin
+	fw-tools sfdp --json sfdp.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		d := sfdp.New(cfg.SFDP)
+		err := d.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer d.Close()
+		err = d.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	sfdpCmd.Flags().Int64VarP(&cfg.SFDP.Offset, "offset", "", 0, "Offset of SFDP in file")
+	sfdpCmd.Flags().BoolVarP(&cfg.SFDP.JSON, "json", "", false, "Print tables as JSON")
+	rootCmd.AddCommand(sfdpCmd)
+}
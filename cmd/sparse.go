@@ -0,0 +1,59 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/sparse"
+)
+
+// sparseCmd represents the sparse command
+var sparseCmd = &cobra.Command{
+	Use:   "sparse filename",
+	Short: "Convert Android sparse image to raw and back",
+	Long: `Convert Android sparse image to raw image or raw image to sparse one, direction is chosen by magic of input.
+	Images are streamed, so multi-GB images like super.img aren't loaded in memory. Don't care and zero fill chunks
+	become holes of raw image, CRC32 chunks are verified. Raw blocks with repeated 4 bytes become fill chunks.
+	With --info chunks of sparse image are printed. Example:
+
+	fw-tools sparse super.img
+	fw-tools sparse --block 0x1000 -o system.simg system.img
+	fw-tools sparse --info super.img
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		c := sparse.New(cfg.Sparse)
+		err := c.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer c.Close()
+		err = c.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	sparseCmd.Flags().Uint32VarP(&cfg.Sparse.Block, "block", "", 0x1000, "Block size of created sparse image")
+	sparseCmd.Flags().BoolVarP(&cfg.Sparse.Info, "info", "", false, "Only print chunks of sparse image")
+	sparseCmd.Flags().StringVarP(&cfg.Sparse.Output, "output", "o", "", "Output image, default is name of input with -raw or -sparse suffix")
+	rootCmd.AddCommand(sparseCmd)
+}
@@ -0,0 +1,60 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/strings"
+)
+
+// stringsCmd represents the strings command
+var stringsCmd = &cobra.Command{
+	Use:   "strings filename [filename2]...",
+	Short: "Find readable strings in dump",
+	Long: `Find ASCII, UTF-8, UTF-16LE and UTF-16BE strings with minimal length and print their offsets,
+	with --pages offsets are printed as page and offset in data or spare area of page.
+	With --score every swap of dump or every merge and swap of several dumps is scored by yield
+	of readable strings, so the best transformation is on top. Example:
+
+	fw-tools strings -e ascii -e utf16le -n 6 firmware.bin
+	fw-tools strings --score chip0.bin chip1.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		f := strings.New(cfg.Strings, cfg.Cut)
+		err := f.Open(cfg.Inputs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		err = f.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	geometryFlags(stringsCmd)
+	stringsCmd.Flags().IntVarP(&cfg.Strings.Min, "min", "n", 4, "Minimal length of string in characters")
+	stringsCmd.Flags().StringArrayVarP(&cfg.Strings.Encodings, "encoding", "e", nil, "Encoding: ascii, utf8, utf16le or utf16be, ascii by default, can be repeated")
+	stringsCmd.Flags().BoolVarP(&cfg.Strings.Pages, "pages", "", false, "Print offsets in pages")
+	stringsCmd.Flags().BoolVarP(&cfg.Strings.Score, "score", "", false, "Score swaps and merges by yield of strings")
+	rootCmd.AddCommand(stringsCmd)
+}
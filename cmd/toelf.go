@@ -0,0 +1,63 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/toelf"
+)
+
+// toelfCmd represents the toelf command
+var toelfCmd = &cobra.Command{
+	Use:   "toelf filename",
+	Short: "Wrap raw firmware into ELF for disassemblers",
+	Long: `Wrap regions of raw firmware into ELF with machine type, byte order, load addresses and entry point,
+	so it can be loaded into Ghidra or IDA without manual setup. Region is <address>[:<start>-[<end>]][:<flags>],
+	empty end is end of file, flags are r, w and x. Without regions whole file is loaded at --base.
+	Symbols are imported from CSV with lines <name>,<address>[,<size>[,func|object]]. Example:
+
+	fw-tools toelf -m arm --region 0x08000000:0x0-0x10000:rx --region 0x20000000:0x10000-:rw --symbols syms.csv firmware.bin
+	fw-tools toelf -m mips --big-endian --base 0x80000000 u-boot.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		b := toelf.New(cfg.ToELF)
+		err := b.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer b.Close()
+		err = b.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	toelfCmd.Flags().StringVarP(&cfg.ToELF.Machine, "machine", "m", "arm", "Machine: arm, aarch64, mips, x86, x86_64, riscv, ppc, xtensa and others or number")
+	toelfCmd.Flags().IntVarP(&cfg.ToELF.Class, "class", "c", 0, "Class of ELF: 32 or 64, by machine by default")
+	toelfCmd.Flags().BoolVarP(&cfg.ToELF.BigEndian, "big-endian", "", false, "Big-endian ELF")
+	toelfCmd.Flags().StringArrayVarP(&cfg.ToELF.Regions, "region", "r", nil, "Loaded region of file, can be repeated")
+	toelfCmd.Flags().Int64VarP(&cfg.ToELF.Base, "base", "b", 0, "Load address of whole file without regions")
+	toelfCmd.Flags().Int64VarP(&cfg.ToELF.Entry, "entry", "e", -1, "Entry point, address of the first region by default")
+	toelfCmd.Flags().StringVarP(&cfg.ToELF.Symbols, "symbols", "", "", "CSV with symbols")
+	toelfCmd.Flags().StringVarP(&cfg.ToELF.Output, "output", "o", "", "Output ELF")
+	rootCmd.AddCommand(toelfCmd)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/uimage"
+)
+
+// uimageCmd represents the uimage command
+var uimageCmd = &cobra.Command{
+	Use:   "uimage filename [filename2]...",
+	Short: "Decode, verify, extract and create U-Boot legacy uImage",
+	Long: `Print header of uImage and verify CRC32 of header and data. With --extract payload is written near image,
+	every image of multi-file uImage is written in own file. With --create new uImage is built around payloads,
+	more than one payload makes multi-file image. Example:
+
+	fw-tools uimage --create -n Linux --os linux --arch arm --type kernel --load 0x80008000 --entry 0x80008000 -o uImage zImage
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		t := uimage.New(cfg.UImage)
+		err := t.Open(cfg.Inputs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer t.Close()
+		err = t.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	e := "extract"
+	c := "create"
+	uimageCmd.Flags().BoolVarP(&cfg.UImage.Extract, e, "x", false, "Extract payload of image")
+	uimageCmd.Flags().BoolVarP(&cfg.UImage.Create, c, "c", false, "Create image around payloads")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.Output, "output", "o", "", "Output of created image or prefix of extracted payloads")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.Name, "name", "n", "", "Name of created image")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.OS, "os", "", "linux", "Operating system of created image")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.Arch, "arch", "", "arm", "Architecture of created image")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.Type, "type", "", "kernel", "Type of created image")
+	uimageCmd.Flags().StringVarP(&cfg.UImage.Comp, "comp", "", "none", "Compression of payload of created image")
+	uimageCmd.Flags().Uint32VarP(&cfg.UImage.Load, "load", "", 0, "Load address of created image")
+	uimageCmd.Flags().Uint32VarP(&cfg.UImage.Entry, "entry", "", 0, "Entry point of created image")
+	uimageCmd.MarkFlagsMutuallyExclusive(e, c)
+	rootCmd.AddCommand(uimageCmd)
+}
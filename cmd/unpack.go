@@ -0,0 +1,61 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/unpack"
+)
+
+// unpackCmd represents the unpack command
+var unpackCmd = &cobra.Command{
+	Use:   "unpack filename",
+	Short: "Find and decompress raw compressed streams",
+	Long: `Find gzip, zlib, bzip2 and LZ4 streams by signatures of scan command and decompress them,
+	number of consumed and produced bytes is printed for every stream, valid streams are written in
+	own files. LZMA, xz and zstd streams are reported as unsupported. With --every decoders and raw deflate
+	are tried at every offset with step, streams smaller than --min-size are dropped. Example:
+
+	fw-tools unpack firmware.bin
+	fw-tools unpack --every --format deflate --min-size 0x100 firmware.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		u := unpack.New(cfg.Unpack)
+		err := u.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer u.Close()
+		err = u.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	unpackCmd.Flags().StringArrayVarP(&cfg.Unpack.Formats, "format", "f", nil, "Format: gzip, zlib, bzip2, LZ4 or deflate, all by default, can be repeated")
+	unpackCmd.Flags().BoolVarP(&cfg.Unpack.Every, "every", "", false, "Try decoders at every offset instead of signatures")
+	unpackCmd.Flags().IntVarP(&cfg.Unpack.Step, "step", "", 1, "Step of offsets for --every")
+	unpackCmd.Flags().Int64VarP(&cfg.Unpack.MinSize, "min-size", "", 0x40, "Minimal size of decompressed stream for --every")
+	unpackCmd.Flags().BoolVarP(&cfg.Unpack.List, "list", "l", false, "Only print streams")
+	unpackCmd.Flags().StringVarP(&cfg.Unpack.Output, "output", "o", "", "Directory for decompressed streams")
+	rootCmd.AddCommand(unpackCmd)
+}
@@ -0,0 +1,58 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Nexadis/fw-tools/internal/yaffs2"
+)
+
+// yaffs2Cmd represents the yaffs2 command
+var yaffs2Cmd = &cobra.Command{
+	Use:   "yaffs2 filename",
+	Short: "Extract files from YAFFS2 dump with spare areas",
+	Long: `Read pages together with spare areas and rebuild files and directories of YAFFS2 by tags in spare.
+	Page geometry is the same as for cut command: page is a data size of chunk, skip is a spare size.
+	When chunk has some copies, copy with the highest sequence number is used. Example:
+
+	fw-tools yaffs2 -p 0x800 -s 0x40 --tags-offset 2 -o rootfs nand.bin
+	`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("set filename")
+		}
+		cfg.Inputs = append(cfg.Inputs, args...)
+		return nil
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+		e := yaffs2.New(cfg.Yaffs2, cfg.Cut)
+		err := e.Open(cfg.Inputs[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer e.Close()
+		err = e.Run(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	geometryFlags(yaffs2Cmd)
+	yaffs2Cmd.Flags().StringVarP(&cfg.Yaffs2.Output, "output", "o", "", "Directory for extracted files, default is name of dump with -yaffs2 suffix")
+	yaffs2Cmd.Flags().IntVarP(&cfg.Yaffs2.TagsOffset, "tags-offset", "", 2, "Offset of packed tags in spare area")
+	yaffs2Cmd.Flags().BoolVarP(&cfg.Yaffs2.TagsECC, "tags-ecc", "", false, "Tags are followed by 12 bytes of ECC in spare area, ECC isn't checked")
+	yaffs2Cmd.Flags().BoolVarP(&cfg.Yaffs2.BigEndian, "big-endian", "", false, "Tags and headers are written by big-endian host")
+	rootCmd.AddCommand(yaffs2Cmd)
+}
@@ -0,0 +1,382 @@
+package bootimg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrMagic = errors.New("invalid magic of boot image")
+var ErrVersion = errors.New("unsupported version of boot image")
+var ErrSize = errors.New("invalid size of boot image")
+
+const (
+	BootMagic   = "ANDROID!"
+	VendorMagic = "VNDRBOOT"
+
+	headerSize = 2128
+	// page size of boot image v3 and v4
+	bootPage = 4096
+
+	ramdiskEntrySize = 108
+	ramdiskNameSize  = 32
+)
+
+var RamdiskTypes = []string{"none", "platform", "recovery", "dlkm"}
+
+// Section is a part of image, offset is from beginning of image.
+type Section struct {
+	Name   string
+	Offset int64
+	Size   int64
+}
+
+func (s Section) String() string {
+	return fmt.Sprintf("%-24s 0x%08x 0x%08x", s.Name, s.Offset, s.Size)
+}
+
+// Image is decoded header of boot or vendor_boot image.
+type Image struct {
+	Vendor      bool
+	Version     uint32
+	PageSize    uint32
+	KernelAddr  uint32
+	RamdiskAddr uint32
+	SecondAddr  uint32
+	TagsAddr    uint32
+	DTBAddr     uint64
+	OSVersion   uint32
+	Name        string
+	Cmdline     string
+	Sections    []Section
+}
+
+// OS returns version of Android and security patch level packed in os_version.
+func (img *Image) OS() (version, patch string) {
+	if img.OSVersion == 0 {
+		return "", ""
+	}
+	v := img.OSVersion >> 11
+	p := img.OSVersion & 0x7ff
+	version = fmt.Sprintf("%d.%d.%d", v>>14, v>>7&0x7f, v&0x7f)
+	patch = fmt.Sprintf("%d-%02d", 2000+p>>4, p&0xf)
+	return version, patch
+}
+
+func (img *Image) String() string {
+	b := &strings.Builder{}
+	kind := "boot"
+	if img.Vendor {
+		kind = "vendor_boot"
+	}
+	fmt.Fprintf(b, "%s image v%d, page 0x%x\n", kind, img.Version, img.PageSize)
+	if img.KernelAddr != 0 || img.RamdiskAddr != 0 || img.TagsAddr != 0 {
+		fmt.Fprintf(b, "kernel 0x%08x, ramdisk 0x%08x, second 0x%08x, tags 0x%08x, dtb 0x%08x\n",
+			img.KernelAddr, img.RamdiskAddr, img.SecondAddr, img.TagsAddr, img.DTBAddr)
+	}
+	if v, p := img.OS(); v != "" {
+		fmt.Fprintf(b, "os version %s, patch level %s\n", v, p)
+	}
+	if img.Name != "" {
+		fmt.Fprintf(b, "name %q\n", img.Name)
+	}
+	fmt.Fprintf(b, "cmdline %q", img.Cmdline)
+	for _, s := range img.Sections {
+		fmt.Fprintf(b, "\n%s", s)
+	}
+	return b.String()
+}
+
+func cstring(b []byte) string {
+	s, _, _ := bytes.Cut(b, []byte{0})
+	return string(s)
+}
+
+func pages(size, page uint32) int64 {
+	return (int64(size) + int64(page) - 1) / int64(page) * int64(page)
+}
+
+// Parse decodes header of image and finds its sections, size is size of image.
+func Parse(r io.ReaderAt, size int64) (*Image, error) {
+	h := make([]byte, headerSize)
+	n, err := r.ReadAt(h, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n < 48 {
+		return nil, ErrSize
+	}
+	h = h[:n]
+	var img *Image
+	switch string(h[:8]) {
+	case BootMagic:
+		img, err = parseBoot(h)
+	case VendorMagic:
+		img, err = parseVendor(h, r)
+	default:
+		return nil, ErrMagic
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range img.Sections {
+		if s.Offset+s.Size > size {
+			return img, fmt.Errorf("%w: %s is out of image", ErrSize, s.Name)
+		}
+	}
+	return img, nil
+}
+
+func field(h []byte, end int) error {
+	if len(h) < end {
+		return fmt.Errorf("%w: header is truncated", ErrSize)
+	}
+	return nil
+}
+
+func parseBoot(h []byte) (*Image, error) {
+	le := binary.LittleEndian
+	img := &Image{Version: le.Uint32(h[40:])}
+	var sizes []uint32
+	var names []string
+	// v0 of Qualcomm has size of device tree instead of version
+	var dt uint32
+	if img.Version > 4 {
+		dt, img.Version = img.Version, 0
+	}
+	if img.Version >= 3 {
+		end := 1580
+		if img.Version == 4 {
+			end = 1584
+		}
+		if err := field(h, end); err != nil {
+			return nil, err
+		}
+		img.PageSize = bootPage
+		img.OSVersion = le.Uint32(h[16:])
+		img.Cmdline = cstring(h[44:1580])
+		names = []string{"kernel", "ramdisk"}
+		sizes = []uint32{le.Uint32(h[8:]), le.Uint32(h[12:])}
+		if img.Version == 4 {
+			names = append(names, "boot_signature")
+			sizes = append(sizes, le.Uint32(h[1580:]))
+		}
+	} else {
+		end := 1632
+		switch img.Version {
+		case 1:
+			end = 1648
+		case 2:
+			end = 1660
+		}
+		if err := field(h, end); err != nil {
+			return nil, err
+		}
+		img.KernelAddr = le.Uint32(h[12:])
+		img.RamdiskAddr = le.Uint32(h[20:])
+		img.SecondAddr = le.Uint32(h[28:])
+		img.TagsAddr = le.Uint32(h[32:])
+		img.PageSize = le.Uint32(h[36:])
+		img.OSVersion = le.Uint32(h[44:])
+		img.Name = cstring(h[48:64])
+		img.Cmdline = cstring(h[64:576]) + cstring(h[608:1632])
+		names = []string{"kernel", "ramdisk", "second"}
+		sizes = []uint32{le.Uint32(h[8:]), le.Uint32(h[16:]), le.Uint32(h[24:])}
+		if dt != 0 {
+			names = append(names, "dt")
+			sizes = append(sizes, dt)
+		}
+		if img.Version >= 1 {
+			names = append(names, "recovery_dtbo")
+			sizes = append(sizes, le.Uint32(h[1632:]))
+		}
+		if img.Version == 2 {
+			names = append(names, "dtb")
+			sizes = append(sizes, le.Uint32(h[1648:]))
+			img.DTBAddr = le.Uint64(h[1652:])
+		}
+	}
+	if img.PageSize == 0 || img.PageSize&(img.PageSize-1) != 0 {
+		return nil, fmt.Errorf("%w: page size 0x%x", ErrSize, img.PageSize)
+	}
+	// every section starts at page after header and previous sections
+	offset := int64(img.PageSize)
+	for i, name := range names {
+		if sizes[i] != 0 {
+			img.Sections = append(img.Sections, Section{name, offset, int64(sizes[i])})
+		}
+		offset += pages(sizes[i], img.PageSize)
+	}
+	return img, nil
+}
+
+func parseVendor(h []byte, r io.ReaderAt) (*Image, error) {
+	le := binary.LittleEndian
+	img := &Image{Vendor: true, Version: le.Uint32(h[8:])}
+	if img.Version < 3 || img.Version > 4 {
+		return nil, fmt.Errorf("%w: vendor_boot %d", ErrVersion, img.Version)
+	}
+	end := 2112
+	if img.Version == 4 {
+		end = 2128
+	}
+	if err := field(h, end); err != nil {
+		return nil, err
+	}
+	img.PageSize = le.Uint32(h[12:])
+	img.KernelAddr = le.Uint32(h[16:])
+	img.RamdiskAddr = le.Uint32(h[20:])
+	img.Cmdline = cstring(h[28:2076])
+	img.TagsAddr = le.Uint32(h[2076:])
+	img.Name = cstring(h[2080:2096])
+	img.DTBAddr = le.Uint64(h[2104:])
+	if img.PageSize == 0 || img.PageSize&(img.PageSize-1) != 0 {
+		return nil, fmt.Errorf("%w: page size 0x%x", ErrSize, img.PageSize)
+	}
+	ramdiskSize := le.Uint32(h[24:])
+	names := []string{"vendor_ramdisk", "dtb"}
+	sizes := []uint32{ramdiskSize, le.Uint32(h[2100:])}
+	if img.Version == 4 {
+		names = append(names, "vendor_ramdisk_table", "bootconfig")
+		sizes = append(sizes, le.Uint32(h[2112:]), le.Uint32(h[2124:]))
+	}
+	offset := pages(le.Uint32(h[2096:]), img.PageSize)
+	ramdisk := offset
+	var table Section
+	for i, name := range names {
+		s := Section{name, offset, int64(sizes[i])}
+		if name == "vendor_ramdisk_table" {
+			table = s
+		}
+		if sizes[i] != 0 {
+			img.Sections = append(img.Sections, s)
+		}
+		offset += pages(sizes[i], img.PageSize)
+	}
+	if table.Size == 0 {
+		return img, nil
+	}
+	// fragments of vendor ramdisk are described by table of v4
+	count := le.Uint32(h[2116:])
+	entrySize := le.Uint32(h[2120:])
+	if entrySize < ramdiskEntrySize || int64(count)*int64(entrySize) > table.Size {
+		return nil, fmt.Errorf("%w: vendor ramdisk table", ErrSize)
+	}
+	entry := make([]byte, ramdiskEntrySize)
+	for i := uint32(0); i < count; i++ {
+		if _, err := r.ReadAt(entry, table.Offset+int64(i)*int64(entrySize)); err != nil {
+			return nil, fmt.Errorf("%w: vendor ramdisk table: %w", ErrSize, err)
+		}
+		size, off := le.Uint32(entry), le.Uint32(entry[4:])
+		if uint64(off)+uint64(size) > uint64(ramdiskSize) {
+			return nil, fmt.Errorf("%w: vendor ramdisk %d is out of vendor ramdisk", ErrSize, i)
+		}
+		name := cstring(entry[12 : 12+ramdiskNameSize])
+		// name is a part of file name, it can't escape from output directory
+		if name == "" || strings.ContainsAny(name, "/\\\x00") || strings.Contains(name, "..") {
+			name = fmt.Sprint(i)
+		}
+		typ := fmt.Sprint(le.Uint32(entry[8:]))
+		if t := le.Uint32(entry[8:]); int(t) < len(RamdiskTypes) {
+			typ = RamdiskTypes[t]
+		}
+		img.Sections = append(img.Sections, Section{
+			Name:   fmt.Sprintf("vendor_ramdisk-%s-%s", typ, name),
+			Offset: ramdisk + int64(off),
+			Size:   int64(size),
+		})
+	}
+	return img, nil
+}
+
+type Tool struct {
+	r      *io.SectionReader
+	closer io.Closer
+	out    io.Writer
+	Config config.BootImg
+}
+
+func New(cfg config.BootImg) *Tool {
+	return &Tool{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (t *Tool) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for unpacking: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for unpacking: %w", input, err)
+	}
+	t.closer = in
+	if t.Config.Offset < 0 || t.Config.Offset >= stat.Size() {
+		return fmt.Errorf("offset 0x%x is out of file", t.Config.Offset)
+	}
+	t.r = io.NewSectionReader(in, t.Config.Offset, stat.Size()-t.Config.Offset)
+	if t.Config.Output == "" {
+		name := filepath.Base(input)
+		t.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-bootimg"
+	}
+	return nil
+}
+
+func (t *Tool) Close() error {
+	if t.closer == nil {
+		return nil
+	}
+	return t.closer.Close()
+}
+
+// Run prints header of image, with --extract sections and cmdline are written
+// in output directory.
+func (t *Tool) Run(ctx context.Context) error {
+	img, err := Parse(t.r, t.r.Size())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(t.out, img)
+	if !t.Config.Extract {
+		return nil
+	}
+	if err := os.MkdirAll(t.Config.Output, 0755); err != nil {
+		return err
+	}
+	if img.Cmdline != "" {
+		if err := os.WriteFile(filepath.Join(t.Config.Output, "cmdline"), []byte(img.Cmdline+"\n"), 0666); err != nil {
+			return err
+		}
+	}
+	for _, s := range img.Sections {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := t.extract(s); err != nil {
+			return fmt.Errorf("%s: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+func (t *Tool) extract(s Section) error {
+	f, err := os.OpenFile(filepath.Join(t.Config.Output, s.Name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, io.NewSectionReader(t.r, s.Offset, s.Size))
+	return errors.Join(err, f.Close())
+}
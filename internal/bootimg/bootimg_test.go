@@ -0,0 +1,232 @@
+package bootimg
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+// Android 11, patch level 2021-05
+const osVersion = 11<<25 | (21<<4 | 5)
+
+func pad(b []byte, page int) []byte {
+	return append(b, make([]byte, (page-len(b)%page)%page)...)
+}
+
+// bootV2 is built like mkbootimg with page 2048
+func bootV2(kernel, ramdisk, dtb []byte) []byte {
+	le := binary.LittleEndian
+	h := make([]byte, 1660)
+	copy(h, BootMagic)
+	le.PutUint32(h[8:], uint32(len(kernel)))
+	le.PutUint32(h[12:], 0x10008000)
+	le.PutUint32(h[16:], uint32(len(ramdisk)))
+	le.PutUint32(h[20:], 0x11000000)
+	le.PutUint32(h[28:], 0x10f00000)
+	le.PutUint32(h[32:], 0x10000100)
+	le.PutUint32(h[36:], 2048)
+	le.PutUint32(h[40:], 2)
+	le.PutUint32(h[44:], osVersion)
+	copy(h[48:], "test")
+	copy(h[64:], "console=ttyMSM0,115200n8")
+	le.PutUint32(h[1644:], 1660)
+	le.PutUint32(h[1648:], uint32(len(dtb)))
+	le.PutUint64(h[1652:], 0x11f00000)
+	img := pad(h, 2048)
+	for _, s := range [][]byte{kernel, ramdisk, dtb} {
+		img = append(img, pad(s, 2048)...)
+	}
+	return img
+}
+
+func bootV4(kernel, ramdisk, signature []byte) []byte {
+	le := binary.LittleEndian
+	h := make([]byte, 1584)
+	copy(h, BootMagic)
+	le.PutUint32(h[8:], uint32(len(kernel)))
+	le.PutUint32(h[12:], uint32(len(ramdisk)))
+	le.PutUint32(h[16:], osVersion)
+	le.PutUint32(h[20:], 1584)
+	le.PutUint32(h[40:], 4)
+	copy(h[44:], "androidboot.hardware=test")
+	le.PutUint32(h[1580:], uint32(len(signature)))
+	img := pad(h, 4096)
+	for _, s := range [][]byte{kernel, ramdisk, signature} {
+		img = append(img, pad(s, 4096)...)
+	}
+	return img
+}
+
+func vendorV4(page int, ramdisks [][]byte, names []string, dtb, bootconfig []byte) []byte {
+	le := binary.LittleEndian
+	h := make([]byte, 2128)
+	copy(h, VendorMagic)
+	le.PutUint32(h[8:], 4)
+	le.PutUint32(h[12:], uint32(page))
+	le.PutUint32(h[16:], 0x80008000)
+	le.PutUint32(h[20:], 0x81000000)
+	copy(h[28:], "androidboot.console=ttyS0")
+	le.PutUint32(h[2076:], 0x80000100)
+	copy(h[2080:], "vendor")
+	le.PutUint32(h[2096:], 2128)
+	le.PutUint32(h[2100:], uint32(len(dtb)))
+	var ramdisk, table []byte
+	for i, r := range ramdisks {
+		e := make([]byte, ramdiskEntrySize)
+		le.PutUint32(e, uint32(len(r)))
+		le.PutUint32(e[4:], uint32(len(ramdisk)))
+		le.PutUint32(e[8:], uint32(i+1))
+		copy(e[12:], names[i])
+		table = append(table, e...)
+		ramdisk = append(ramdisk, r...)
+	}
+	le.PutUint32(h[24:], uint32(len(ramdisk)))
+	le.PutUint32(h[2112:], uint32(len(table)))
+	le.PutUint32(h[2116:], uint32(len(ramdisks)))
+	le.PutUint32(h[2120:], ramdiskEntrySize)
+	le.PutUint32(h[2124:], uint32(len(bootconfig)))
+	img := pad(h, page)
+	for _, s := range [][]byte{ramdisk, dtb, table, bootconfig} {
+		img = append(img, pad(s, page)...)
+	}
+	return img
+}
+
+func TestParse(t *testing.T) {
+	kernel := bytes.Repeat([]byte{'k'}, 5000)
+	ramdisk := bytes.Repeat([]byte{'r'}, 3000)
+	tests := []struct {
+		name string
+		img  []byte
+		want *Image
+		err  error
+	}{
+		{
+			name: "v2",
+			img:  bootV2(kernel, ramdisk, []byte{0xd0, 0x0d, 0xfe, 0xed}),
+			want: &Image{
+				Version: 2, PageSize: 2048,
+				KernelAddr: 0x10008000, RamdiskAddr: 0x11000000, SecondAddr: 0x10f00000,
+				TagsAddr: 0x10000100, DTBAddr: 0x11f00000, OSVersion: osVersion,
+				Name: "test", Cmdline: "console=ttyMSM0,115200n8",
+				Sections: []Section{
+					{"kernel", 0x800, 5000},
+					{"ramdisk", 0x2000, 3000},
+					{"dtb", 0x3000, 4},
+				},
+			},
+		},
+		{
+			name: "v4",
+			img:  bootV4(kernel, ramdisk, []byte("AVB0")),
+			want: &Image{
+				Version: 4, PageSize: 4096, OSVersion: osVersion,
+				Cmdline: "androidboot.hardware=test",
+				Sections: []Section{
+					{"kernel", 0x1000, 5000},
+					{"ramdisk", 0x3000, 3000},
+					{"boot_signature", 0x4000, 4},
+				},
+			},
+		},
+		{
+			name: "vendor v4",
+			img:  vendorV4(2048, [][]byte{ramdisk, []byte("recovery")}, []string{"", "recovery"}, []byte("dtb"), []byte("a=b\n")),
+			want: &Image{
+				Vendor: true, Version: 4, PageSize: 2048,
+				KernelAddr: 0x80008000, RamdiskAddr: 0x81000000, TagsAddr: 0x80000100,
+				Name: "vendor", Cmdline: "androidboot.console=ttyS0",
+				Sections: []Section{
+					{"vendor_ramdisk", 0x1000, 3008},
+					{"dtb", 0x2000, 3},
+					{"vendor_ramdisk_table", 0x2800, 216},
+					{"bootconfig", 0x3000, 4},
+					{"vendor_ramdisk-platform-0", 0x1000, 3000},
+					{"vendor_ramdisk-recovery-recovery", 0x1000 + 3000, 8},
+				},
+			},
+		},
+		{
+			name: "Unsafe names of vendor ramdisks",
+			img:  vendorV4(2048, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, []string{"/../../../x", "..", `a\b`}, nil, nil),
+			want: &Image{
+				Vendor: true, Version: 4, PageSize: 2048,
+				KernelAddr: 0x80008000, RamdiskAddr: 0x81000000, TagsAddr: 0x80000100,
+				Name: "vendor", Cmdline: "androidboot.console=ttyS0",
+				Sections: []Section{
+					{"vendor_ramdisk", 0x1000, 3},
+					{"vendor_ramdisk_table", 0x1800, 324},
+					{"vendor_ramdisk-platform-0", 0x1000, 1},
+					{"vendor_ramdisk-recovery-1", 0x1001, 1},
+					{"vendor_ramdisk-dlkm-2", 0x1002, 1},
+				},
+			},
+		},
+		{
+			name: "Truncated",
+			img:  bootV2(kernel, ramdisk, nil)[:0x2000],
+			err:  ErrSize,
+		},
+		{
+			name: "Magic",
+			img:  make([]byte, 0x1000),
+			err:  ErrMagic,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Parse(bytes.NewReader(tt.img), int64(len(tt.img)))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, img)
+		})
+	}
+}
+
+func TestOS(t *testing.T) {
+	img := &Image{OSVersion: osVersion}
+	version, patch := img.OS()
+	require.Equal(t, "11.0.0", version)
+	require.Equal(t, "2021-05", patch)
+}
+
+func TestTool(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "boot.img")
+	kernel := bytes.Repeat([]byte{'k'}, 5000)
+	require.NoError(t, os.WriteFile(input, append(make([]byte, 0x100), bootV4(kernel, []byte("ramdisk"), nil)...), 0644))
+
+	tool := New(config.BootImg{Offset: 0x100, Extract: true})
+	out := &bytes.Buffer{}
+	tool.out = out
+	require.NoError(t, tool.Open(input))
+	defer tool.Close()
+	tool.Config.Output = filepath.Join(dir, "out")
+	require.NoError(t, tool.Run(context.Background()))
+	require.Equal(t, `boot image v4, page 0x1000
+os version 11.0.0, patch level 2021-05
+cmdline "androidboot.hardware=test"
+kernel                   0x00001000 0x00001388
+ramdisk                  0x00003000 0x00000007
+`, out.String())
+
+	got, err := os.ReadFile(filepath.Join(tool.Config.Output, "kernel"))
+	require.NoError(t, err)
+	require.Equal(t, kernel, got)
+	got, err = os.ReadFile(filepath.Join(tool.Config.Output, "ramdisk"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("ramdisk"), got)
+	got, err = os.ReadFile(filepath.Join(tool.Config.Output, "cmdline"))
+	require.NoError(t, err)
+	require.Equal(t, "androidboot.hardware=test\n", string(got))
+}
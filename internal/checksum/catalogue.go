@@ -0,0 +1,104 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"hash/adler32"
+)
+
+func crc(name string, c CRC) Algorithm {
+	m := NewCRC(c)
+	return Algorithm{Name: name, New: m.New, CRC: m}
+}
+
+func digest(name string, h func() hash.Hash) Algorithm {
+	return Algorithm{Name: name, Digest: true, New: h}
+}
+
+const ones = ^uint64(0)
+
+// Algorithms is a catalogue, CRC models are named as in reveng catalogue.
+var Algorithms = []Algorithm{
+	crc("crc-8/smbus", CRC{Width: 8, Poly: 0x07, Check: 0xf4}),
+	crc("crc-8/autosar", CRC{Width: 8, Poly: 0x2f, Init: 0xff, XorOut: 0xff, Check: 0xdf}),
+	crc("crc-8/bluetooth", CRC{Width: 8, Poly: 0xa7, RefIn: true, RefOut: true, Check: 0x26}),
+	crc("crc-8/cdma2000", CRC{Width: 8, Poly: 0x9b, Init: 0xff, Check: 0xda}),
+	crc("crc-8/dvb-s2", CRC{Width: 8, Poly: 0xd5, Check: 0xbc}),
+	crc("crc-8/i-432-1", CRC{Width: 8, Poly: 0x07, XorOut: 0x55, Check: 0xa1}),
+	crc("crc-8/maxim-dow", CRC{Width: 8, Poly: 0x31, RefIn: true, RefOut: true, Check: 0xa1}),
+	crc("crc-8/nrsc-5", CRC{Width: 8, Poly: 0x31, Init: 0xff, Check: 0xf7}),
+	crc("crc-8/rohc", CRC{Width: 8, Poly: 0x07, Init: 0xff, RefIn: true, RefOut: true, Check: 0xd0}),
+	crc("crc-8/sae-j1850", CRC{Width: 8, Poly: 0x1d, Init: 0xff, XorOut: 0xff, Check: 0x4b}),
+	crc("crc-8/wcdma", CRC{Width: 8, Poly: 0x9b, RefIn: true, RefOut: true, Check: 0x25}),
+
+	crc("crc-16/arc", CRC{Width: 16, Poly: 0x8005, RefIn: true, RefOut: true, Check: 0xbb3d}),
+	crc("crc-16/cdma2000", CRC{Width: 16, Poly: 0xc867, Init: 0xffff, Check: 0x4c06}),
+	crc("crc-16/dds-110", CRC{Width: 16, Poly: 0x8005, Init: 0x800d, Check: 0x9ecf}),
+	crc("crc-16/dect-x", CRC{Width: 16, Poly: 0x0589, Check: 0x007f}),
+	crc("crc-16/dnp", CRC{Width: 16, Poly: 0x3d65, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0xea82}),
+	crc("crc-16/en-13757", CRC{Width: 16, Poly: 0x3d65, XorOut: 0xffff, Check: 0xc2b7}),
+	crc("crc-16/genibus", CRC{Width: 16, Poly: 0x1021, Init: 0xffff, XorOut: 0xffff, Check: 0xd64e}),
+	crc("crc-16/gsm", CRC{Width: 16, Poly: 0x1021, XorOut: 0xffff, Check: 0xce3c}),
+	crc("crc-16/ibm-3740", CRC{Width: 16, Poly: 0x1021, Init: 0xffff, Check: 0x29b1}),
+	crc("crc-16/ibm-sdlc", CRC{Width: 16, Poly: 0x1021, Init: 0xffff, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0x906e}),
+	crc("crc-16/kermit", CRC{Width: 16, Poly: 0x1021, RefIn: true, RefOut: true, Check: 0x2189}),
+	crc("crc-16/maxim-dow", CRC{Width: 16, Poly: 0x8005, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0x44c2}),
+	crc("crc-16/mcrf4xx", CRC{Width: 16, Poly: 0x1021, Init: 0xffff, RefIn: true, RefOut: true, Check: 0x6f91}),
+	crc("crc-16/modbus", CRC{Width: 16, Poly: 0x8005, Init: 0xffff, RefIn: true, RefOut: true, Check: 0x4b37}),
+	crc("crc-16/spi-fujitsu", CRC{Width: 16, Poly: 0x1021, Init: 0x1d0f, Check: 0xe5cc}),
+	crc("crc-16/t10-dif", CRC{Width: 16, Poly: 0x8bb7, Check: 0xd0db}),
+	crc("crc-16/umts", CRC{Width: 16, Poly: 0x8005, Check: 0xfee8}),
+	crc("crc-16/usb", CRC{Width: 16, Poly: 0x8005, Init: 0xffff, RefIn: true, RefOut: true, XorOut: 0xffff, Check: 0xb4c8}),
+	crc("crc-16/xmodem", CRC{Width: 16, Poly: 0x1021, Check: 0x31c3}),
+
+	crc("crc-24/openpgp", CRC{Width: 24, Poly: 0x864cfb, Init: 0xb704ce, Check: 0x21cf02}),
+
+	crc("crc-32/iso-hdlc", CRC{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0xcbf43926}),
+	crc("crc-32/aixm", CRC{Width: 32, Poly: 0x814141ab, Check: 0x3010bf7f}),
+	crc("crc-32/autosar", CRC{Width: 32, Poly: 0xf4acfb13, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0x1697d06a}),
+	crc("crc-32/base91-d", CRC{Width: 32, Poly: 0xa833982b, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0x87315576}),
+	crc("crc-32/bzip2", CRC{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, XorOut: 0xffffffff, Check: 0xfc891918}),
+	crc("crc-32/cksum", CRC{Width: 32, Poly: 0x04c11db7, XorOut: 0xffffffff, Check: 0x765e7680}),
+	crc("crc-32/iscsi", CRC{Width: 32, Poly: 0x1edc6f41, Init: 0xffffffff, RefIn: true, RefOut: true, XorOut: 0xffffffff, Check: 0xe3069283}),
+	crc("crc-32/jamcrc", CRC{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, RefIn: true, RefOut: true, Check: 0x340bc6d9}),
+	crc("crc-32/mpeg-2", CRC{Width: 32, Poly: 0x04c11db7, Init: 0xffffffff, Check: 0x0376e6e7}),
+	crc("crc-32/xfer", CRC{Width: 32, Poly: 0x000000af, Check: 0xbd0be338}),
+
+	crc("crc-64/ecma-182", CRC{Width: 64, Poly: 0x42f0e1eba9ea3693, Check: 0x6c40df5f0b497347}),
+	crc("crc-64/go-iso", CRC{Width: 64, Poly: 0x1b, Init: ones, RefIn: true, RefOut: true, XorOut: ones, Check: 0xb90956c775a41001}),
+	crc("crc-64/redis", CRC{Width: 64, Poly: 0xad93d23594c935a9, RefIn: true, RefOut: true, Check: 0xe9c6d914c4b8d9ca}),
+	crc("crc-64/we", CRC{Width: 64, Poly: 0x42f0e1eba9ea3693, Init: ones, XorOut: ones, Check: 0x62ec59e3f1a4f00a}),
+	crc("crc-64/xz", CRC{Width: 64, Poly: 0x42f0e1eba9ea3693, Init: ones, RefIn: true, RefOut: true, XorOut: ones, Check: 0x995dc9bbdf1939fa}),
+
+	{Name: "sum8", New: newSum(8, false, false)},
+	{Name: "sum16", New: newSum(16, false, false)},
+	{Name: "sum32", New: newSum(32, false, false)},
+	{Name: "sum8-2c", New: newSum(8, true, false)},
+	{Name: "sum16-2c", New: newSum(16, true, false)},
+	{Name: "sum32-2c", New: newSum(32, true, false)},
+	{Name: "xor8", New: newSum(8, false, true)},
+	{Name: "adler32", New: func() hash.Hash { return adler32.New() }},
+	{Name: "fletcher16", New: newFletcher(16)},
+	{Name: "fletcher32", New: newFletcher(32)},
+
+	digest("md5", md5.New),
+	digest("sha1", sha1.New),
+	digest("sha224", sha256.New224),
+	digest("sha256", sha256.New),
+	digest("sha384", sha512.New384),
+	digest("sha512", sha512.New),
+}
+
+// aliases are common names of algorithms
+var aliases = map[string]string{
+	"crc8":         "crc-8/smbus",
+	"crc16":        "crc-16/arc",
+	"crc16-ccitt":  "crc-16/ibm-3740",
+	"crc16-modbus": "crc-16/modbus",
+	"crc32":        "crc-32/iso-hdlc",
+	"crc32c":       "crc-32/iscsi",
+	"crc64":        "crc-64/xz",
+}
@@ -0,0 +1,166 @@
+package checksum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrUnknown = errors.New("unknown algorithm")
+var ErrNotFound = errors.New("checksum isn't found")
+
+type Algorithm struct {
+	Name string
+	// Digest is a hash, its value isn't an integer and has no byte order
+	Digest bool
+	New    func() hash.Hash
+	// CRC is a model of CRC algorithms
+	CRC *CRC
+}
+
+// Sum returns checksum of data, integer checksums are in given byte order.
+func (a Algorithm) Sum(data []byte, bigEndian bool) []byte {
+	h := a.New()
+	h.Write(data)
+	s := h.Sum(nil)
+	if !a.Digest && !bigEndian {
+		slices.Reverse(s)
+	}
+	return s
+}
+
+// Lookup finds algorithm by name or alias, case is ignored.
+func Lookup(name string) (Algorithm, error) {
+	name = strings.ToLower(name)
+	if a, ok := aliases[name]; ok {
+		name = a
+	}
+	for _, a := range Algorithms {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Algorithm{}, fmt.Errorf("%w: %s", ErrUnknown, name)
+}
+
+// String returns parameters of CRC in reveng style.
+func (c *CRC) String() string {
+	digits := (c.Width + 3) / 4
+	return fmt.Sprintf("width=%d poly=0x%0*x init=0x%0*x refin=%t refout=%t xorout=0x%0*x check=0x%0*x",
+		c.Width, digits, c.Poly, digits, c.Init, c.RefIn, c.RefOut, digits, c.XorOut, digits, c.Check)
+}
+
+type Calculator struct {
+	data   []byte
+	algs   []Algorithm
+	value  []byte
+	out    io.Writer
+	Config config.Checksum
+}
+
+func New(cfg config.Checksum) *Calculator {
+	return &Calculator{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (c *Calculator) Open(input string) error {
+	c.algs = Algorithms
+	if len(c.Config.Algorithms) > 0 {
+		c.algs = nil
+		for _, name := range c.Config.Algorithms {
+			a, err := Lookup(name)
+			if err != nil {
+				return err
+			}
+			c.algs = append(c.algs, a)
+		}
+	}
+	if c.Config.List {
+		return nil
+	}
+	var err error
+	c.data, err = os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for checksum: %w", input, err)
+	}
+	if c.Config.End == 0 {
+		c.Config.End = int64(len(c.data))
+	}
+	if c.Config.Start < 0 || c.Config.Start >= c.Config.End || c.Config.End > int64(len(c.data)) {
+		return fmt.Errorf("invalid range 0x%x-0x%x of file with size 0x%x", c.Config.Start, c.Config.End, len(c.data))
+	}
+	if c.Config.Value != "" {
+		v := strings.TrimPrefix(strings.ReplaceAll(c.Config.Value, " ", ""), "0x")
+		if c.value, err = hex.DecodeString(v); err != nil {
+			return fmt.Errorf("invalid value '%s': %w", c.Config.Value, err)
+		}
+	}
+	if c.Config.Search {
+		if c.value == nil && c.Config.At < 0 {
+			return errors.New("set value or offset of checksum for search")
+		}
+		if c.Config.Step <= 0 {
+			return fmt.Errorf("invalid step %d", c.Config.Step)
+		}
+	}
+	return nil
+}
+
+func (c *Calculator) Close() error {
+	return nil
+}
+
+func (c *Calculator) Run(ctx context.Context) error {
+	switch {
+	case c.Config.List:
+		for _, a := range c.algs {
+			switch {
+			case a.CRC != nil:
+				fmt.Fprintf(c.out, "%-20s %s\n", a.Name, a.CRC)
+			default:
+				fmt.Fprintf(c.out, "%-20s size=%d\n", a.Name, a.New().Size())
+			}
+		}
+		names := make([]string, 0, len(aliases))
+		for alias := range aliases {
+			names = append(names, alias)
+		}
+		slices.Sort(names)
+		for _, alias := range names {
+			fmt.Fprintf(c.out, "%-20s alias of %s\n", alias, aliases[alias])
+		}
+		return nil
+	case c.Config.Search:
+		matches, err := Search(ctx, c.data, c.value, c.Config.At, c.Config.Start, c.Config.End, c.Config.Step, c.algs)
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			fmt.Fprintln(c.out, m)
+		}
+		if len(matches) == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}
+	data := c.data[c.Config.Start:c.Config.End]
+	for _, a := range c.algs {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		fmt.Fprintf(c.out, "%-20s %x\n", a.Name, a.Sum(data, true))
+	}
+	return nil
+}
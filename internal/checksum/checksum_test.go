@@ -0,0 +1,159 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var check = []byte("123456789")
+
+func TestAlgorithms(t *testing.T) {
+	for _, a := range Algorithms {
+		if a.CRC == nil {
+			continue
+		}
+		t.Run(a.Name, func(t *testing.T) {
+			require.Equal(t, a.CRC.Check, a.CRC.Checksum(check))
+			// data is written by parts
+			h := a.New()
+			h.Write(check[:4])
+			h.Write(check[4:])
+			sum := h.Sum(nil)
+			require.Len(t, sum, h.Size())
+			v := make([]byte, 8)
+			copy(v[8-len(sum):], sum)
+			require.Equal(t, a.CRC.Check, binary.BigEndian.Uint64(v))
+		})
+	}
+}
+
+func TestSum(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		bigEndian bool
+		want      []byte
+	}{
+		{"crc32", check, false, []byte{0x26, 0x39, 0xf4, 0xcb}},
+		{"CRC32", check, true, []byte{0xcb, 0xf4, 0x39, 0x26}},
+		{"crc16-ccitt", check, true, []byte{0x29, 0xb1}},
+		{"sum8", []byte{0xff, 0x02}, false, []byte{0x01}},
+		{"sum16", []byte{0xff, 0x02}, true, []byte{0x01, 0x01}},
+		{"sum32", []byte{0xff, 0x02}, false, []byte{0x01, 0x01, 0x00, 0x00}},
+		{"sum8-2c", []byte{0x10, 0x20}, false, []byte{0xd0}},
+		{"sum16-2c", []byte{0x10, 0x20}, true, []byte{0xff, 0xd0}},
+		{"xor8", []byte{0x0f, 0xff}, false, []byte{0xf0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Lookup(tt.name)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, a.Sum(tt.data, tt.bigEndian))
+		})
+	}
+	_, err := Lookup("crc-7")
+	require.ErrorIs(t, err, ErrUnknown)
+}
+
+func TestFletcher(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want uint64
+	}{
+		{"fletcher16", "abcde", 0xc8f0},
+		{"fletcher16", "abcdef", 0x2057},
+		{"fletcher32", "abcde", 0xf04fc729},
+		{"fletcher32", "abcdef", 0x56502d2a},
+		{"adler32", "Wikipedia", 0x11e60398},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" "+tt.data, func(t *testing.T) {
+			a, err := Lookup(tt.name)
+			require.NoError(t, err)
+			sum := a.Sum([]byte(tt.data), true)
+			v := make([]byte, 8)
+			copy(v[8-len(sum):], sum)
+			require.Equal(t, tt.want, binary.BigEndian.Uint64(v))
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	data := bytes.Repeat([]byte{0x5a}, 0x100)
+	copy(data, "header")
+	crc := crc32.ChecksumIEEE(data[:0x80])
+	binary.LittleEndian.PutUint32(data[0xf0:], crc)
+
+	a, _ := Lookup("crc32")
+	b, _ := Lookup("crc-16/modbus")
+	matches, err := Search(context.TODO(), data, nil, 0xf0, 0, 0xf0, 4, []Algorithm{b, a})
+	require.NoError(t, err)
+	require.Equal(t, []Match{{"crc-32/iso-hdlc", 0, 0x80, false}}, matches)
+
+	matches, err = Search(context.TODO(), data, binary.BigEndian.AppendUint32(nil, crc), -1, 0, 0x100, 1, Algorithms)
+	require.NoError(t, err)
+	require.Equal(t, []Match{{"crc-32/iso-hdlc", 0, 0x80, true}}, matches)
+}
+
+func TestCalculator_Run(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "fw.bin")
+	require.NoError(t, os.WriteFile(name, append([]byte("xx123456789"), 0x26, 0x39, 0xf4, 0xcb), 0666))
+	tests := []struct {
+		name    string
+		cfg     config.Checksum
+		want    []string
+		wantErr error
+	}{
+		{
+			"Calculate",
+			config.Checksum{Algorithms: []string{"crc32", "sha1", "sum8"}, Start: 2, End: 11},
+			[]string{"crc-32/iso-hdlc      cbf43926\n", "sha1                 f7c3bc1d808e04732adf679965ccc34ca7ae3441\n", "sum8                 dd\n"},
+			nil,
+		},
+		{
+			"List",
+			config.Checksum{List: true},
+			[]string{"crc-16/modbus        width=16 poly=0x8005 init=0xffff refin=true refout=true xorout=0x0000 check=0x4b37\n", "md5                  size=16\n", "crc32c               alias of crc-32/iscsi\n"},
+			nil,
+		},
+		{
+			"Search at offset",
+			config.Checksum{Search: true, At: 11, Start: 2, End: 11, Step: 1},
+			[]string{"crc-32/iso-hdlc      0x00000002-0x0000000b le\n"},
+			nil,
+		},
+		{
+			"Search value",
+			config.Checksum{Search: true, Value: "0x1234", At: -1, Step: 1},
+			nil,
+			ErrNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.cfg)
+			out := &bytes.Buffer{}
+			c.out = out
+			require.NoError(t, c.Open(name))
+			err := c.Run(context.TODO())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			for _, w := range tt.want {
+				require.Contains(t, out.String(), w)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package checksum
+
+import (
+	"hash"
+	"math/bits"
+)
+
+// CRC is a model of CRC with parameters as in reveng catalogue.
+type CRC struct {
+	Width  int
+	Poly   uint64
+	Init   uint64
+	RefIn  bool
+	RefOut bool
+	XorOut uint64
+	// Check is CRC of "123456789"
+	Check uint64
+	table *[256]uint64
+}
+
+// NewCRC precomputes table of model, width must be from 8 to 64 bits.
+func NewCRC(c CRC) *CRC {
+	c.table = &[256]uint64{}
+	mask := c.mask()
+	for i := range c.table {
+		if c.RefIn {
+			poly := reflect(c.Poly, c.Width)
+			r := uint64(i)
+			for j := 0; j < 8; j++ {
+				if r&1 != 0 {
+					r = r>>1 ^ poly
+				} else {
+					r >>= 1
+				}
+			}
+			c.table[i] = r
+			continue
+		}
+		top := uint64(1) << (c.Width - 1)
+		r := uint64(i) << (c.Width - 8)
+		for j := 0; j < 8; j++ {
+			if r&top != 0 {
+				r = r<<1 ^ c.Poly
+			} else {
+				r <<= 1
+			}
+		}
+		c.table[i] = r & mask
+	}
+	return &c
+}
+
+func (c *CRC) mask() uint64 {
+	return ^uint64(0) >> (64 - c.Width)
+}
+
+func reflect(v uint64, width int) uint64 {
+	return bits.Reverse64(v) >> (64 - width)
+}
+
+func (c *CRC) Checksum(data []byte) uint64 {
+	d := c.New().(*crcDigest)
+	d.Write(data)
+	return d.Sum64()
+}
+
+func (c *CRC) New() hash.Hash {
+	d := &crcDigest{c: c}
+	d.Reset()
+	return d
+}
+
+type crcDigest struct {
+	c   *CRC
+	reg uint64
+}
+
+func (d *crcDigest) Reset() {
+	d.reg = d.c.Init
+	if d.c.RefIn {
+		d.reg = reflect(d.c.Init, d.c.Width)
+	}
+}
+
+func (d *crcDigest) Write(p []byte) (int, error) {
+	c := d.c
+	reg := d.reg
+	if c.RefIn {
+		for _, b := range p {
+			reg = c.table[byte(reg)^b] ^ reg>>8
+		}
+	} else {
+		shift := c.Width - 8
+		mask := c.mask()
+		for _, b := range p {
+			reg = (c.table[byte(reg>>shift)^b] ^ reg<<8) & mask
+		}
+	}
+	d.reg = reg
+	return len(p), nil
+}
+
+func (d *crcDigest) Sum64() uint64 {
+	r := d.reg
+	if d.c.RefIn != d.c.RefOut {
+		r = reflect(r, d.c.Width)
+	}
+	return (r ^ d.c.XorOut) & d.c.mask()
+}
+
+func (d *crcDigest) Sum(b []byte) []byte {
+	return appendUint(b, d.Sum64(), d.Size())
+}
+
+func (d *crcDigest) Size() int {
+	return (d.c.Width + 7) / 8
+}
+
+func (d *crcDigest) BlockSize() int {
+	return 1
+}
+
+// appendUint appends big-endian value of size bytes
+func appendUint(b []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
@@ -0,0 +1,65 @@
+package checksum
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+)
+
+type Match struct {
+	Algorithm string
+	Start     int64
+	End       int64
+	BigEndian bool
+}
+
+func (m Match) String() string {
+	order := "le"
+	if m.BigEndian {
+		order = "be"
+	}
+	return fmt.Sprintf("%-20s 0x%08x-0x%08x %s", m.Algorithm, m.Start, m.End, order)
+}
+
+// Search computes checksums of ranges from start to every end in (start, end]
+// with step and compares them with value in both byte orders. If value is nil,
+// it's read at offset at with size of checksum.
+func Search(ctx context.Context, data []byte, value []byte, at, start, end, step int64, algs []Algorithm) ([]Match, error) {
+	var res []Match
+	buf := make([]byte, 0, 64)
+	for _, a := range algs {
+		h := a.New()
+		want := value
+		if want == nil {
+			if at < 0 || at+int64(h.Size()) > int64(len(data)) {
+				continue
+			}
+			want = data[at : at+int64(h.Size())]
+		}
+		if len(want) != h.Size() {
+			continue
+		}
+		reversed := slices.Clone(want)
+		slices.Reverse(reversed)
+		for pos := start; pos < end; {
+			if (pos-start)%0x10000 < step {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				default:
+				}
+			}
+			next := min(pos+step, end)
+			h.Write(data[pos:next])
+			pos = next
+			buf = h.Sum(buf[:0])
+			if bytes.Equal(buf, want) {
+				res = append(res, Match{a.Name, start, pos, true})
+			} else if !a.Digest && len(want) > 1 && bytes.Equal(buf, reversed) {
+				res = append(res, Match{a.Name, start, pos, false})
+			}
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+package checksum
+
+import "hash"
+
+// additive sum of bytes, negative sum is two's complement, which makes
+// sum of data with checksum zero
+type sum struct {
+	width int
+	neg   bool
+	xor   bool
+	value uint64
+}
+
+func newSum(width int, neg, xor bool) func() hash.Hash {
+	return func() hash.Hash {
+		return &sum{width: width, neg: neg, xor: xor}
+	}
+}
+
+func (s *sum) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if s.xor {
+			s.value ^= uint64(b)
+		} else {
+			s.value += uint64(b)
+		}
+	}
+	return len(p), nil
+}
+
+func (s *sum) Sum64() uint64 {
+	v := s.value
+	if s.neg {
+		v = -v
+	}
+	return v & (^uint64(0) >> (64 - s.width))
+}
+
+func (s *sum) Sum(b []byte) []byte {
+	return appendUint(b, s.Sum64(), s.Size())
+}
+
+func (s *sum) Reset() {
+	s.value = 0
+}
+
+func (s *sum) Size() int {
+	return s.width / 8
+}
+
+func (s *sum) BlockSize() int {
+	return 1
+}
+
+// fletcher checksum, Fletcher-32 sums little-endian words, odd byte
+// is padded with zero
+type fletcher struct {
+	width   int
+	a, b    uint64
+	odd     bool
+	pending byte
+}
+
+func newFletcher(width int) func() hash.Hash {
+	return func() hash.Hash {
+		return &fletcher{width: width}
+	}
+}
+
+func (f *fletcher) modulus() uint64 {
+	return 1<<(f.width/2) - 1
+}
+
+func (f *fletcher) add(v uint64) {
+	f.a = (f.a + v) % f.modulus()
+	f.b = (f.b + f.a) % f.modulus()
+}
+
+func (f *fletcher) Write(p []byte) (int, error) {
+	for _, c := range p {
+		switch {
+		case f.width == 16:
+			f.add(uint64(c))
+		case f.odd:
+			f.add(uint64(f.pending) | uint64(c)<<8)
+			f.odd = false
+		default:
+			f.pending, f.odd = c, true
+		}
+	}
+	return len(p), nil
+}
+
+func (f *fletcher) Sum64() uint64 {
+	t := *f
+	if t.odd {
+		t.add(uint64(t.pending))
+	}
+	return t.b<<(f.width/2) | t.a
+}
+
+func (f *fletcher) Sum(b []byte) []byte {
+	return appendUint(b, f.Sum64(), f.Size())
+}
+
+func (f *fletcher) Reset() {
+	*f = fletcher{width: f.width}
+}
+
+func (f *fletcher) Size() int {
+	return f.width / 8
+}
+
+func (f *fletcher) BlockSize() int {
+	return 1
+}
@@ -1,15 +1,46 @@
 package config
 
 type Config struct {
-	Inputs []string
-	Cut    Cut
-	Merge  Merge
-	Swap   Swap
+	Inputs    []string
+	Cut       Cut
+	Merge     Merge
+	Swap      Swap
+	Yaffs2    Yaffs2
+	RootFS    RootFS
+	Scan      Scan
+	Entropy   Entropy
+	UImage    UImage
+	DTB       DTB
+	Partition Partition
+	Env       Env
+	Patch     Patch
+	Checksum  Checksum
+	Diff      Diff
+	Hexdump   Hexdump
+	Convert   Convert
+	ToELF     ToELF
+	Strings   Strings
+	Unpack    Unpack
+	EMMC      EMMC
+	SFDP      SFDP
+	FAT       FAT
+	Ext4      Ext4
+	BootImg   BootImg
+	Sparse    Sparse
+	FTL       FTL
+	Extract   Extract
+	Parse     Parse
 }
 
 type Cut struct {
-	PageSize int
-	SkipSize int
+	PageSize      int
+	SkipSize      int
+	PagesPerBlock int
+	// FTL fields in spare area, zero size means field is absent
+	LBAOffset     int
+	LBASize       int
+	VersionOffset int
+	VersionSize   int
 }
 
 type Merge struct {
@@ -27,3 +58,203 @@ type Swap struct {
 	Words  bool
 	Dwords bool
 }
+
+type Yaffs2 struct {
+	Output     string
+	TagsOffset int
+	TagsECC    bool
+	BigEndian  bool
+}
+
+type RootFS struct {
+	Output     string
+	DetectOnly bool
+}
+
+type Scan struct {
+	Output string
+	JSON   bool
+	Carve  bool
+}
+
+type Entropy struct {
+	BlockSize int
+	Format    string
+	Sparkline bool
+	Width     int
+	PNG       string
+	High      float64
+}
+
+type UImage struct {
+	Output  string
+	Extract bool
+	Create  bool
+	Name    string
+	OS      string
+	Arch    string
+	Type    string
+	Comp    string
+	Load    uint32
+	Entry   uint32
+}
+
+type DTB struct {
+	Output     string
+	Offset     int64
+	JSON       bool
+	Images     bool
+	Extract    bool
+	Partitions bool
+}
+
+type Partition struct {
+	Output   string
+	MTDParts string
+	Device   string
+	Join     bool
+	Table    string
+	Sector   int64
+}
+
+type Env struct {
+	Output string
+	Align  int
+	Size   int
+	Set    []string
+	Delete []string
+}
+
+type Patch struct {
+	Output  string
+	Patches string
+	Fixups  []string
+}
+
+type Checksum struct {
+	Algorithms []string
+	Start      int64
+	End        int64
+	List       bool
+	Search     bool
+	Value      string
+	At         int64
+	Step       int64
+}
+
+type Diff struct {
+	Swap      []string
+	Merge     string
+	MaxRanges int
+}
+
+type Hexdump struct {
+	Start   int64
+	Length  int64
+	Width   int
+	Word    int
+	SubPage int
+	Swap    []string
+	Color   string
+	Plain   bool
+}
+
+type Convert struct {
+	Output string
+	From   string
+	To     string
+	Base   int64
+	Fill   uint8
+	Record int
+	Family string
+	Sparse bool
+}
+
+type ToELF struct {
+	Output    string
+	Regions   []string
+	Base      int64
+	Machine   string
+	Class     int
+	BigEndian bool
+	Entry     int64
+	Symbols   string
+}
+
+type Strings struct {
+	Min       int
+	Encodings []string
+	Pages     bool
+	Score     bool
+}
+
+type Unpack struct {
+	Output  string
+	Formats []string
+	Every   bool
+	Step    int
+	MinSize int64
+	List    bool
+}
+
+type EMMC struct {
+	Output  string
+	User    string
+	Boot0   string
+	Boot1   string
+	RPMB    string
+	ExtCSD  string
+	Extract bool
+	Force   bool
+}
+
+type SFDP struct {
+	Offset int64
+	JSON   bool
+}
+
+type FAT struct {
+	Output  string
+	Offset  int64
+	List    bool
+	Deleted bool
+}
+
+type Ext4 struct {
+	Output string
+	Offset int64
+	List   bool
+	Cat    string
+}
+
+type BootImg struct {
+	Output  string
+	Offset  int64
+	Extract bool
+}
+
+type Sparse struct {
+	Output string
+	Block  uint32
+	Info   bool
+}
+
+type FTL struct {
+	Output    string
+	Mapper    string
+	BigEndian bool
+	Map       bool
+}
+
+type Extract struct {
+	Output string
+	Ranges []string
+	Concat bool
+}
+
+type Parse struct {
+	Output   string
+	Template string
+	Offset   int64
+	Build    bool
+}
@@ -0,0 +1,275 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrFormat = errors.New("unknown format")
+var ErrSyntax = errors.New("invalid record")
+var ErrChecksum = errors.New("invalid checksum of record")
+var ErrOverlap = errors.New("segments overlap")
+var ErrRange = errors.New("address is out of range")
+
+const (
+	Binary = "bin"
+	IHex   = "ihex"
+	SREC   = "srec"
+	UF2    = "uf2"
+)
+
+var extensions = map[string]string{
+	".bin":  Binary,
+	".hex":  IHex,
+	".ihex": IHex,
+	".ihx":  IHex,
+	".srec": SREC,
+	".s19":  SREC,
+	".s28":  SREC,
+	".s37":  SREC,
+	".mot":  SREC,
+	".uf2":  UF2,
+}
+
+// Segment is a contiguous data at address of target memory
+type Segment struct {
+	Addr uint64
+	Data []byte
+}
+
+func (s Segment) End() uint64 {
+	return s.Addr + uint64(len(s.Data))
+}
+
+func (s Segment) String() string {
+	return fmt.Sprintf("0x%08x-0x%08x 0x%x bytes", s.Addr, s.End(), len(s.Data))
+}
+
+// Detect returns format of data by its content.
+func Detect(data []byte) string {
+	text := bytes.TrimLeft(data, " \t\r\n")
+	switch {
+	case len(data) >= uf2BlockSize && binary.LittleEndian.Uint32(data) == uf2MagicStart0 &&
+		binary.LittleEndian.Uint32(data[4:]) == uf2MagicStart1:
+		return UF2
+	case len(text) > 0 && text[0] == ':':
+		return IHex
+	case len(text) > 1 && text[0] == 'S' && text[1] >= '0' && text[1] <= '9':
+		return SREC
+	}
+	return Binary
+}
+
+// FormatOf returns format by extension of file name, empty if it's unknown.
+func FormatOf(name string) string {
+	return extensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// normalize sorts segments and joins adjacent ones.
+func normalize(segs []Segment) ([]Segment, error) {
+	sort.SliceStable(segs, func(i, j int) bool { return segs[i].Addr < segs[j].Addr })
+	var res []Segment
+	for _, s := range segs {
+		if len(s.Data) == 0 {
+			continue
+		}
+		n := len(res)
+		switch {
+		case n > 0 && s.Addr < res[n-1].End():
+			return nil, fmt.Errorf("%w: %s and %s", ErrOverlap, res[n-1], s)
+		case n > 0 && s.Addr == res[n-1].End():
+			res[n-1].Data = append(res[n-1].Data, s.Data...)
+		default:
+			res = append(res, Segment{s.Addr, bytes.Clone(s.Data)})
+		}
+	}
+	return res, nil
+}
+
+// Flatten places segments in flat image, which begins at base, gaps are filled
+// by fill byte. Negative base is the lowest address of segments.
+func Flatten(segs []Segment, base int64, fill byte) ([]byte, uint64, error) {
+	if len(segs) == 0 {
+		return nil, 0, nil
+	}
+	start := segs[0].Addr
+	if base >= 0 {
+		start = uint64(base)
+	}
+	var end uint64
+	for _, s := range segs {
+		if s.Addr < start {
+			return nil, 0, fmt.Errorf("%w: %s is below base 0x%x", ErrRange, s, start)
+		}
+		end = max(end, s.End())
+	}
+	image := bytes.Repeat([]byte{fill}, int(end-start))
+	for _, s := range segs {
+		copy(image[s.Addr-start:], s.Data)
+	}
+	return image, start, nil
+}
+
+// Sparse splits segments into chunks of size aligned by address and drops
+// chunks, which are filled by fill byte only.
+func Sparse(segs []Segment, size int, fill byte) []Segment {
+	var res []Segment
+	for _, s := range segs {
+		for off := 0; off < len(s.Data); {
+			n := size - int((s.Addr+uint64(off))%uint64(size))
+			n = min(n, len(s.Data)-off)
+			chunk := s.Data[off : off+n]
+			if len(bytes.Trim(chunk, string([]byte{fill}))) > 0 {
+				res = append(res, Segment{s.Addr + uint64(off), chunk})
+			}
+			off += n
+		}
+	}
+	res, _ = normalize(res)
+	return res
+}
+
+// Read decodes data in format to segments.
+func Read(data []byte, format string) ([]Segment, error) {
+	var segs []Segment
+	var err error
+	switch format {
+	case Binary:
+		segs = []Segment{{0, data}}
+	case IHex:
+		segs, err = ReadIHex(data)
+	case SREC:
+		segs, err = ReadSREC(data)
+	case UF2:
+		segs, err = ReadUF2(data)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalize(segs)
+}
+
+type Converter struct {
+	data   []byte
+	input  string
+	family uint32
+	out    io.Writer
+	Config config.Convert
+}
+
+func New(cfg config.Convert) *Converter {
+	return &Converter{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (c *Converter) Open(input string) error {
+	var err error
+	c.data, err = os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for converting: %w", input, err)
+	}
+	c.input = input
+	if c.Config.From == "" {
+		c.Config.From = Detect(c.data)
+	}
+	if c.Config.To == "" && c.Config.Output != "" {
+		c.Config.To = FormatOf(c.Config.Output)
+	}
+	if c.Config.To == "" {
+		if c.Config.From == Binary {
+			return errors.New("set format of output")
+		}
+		c.Config.To = Binary
+	}
+	for _, f := range []string{c.Config.From, c.Config.To} {
+		switch f {
+		case Binary, IHex, SREC, UF2:
+		default:
+			return fmt.Errorf("%w: %s", ErrFormat, f)
+		}
+	}
+	if c.Config.Family != "" {
+		c.family, err = Family(c.Config.Family)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Config.Record <= 0 || c.Config.Record > 0xff-4 {
+		return fmt.Errorf("invalid size of record %d", c.Config.Record)
+	}
+	if c.Config.Output == "" {
+		ext := "." + c.Config.To
+		if c.Config.To == IHex {
+			ext = ".hex"
+		}
+		c.Config.Output = strings.TrimSuffix(input, filepath.Ext(input)) + ext
+		if c.Config.Output == input {
+			c.Config.Output = strings.TrimSuffix(input, ext) + "-converted" + ext
+		}
+	}
+	return nil
+}
+
+func (c *Converter) Close() error {
+	return nil
+}
+
+func (c *Converter) Run(ctx context.Context) error {
+	segs, err := Read(c.data, c.Config.From)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.input, err)
+	}
+	if c.Config.From == Binary && c.Config.Base > 0 {
+		segs[0].Addr = uint64(c.Config.Base)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	for _, s := range segs {
+		fmt.Fprintln(c.out, s)
+	}
+	var out []byte
+	switch c.Config.To {
+	case Binary:
+		var base uint64
+		out, base, err = Flatten(segs, c.Config.Base, c.Config.Fill)
+		if err == nil {
+			fmt.Fprintf(c.out, "flat image at 0x%08x, 0x%x bytes\n", base, len(out))
+		}
+	case IHex:
+		if c.Config.Sparse {
+			segs = Sparse(segs, c.Config.Record, c.Config.Fill)
+		}
+		out, err = WriteIHex(segs, c.Config.Record)
+	case SREC:
+		if c.Config.Sparse {
+			segs = Sparse(segs, c.Config.Record, c.Config.Fill)
+		}
+		out, err = WriteSREC(segs, c.Config.Record, filepath.Base(c.input))
+	case UF2:
+		if c.Config.Sparse {
+			segs = Sparse(segs, uf2Payload, c.Config.Fill)
+		}
+		out, err = WriteUF2(segs, c.family, c.Config.Fill)
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.Config.Output, out, 0666)
+}
@@ -0,0 +1,171 @@
+package convert
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func TestReadIHex(t *testing.T) {
+	tests := []struct {
+		name string
+		hex  string
+		want []Segment
+		err  error
+	}{
+		{
+			name: "extended addresses",
+			hex: `:020000040001F9
+:02FFFE0041427E
+:020000021000EC
+:02001000434467
+:00000001FF
+`,
+			want: []Segment{{0x1fffe, []byte("AB")}, {0x10010, []byte("CD")}},
+		},
+		{
+			name: "checksum",
+			hex:  ":02FFFE0041427F\n:00000001FF\n",
+			err:  ErrChecksum,
+		},
+		{
+			name: "no end",
+			hex:  ":02FFFE0041427E\n",
+			err:  ErrSyntax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs, err := ReadIHex([]byte(tt.hex))
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, segs)
+		})
+	}
+}
+
+func TestReadSREC(t *testing.T) {
+	segs, err := ReadSREC([]byte("S00600004844521B\nS1137AF00A0A0D0000000000000000000000000061\nS5030001FB\nS9030000FC\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Segment{{0x7af0, append([]byte{0x0a, 0x0a, 0x0d}, make([]byte, 13)...)}}, segs)
+
+	_, err = ReadSREC([]byte("S1137AF00A0A0D0000000000000000000000000062\n"))
+	require.ErrorIs(t, err, ErrChecksum)
+	_, err = ReadSREC([]byte("S1137AF00A0A0D0000000000000000000000000061\nS5030002FA\n"))
+	require.ErrorIs(t, err, ErrSyntax)
+}
+
+func TestRoundTrip(t *testing.T) {
+	segs := []Segment{
+		{0xfff0, bytes.Repeat([]byte{0x11}, 0x30)},
+		{0x20100, []byte("sparse data")},
+		{0x20120, bytes.Repeat([]byte{0x22}, 0x120)},
+	}
+	for _, format := range []string{IHex, SREC, UF2} {
+		t.Run(format, func(t *testing.T) {
+			var data []byte
+			var err error
+			switch format {
+			case IHex:
+				data, err = WriteIHex(segs, 0x20)
+			case SREC:
+				data, err = WriteSREC(segs, 0x20, "test")
+			case UF2:
+				data, err = WriteUF2(segs, 0xe48bff56, 0xff)
+			}
+			require.NoError(t, err)
+			require.Equal(t, format, Detect(data))
+			got, err := Read(data, format)
+			require.NoError(t, err)
+			image, base, err := Flatten(got, -1, 0xff)
+			require.NoError(t, err)
+			want, wantBase, err := Flatten(segs, -1, 0xff)
+			require.NoError(t, err)
+			if format == UF2 {
+				// blocks are aligned by 256 bytes
+				want = append(bytes.Repeat([]byte{0xff}, 0xf0), want...)
+				want = append(want, bytes.Repeat([]byte{0xff}, len(image)-len(want))...)
+				wantBase -= 0xf0
+			}
+			require.Equal(t, wantBase, base)
+			require.Equal(t, want, image)
+		})
+	}
+}
+
+func TestWriteSREC(t *testing.T) {
+	tests := []struct {
+		name   string
+		addr   uint64
+		record int
+		ok     bool
+	}{
+		{"S1", 0x100, 252, true},
+		{"S1 too long", 0x100, 253, false},
+		{"S2", 0x10000, 251, true},
+		{"S2 too long", 0x10000, 252, false},
+		{"S3", 0x1000000, 250, true},
+		{"S3 too long", 0x1000000, 251, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs := []Segment{{tt.addr, bytes.Repeat([]byte{0x5a}, 2*tt.record+1)}}
+			data, err := WriteSREC(segs, tt.record, "test")
+			if !tt.ok {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := ReadSREC(data)
+			require.NoError(t, err)
+			image, base, err := Flatten(got, -1, 0xff)
+			require.NoError(t, err)
+			require.Equal(t, tt.addr, base)
+			require.Equal(t, segs[0].Data, image)
+		})
+	}
+}
+
+func TestSparse(t *testing.T) {
+	data := bytes.Repeat([]byte{0xff}, 0x40)
+	data[0x05] = 0
+	data[0x31] = 0
+	segs := Sparse([]Segment{{0x1008, data}}, 0x10, 0xff)
+	require.Equal(t, []Segment{{0x1008, data[:0x8]}, {0x1030, data[0x28:0x38]}}, segs)
+
+	_, _, err := Flatten(segs, 0x1010, 0)
+	require.ErrorIs(t, err, ErrRange)
+	image, _, err := Flatten(segs, 0x1000, 0)
+	require.NoError(t, err)
+	require.Len(t, image, 0x40)
+}
+
+func TestConverter_Run(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "firmware.bin")
+	require.NoError(t, os.WriteFile(input, []byte("firmware"), 0o644))
+
+	c := New(config.Convert{To: IHex, Base: 0x08000000, Record: 16})
+	c.out = &bytes.Buffer{}
+	require.NoError(t, c.Open(input))
+	require.NoError(t, c.Run(context.TODO()))
+	hex, err := os.ReadFile(filepath.Join(dir, "firmware.hex"))
+	require.NoError(t, err)
+	require.Equal(t, ":020000040800F2\n:080000006669726D776172659B\n:00000001FF\n", string(hex))
+
+	c = New(config.Convert{Base: -1, Record: 16, Output: filepath.Join(dir, "out.bin")})
+	c.out = &bytes.Buffer{}
+	require.NoError(t, c.Open(filepath.Join(dir, "firmware.hex")))
+	require.NoError(t, c.Run(context.TODO()))
+	data, err := os.ReadFile(filepath.Join(dir, "out.bin"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("firmware"), data)
+
+	c = New(config.Convert{Record: 16})
+	require.Error(t, c.Open(input))
+}
@@ -0,0 +1,108 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// types of Intel HEX records
+const (
+	ihexData = iota
+	ihexEOF
+	ihexSegment
+	ihexStartSegment
+	ihexLinear
+	ihexStartLinear
+)
+
+// ReadIHex decodes Intel HEX with extended segment and linear addresses.
+func ReadIHex(data []byte) ([]Segment, error) {
+	var segs []Segment
+	var base uint64
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if line[0] != ':' {
+			return nil, fmt.Errorf("%w: line %d doesn't start with ':'", ErrSyntax, n)
+		}
+		rec, err := hex.DecodeString(line[1:])
+		if err != nil || len(rec) < 5 || len(rec) != int(rec[0])+5 {
+			return nil, fmt.Errorf("%w: line %d", ErrSyntax, n)
+		}
+		var sum byte
+		for _, b := range rec {
+			sum += b
+		}
+		if sum != 0 {
+			return nil, fmt.Errorf("%w: line %d", ErrChecksum, n)
+		}
+		off := uint64(binary.BigEndian.Uint16(rec[1:]))
+		payload := rec[4 : len(rec)-1]
+		switch rec[3] {
+		case ihexData:
+			segs = append(segs, Segment{base + off, payload})
+		case ihexEOF:
+			return segs, nil
+		case ihexSegment, ihexLinear:
+			if len(payload) != 2 {
+				return nil, fmt.Errorf("%w: extended address at line %d", ErrSyntax, n)
+			}
+			base = uint64(binary.BigEndian.Uint16(payload))
+			if rec[3] == ihexSegment {
+				base <<= 4
+			} else {
+				base <<= 16
+			}
+		case ihexStartSegment, ihexStartLinear:
+			// start address isn't used in flat image
+		default:
+			return nil, fmt.Errorf("%w: type 0x%02x at line %d", ErrSyntax, rec[3], n)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w: no end of file record", ErrSyntax)
+}
+
+// WriteIHex encodes segments as Intel HEX with extended linear addresses.
+func WriteIHex(segs []Segment, record int) ([]byte, error) {
+	var b bytes.Buffer
+	upper := uint64(0)
+	for _, s := range segs {
+		if s.End() > 1<<32 {
+			return nil, fmt.Errorf("%w: %s", ErrRange, s)
+		}
+		for off := 0; off < len(s.Data); {
+			addr := s.Addr + uint64(off)
+			if addr>>16 != upper {
+				upper = addr >> 16
+				ihexRecord(&b, ihexLinear, 0, []byte{byte(upper >> 8), byte(upper)})
+			}
+			// record doesn't cross 64K boundary
+			n := min(record, len(s.Data)-off, int(0x10000-addr&0xffff))
+			ihexRecord(&b, ihexData, uint16(addr), s.Data[off:off+n])
+			off += n
+		}
+	}
+	ihexRecord(&b, ihexEOF, 0, nil)
+	return b.Bytes(), nil
+}
+
+func ihexRecord(b *bytes.Buffer, typ byte, addr uint16, data []byte) {
+	rec := []byte{byte(len(data)), byte(addr >> 8), byte(addr), typ}
+	rec = append(rec, data...)
+	var sum byte
+	for _, c := range rec {
+		sum += c
+	}
+	rec = append(rec, -sum)
+	fmt.Fprintf(b, ":%s\n", strings.ToUpper(hex.EncodeToString(rec)))
+}
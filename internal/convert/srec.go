@@ -0,0 +1,113 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// sizes of address in S0-S9 records, zero is unused record type
+var srecAddr = [10]int{2, 2, 3, 4, 0, 2, 3, 4, 3, 2}
+
+// ReadSREC decodes Motorola S-Record, count records are verified.
+func ReadSREC(data []byte) ([]Segment, error) {
+	var segs []Segment
+	records := 0
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if len(line) < 2 || line[0] != 'S' || line[1] < '0' || line[1] > '9' || line[1] == '4' {
+			return nil, fmt.Errorf("%w: line %d", ErrSyntax, n)
+		}
+		typ := int(line[1] - '0')
+		rec, err := hex.DecodeString(line[2:])
+		size := srecAddr[typ]
+		if err != nil || len(rec) < size+2 || len(rec) != int(rec[0])+1 {
+			return nil, fmt.Errorf("%w: line %d", ErrSyntax, n)
+		}
+		var sum byte
+		for _, b := range rec[:len(rec)-1] {
+			sum += b
+		}
+		if ^sum != rec[len(rec)-1] {
+			return nil, fmt.Errorf("%w: line %d", ErrChecksum, n)
+		}
+		var addr uint64
+		for _, b := range rec[1 : 1+size] {
+			addr = addr<<8 | uint64(b)
+		}
+		switch typ {
+		case 1, 2, 3:
+			segs = append(segs, Segment{addr, rec[1+size : len(rec)-1]})
+			records++
+		case 5, 6:
+			if addr != uint64(records) {
+				return nil, fmt.Errorf("%w: count %d of %d records at line %d", ErrSyntax, addr, records, n)
+			}
+		case 7, 8, 9:
+			return segs, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return segs, nil
+}
+
+// WriteSREC encodes segments as Motorola S-Record, size of addresses
+// is chosen by the end of the last segment.
+func WriteSREC(segs []Segment, record int, header string) ([]byte, error) {
+	var end uint64
+	if len(segs) > 0 {
+		end = segs[len(segs)-1].End()
+	}
+	typ := 1
+	switch {
+	case end > 1<<32:
+		return nil, fmt.Errorf("%w: 0x%x", ErrRange, end)
+	case end > 1<<24:
+		typ = 3
+	case end > 1<<16:
+		typ = 2
+	}
+	// count of record includes address and checksum
+	if limit := 0xff - srecAddr[typ] - 1; record <= 0 || record > limit {
+		return nil, fmt.Errorf("invalid size of record %d, S%d records have up to %d bytes", record, typ, limit)
+	}
+	var b bytes.Buffer
+	srecRecord(&b, 0, 0, []byte(header[:min(len(header), record)]))
+	records := 0
+	for _, s := range segs {
+		for off := 0; off < len(s.Data); off += record {
+			srecRecord(&b, typ, s.Addr+uint64(off), s.Data[off:min(off+record, len(s.Data))])
+			records++
+		}
+	}
+	if records <= 0xffff {
+		srecRecord(&b, 5, uint64(records), nil)
+	} else {
+		srecRecord(&b, 6, uint64(records), nil)
+	}
+	srecRecord(&b, 10-typ, 0, nil)
+	return b.Bytes(), nil
+}
+
+func srecRecord(b *bytes.Buffer, typ int, addr uint64, data []byte) {
+	size := srecAddr[typ]
+	rec := []byte{byte(size + len(data) + 1)}
+	for i := size - 1; i >= 0; i-- {
+		rec = append(rec, byte(addr>>(8*i)))
+	}
+	rec = append(rec, data...)
+	var sum byte
+	for _, c := range rec {
+		sum += c
+	}
+	rec = append(rec, ^sum)
+	fmt.Fprintf(b, "S%d%s\n", typ, strings.ToUpper(hex.EncodeToString(rec)))
+}
@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrFamily = errors.New("unknown family")
+
+const (
+	uf2BlockSize   = 512
+	uf2Payload     = 256
+	uf2MaxPayload  = 476
+	uf2MagicStart0 = 0x0a324655
+	uf2MagicStart1 = 0x9e5d5157
+	uf2MagicEnd    = 0x0ab16f30
+
+	uf2NotMainFlash = 0x00000001
+	uf2FamilyID     = 0x00002000
+)
+
+// families are IDs of some families of microcontrollers from UF2 specification
+var families = map[string]uint32{
+	"atmega32":   0x16573617,
+	"samd21":     0x68ed2b88,
+	"samd51":     0x55114460,
+	"nrf52840":   0xada52840,
+	"stm32f1":    0x5ee21072,
+	"stm32f4":    0x57755a57,
+	"esp32s2":    0xbfdd4eee,
+	"esp32s3":    0xc47e5767,
+	"esp32c3":    0xd42ba06c,
+	"rp2040":     0xe48bff56,
+	"rp2350-arm": 0xe48bff59,
+}
+
+// Family returns ID of family by name or number.
+func Family(name string) (uint32, error) {
+	if id, ok := families[strings.ToLower(name)]; ok {
+		return id, nil
+	}
+	id, err := strconv.ParseUint(name, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrFamily, name)
+	}
+	return uint32(id), nil
+}
+
+// ReadUF2 decodes UF2 blocks, blocks not for main flash are skipped.
+func ReadUF2(data []byte) ([]Segment, error) {
+	if len(data)%uf2BlockSize != 0 {
+		return nil, fmt.Errorf("%w: size 0x%x isn't multiple of UF2 block", ErrSyntax, len(data))
+	}
+	var segs []Segment
+	for off := 0; off < len(data); off += uf2BlockSize {
+		b := data[off : off+uf2BlockSize]
+		le := binary.LittleEndian
+		if le.Uint32(b) != uf2MagicStart0 || le.Uint32(b[4:]) != uf2MagicStart1 || le.Uint32(b[508:]) != uf2MagicEnd {
+			return nil, fmt.Errorf("%w: magic of UF2 block %d", ErrSyntax, off/uf2BlockSize)
+		}
+		flags, addr, size := le.Uint32(b[8:]), le.Uint32(b[12:]), le.Uint32(b[16:])
+		if flags&uf2NotMainFlash != 0 {
+			continue
+		}
+		if size > uf2MaxPayload {
+			return nil, fmt.Errorf("%w: payload 0x%x of UF2 block %d", ErrSyntax, size, off/uf2BlockSize)
+		}
+		segs = append(segs, Segment{uint64(addr), b[32 : 32+size]})
+	}
+	return segs, nil
+}
+
+// WriteUF2 encodes segments as UF2 blocks with 256 bytes of payload aligned by address,
+// incomplete blocks are padded by fill byte. Zero family isn't written.
+func WriteUF2(segs []Segment, family uint32, fill byte) ([]byte, error) {
+	var blocks [][]byte
+	for _, s := range segs {
+		if s.End() > 1<<32 {
+			return nil, fmt.Errorf("%w: %s", ErrRange, s)
+		}
+		start := s.Addr &^ (uf2Payload - 1)
+		for addr := start; addr < s.End(); addr += uf2Payload {
+			b := make([]byte, uf2BlockSize)
+			payload := b[32 : 32+uf2Payload]
+			for i := range payload {
+				payload[i] = fill
+			}
+			// previous segment can share this block
+			if n := len(blocks); n > 0 && uint64(binary.LittleEndian.Uint32(blocks[n-1][12:])) == addr {
+				b = blocks[n-1]
+				payload = b[32 : 32+uf2Payload]
+				blocks = blocks[:n-1]
+			}
+			lo := max(addr, s.Addr)
+			hi := min(addr+uf2Payload, s.End())
+			copy(payload[lo-addr:], s.Data[lo-s.Addr:hi-s.Addr])
+			binary.LittleEndian.PutUint32(b[12:], uint32(addr))
+			blocks = append(blocks, b)
+		}
+	}
+	var flags uint32
+	if family != 0 {
+		flags = uf2FamilyID
+	}
+	out := make([]byte, 0, len(blocks)*uf2BlockSize)
+	for i, b := range blocks {
+		le := binary.LittleEndian
+		le.PutUint32(b, uf2MagicStart0)
+		le.PutUint32(b[4:], uf2MagicStart1)
+		le.PutUint32(b[8:], flags)
+		le.PutUint32(b[16:], uf2Payload)
+		le.PutUint32(b[20:], uint32(i))
+		le.PutUint32(b[24:], uint32(len(blocks)))
+		le.PutUint32(b[28:], family)
+		le.PutUint32(b[508:], uf2MagicEnd)
+		out = append(out, b...)
+	}
+	return out, nil
+}
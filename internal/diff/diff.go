@@ -0,0 +1,322 @@
+package diff
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+	"github.com/Nexadis/fw-tools/internal/merge"
+	"github.com/Nexadis/fw-tools/internal/swap"
+)
+
+var ErrTransform = errors.New("unknown transformation")
+var ErrAlign = errors.New("page isn't aligned on swapped unit")
+
+const barWidth = 40
+
+type Range struct {
+	Start int64
+	End   int64
+	Flips int
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("0x%08x-0x%08x %d bytes, %d bit flips", r.Start, r.End, r.End-r.Start, r.Flips)
+}
+
+// Result is a difference of one image from reference.
+type Result struct {
+	Name    string
+	Size    int64
+	RefSize int64
+	Bytes   int64
+	Flips   int64
+	Ranges  []Range
+	// More is a number of ranges, which are over limit
+	More int
+	// Pages has bit flips of differing pages
+	Pages      map[int64]int
+	TotalPages int64
+	maxRanges  int
+	// last is an end of last differing byte
+	last int64
+}
+
+func (r *Result) Identical() bool {
+	return r.Bytes == 0 && r.Size == r.RefSize
+}
+
+func (r *Result) compare(page, off int64, a, b []byte) {
+	r.RefSize += int64(len(a))
+	r.Size += int64(len(b))
+	if len(a) > 0 || len(b) > 0 {
+		r.TotalPages++
+	}
+	for i := 0; i < min(len(a), len(b)); i++ {
+		x := a[i] ^ b[i]
+		if x == 0 {
+			continue
+		}
+		flips := bits.OnesCount8(x)
+		pos := off + int64(i)
+		r.Bytes++
+		r.Flips += int64(flips)
+		r.Pages[page] += flips
+		n := len(r.Ranges)
+		contiguous := r.Bytes > 1 && r.last == pos
+		r.last = pos + 1
+		switch {
+		case contiguous && r.More == 0:
+			r.Ranges[n-1].End++
+			r.Ranges[n-1].Flips += flips
+		case contiguous:
+			// the range is over limit and it's counted already
+		case n < r.maxRanges:
+			r.Ranges = append(r.Ranges, Range{pos, pos + 1, flips})
+		default:
+			r.More++
+		}
+	}
+}
+
+type Differ struct {
+	names   []string
+	inputs  []io.ReadCloser
+	swapper *swap.Swapper
+	merger  *merge.Merger
+	out     io.Writer
+	Config  config.Diff
+	Cut     config.Cut
+}
+
+func New(cfg config.Diff, geometry config.Cut) *Differ {
+	return &Differ{
+		Config: cfg,
+		Cut:    geometry,
+		out:    os.Stdout,
+	}
+}
+
+func (d *Differ) Open(inputs []string) error {
+	if len(inputs) < 2 {
+		return errors.New("set two or more files")
+	}
+	if err := d.transforms(len(inputs)); err != nil {
+		return err
+	}
+	if d.page() <= 0 || d.Cut.PagesPerBlock <= 0 {
+		return fmt.Errorf("invalid geometry: page 0x%x, %d pages in block", d.page(), d.Cut.PagesPerBlock)
+	}
+	for _, in := range inputs {
+		f, err := os.Open(in)
+		if err != nil {
+			return fmt.Errorf("can't open file '%s' for diff: %w", in, err)
+		}
+		d.inputs = append(d.inputs, f)
+	}
+	d.names = inputs
+	return nil
+}
+
+// transforms checks transformations of compared images
+func (d *Differ) transforms(inputs int) error {
+	s, unit, err := swap.Parse(d.Config.Swap)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTransform, err)
+	}
+	if len(d.Config.Swap) > 0 {
+		if d.page()%unit != 0 {
+			return fmt.Errorf("%w: %d", ErrAlign, unit)
+		}
+		d.swapper = swap.New(s)
+	}
+	if d.Config.Merge == "" {
+		return nil
+	}
+	m, err := merge.Parse(d.Config.Merge)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrTransform, err)
+	}
+	if inputs < 3 {
+		return errors.New("set reference and two or more files for merge")
+	}
+	d.merger = merge.New(m)
+	return nil
+}
+
+func (d *Differ) page() int {
+	return d.Cut.PageSize + d.Cut.SkipSize
+}
+
+func (d *Differ) Close() error {
+	var err error
+	for _, in := range d.inputs {
+		err = errors.Join(in.Close(), err)
+	}
+	return err
+}
+
+func (d *Differ) Run(ctx context.Context) error {
+	ref := bufio.NewReader(d.inputs[0])
+	var others []io.Reader
+	names := d.names[1:]
+	if d.merger != nil {
+		rs := make([]io.Reader, 0, len(d.inputs)-1)
+		for _, in := range d.inputs[1:] {
+			rs = append(rs, bufio.NewReader(in))
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			w := bufio.NewWriter(pw)
+			err := d.merger.Merge(ctx, rs, w)
+			pw.CloseWithError(errors.Join(err, w.Flush()))
+		}()
+		defer pr.Close()
+		others = []io.Reader{pr}
+		names = []string{fmt.Sprintf("merge by %s of %s", d.Config.Merge, strings.Join(names, ", "))}
+	} else {
+		for _, in := range d.inputs[1:] {
+			others = append(others, bufio.NewReader(in))
+		}
+	}
+	results, err := d.Diff(ctx, ref, others)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		r.Name = names[i]
+		d.print(r)
+	}
+	return nil
+}
+
+// Diff reads images page by page in lockstep and compares them with reference,
+// others are swapped before comparison, if it's configured.
+func (d *Differ) Diff(ctx context.Context, ref io.Reader, others []io.Reader) ([]*Result, error) {
+	page := d.page()
+	results := make([]*Result, len(others))
+	bufs := make([][]byte, len(others))
+	for i := range others {
+		results[i] = &Result{Pages: map[int64]int{}, maxRanges: d.Config.MaxRanges}
+		bufs[i] = make([]byte, page)
+	}
+	refBuf := make([]byte, page)
+	for p := int64(0); ; p++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		n, err := readPage(ref, refBuf)
+		if err != nil {
+			return nil, err
+		}
+		done := n == 0
+		for i, o := range others {
+			m, err := readPage(o, bufs[i])
+			if err != nil {
+				return nil, err
+			}
+			if d.swapper != nil {
+				d.swapper.Transform(bufs[i][:m])
+			}
+			results[i].compare(p, p*int64(page), refBuf[:n], bufs[i][:m])
+			done = done && m == 0
+		}
+		if done {
+			return results, nil
+		}
+	}
+}
+
+func readPage(r io.Reader, buf []byte) (int, error) {
+	n, err := io.ReadFull(r, buf)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return n, err
+}
+
+func (d *Differ) print(r *Result) {
+	w := bufio.NewWriter(d.out)
+	defer w.Flush()
+	fmt.Fprintf(w, "%s vs %s:\n", r.Name, d.names[0])
+	if r.Identical() {
+		fmt.Fprintln(w, "  identical")
+		return
+	}
+	for _, rng := range r.Ranges {
+		fmt.Fprintf(w, "  %s\n", rng)
+	}
+	if r.More > 0 {
+		fmt.Fprintf(w, "  ... and %d more ranges\n", r.More)
+	}
+
+	pages := make([]int64, 0, len(r.Pages))
+	blocks := map[int64]int{}
+	for p, flips := range r.Pages {
+		pages = append(pages, p)
+		blocks[p/int64(d.Cut.PagesPerBlock)] += flips
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i] < pages[j] })
+	if len(pages) > 0 {
+		fmt.Fprintf(w, "  bit flips per page (0x%x bytes) and block (%d pages):\n", d.page(), d.Cut.PagesPerBlock)
+	}
+	for i, p := range pages {
+		if i == d.Config.MaxRanges {
+			fmt.Fprintf(w, "  ... and %d more pages\n", len(pages)-i)
+			break
+		}
+		b := p / int64(d.Cut.PagesPerBlock)
+		fmt.Fprintf(w, "  page 0x%06x block 0x%04x: %d, block total %d\n", p, b, r.Pages[p], blocks[b])
+	}
+
+	fmt.Fprintln(w, "  histogram of bit flips per page:")
+	hist := histogram(r)
+	var most int64
+	for _, c := range hist {
+		most = max(most, c)
+	}
+	for i, c := range hist {
+		if c == 0 {
+			continue
+		}
+		label := "0"
+		if i > 0 {
+			lo, hi := 1<<(i-1), 1<<i-1
+			label = fmt.Sprintf("%d-%d", lo, hi)
+			if lo == hi {
+				label = fmt.Sprint(lo)
+			}
+		}
+		bar := int(c * barWidth / most)
+		fmt.Fprintf(w, "  %11s | %8d %s\n", label, c, strings.Repeat("#", max(bar, 1)))
+	}
+	fmt.Fprintf(w, "  total: %d bytes, %d bit flips in %d of %d pages, %d of %d blocks\n",
+		r.Bytes, r.Flips, len(r.Pages), r.TotalPages,
+		len(blocks), (r.TotalPages+int64(d.Cut.PagesPerBlock)-1)/int64(d.Cut.PagesPerBlock))
+	if r.Size != r.RefSize {
+		fmt.Fprintf(w, "  size differs: 0x%x vs 0x%x\n", r.Size, r.RefSize)
+	}
+}
+
+// histogram counts pages by bit flips, i-th bucket has pages with
+// flips in [2^(i-1), 2^i), zero bucket has pages without flips.
+func histogram(r *Result) []int64 {
+	hist := []int64{r.TotalPages - int64(len(r.Pages))}
+	for _, flips := range r.Pages {
+		i := bits.Len(uint(flips))
+		for len(hist) <= i {
+			hist = append(hist, 0)
+		}
+		hist[i]++
+	}
+	return hist
+}
@@ -0,0 +1,98 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var geometry = config.Cut{PageSize: 0x10, SkipSize: 0x0, PagesPerBlock: 2}
+
+func TestDiffer_Diff(t *testing.T) {
+	ref := bytes.Repeat([]byte{0xFF}, 0x80)
+	other := bytes.Clone(ref)
+	other[0x11] = 0xFE
+	other[0x12] = 0x00
+	other[0x13] = 0x0F
+	other[0x40] = 0x7F
+	other[0x70] = 0x00
+
+	d := New(config.Diff{MaxRanges: 2}, geometry)
+	results, err := d.Diff(context.TODO(), bytes.NewReader(ref), []io.Reader{bytes.NewReader(other), bytes.NewReader(ref[:0x70])})
+	require.NoError(t, err)
+	r := results[0]
+	require.Equal(t, []Range{{0x11, 0x14, 1 + 8 + 4}, {0x40, 0x41, 1}}, r.Ranges)
+	require.Equal(t, 1, r.More)
+	require.Equal(t, map[int64]int{1: 13, 4: 1, 7: 8}, r.Pages)
+	require.Equal(t, int64(8), r.TotalPages)
+	require.Equal(t, []int64{5, 1, 0, 0, 2}, histogram(r))
+	require.False(t, r.Identical())
+
+	require.Equal(t, int64(0), results[1].Bytes)
+	require.Equal(t, int64(0x70), results[1].Size)
+	require.False(t, results[1].Identical())
+}
+
+func TestDiffer_Run(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		name = filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(name, data, 0666))
+		return name
+	}
+	full := write("full.bin", []byte("0123456789abcdef0123456789abcdef"))
+	swapped := write("swapped.bin", []byte("1032547698badcfe1032547698badcfe"))
+	lo := write("lo.bin", []byte("02468ace02468ace"))
+	hi := write("hi.bin", []byte("13579bdf13579bdf"))
+	broken := write("broken.bin", []byte("0123456789abcdef0123456789abcdeg"))
+	tests := []struct {
+		name    string
+		cfg     config.Diff
+		inputs  []string
+		want    []string
+		wantErr bool
+	}{
+		{"Swapped bytes", config.Diff{Swap: []string{"bytes"}}, []string{full, swapped}, []string{"swapped.bin vs " + full + ":\n  identical\n"}, false},
+		{"Merged bytes", config.Diff{Merge: "byte"}, []string{full, lo, hi}, []string{"merge by byte of " + lo + ", " + hi + " vs " + full + ":\n  identical\n"}, false},
+		{
+			"Bit flip",
+			config.Diff{MaxRanges: 10},
+			[]string{full, broken},
+			[]string{
+				"  0x0000001f-0x00000020 1 bytes, 1 bit flips\n",
+				"  page 0x000001 block 0x0000: 1, block total 1\n",
+				"            0 |        1 ########################################\n",
+				"            1 |        1 ########################################\n",
+				"  total: 1 bytes, 1 bit flips in 1 of 2 pages, 1 of 1 blocks\n",
+			},
+			false,
+		},
+		{"Unknown swap", config.Diff{Swap: []string{"nibbles"}}, []string{full, swapped}, nil, true},
+		{"Merge of one file", config.Diff{Merge: "word"}, []string{full, lo}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := New(tt.cfg, geometry)
+			out := &bytes.Buffer{}
+			d.out = out
+			err := d.Open(tt.inputs)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer d.Close()
+			require.NoError(t, d.Run(context.TODO()))
+			for _, w := range tt.want {
+				require.Contains(t, out.String(), w)
+			}
+		})
+	}
+}
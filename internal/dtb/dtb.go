@@ -0,0 +1,407 @@
+package dtb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrMagic = errors.New("invalid magic of device tree")
+var ErrCorrupted = errors.New("corrupted device tree")
+
+const (
+	Magic      = 0xd00dfeed
+	headerSize = 40
+)
+
+// tokens of structure block
+const (
+	tokenBeginNode = 1
+	tokenEndNode   = 2
+	tokenProp      = 3
+	tokenNop       = 4
+	tokenEnd       = 9
+)
+
+type Header struct {
+	Magic           uint32
+	TotalSize       uint32
+	OffDtStruct     uint32
+	OffDtStrings    uint32
+	OffMemRsvmap    uint32
+	Version         uint32
+	LastCompVersion uint32
+	BootCPUID       uint32
+	SizeDtStrings   uint32
+	SizeDtStruct    uint32
+}
+
+type Property struct {
+	Name  string
+	Value []byte
+}
+
+type Node struct {
+	Name     string
+	Props    []Property
+	Children []*Node
+}
+
+// Prop returns value of property and false, if node hasn't it.
+func (n *Node) Prop(name string) ([]byte, bool) {
+	for _, p := range n.Props {
+		if p.Name == name {
+			return p.Value, true
+		}
+	}
+	return nil, false
+}
+
+// String returns value of string property.
+func (n *Node) String(name string) string {
+	v, _ := n.Prop(name)
+	s, _, _ := bytes.Cut(v, []byte{0})
+	return string(s)
+}
+
+// Uint returns value of one or two cells property.
+func (n *Node) Uint(name string) (uint64, bool) {
+	v, ok := n.Prop(name)
+	return cells(v), ok && (len(v) == 4 || len(v) == 8)
+}
+
+func (n *Node) Child(name string) *Node {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+type Tree struct {
+	Header Header
+	Root   *Node
+}
+
+// Parse decodes flattened device tree at beginning of b.
+func Parse(b []byte) (*Tree, error) {
+	t := &Tree{}
+	if len(b) < headerSize {
+		return nil, fmt.Errorf("%w: too short", ErrCorrupted)
+	}
+	binary.Read(bytes.NewReader(b), binary.BigEndian, &t.Header)
+	h := t.Header
+	if h.Magic != Magic {
+		return nil, ErrMagic
+	}
+	if int64(h.TotalSize) > int64(len(b)) || h.OffDtStruct >= h.TotalSize || h.OffDtStrings > h.TotalSize {
+		return nil, fmt.Errorf("%w: invalid offsets", ErrCorrupted)
+	}
+	structEnd := h.TotalSize
+	if h.Version >= 17 {
+		if uint64(h.OffDtStruct)+uint64(h.SizeDtStruct) > uint64(h.TotalSize) {
+			return nil, fmt.Errorf("%w: invalid size of structure", ErrCorrupted)
+		}
+		structEnd = h.OffDtStruct + h.SizeDtStruct
+	}
+	p := &parser{
+		b:       b[h.OffDtStruct:structEnd],
+		strings: b[h.OffDtStrings:h.TotalSize],
+	}
+	root, err := p.tree()
+	if err != nil {
+		return nil, err
+	}
+	t.Root = root
+	return t, nil
+}
+
+type parser struct {
+	b       []byte
+	pos     int
+	strings []byte
+}
+
+func (p *parser) u32() (uint32, error) {
+	if p.pos+4 > len(p.b) {
+		return 0, fmt.Errorf("%w: unexpected end of structure", ErrCorrupted)
+	}
+	v := binary.BigEndian.Uint32(p.b[p.pos:])
+	p.pos += 4
+	return v, nil
+}
+
+func (p *parser) align() {
+	p.pos = (p.pos + 3) &^ 3
+}
+
+func (p *parser) tree() (*Node, error) {
+	var stack []*Node
+	var root *Node
+	for {
+		token, err := p.u32()
+		if err != nil {
+			return nil, err
+		}
+		switch token {
+		case tokenBeginNode:
+			end := bytes.IndexByte(p.b[p.pos:], 0)
+			if end < 0 {
+				return nil, fmt.Errorf("%w: name of node", ErrCorrupted)
+			}
+			n := &Node{Name: string(p.b[p.pos : p.pos+end])}
+			p.pos += end + 1
+			p.align()
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			} else if root == nil {
+				root = n
+			} else {
+				return nil, fmt.Errorf("%w: many root nodes", ErrCorrupted)
+			}
+			stack = append(stack, n)
+		case tokenEndNode:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: unexpected end of node", ErrCorrupted)
+			}
+			stack = stack[:len(stack)-1]
+		case tokenProp:
+			size, err := p.u32()
+			if err != nil {
+				return nil, err
+			}
+			nameOff, err := p.u32()
+			if err != nil {
+				return nil, err
+			}
+			if len(stack) == 0 || p.pos+int(size) > len(p.b) || int(nameOff) >= len(p.strings) {
+				return nil, fmt.Errorf("%w: property", ErrCorrupted)
+			}
+			name, _, _ := bytes.Cut(p.strings[nameOff:], []byte{0})
+			n := stack[len(stack)-1]
+			n.Props = append(n.Props, Property{string(name), p.b[p.pos : p.pos+int(size)]})
+			p.pos += int(size)
+			p.align()
+		case tokenNop:
+		case tokenEnd:
+			if root == nil || len(stack) != 0 {
+				return nil, fmt.Errorf("%w: unexpected end", ErrCorrupted)
+			}
+			return root, nil
+		default:
+			return nil, fmt.Errorf("%w: token 0x%x", ErrCorrupted, token)
+		}
+	}
+}
+
+func cells(v []byte) uint64 {
+	var r uint64
+	for i := 0; i+4 <= len(v); i += 4 {
+		r = r<<32 | uint64(binary.BigEndian.Uint32(v[i:]))
+	}
+	return r
+}
+
+// strs decodes value as list of strings, if it looks like it.
+func strs(v []byte) ([]string, bool) {
+	if len(v) == 0 || v[len(v)-1] != 0 {
+		return nil, false
+	}
+	parts := strings.Split(string(v[:len(v)-1]), "\x00")
+	for _, s := range parts {
+		if s == "" {
+			return nil, false
+		}
+		for _, c := range []byte(s) {
+			if c < 0x20 || c > 0x7e {
+				return nil, false
+			}
+		}
+	}
+	return parts, true
+}
+
+// dtsValue formats value in DTS syntax
+func dtsValue(v []byte) string {
+	if ss, ok := strs(v); ok {
+		q := make([]string, len(ss))
+		for i, s := range ss {
+			q[i] = fmt.Sprintf("%q", s)
+		}
+		return strings.Join(q, ", ")
+	}
+	if len(v)%4 == 0 {
+		c := make([]string, 0, len(v)/4)
+		for i := 0; i < len(v); i += 4 {
+			c = append(c, fmt.Sprintf("0x%08x", binary.BigEndian.Uint32(v[i:])))
+		}
+		return "<" + strings.Join(c, " ") + ">"
+	}
+	return fmt.Sprintf("[%x]", v)
+}
+
+// WriteDTS prints tree as source, long binary values are shortened.
+func (t *Tree) WriteDTS(w io.Writer) error {
+	bw := &errWriter{w: w}
+	fmt.Fprintf(bw, "/dts-v1/;\n// version: %d, boot_cpuid: %d\n\n", t.Header.Version, t.Header.BootCPUID)
+	writeNode(bw, t.Root, 0)
+	return bw.err
+}
+
+func writeNode(w io.Writer, n *Node, depth int) {
+	indent := strings.Repeat("\t", depth)
+	name := n.Name
+	if depth == 0 {
+		name = "/"
+	}
+	fmt.Fprintf(w, "%s%s {\n", indent, name)
+	for _, p := range n.Props {
+		switch {
+		case len(p.Value) == 0:
+			fmt.Fprintf(w, "%s\t%s;\n", indent, p.Name)
+		case len(p.Value) > 256:
+			fmt.Fprintf(w, "%s\t%s = [...]; // %d bytes\n", indent, p.Name, len(p.Value))
+		default:
+			fmt.Fprintf(w, "%s\t%s = %s;\n", indent, p.Name, dtsValue(p.Value))
+		}
+	}
+	for _, c := range n.Children {
+		fmt.Fprintln(w)
+		writeNode(w, c, depth+1)
+	}
+	fmt.Fprintf(w, "%s};\n", indent)
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// MarshalJSON writes properties as strings, cells or hex bytes and
+// children as nested objects.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	first := true
+	add := func(k string, v any) error {
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(v)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+		return err
+	}
+	for _, p := range n.Props {
+		var v any
+		if ss, ok := strs(p.Value); ok {
+			v = ss
+			if len(ss) == 1 {
+				v = ss[0]
+			}
+		} else if len(p.Value)%4 == 0 && len(p.Value) <= 256 {
+			c := make([]uint32, 0, len(p.Value)/4)
+			for i := 0; i < len(p.Value); i += 4 {
+				c = append(c, binary.BigEndian.Uint32(p.Value[i:]))
+			}
+			v = c
+		} else {
+			v = fmt.Sprintf("%x", p.Value)
+		}
+		if err := add(p.Name, v); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range n.Children {
+		if err := add(c.Name, c); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type Decoder struct {
+	data   []byte
+	out    io.Writer
+	Config config.DTB
+}
+
+func New(cfg config.DTB) *Decoder {
+	return &Decoder{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (d *Decoder) Open(input string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for decoding: %w", input, err)
+	}
+	defer f.Close()
+	// size of tree is read from header, external data of FIT follows it
+	if _, err := f.Seek(d.Config.Offset, io.SeekStart); err != nil {
+		return err
+	}
+	d.data, err = io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if d.Config.Output == "" {
+		d.Config.Output = strings.TrimSuffix(input, ".itb") + "-images"
+	}
+	return nil
+}
+
+func (d *Decoder) Close() error {
+	return nil
+}
+
+func (d *Decoder) Run(ctx context.Context) error {
+	t, err := Parse(d.data)
+	if err != nil {
+		return err
+	}
+	switch {
+	case d.Config.Partitions:
+		parts := Partitions(t.Root)
+		if len(parts) == 0 {
+			return errors.New("no MTD partitions in tree")
+		}
+		for _, p := range parts {
+			fmt.Fprintln(d.out, p)
+		}
+		return nil
+	case d.Config.Images || d.Config.Extract:
+		return d.images(ctx, t)
+	case d.Config.JSON:
+		enc := json.NewEncoder(d.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t.Root)
+	default:
+		return t.WriteDTS(d.out)
+	}
+}
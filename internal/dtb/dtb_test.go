@@ -0,0 +1,259 @@
+package dtb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func str(s string) []byte {
+	return append([]byte(s), 0)
+}
+
+func u32(v ...uint32) []byte {
+	b := []byte{}
+	for _, c := range v {
+		b = binary.BigEndian.AppendUint32(b, c)
+	}
+	return b
+}
+
+// build flattens tree, it's a minimal dtc
+func build(root *Node) []byte {
+	st := &bytes.Buffer{}
+	strs := &bytes.Buffer{}
+	offsets := map[string]int{}
+	pad := func() {
+		st.Write(make([]byte, (4-st.Len()%4)%4))
+	}
+	var node func(n *Node)
+	node = func(n *Node) {
+		st.Write(u32(tokenBeginNode))
+		st.Write(str(n.Name))
+		pad()
+		for _, p := range n.Props {
+			off, ok := offsets[p.Name]
+			if !ok {
+				off = strs.Len()
+				offsets[p.Name] = off
+				strs.Write(str(p.Name))
+			}
+			st.Write(u32(tokenProp, uint32(len(p.Value)), uint32(off)))
+			st.Write(p.Value)
+			pad()
+		}
+		for _, c := range n.Children {
+			node(c)
+		}
+		st.Write(u32(tokenEndNode))
+	}
+	node(root)
+	st.Write(u32(tokenEnd))
+	rsv := make([]byte, 16)
+	structOff := headerSize + len(rsv)
+	stringsOff := structOff + st.Len()
+	total := stringsOff + strs.Len()
+	b := u32(Magic, uint32(total), uint32(structOff), uint32(stringsOff), headerSize, 17, 16, 0, uint32(strs.Len()), uint32(st.Len()))
+	b = append(b, rsv...)
+	b = append(b, st.Bytes()...)
+	return append(b, strs.Bytes()...)
+}
+
+func testTree() *Node {
+	return &Node{
+		Props: []Property{
+			{"compatible", []byte("vendor,board\x00vendor,soc\x00")},
+			{"#address-cells", u32(1)},
+		},
+		Children: []*Node{
+			{Name: "chosen", Props: []Property{{"bootargs", str("console=ttyS0")}}},
+			{Name: "spi@1000", Children: []*Node{
+				{Name: "flash@0", Props: []Property{{"label", str("spi-nor")}}, Children: []*Node{
+					{Name: "partitions", Props: []Property{
+						{"compatible", str("fixed-partitions")},
+						{"#address-cells", u32(1)},
+						{"#size-cells", u32(1)},
+					}, Children: []*Node{
+						{Name: "partition@0", Props: []Property{
+							{"label", str("u-boot")},
+							{"reg", u32(0, 0x40000)},
+							{"read-only", nil},
+						}},
+						{Name: "partition@40000", Props: []Property{{"reg", u32(0x40000, 0x10000)}}},
+					}},
+				}},
+			}},
+			{Name: "nand@2000", Props: []Property{{"#address-cells", u32(2)}, {"#size-cells", u32(2)}}, Children: []*Node{
+				{Name: "partition@0", Props: []Property{{"label", str("rootfs")}, {"reg", u32(0, 0, 0, 0x100000)}}},
+			}},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	tree, err := Parse(build(testTree()))
+	require.NoError(t, err)
+	require.Equal(t, uint32(17), tree.Header.Version)
+	require.Equal(t, "console=ttyS0", tree.Root.Child("chosen").String("bootargs"))
+	require.Len(t, tree.Root.Children, 3)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, tree.WriteDTS(out))
+	require.Contains(t, out.String(), "/ {\n\tcompatible = \"vendor,board\", \"vendor,soc\";\n\t#address-cells = <0x00000001>;\n")
+	require.Contains(t, out.String(), "\t\t\t\t\tread-only;\n")
+
+	data, err := json.Marshal(tree.Root)
+	require.NoError(t, err)
+	var v map[string]any
+	require.NoError(t, json.Unmarshal(data, &v))
+	require.Equal(t, []any{"vendor,board", "vendor,soc"}, v["compatible"])
+	require.Equal(t, "console=ttyS0", v["chosen"].(map[string]any)["bootargs"])
+
+	_, err = Parse(build(testTree())[:30])
+	require.ErrorIs(t, err, ErrCorrupted)
+	bad := build(testTree())
+	bad[0] = 0
+	_, err = Parse(bad)
+	require.ErrorIs(t, err, ErrMagic)
+	bad = build(testTree())
+	binary.BigEndian.PutUint32(bad[36:], 0xffffffd0)
+	_, err = Parse(bad)
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestPartitions(t *testing.T) {
+	tree, err := Parse(build(testTree()))
+	require.NoError(t, err)
+	parts := Partitions(tree.Root)
+	require.Len(t, parts, 2)
+	require.Equal(t, "mtdparts=spi-nor:0x40000@0x0(u-boot)ro,0x10000@0x40000(partition)", parts[0].String())
+	require.Equal(t, "mtdparts=nand@2000:0x100000@0x0(rootfs)", parts[1].String())
+}
+
+func fitImage() []byte {
+	kernel := []byte("kernel image")
+	fdt := []byte("device tree")
+	sum := sha256.Sum256(fdt)
+	tree := &Node{
+		Props: []Property{{"description", str("FIT")}},
+		Children: []*Node{
+			{Name: "images", Children: []*Node{
+				{Name: "kernel-1", Props: []Property{
+					{"description", str("Linux")},
+					{"data", kernel},
+					{"type", str("kernel")},
+					{"load", u32(0x80008000)},
+				}, Children: []*Node{
+					{Name: "hash-1", Props: []Property{{"algo", str("crc32")}, {"value", u32(crc32.ChecksumIEEE(kernel))}}},
+				}},
+				{Name: "fdt-1", Props: []Property{
+					{"data-offset", u32(0)},
+					{"data-size", u32(uint32(len(fdt)))},
+					{"type", str("flat_dt")},
+				}, Children: []*Node{
+					{Name: "hash-1", Props: []Property{{"algo", str("sha256")}, {"value", sum[:]}}},
+				}},
+			}},
+		},
+	}
+	b := build(tree)
+	b = append(b, make([]byte, (4-len(b)%4)%4)...)
+	return append(b, fdt...)
+}
+
+func TestImages(t *testing.T) {
+	tests := []struct {
+		name  string
+		props []Property
+	}{
+		{"Position", []Property{{"data-position", u32(0xffffffff, 0xfffffff0)}, {"data-size", u32(0x20)}}},
+		{"Offset", []Property{{"data-offset", u32(0xffffffff, 0xfffffff0)}, {"data-size", u32(0x20)}}},
+		{"Size", []Property{{"data-position", u32(0)}, {"data-size", u32(0xffffffff, 0xffffffff)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := build(&Node{Children: []*Node{
+				{Name: "images", Children: []*Node{{Name: "kernel-1", Props: tt.props}}},
+			}})
+			tree, err := Parse(fit)
+			require.NoError(t, err)
+			_, err = tree.Images(fit)
+			require.ErrorIs(t, err, ErrCorrupted)
+		})
+	}
+}
+
+func TestDecoder_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.DTB
+		image   []byte
+		want    []string
+		files   map[string]string
+		wantErr error
+	}{
+		{
+			"FIT images",
+			config.DTB{Images: true, Extract: true},
+			fitImage(),
+			[]string{"kernel-1: Linux\n", "  load: 0x80008000\n", "  verify: OK\n", "fdt-1: \n  type: flat_dt"},
+			map[string]string{"kernel-1.bin": "kernel image", "fdt-1.bin": "device tree"},
+			nil,
+		},
+		{
+			"Broken hash",
+			config.DTB{Images: true},
+			func() []byte {
+				b := fitImage()
+				b[len(b)-1] ^= 1
+				return b
+			}(),
+			[]string{"verify: hash mismatch: sha256 of fdt-1"},
+			nil,
+			ErrHash,
+		},
+		{
+			"Partitions at offset",
+			config.DTB{Partitions: true, Offset: 0x10},
+			append(make([]byte, 0x10), build(testTree())...),
+			[]string{"mtdparts=spi-nor:0x40000@0x0(u-boot)ro,0x10000@0x40000(partition)\n"},
+			nil,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			name := filepath.Join(dir, "image.itb")
+			require.NoError(t, os.WriteFile(name, tt.image, 0666))
+			d := New(tt.cfg)
+			out := &bytes.Buffer{}
+			d.out = out
+			require.NoError(t, d.Open(name))
+			err := d.Run(context.TODO())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			for _, w := range tt.want {
+				require.Contains(t, out.String(), w)
+			}
+			for f, content := range tt.files {
+				data, err := os.ReadFile(filepath.Join(dir, "image-images", f))
+				require.NoError(t, err)
+				require.Equal(t, content, string(data))
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package dtb
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrHash = errors.New("hash mismatch")
+var ErrAlgo = errors.New("unsupported hash algorithm")
+
+type Hash struct {
+	Algo  string
+	Value []byte
+}
+
+type Image struct {
+	Name        string
+	Description string
+	Type        string
+	Arch        string
+	OS          string
+	Compression string
+	Load        uint64
+	Entry       uint64
+	HasLoad     bool
+	HasEntry    bool
+	Data        []byte
+	Hashes      []Hash
+}
+
+func (img Image) String() string {
+	s := fmt.Sprintf("%s: %s", img.Name, img.Description)
+	s += fmt.Sprintf("\n  type: %s, arch: %s, os: %s, compression: %s, size: %d",
+		img.Type, img.Arch, img.OS, img.Compression, len(img.Data))
+	if img.HasLoad {
+		s += fmt.Sprintf("\n  load: 0x%08x", img.Load)
+	}
+	if img.HasEntry {
+		s += fmt.Sprintf("\n  entry: 0x%08x", img.Entry)
+	}
+	return s
+}
+
+// Images returns sub-images of FIT, fit is a whole image with external data.
+func (t *Tree) Images(fit []byte) ([]Image, error) {
+	images := t.Root.Child("images")
+	if images == nil {
+		return nil, errors.New("tree isn't a FIT image")
+	}
+	// external data starts after aligned tree
+	external := int64(t.Header.TotalSize+3) &^ 3
+	res := make([]Image, 0, len(images.Children))
+	for _, n := range images.Children {
+		img := Image{
+			Name:        n.Name,
+			Description: n.String("description"),
+			Type:        n.String("type"),
+			Arch:        n.String("arch"),
+			OS:          n.String("os"),
+			Compression: n.String("compression"),
+		}
+		img.Load, img.HasLoad = n.Uint("load")
+		img.Entry, img.HasEntry = n.Uint("entry")
+		data, ok := n.Prop("data")
+		if !ok {
+			size, okSize := n.Uint("data-size")
+			pos, okPos := n.Uint("data-position")
+			if off, okOff := n.Uint("data-offset"); okOff {
+				pos, okPos = uint64(external)+off, off <= uint64(len(fit))
+			}
+			if !okSize || !okPos || pos > uint64(len(fit)) || size > uint64(len(fit))-pos {
+				return nil, fmt.Errorf("%w: data of image %s", ErrCorrupted, n.Name)
+			}
+			data = fit[pos : pos+size]
+		}
+		img.Data = data
+		for _, c := range n.Children {
+			if !strings.HasPrefix(c.Name, "hash") {
+				continue
+			}
+			v, _ := c.Prop("value")
+			img.Hashes = append(img.Hashes, Hash{c.String("algo"), v})
+		}
+		res = append(res, img)
+	}
+	return res, nil
+}
+
+// Verify checks all hashes of image.
+func (img Image) Verify() error {
+	var errs error
+	for _, h := range img.Hashes {
+		var sum []byte
+		switch h.Algo {
+		case "crc32":
+			sum = binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(img.Data))
+		case "md5":
+			s := md5.Sum(img.Data)
+			sum = s[:]
+		case "sha1":
+			s := sha1.Sum(img.Data)
+			sum = s[:]
+		case "sha256":
+			s := sha256.Sum256(img.Data)
+			sum = s[:]
+		case "sha384":
+			s := sha512.Sum384(img.Data)
+			sum = s[:]
+		case "sha512":
+			s := sha512.Sum512(img.Data)
+			sum = s[:]
+		default:
+			errs = errors.Join(errs, fmt.Errorf("%w: %s", ErrAlgo, h.Algo))
+			continue
+		}
+		if !bytes.Equal(sum, h.Value) {
+			errs = errors.Join(errs, fmt.Errorf("%w: %s of %s", ErrHash, h.Algo, img.Name))
+		}
+	}
+	return errs
+}
+
+func (d *Decoder) images(ctx context.Context, t *Tree) error {
+	images, err := t.Images(d.data)
+	if err != nil {
+		return err
+	}
+	if d.Config.Extract {
+		if err := os.MkdirAll(d.Config.Output, 0755); err != nil {
+			return err
+		}
+	}
+	var errs error
+	for _, img := range images {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		fmt.Fprintln(d.out, img)
+		for _, h := range img.Hashes {
+			fmt.Fprintf(d.out, "  hash %s: %x\n", h.Algo, h.Value)
+		}
+		if err := img.Verify(); err != nil {
+			fmt.Fprintf(d.out, "  verify: %s\n", err)
+			errs = errors.Join(errs, err)
+		} else if len(img.Hashes) > 0 {
+			fmt.Fprintln(d.out, "  verify: OK")
+		}
+		if !d.Config.Extract {
+			continue
+		}
+		name := filepath.Base(img.Name)
+		if name == "." || name == ".." || name == "/" {
+			return fmt.Errorf("%w: name of image %s", ErrCorrupted, img.Name)
+		}
+		if err := os.WriteFile(filepath.Join(d.Config.Output, name+".bin"), img.Data, 0666); err != nil {
+			return err
+		}
+	}
+	return errs
+}
@@ -0,0 +1,88 @@
+package dtb
+
+import (
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/partition"
+)
+
+// Partitions finds "fixed-partitions" nodes and legacy partitions,
+// which are children of flash node.
+func Partitions(root *Node) []partition.Layout {
+	var res []partition.Layout
+	var walk func(n, parent *Node)
+	walk = func(n, parent *Node) {
+		device := n
+		container := n.Name == "partitions" || hasCompatible(n, "fixed-partitions")
+		if container && parent != nil {
+			device = parent
+		}
+		if container || hasPartitions(n) {
+			m := partition.Layout{Device: device.Name}
+			if label := device.String("label"); label != "" {
+				m.Device = label
+			}
+			m.Partitions = partitions(n)
+			if len(m.Partitions) > 0 {
+				res = append(res, m)
+			}
+			if container {
+				return
+			}
+		}
+		for _, c := range n.Children {
+			walk(c, n)
+		}
+	}
+	walk(root, nil)
+	return res
+}
+
+func hasCompatible(n *Node, compatible string) bool {
+	v, _ := n.Prop("compatible")
+	ss, _ := strs(v)
+	for _, s := range ss {
+		if s == compatible {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPartitions checks legacy binding, where partitions are children of flash
+func hasPartitions(n *Node) bool {
+	for _, c := range n.Children {
+		if strings.HasPrefix(c.Name, "partition@") {
+			return true
+		}
+	}
+	return false
+}
+
+func partitions(n *Node) []partition.Partition {
+	addrCells, sizeCells := 1, 1
+	if v, ok := n.Uint("#address-cells"); ok {
+		addrCells = int(v)
+	}
+	if v, ok := n.Uint("#size-cells"); ok {
+		sizeCells = int(v)
+	}
+	var res []partition.Partition
+	for _, c := range n.Children {
+		reg, ok := c.Prop("reg")
+		if !ok || len(reg) != (addrCells+sizeCells)*4 {
+			continue
+		}
+		p := partition.Partition{
+			Offset: int64(cells(reg[:addrCells*4])),
+			Size:   int64(cells(reg[addrCells*4:])),
+			Name:   c.String("label"),
+		}
+		if p.Name == "" {
+			p.Name, _, _ = strings.Cut(c.Name, "@")
+		}
+		_, p.ReadOnly = c.Prop("read-only")
+		res = append(res, p)
+	}
+	return res
+}
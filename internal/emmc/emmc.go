@@ -0,0 +1,315 @@
+package emmc
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrSize = errors.New("size of part doesn't match EXT_CSD")
+var ErrManifest = errors.New("invalid manifest")
+
+const (
+	manifestName = "manifest.json"
+	extCSDName   = "ext_csd"
+)
+
+// Part is a file of eMMC dump in container.
+type Part struct {
+	Name     string `json:"name"`
+	File     string `json:"file"`
+	Size     int64  `json:"size"`
+	Expected int64  `json:"expected,omitempty"`
+	SHA256   string `json:"sha256"`
+	path     string
+}
+
+type Manifest struct {
+	ExtCSD *ExtCSD `json:"ext_csd,omitempty"`
+	Parts  []Part  `json:"parts"`
+}
+
+type Assembler struct {
+	input    string
+	decode   bool
+	manifest Manifest
+	warnings []string
+	out      io.Writer
+	Config   config.EMMC
+}
+
+func New(cfg config.EMMC) *Assembler {
+	return &Assembler{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+// Open checks parts and their sizes by EXT_CSD or container for extraction.
+func (a *Assembler) Open(input string) error {
+	if a.Config.Extract {
+		if _, err := os.Stat(input); err != nil {
+			return fmt.Errorf("can't open container '%s': %w", input, err)
+		}
+		a.input = input
+		if a.Config.Output == "" {
+			a.Config.Output = strings.TrimSuffix(input, ".tar")
+		}
+		return nil
+	}
+	var csd *ExtCSD
+	if a.Config.ExtCSD != "" {
+		f, err := os.Open(a.Config.ExtCSD)
+		if err != nil {
+			return fmt.Errorf("can't open EXT_CSD '%s': %w", a.Config.ExtCSD, err)
+		}
+		raw, err := ReadExtCSD(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if csd, err = ParseExtCSD(raw); err != nil {
+			return err
+		}
+		a.manifest.ExtCSD = csd
+		// raw register is archived too
+		a.manifest.Parts = append(a.manifest.Parts, Part{Name: extCSDName, path: a.Config.ExtCSD, Expected: extCSDSize})
+	}
+	for _, p := range []struct {
+		name, path string
+	}{
+		{"user", a.Config.User},
+		{"boot0", a.Config.Boot0},
+		{"boot1", a.Config.Boot1},
+		{"rpmb", a.Config.RPMB},
+	} {
+		if p.path == "" {
+			continue
+		}
+		part := Part{Name: p.name, path: p.path}
+		if csd != nil {
+			switch p.name {
+			case "user":
+				part.Expected = csd.UserSize
+			case "boot0", "boot1":
+				part.Expected = csd.BootSize
+			case "rpmb":
+				part.Expected = csd.RPMBSize
+			}
+		}
+		a.manifest.Parts = append(a.manifest.Parts, part)
+	}
+	if len(a.manifest.Parts) == 0 {
+		return errors.New("set parts of dump or EXT_CSD")
+	}
+	// single EXT_CSD without output is only decoded
+	if len(a.manifest.Parts) == 1 && csd != nil && a.Config.Output == "" {
+		a.decode = true
+		return nil
+	}
+	for i := range a.manifest.Parts {
+		p := &a.manifest.Parts[i]
+		fi, err := os.Stat(p.path)
+		if err != nil {
+			return fmt.Errorf("can't open %s '%s': %w", p.Name, p.path, err)
+		}
+		p.Size = fi.Size()
+		p.File = p.Name + ".bin"
+		if p.Name == extCSDName && p.Size != extCSDSize {
+			// register in hex is stored as binary
+			p.Size = extCSDSize
+		}
+		if p.Expected > 0 && p.Size != p.Expected {
+			err := fmt.Errorf("%w: %s 0x%x, expected 0x%x", ErrSize, p.Name, p.Size, p.Expected)
+			if !a.Config.Force {
+				return err
+			}
+			a.warnings = append(a.warnings, err.Error())
+		}
+	}
+	if a.Config.Output == "" {
+		a.Config.Output = "emmc.tar"
+	}
+	return nil
+}
+
+func (a *Assembler) Close() error {
+	return nil
+}
+
+func (a *Assembler) Run(ctx context.Context) error {
+	if a.Config.Extract {
+		f, err := os.Open(a.input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		m, err := Extract(ctx, f, a.Config.Output)
+		if err != nil {
+			return err
+		}
+		a.print(m)
+		return nil
+	}
+	if a.decode {
+		fmt.Fprint(a.out, a.manifest.ExtCSD)
+		return nil
+	}
+	for _, w := range a.warnings {
+		fmt.Fprintln(a.out, "warning:", w)
+	}
+	f, err := os.OpenFile(a.Config.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	err = a.Pack(ctx, f)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	a.print(a.manifest)
+	return nil
+}
+
+func (a *Assembler) print(m Manifest) {
+	if m.ExtCSD != nil {
+		fmt.Fprint(a.out, m.ExtCSD)
+	}
+	for _, p := range m.Parts {
+		fmt.Fprintf(a.out, "%-8s 0x%010x %s %s\n", p.Name, p.Size, p.SHA256, p.File)
+	}
+}
+
+// content returns reader of part, EXT_CSD is converted to binary
+func (p Part) content() (io.ReadCloser, error) {
+	f, err := os.Open(p.path)
+	if err != nil || p.Name != extCSDName {
+		return f, err
+	}
+	defer f.Close()
+	raw, err := ReadExtCSD(f)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(raw)), nil
+}
+
+// Pack writes container in tar format, manifest is the first file.
+func (a *Assembler) Pack(ctx context.Context, w io.Writer) error {
+	for i := range a.manifest.Parts {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		p := &a.manifest.Parts[i]
+		r, err := p.content()
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, r)
+		if err := errors.Join(err, r.Close()); err != nil {
+			return err
+		}
+		p.SHA256 = hex.EncodeToString(h.Sum(nil))
+	}
+	manifest, err := json.MarshalIndent(a.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(w)
+	err = tw.WriteHeader(&tar.Header{Name: manifestName, Size: int64(len(manifest)), Mode: 0644, Format: tar.FormatPAX})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return err
+	}
+	for _, p := range a.manifest.Parts {
+		err := tw.WriteHeader(&tar.Header{Name: p.File, Size: p.Size, Mode: 0644, Format: tar.FormatPAX})
+		if err != nil {
+			return err
+		}
+		r, err := p.content()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, r)
+		if err := errors.Join(err, r.Close()); err != nil {
+			return fmt.Errorf("%s: %w", p.Name, err)
+		}
+	}
+	return tw.Close()
+}
+
+// Extract writes parts of container in dir and verifies their hashes by manifest.
+func Extract(ctx context.Context, r io.Reader, dir string) (Manifest, error) {
+	var m Manifest
+	tr := tar.NewReader(r)
+	h, err := tr.Next()
+	if err != nil {
+		return m, fmt.Errorf("%w: %w", ErrManifest, err)
+	}
+	if h.Name != manifestName {
+		return m, fmt.Errorf("%w: the first file is '%s'", ErrManifest, h.Name)
+	}
+	if err := json.NewDecoder(tr).Decode(&m); err != nil {
+		return m, fmt.Errorf("%w: %w", ErrManifest, err)
+	}
+	parts := map[string]Part{}
+	for _, p := range m.Parts {
+		if p.File != filepath.Base(p.File) {
+			return m, fmt.Errorf("%w: file name '%s'", ErrManifest, p.File)
+		}
+		parts[p.File] = p
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return m, err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return m, ctx.Err()
+		default:
+		}
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return m, err
+		}
+		p, ok := parts[h.Name]
+		if !ok {
+			return m, fmt.Errorf("%w: file '%s' isn't in manifest", ErrManifest, h.Name)
+		}
+		delete(parts, h.Name)
+		f, err := os.OpenFile(filepath.Join(dir, p.File), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			return m, err
+		}
+		sum := sha256.New()
+		n, err := io.Copy(io.MultiWriter(f, sum), tr)
+		if err := errors.Join(err, f.Close()); err != nil {
+			return m, err
+		}
+		if n != p.Size || hex.EncodeToString(sum.Sum(nil)) != p.SHA256 {
+			return m, fmt.Errorf("%w: %s is damaged", ErrManifest, p.File)
+		}
+	}
+	for name := range parts {
+		return m, fmt.Errorf("%w: file '%s' is missing", ErrManifest, name)
+	}
+	return m, nil
+}
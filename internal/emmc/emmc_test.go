@@ -0,0 +1,166 @@
+package emmc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func extCSD() []byte {
+	b := make([]byte, extCSDSize)
+	b[extCSDRev] = 8
+	binary.LittleEndian.PutUint32(b[secCount:], 16)
+	b[bootSizeMult] = 1
+	b[rpmbSizeMult] = 1
+	b[partitionConfig] = 0x48
+	b[hcEraseGrpSize] = 1
+	b[hcWPGrpSize] = 2
+	b[gpSizeMult] = 3
+	b[lifeTimeEstA] = 1
+	b[lifeTimeEstB] = 0x0b
+	b[preEOLInfo] = 1
+	return b
+}
+
+func TestParseExtCSD(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *ExtCSD
+		err  error
+	}{
+		{
+			"Decode",
+			extCSD(),
+			&ExtCSD{
+				Revision:         8,
+				Version:          "5.1",
+				SecCount:         16,
+				UserSize:         16 * sector,
+				BootSize:         bootUnit,
+				RPMBSize:         bootUnit,
+				GPSizes:          [4]int64{3 * 2 * 512 << 10},
+				PartitionConfig:  0x48,
+				BootPartition:    "boot0",
+				BootAck:          true,
+				HCEraseGroupSize: 512 << 10,
+				HCWPGroupSize:    2,
+				PreEOL:           "normal",
+				LifeTimeA:        "0-10%",
+				LifeTimeB:        "exceeded",
+			},
+			nil,
+		},
+		{"Empty", make([]byte, extCSDSize), nil, ErrExtCSD},
+		{"Short", make([]byte, 16), nil, ErrExtCSD},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExtCSD(tt.data)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestReadExtCSD(t *testing.T) {
+	raw := extCSD()
+	text := hex.EncodeToString(raw[:256]) + "\n" + hex.EncodeToString(raw[256:]) + "\n"
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"Binary", raw, nil},
+		{"Hex", []byte(text), nil},
+		{"Invalid", []byte("ext_csd"), ErrExtCSD},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadExtCSD(bytes.NewReader(tt.data))
+			require.ErrorIs(t, err, tt.err)
+			if err == nil {
+				require.Equal(t, raw, got)
+			}
+		})
+	}
+}
+
+func TestAssembler(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return path
+	}
+	parts := map[string][]byte{
+		"user.bin":  bytes.Repeat([]byte{1}, 16*sector),
+		"boot0.bin": bytes.Repeat([]byte{2}, bootUnit),
+		"boot1.bin": bytes.Repeat([]byte{3}, bootUnit),
+		"rpmb.bin":  bytes.Repeat([]byte{4}, bootUnit),
+	}
+	cfg := config.EMMC{
+		User:   write("user.img", parts["user.bin"]),
+		Boot0:  write("boot0.img", parts["boot0.bin"]),
+		Boot1:  write("boot1.img", parts["boot1.bin"]),
+		RPMB:   write("rpmb.img", parts["rpmb.bin"]),
+		ExtCSD: write("ext_csd.txt", []byte(hex.EncodeToString(extCSD()))),
+		Output: filepath.Join(dir, "emmc.tar"),
+	}
+	parts["ext_csd.bin"] = extCSD()
+
+	t.Run("Pack and extract", func(t *testing.T) {
+		a := New(cfg)
+		a.out = &bytes.Buffer{}
+		require.NoError(t, a.Open(""))
+		require.NoError(t, a.Run(context.Background()))
+
+		x := New(config.EMMC{Extract: true})
+		x.out = &bytes.Buffer{}
+		require.NoError(t, x.Open(cfg.Output))
+		require.Equal(t, filepath.Join(dir, "emmc"), x.Config.Output)
+		require.NoError(t, x.Run(context.Background()))
+		for name, data := range parts {
+			got, err := os.ReadFile(filepath.Join(dir, "emmc", name))
+			require.NoError(t, err)
+			require.Equal(t, data, got, name)
+		}
+	})
+	t.Run("Size mismatch", func(t *testing.T) {
+		c := cfg
+		c.Boot1 = write("short.img", make([]byte, 16))
+		require.ErrorIs(t, New(c).Open(""), ErrSize)
+
+		c.Force = true
+		a := New(c)
+		out := &bytes.Buffer{}
+		a.out = out
+		require.NoError(t, a.Open(""))
+		require.NoError(t, a.Run(context.Background()))
+		require.Contains(t, out.String(), "warning: size of part doesn't match EXT_CSD: boot1 0x10, expected 0x20000")
+	})
+	t.Run("Decode only", func(t *testing.T) {
+		a := New(config.EMMC{ExtCSD: cfg.ExtCSD})
+		out := &bytes.Buffer{}
+		a.out = out
+		require.NoError(t, a.Open(""))
+		require.NoError(t, a.Run(context.Background()))
+		require.Contains(t, out.String(), "EXT_CSD revision 8 (eMMC 5.1)")
+	})
+	t.Run("Damaged container", func(t *testing.T) {
+		data, err := os.ReadFile(cfg.Output)
+		require.NoError(t, err)
+		i := bytes.Index(data, parts["rpmb.bin"][:64])
+		data[i] ^= 0xff
+		_, err = Extract(context.Background(), bytes.NewReader(data), filepath.Join(dir, "damaged"))
+		require.ErrorIs(t, err, ErrManifest)
+	})
+}
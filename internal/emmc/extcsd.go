@@ -0,0 +1,185 @@
+package emmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrExtCSD = errors.New("invalid EXT_CSD")
+
+const (
+	extCSDSize = 512
+	// units of sizes of boot and RPMB partitions
+	bootUnit = 128 << 10
+	sector   = 512
+)
+
+// offsets of fields in EXT_CSD
+const (
+	gpSizeMult       = 143
+	partitionSupport = 160
+	rpmbSizeMult     = 168
+	bootWP           = 173
+	eraseGroupDef    = 175
+	bootBusCond      = 177
+	partitionConfig  = 179
+	extCSDRev        = 192
+	deviceType       = 196
+	secCount         = 212
+	hcWPGrpSize      = 221
+	hcEraseGrpSize   = 224
+	bootSizeMult     = 226
+	preEOLInfo       = 267
+	lifeTimeEstA     = 268
+	lifeTimeEstB     = 269
+)
+
+var versions = map[byte]string{
+	0: "4.0",
+	1: "4.1",
+	2: "4.2",
+	3: "4.3",
+	5: "4.41",
+	6: "4.5",
+	7: "5.0",
+	8: "5.1",
+}
+
+var bootPartitions = map[byte]string{
+	0: "none",
+	1: "boot0",
+	2: "boot1",
+	7: "user",
+}
+
+var preEOL = map[byte]string{
+	0: "undefined",
+	1: "normal",
+	2: "warning",
+	3: "urgent",
+}
+
+// ExtCSD is a decoded extended CSD register of eMMC.
+type ExtCSD struct {
+	Revision         int      `json:"revision"`
+	Version          string   `json:"version"`
+	SecCount         uint32   `json:"sec_count"`
+	UserSize         int64    `json:"user_size"`
+	BootSize         int64    `json:"boot_size"`
+	RPMBSize         int64    `json:"rpmb_size"`
+	GPSizes          [4]int64 `json:"gp_sizes"`
+	PartitionSupport byte     `json:"partition_support"`
+	PartitionConfig  byte     `json:"partition_config"`
+	BootPartition    string   `json:"boot_partition"`
+	BootAck          bool     `json:"boot_ack"`
+	BootBusCond      byte     `json:"boot_bus_conditions"`
+	BootWP           byte     `json:"boot_wp"`
+	EraseGroupDef    bool     `json:"erase_group_def"`
+	HCEraseGroupSize int64    `json:"hc_erase_group_size"`
+	HCWPGroupSize    int64    `json:"hc_wp_group_size"`
+	DeviceType       byte     `json:"device_type"`
+	PreEOL           string   `json:"pre_eol"`
+	LifeTimeA        string   `json:"life_time_a"`
+	LifeTimeB        string   `json:"life_time_b"`
+}
+
+// lifeTime decodes estimation of used life time in steps of 10%
+func lifeTime(v byte) string {
+	switch {
+	case v == 0:
+		return "undefined"
+	case v <= 0x0a:
+		return fmt.Sprintf("%d-%d%%", (v-1)*10, v*10)
+	case v == 0x0b:
+		return "exceeded"
+	}
+	return fmt.Sprintf("0x%02x", v)
+}
+
+// ReadExtCSD reads EXT_CSD as 512 bytes of binary or as hex text,
+// like ext_csd file in debugfs of Linux.
+func ReadExtCSD(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == extCSDSize {
+		return b, nil
+	}
+	text := strings.Join(strings.Fields(string(b)), "")
+	raw, err := hex.DecodeString(text)
+	if err != nil || len(raw) != extCSDSize {
+		return nil, fmt.Errorf("%w: should be %d bytes in binary or hex", ErrExtCSD, extCSDSize)
+	}
+	return raw, nil
+}
+
+// ParseExtCSD decodes fields of EXT_CSD.
+func ParseExtCSD(b []byte) (*ExtCSD, error) {
+	if len(b) != extCSDSize {
+		return nil, fmt.Errorf("%w: size %d", ErrExtCSD, len(b))
+	}
+	if bytes.Equal(b, make([]byte, extCSDSize)) || bytes.Equal(b, bytes.Repeat([]byte{0xff}, extCSDSize)) {
+		return nil, fmt.Errorf("%w: register is empty", ErrExtCSD)
+	}
+	e := &ExtCSD{
+		Revision:         int(b[extCSDRev]),
+		Version:          versions[b[extCSDRev]],
+		SecCount:         binary.LittleEndian.Uint32(b[secCount:]),
+		BootSize:         int64(b[bootSizeMult]) * bootUnit,
+		RPMBSize:         int64(b[rpmbSizeMult]) * bootUnit,
+		PartitionSupport: b[partitionSupport],
+		PartitionConfig:  b[partitionConfig],
+		BootAck:          b[partitionConfig]&0x40 != 0,
+		BootBusCond:      b[bootBusCond],
+		BootWP:           b[bootWP],
+		EraseGroupDef:    b[eraseGroupDef]&1 != 0,
+		HCEraseGroupSize: int64(b[hcEraseGrpSize]) * 512 << 10,
+		HCWPGroupSize:    int64(b[hcWPGrpSize]),
+		DeviceType:       b[deviceType],
+		PreEOL:           preEOL[b[preEOLInfo]],
+		LifeTimeA:        lifeTime(b[lifeTimeEstA]),
+		LifeTimeB:        lifeTime(b[lifeTimeEstB]),
+	}
+	if e.Version == "" {
+		e.Version = "unknown"
+	}
+	e.UserSize = int64(e.SecCount) * sector
+	e.BootPartition = bootPartitions[b[partitionConfig]>>3&7]
+	if e.BootPartition == "" {
+		e.BootPartition = fmt.Sprintf("reserved %d", b[partitionConfig]>>3&7)
+	}
+	if e.PreEOL == "" {
+		e.PreEOL = fmt.Sprintf("0x%02x", b[preEOLInfo])
+	}
+	// sizes of general purpose partitions are in groups of write protection
+	for i := range e.GPSizes {
+		m := b[gpSizeMult+3*i:]
+		mult := int64(m[2])<<16 | int64(m[1])<<8 | int64(m[0])
+		e.GPSizes[i] = mult * e.HCWPGroupSize * e.HCEraseGroupSize
+	}
+	return e, nil
+}
+
+func (e *ExtCSD) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "EXT_CSD revision %d (eMMC %s)\n", e.Revision, e.Version)
+	fmt.Fprintf(&b, "  user area:        0x%x bytes (%d sectors)\n", e.UserSize, e.SecCount)
+	fmt.Fprintf(&b, "  boot partitions:  2 x 0x%x bytes\n", e.BootSize)
+	fmt.Fprintf(&b, "  RPMB:             0x%x bytes\n", e.RPMBSize)
+	for i, s := range e.GPSizes {
+		if s > 0 {
+			fmt.Fprintf(&b, "  GP%d:              0x%x bytes\n", i+1, s)
+		}
+	}
+	fmt.Fprintf(&b, "  boot from:        %s, ack %t, bus conditions 0x%02x, write protection 0x%02x\n",
+		e.BootPartition, e.BootAck, e.BootBusCond, e.BootWP)
+	fmt.Fprintf(&b, "  erase group:      0x%x bytes, high capacity %t\n", e.HCEraseGroupSize, e.EraseGroupDef)
+	fmt.Fprintf(&b, "  life time:        A %s, B %s, pre EOL %s\n", e.LifeTimeA, e.LifeTimeB, e.PreEOL)
+	return b.String()
+}
@@ -0,0 +1,259 @@
+package entropy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrFormat = errors.New("unknown output format")
+
+const (
+	plotHeight = 256
+	plotWidth  = 1024
+	// levels of sparkline from low to high entropy
+	levels = " .:-=+*#%@"
+)
+
+type Block struct {
+	Offset  int64   `json:"offset"`
+	Entropy float64 `json:"entropy"`
+	// Erased is true when all bytes are 0xFF or 0x00
+	Erased bool `json:"erased,omitempty"`
+	Value  byte `json:"-"`
+}
+
+type Range struct {
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	Kind  string `json:"kind"`
+}
+
+func (r Range) String() string {
+	return fmt.Sprintf("0x%08x-0x%08x %s", r.Start, r.End, r.Kind)
+}
+
+type Report struct {
+	BlockSize int     `json:"block_size"`
+	Blocks    []Block `json:"blocks"`
+	Ranges    []Range `json:"ranges"`
+}
+
+type Mapper struct {
+	input  io.ReadCloser
+	out    io.Writer
+	Config config.Entropy
+}
+
+func New(cfg config.Entropy) *Mapper {
+	return &Mapper{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (m *Mapper) Open(input string) error {
+	switch m.Config.Format {
+	case "csv", "json", "ranges", "none":
+	default:
+		return fmt.Errorf("%w: %s", ErrFormat, m.Config.Format)
+	}
+	if m.Config.BlockSize <= 0 {
+		return fmt.Errorf("invalid block size %d", m.Config.BlockSize)
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for entropy: %w", input, err)
+	}
+	m.input = in
+	return nil
+}
+
+func (m *Mapper) Close() error {
+	if m.input == nil {
+		return nil
+	}
+	return m.input.Close()
+}
+
+func (m *Mapper) Run(ctx context.Context) error {
+	r, err := m.Map(ctx, m.input)
+	if err != nil {
+		return err
+	}
+	switch m.Config.Format {
+	case "csv":
+		w := bufio.NewWriter(m.out)
+		fmt.Fprintln(w, "offset,entropy")
+		for _, b := range r.Blocks {
+			fmt.Fprintf(w, "0x%08x,%.4f\n", b.Offset, b.Entropy)
+		}
+		err = w.Flush()
+	case "json":
+		enc := json.NewEncoder(m.out)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(r)
+	case "ranges":
+		for _, rng := range r.Ranges {
+			fmt.Fprintln(m.out, rng)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if m.Config.Sparkline {
+		m.sparkline(r)
+	}
+	if m.Config.PNG != "" {
+		return m.plot(r)
+	}
+	return nil
+}
+
+// Map computes entropy of every block in stream and finds ranges
+// of high entropy and erased blocks.
+func (m *Mapper) Map(ctx context.Context, r io.Reader) (*Report, error) {
+	rep := &Report{BlockSize: m.Config.BlockSize}
+	buf := make([]byte, m.Config.BlockSize)
+	br := bufio.NewReader(r)
+	for off := int64(0); ; {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		n, err := io.ReadFull(br, buf)
+		if n == 0 {
+			break
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		b := Block{Offset: off, Entropy: Shannon(buf[:n])}
+		b.Value, b.Erased = erased(buf[:n])
+		rep.Blocks = append(rep.Blocks, b)
+		m.addRange(rep, b, off+int64(n))
+		off += int64(n)
+	}
+	return rep, nil
+}
+
+func (m *Mapper) addRange(rep *Report, b Block, end int64) {
+	var kind string
+	switch {
+	case b.Erased:
+		kind = fmt.Sprintf("erased 0x%02x", b.Value)
+	case b.Entropy >= m.Config.High:
+		kind = "high"
+	default:
+		return
+	}
+	if n := len(rep.Ranges); n > 0 && rep.Ranges[n-1].End == b.Offset && rep.Ranges[n-1].Kind == kind {
+		rep.Ranges[n-1].End = end
+		return
+	}
+	rep.Ranges = append(rep.Ranges, Range{Start: b.Offset, End: end, Kind: kind})
+}
+
+// Shannon returns entropy of data in bits per byte.
+func Shannon(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	var e float64
+	total := float64(len(data))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / total
+		e -= p * math.Log2(p)
+	}
+	return e
+}
+
+func erased(data []byte) (byte, bool) {
+	v := data[0]
+	if v != 0x00 && v != 0xFF {
+		return v, false
+	}
+	for _, b := range data {
+		if b != v {
+			return v, false
+		}
+	}
+	return v, true
+}
+
+// sparkline prints blocks as lines of characters, each line starts with offset
+func (m *Mapper) sparkline(r *Report) {
+	width := max(m.Config.Width, 1)
+	w := bufio.NewWriter(m.out)
+	defer w.Flush()
+	for i, b := range r.Blocks {
+		if i%width == 0 {
+			if i != 0 {
+				fmt.Fprintln(w, "|")
+			}
+			fmt.Fprintf(w, "0x%08x |", b.Offset)
+		}
+		level := int(b.Entropy / 8 * float64(len(levels)-1))
+		w.WriteByte(levels[min(level, len(levels)-1)])
+	}
+	if len(r.Blocks) > 0 {
+		fmt.Fprintln(w, "|")
+	}
+}
+
+// plot draws entropy of blocks, every column shows maximum of some blocks
+func (m *Mapper) plot(r *Report) error {
+	if len(r.Blocks) == 0 {
+		return errors.New("nothing to plot")
+	}
+	perColumn := (len(r.Blocks) + plotWidth - 1) / plotWidth
+	width := (len(r.Blocks) + perColumn - 1) / perColumn
+	img := image.NewRGBA(image.Rect(0, 0, width, plotHeight))
+	bg := color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}
+	for x := 0; x < width; x++ {
+		var e float64
+		erased := true
+		for _, b := range r.Blocks[x*perColumn : min((x+1)*perColumn, len(r.Blocks))] {
+			e = max(e, b.Entropy)
+			erased = erased && b.Erased
+		}
+		c := color.RGBA{0x20, 0x60, 0xC0, 0xFF}
+		switch {
+		case erased:
+			c = color.RGBA{0xA0, 0xA0, 0xA0, 0xFF}
+		case e >= m.Config.High:
+			c = color.RGBA{0xD0, 0x20, 0x20, 0xFF}
+		}
+		top := plotHeight - 1 - int(e/8*float64(plotHeight-1))
+		for y := 0; y < plotHeight; y++ {
+			if y >= top {
+				img.Set(x, y, c)
+			} else {
+				img.Set(x, y, bg)
+			}
+		}
+	}
+	f, err := os.OpenFile(m.Config.PNG, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(png.Encode(f, img), f.Close())
+}
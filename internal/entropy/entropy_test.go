@@ -0,0 +1,133 @@
+package entropy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func TestShannon(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want float64
+	}{
+		{"Empty", nil, 0},
+		{"Same bytes", bytes.Repeat([]byte{0x55}, 100), 0},
+		{"Two values", bytes.Repeat([]byte{0, 1}, 100), 1},
+		{"All values", func() []byte {
+			b := make([]byte, 256)
+			for i := range b {
+				b[i] = byte(i)
+			}
+			return b
+		}(), 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, Shannon(tt.data), 1e-9)
+		})
+	}
+}
+
+func testImage() []byte {
+	// every value once per block
+	random := make([]byte, 0x300)
+	for i := range random {
+		random[i] = byte(i * 167)
+	}
+	img := bytes.Repeat([]byte{0xFF}, 0x200)
+	img = append(img, random...)
+	img = append(img, bytes.Repeat([]byte("text"), 0x40)...)
+	img = append(img, make([]byte, 0x180)...)
+	return img
+}
+
+func TestMapper_Map(t *testing.T) {
+	m := New(config.Entropy{BlockSize: 0x100, High: 7})
+	r, err := m.Map(context.TODO(), bytes.NewReader(testImage()))
+	require.NoError(t, err)
+	require.Len(t, r.Blocks, 8)
+	require.Equal(t, int64(0x700), r.Blocks[7].Offset)
+	require.Equal(t, []Range{
+		{0x000, 0x200, "erased 0xff"},
+		{0x200, 0x500, "high"},
+		{0x600, 0x780, "erased 0x00"},
+	}, r.Ranges)
+}
+
+func TestMapper_Run(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   config.Entropy
+		check func(t *testing.T, out string, m *Mapper)
+	}{
+		{
+			"CSV",
+			config.Entropy{Format: "csv"},
+			func(t *testing.T, out string, m *Mapper) {
+				require.Contains(t, out, "offset,entropy\n0x00000000,0.0000\n")
+				require.Contains(t, out, "0x00000500,1.5000\n")
+			},
+		},
+		{
+			"JSON",
+			config.Entropy{Format: "json"},
+			func(t *testing.T, out string, m *Mapper) {
+				var r Report
+				require.NoError(t, json.Unmarshal([]byte(out), &r))
+				require.Len(t, r.Ranges, 3)
+			},
+		},
+		{
+			"Ranges and sparkline",
+			config.Entropy{Format: "ranges", Sparkline: true, Width: 6},
+			func(t *testing.T, out string, m *Mapper) {
+				require.Equal(t, "0x00000000-0x00000200 erased 0xff\n"+
+					"0x00000200-0x00000500 high\n"+
+					"0x00000600-0x00000780 erased 0x00\n"+
+					"0x00000000 |  @@@.|\n"+
+					"0x00000600 |  |\n", out)
+			},
+		},
+		{
+			"PNG",
+			config.Entropy{Format: "none", PNG: "plot.png"},
+			func(t *testing.T, out string, m *Mapper) {
+				f, err := os.Open(m.Config.PNG)
+				require.NoError(t, err)
+				defer f.Close()
+				img, err := png.Decode(f)
+				require.NoError(t, err)
+				require.Equal(t, 8, img.Bounds().Dx())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.BlockSize = 0x100
+			tt.cfg.High = 7
+			if tt.cfg.PNG != "" {
+				tt.cfg.PNG = filepath.Join(t.TempDir(), tt.cfg.PNG)
+			}
+			name := filepath.Join(t.TempDir(), "dump.bin")
+			require.NoError(t, os.WriteFile(name, testImage(), 0666))
+			m := New(tt.cfg)
+			out := &bytes.Buffer{}
+			m.out = out
+			require.NoError(t, m.Open(name))
+			defer m.Close()
+			require.NoError(t, m.Run(context.TODO()))
+			tt.check(t, out.String(), m)
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrNotFound = errors.New("environment isn't found")
+var ErrSize = errors.New("variables don't fit in environment")
+
+const (
+	crcSize = 4
+	// redundant environment has flag byte after CRC
+	flagSize = 1
+)
+
+// DefaultSizes are usual values of CONFIG_ENV_SIZE.
+var DefaultSizes = []int{0x400, 0x800, 0x1000, 0x2000, 0x4000, 0x8000, 0x10000, 0x20000, 0x40000}
+
+type Var struct {
+	Name  string
+	Value string
+}
+
+type Block struct {
+	Offset    int64
+	Size      int
+	CRC       uint32
+	Redundant bool
+	Flag      byte
+	BigEndian bool
+	Vars      []Var
+	pad       byte
+}
+
+func (b *Block) header() int {
+	if b.Redundant {
+		return crcSize + flagSize
+	}
+	return crcSize
+}
+
+func (b *Block) Get(name string) (string, bool) {
+	for _, v := range b.Vars {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces value of variable or appends new one.
+func (b *Block) Set(name, value string) {
+	for i, v := range b.Vars {
+		if v.Name == name {
+			b.Vars[i].Value = value
+			return
+		}
+	}
+	b.Vars = append(b.Vars, Var{name, value})
+}
+
+func (b *Block) Delete(name string) bool {
+	for i, v := range b.Vars {
+		if v.Name == name {
+			b.Vars = append(b.Vars[:i], b.Vars[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Bytes encodes block with new CRC, the rest of block is padded as in original.
+func (b *Block) Bytes() ([]byte, error) {
+	h := b.header()
+	buf := bytes.NewBuffer(make([]byte, h, b.Size))
+	for _, v := range b.Vars {
+		buf.WriteString(v.Name)
+		buf.WriteByte('=')
+		buf.WriteString(v.Value)
+		buf.WriteByte(0)
+	}
+	buf.WriteByte(0)
+	if buf.Len() > b.Size {
+		return nil, fmt.Errorf("%w: 0x%x > 0x%x", ErrSize, buf.Len(), b.Size)
+	}
+	data := buf.Bytes()
+	data = append(data, bytes.Repeat([]byte{b.pad}, b.Size-len(data))...)
+	if b.Redundant {
+		data[crcSize] = b.Flag
+	}
+	b.CRC = crc32.ChecksumIEEE(data[h:])
+	b.order().PutUint32(data, b.CRC)
+	return data, nil
+}
+
+func (b *Block) order() binary.ByteOrder {
+	if b.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (b *Block) String() string {
+	s := fmt.Sprintf("0x%08x env size 0x%x crc 0x%08x", b.Offset, b.Size, b.CRC)
+	if b.Redundant {
+		s += fmt.Sprintf(" redundant flag 0x%02x", b.Flag)
+	}
+	return s
+}
+
+// Newer compares flags of redundant copies, flag is a counter,
+// which can wrap around.
+func (b *Block) Newer(other *Block) bool {
+	return int8(b.Flag-other.Flag) > 0
+}
+
+// Find locates environment blocks at aligned offsets by their CRC.
+func Find(ctx context.Context, data []byte, align int, sizes []int) ([]*Block, error) {
+	var res []*Block
+	for off := 0; off+crcSize < len(data); off += align {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if b := probe(data, off, sizes); b != nil {
+			res = append(res, b)
+			off += (b.Size - 1) / align * align
+		}
+	}
+	return res, nil
+}
+
+func probe(data []byte, off int, sizes []int) *Block {
+	for _, h := range []int{crcSize, crcSize + flagSize} {
+		if off+h >= len(data) || !looksLikeVars(data[off+h:]) {
+			continue
+		}
+		le := binary.LittleEndian.Uint32(data[off:])
+		be := binary.BigEndian.Uint32(data[off:])
+		var crc uint32
+		pos := off + h
+		// CRC is computed incrementally for growing sizes
+		for _, size := range sizes {
+			if off+size > len(data) {
+				break
+			}
+			crc = crc32.Update(crc, crc32.IEEETable, data[pos:off+size])
+			pos = off + size
+			if crc != le && crc != be {
+				continue
+			}
+			b := &Block{
+				Offset:    int64(off),
+				Size:      size,
+				CRC:       crc,
+				Redundant: h > crcSize,
+				Flag:      data[off+crcSize],
+				BigEndian: crc != le,
+			}
+			b.Vars, b.pad = parseVars(data[off+h : off+size])
+			return b
+		}
+	}
+	return nil
+}
+
+// looksLikeVars checks, that data begins with name of variable
+func looksLikeVars(b []byte) bool {
+	for i, c := range b[:min(len(b), 64)] {
+		switch {
+		case c == '=' && i > 0:
+			return true
+		case c <= 0x20 || c >= 0x7f || c == '=':
+			return false
+		}
+	}
+	return false
+}
+
+func parseVars(b []byte) ([]Var, byte) {
+	var vars []Var
+	for len(b) > 0 {
+		entry, rest, _ := bytes.Cut(b, []byte{0})
+		b = rest
+		if len(entry) == 0 {
+			break
+		}
+		name, value, _ := strings.Cut(string(entry), "=")
+		vars = append(vars, Var{name, value})
+	}
+	var pad byte
+	if len(b) > 0 {
+		pad = b[0]
+	}
+	return vars, pad
+}
+
+type Editor struct {
+	data   []byte
+	out    io.Writer
+	Config config.Env
+}
+
+func New(cfg config.Env) *Editor {
+	return &Editor{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (e *Editor) Open(input string) error {
+	var err error
+	e.data, err = os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for env: %w", input, err)
+	}
+	if e.Config.Align <= 0 {
+		return fmt.Errorf("invalid align %d", e.Config.Align)
+	}
+	for _, s := range e.Config.Set {
+		if name, _, ok := strings.Cut(s, "="); !ok || name == "" {
+			return fmt.Errorf("set variable as name=value, not '%s'", s)
+		}
+	}
+	if e.Config.Output == "" {
+		e.Config.Output = strings.TrimSuffix(input, ".bin") + "-patched.bin"
+	}
+	return nil
+}
+
+func (e *Editor) Close() error {
+	return nil
+}
+
+func (e *Editor) Run(ctx context.Context) error {
+	sizes := DefaultSizes
+	if e.Config.Size > 0 {
+		sizes = []int{e.Config.Size}
+	}
+	blocks, err := Find(ctx, e.data, e.Config.Align, sizes)
+	if err != nil {
+		return err
+	}
+	if len(blocks) == 0 {
+		return ErrNotFound
+	}
+	edit := len(e.Config.Set) > 0 || len(e.Config.Delete) > 0
+	for i := 0; i < len(blocks); i++ {
+		copies := []*Block{blocks[i]}
+		if i+1 < len(blocks) && redundantPair(blocks[i], blocks[i+1]) {
+			copies = append(copies, blocks[i+1])
+			i++
+		}
+		active := copies[0]
+		for _, b := range copies {
+			if b.Newer(active) {
+				active = b
+			}
+		}
+		if edit {
+			if active, err = e.edit(active, copies); err != nil {
+				return err
+			}
+		}
+		for _, b := range copies {
+			if len(copies) > 1 && b == active {
+				fmt.Fprintln(e.out, b, "active")
+			} else {
+				fmt.Fprintln(e.out, b)
+			}
+		}
+		for _, v := range active.Vars {
+			fmt.Fprintf(e.out, "%s=%s\n", v.Name, v.Value)
+		}
+	}
+	if !edit {
+		return nil
+	}
+	return os.WriteFile(e.Config.Output, e.data, 0666)
+}
+
+func redundantPair(a, b *Block) bool {
+	return a.Redundant && b.Redundant && a.Size == b.Size && b.Offset-a.Offset <= int64(a.Size)*2
+}
+
+// edit applies changes to variables of active copy and returns the new active
+// copy. As U-Boot does, redundant environment is written in the other copy with
+// the next flag and the previous copy stays as fallback.
+func (e *Editor) edit(active *Block, copies []*Block) (*Block, error) {
+	b := active
+	for _, c := range copies {
+		if c != active {
+			b = c
+			b.Vars = append([]Var(nil), active.Vars...)
+			b.Flag = active.Flag + 1
+		}
+	}
+	for _, s := range e.Config.Set {
+		name, value, _ := strings.Cut(s, "=")
+		b.Set(name, value)
+	}
+	for _, name := range e.Config.Delete {
+		if !b.Delete(name) {
+			return nil, fmt.Errorf("variable '%s' isn't found", name)
+		}
+	}
+	data, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	copy(e.data[b.Offset:], data)
+	return b, nil
+}
@@ -0,0 +1,125 @@
+package env
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func block(size int, redundant bool, flag byte, bigEndian bool, vars ...Var) []byte {
+	b := &Block{Size: size, Redundant: redundant, Flag: flag, BigEndian: bigEndian, Vars: vars}
+	data, err := b.Bytes()
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestFind(t *testing.T) {
+	dump := bytes.Repeat([]byte{0xFF}, 0x10000)
+	copy(dump[0x1000:], block(0x2000, false, 0, false, Var{"bootdelay", "3"}, Var{"bootargs", "console=ttyS0"}))
+	copy(dump[0x4000:], block(0x1000, true, 2, true, Var{"ipaddr", "10.0.0.1"}))
+	copy(dump[0x5000:], block(0x1000, true, 3, true, Var{"ipaddr", "10.0.0.2"}))
+	// CRC is broken
+	bad := block(0x400, false, 0, false, Var{"a", "b"})
+	bad[0] ^= 1
+	copy(dump[0x8000:], bad)
+
+	blocks, err := Find(context.TODO(), dump, 0x200, DefaultSizes)
+	require.NoError(t, err)
+	require.Len(t, blocks, 3)
+
+	require.Equal(t, int64(0x1000), blocks[0].Offset)
+	require.Equal(t, 0x2000, blocks[0].Size)
+	require.False(t, blocks[0].Redundant)
+	v, ok := blocks[0].Get("bootargs")
+	require.True(t, ok)
+	require.Equal(t, "console=ttyS0", v)
+
+	require.True(t, blocks[1].Redundant)
+	require.True(t, blocks[1].BigEndian)
+	require.Equal(t, byte(2), blocks[1].Flag)
+	require.True(t, blocks[2].Newer(blocks[1]))
+	require.True(t, (&Block{Flag: 0}).Newer(&Block{Flag: 0xFF}))
+
+	_, err = (&Block{Size: 0x10, Vars: []Var{{"bootargs", "console=ttyS0,115200"}}}).Bytes()
+	require.ErrorIs(t, err, ErrSize)
+}
+
+func TestEditor_Run(t *testing.T) {
+	dump := make([]byte, 0x4000)
+	copy(dump[0x2000:], block(0x1000, true, 5, false, Var{"bootcmd", "run old"}))
+	copy(dump[0x3000:], block(0x1000, true, 6, false, Var{"bootcmd", "bootm"}, Var{"bootargs", "quiet"}))
+	tests := []struct {
+		name    string
+		cfg     config.Env
+		want    string
+		vars    []Var
+		active  string
+		wantErr bool
+	}{
+		{
+			"Print",
+			config.Env{},
+			"0x00002000 env size 0x1000 crc 0x",
+			nil,
+			"redundant flag 0x06 active\n",
+			false,
+		},
+		{
+			"Set and delete",
+			config.Env{Set: []string{"bootargs=console=ttyS0 init=/bin/sh", "ethaddr=00:11:22:33:44:55"}, Delete: []string{"bootcmd"}},
+			"bootargs=console=ttyS0 init=/bin/sh\nethaddr=00:11:22:33:44:55\n",
+			[]Var{{"bootargs", "console=ttyS0 init=/bin/sh"}, {"ethaddr", "00:11:22:33:44:55"}},
+			"redundant flag 0x07 active\n",
+			false,
+		},
+		{
+			"Delete unknown",
+			config.Env{Delete: []string{"unknown"}},
+			"",
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "dump.bin")
+			require.NoError(t, os.WriteFile(name, dump, 0666))
+			tt.cfg.Align = 0x1000
+			e := New(tt.cfg)
+			out := &bytes.Buffer{}
+			e.out = out
+			require.NoError(t, e.Open(name))
+			err := e.Run(context.TODO())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, out.String(), tt.want)
+			require.Contains(t, out.String(), tt.active)
+			if tt.vars == nil {
+				require.NoFileExists(t, e.Config.Output)
+				return
+			}
+			data, err := os.ReadFile(e.Config.Output)
+			require.NoError(t, err)
+			blocks, err := Find(context.TODO(), data, 0x1000, DefaultSizes)
+			require.NoError(t, err)
+			require.Len(t, blocks, 2)
+			// inactive copy gets changes with the next flag, previous copy is fallback
+			require.Equal(t, byte(7), blocks[0].Flag)
+			require.Equal(t, tt.vars, blocks[0].Vars)
+			require.Equal(t, byte(6), blocks[1].Flag)
+			require.Equal(t, []Var{{"bootcmd", "bootm"}, {"bootargs", "quiet"}}, blocks[1].Vars)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package ext4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+// Walk calls fn for every file of tree with its path, errors of directories are passed
+// to fn with inode of directory.
+func (f *FS) Walk(ctx context.Context, fn func(name string, in *Inode, err error) error) error {
+	root, err := f.Inode(rootInode)
+	if err != nil {
+		return err
+	}
+	if !root.IsDir() {
+		return fmt.Errorf("%w: root isn't directory", ErrCorrupted)
+	}
+	return f.walk(ctx, "/", root, 0, map[uint32]bool{rootInode: true}, fn)
+}
+
+func (f *FS) walk(ctx context.Context, dir string, in *Inode, depth int, seen map[uint32]bool, fn func(string, *Inode, error) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if depth > maxDepth {
+		return fn(dir, in, fmt.Errorf("%w: too deep directories", ErrCorrupted))
+	}
+	entries, err := f.ReadDir(in)
+	if err != nil {
+		return fn(dir, in, err)
+	}
+	for _, e := range entries {
+		name := path.Join(dir, e.Name)
+		if strings.ContainsAny(e.Name, "/\x00") {
+			if err := fn(name, nil, fmt.Errorf("%w: name %q", ErrCorrupted, e.Name)); err != nil {
+				return err
+			}
+			continue
+		}
+		child, err := f.Inode(e.Inode)
+		if err := fn(name, child, err); err != nil {
+			return err
+		}
+		if child == nil {
+			continue
+		}
+		// hard links of directories are loops
+		if !child.IsDir() || seen[child.Num] {
+			continue
+		}
+		seen[child.Num] = true
+		if err := f.walk(ctx, name, child, depth+1, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type Extractor struct {
+	fs     *FS
+	closer io.Closer
+	links  []symlink
+	out    io.Writer
+	Config config.Ext4
+}
+
+type symlink struct {
+	target, name string
+}
+
+func New(cfg config.Ext4) *Extractor {
+	return &Extractor{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (e *Extractor) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for extracting: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for extracting: %w", input, err)
+	}
+	e.closer = in
+	if e.Config.Offset < 0 || e.Config.Offset >= stat.Size() {
+		return fmt.Errorf("offset 0x%x is out of file", e.Config.Offset)
+	}
+	e.fs, err = Open(io.NewSectionReader(in, e.Config.Offset, stat.Size()-e.Config.Offset))
+	if err != nil {
+		return err
+	}
+	if e.Config.Output == "" {
+		name := filepath.Base(input)
+		e.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-ext4"
+	}
+	return nil
+}
+
+func (e *Extractor) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Run prints file with --cat, lists or extracts files. Errors of single files
+// don't stop extraction of others.
+func (e *Extractor) Run(ctx context.Context) error {
+	if e.Config.Cat != "" {
+		in, err := e.fs.Lookup(e.Config.Cat)
+		if err != nil {
+			return err
+		}
+		if in.IsDir() {
+			return fmt.Errorf("%s is directory", e.Config.Cat)
+		}
+		r, err := e.fs.File(in)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(e.out, r)
+		return err
+	}
+	fmt.Fprintln(e.out, e.fs)
+	if !e.Config.List {
+		if err := os.MkdirAll(e.Config.Output, 0755); err != nil {
+			return err
+		}
+	}
+	var errs error
+	err := e.fs.Walk(ctx, func(name string, in *Inode, err error) error {
+		if err == nil {
+			err = e.entry(name, in)
+		}
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		return nil
+	})
+	// symlinks are created the last, so files can't be written through them
+	for _, l := range e.links {
+		if err := os.Symlink(l.target, l.name); err != nil && !errors.Is(err, os.ErrExist) {
+			errs = errors.Join(errs, err)
+		}
+	}
+	for _, w := range e.fs.Warnings {
+		fmt.Fprintln(e.out, "warning:", w)
+	}
+	return errors.Join(err, errs)
+}
+
+func (e *Extractor) entry(name string, in *Inode) error {
+	mode := in.FileMode()
+	if e.Config.List {
+		s := fmt.Sprintf("%s %5d %5d 0x%010x %s %s", mode, in.UID, in.GID, in.Size, in.Modified.Format(time.DateTime), name)
+		if mode&os.ModeSymlink != 0 {
+			target, err := e.fs.Readlink(in)
+			if err != nil {
+				return err
+			}
+			s += " -> " + target
+		}
+		fmt.Fprintln(e.out, s)
+		return nil
+	}
+	dst := filepath.Join(e.Config.Output, filepath.FromSlash(name))
+	switch {
+	case mode.IsDir():
+		return os.MkdirAll(dst, 0755)
+	case mode&os.ModeSymlink != 0:
+		target, err := e.fs.Readlink(in)
+		if err != nil {
+			return err
+		}
+		e.links = append(e.links, symlink{target, dst})
+		return nil
+	case mode.IsRegular():
+		r, err := e.fs.File(in)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, r)
+		if err := errors.Join(err, f.Close()); err != nil {
+			return err
+		}
+		return os.Chtimes(dst, in.Modified, in.Modified)
+	}
+	// devices, pipes and sockets aren't created
+	return nil
+}
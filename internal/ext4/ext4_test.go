@@ -0,0 +1,344 @@
+package ext4
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const (
+	testBlock      = 1024
+	testInodes     = 32
+	testInodeSize  = 256
+	testInodeTable = 3
+	mtime          = 1700000000
+)
+
+// image builds filesystem of one group with blocks of 1K
+type image struct {
+	b    []byte
+	next uint64
+}
+
+func newImage(incompat uint32) *image {
+	m := &image{b: make([]byte, 256*testBlock), next: 16}
+	le := binary.LittleEndian
+	sb := m.b[superblockOffset:]
+	le.PutUint32(sb[0x00:], testInodes)
+	le.PutUint32(sb[0x04:], 256)
+	le.PutUint32(sb[0x14:], 1)
+	le.PutUint32(sb[0x20:], 8192)
+	le.PutUint32(sb[0x28:], testInodes)
+	le.PutUint16(sb[0x38:], magic)
+	le.PutUint32(sb[0x4c:], 1)
+	le.PutUint16(sb[0x58:], testInodeSize)
+	le.PutUint32(sb[0x60:], incompat|incompatFiletype)
+	copy(sb[0x78:], "rootfs")
+	if incompat&incompat64Bit != 0 {
+		le.PutUint16(sb[0xfe:], 64)
+	}
+	le.PutUint32(m.b[2*testBlock+0x08:], testInodeTable)
+	return m
+}
+
+func (m *image) block(data []byte) uint64 {
+	n := m.next
+	copy(m.b[n*testBlock:][:testBlock], data)
+	m.next++
+	return n
+}
+
+func (m *image) inode(n uint32, mode uint16, size int, flags uint32, block []byte) []byte {
+	le := binary.LittleEndian
+	in := m.b[testInodeTable*testBlock+int(n-1)*testInodeSize:][:testInodeSize]
+	le.PutUint16(in[0x00:], mode)
+	le.PutUint32(in[0x04:], uint32(size))
+	le.PutUint32(in[0x10:], mtime)
+	le.PutUint32(in[0x20:], flags)
+	copy(in[0x28:0x28+iBlockSize], block)
+	le.PutUint16(in[0x80:], 32)
+	return in
+}
+
+// inline stores the rest of inline data in extended attribute system.data
+func inline(in []byte, rest []byte) {
+	le := binary.LittleEndian
+	x := in[128+32:]
+	le.PutUint32(x, xattrMagic)
+	e := x[4:]
+	e[0], e[1] = 4, 7
+	le.PutUint16(e[2:], 24)
+	le.PutUint32(e[8:], uint32(len(rest)))
+	copy(e[16:], "data")
+	copy(e[24:], rest)
+}
+
+type dirent struct {
+	name  string
+	inode uint32
+	typ   byte
+}
+
+func dirents(entries []dirent, size int) []byte {
+	var b []byte
+	for i, e := range entries {
+		n := (8 + len(e.name) + 3) &^ 3
+		if i == len(entries)-1 {
+			n = size - len(b)
+		}
+		d := make([]byte, n)
+		binary.LittleEndian.PutUint32(d, e.inode)
+		binary.LittleEndian.PutUint16(d[4:], uint16(n))
+		d[6], d[7] = byte(len(e.name)), e.typ
+		copy(d[8:], e.name)
+		b = append(b, d...)
+	}
+	return b
+}
+
+type run struct {
+	logical, physical uint64
+	length            uint16
+}
+
+func extentNode(depth uint16, entries []run, index bool) []byte {
+	le := binary.LittleEndian
+	b := make([]byte, 12+12*len(entries))
+	le.PutUint16(b, extentMagic)
+	le.PutUint16(b[2:], uint16(len(entries)))
+	le.PutUint16(b[4:], 4)
+	le.PutUint16(b[6:], depth)
+	for i, r := range entries {
+		e := b[12+12*i:]
+		le.PutUint32(e, uint32(r.logical))
+		if index {
+			le.PutUint32(e[4:], uint32(r.physical))
+			le.PutUint16(e[8:], uint16(r.physical>>32))
+			continue
+		}
+		le.PutUint16(e[4:], r.length)
+		le.PutUint16(e[6:], uint16(r.physical>>32))
+		le.PutUint32(e[8:], uint32(r.physical))
+	}
+	return b
+}
+
+func fill(c byte, n int) []byte {
+	return bytes.Repeat([]byte{c}, n)
+}
+
+// ext4Image has extent directory, file with extent tree, hole and uninitialized
+// extent, inline file, inline directory and fast symlink.
+func ext4Image() []byte {
+	m := newImage(incompatExtents | incompat64Bit | incompatFlexBG | incompatInlineData)
+	hello := m.block([]byte("hello ext4\n"))
+	m.inode(12, modeFile|0644, 11, flagExtents, extentNode(0, []run{{0, hello, 1}}, false))
+
+	// blocks 0-1 are data, 2 is hole, 3 is uninitialized, 4 is data
+	a := m.block(fill('a', testBlock))
+	m.block(fill('b', testBlock))
+	u := m.block(fill('u', testBlock))
+	c := m.block(fill('c', testBlock))
+	leaf := m.block(extentNode(0, []run{{0, a, 2}, {3, u, 32768 + 1}, {4, c, 1}}, false))
+	m.inode(13, modeFile|0644, 4*testBlock+100, flagExtents, extentNode(1, []run{{0, leaf, 0}}, true))
+
+	text := []byte("inline data is longer than sixty bytes of block map, so it continues in xattr")
+	in := m.inode(14, modeFile|0600, len(text), flagInlineData, text[:iBlockSize])
+	inline(in, text[iBlockSize:])
+
+	m.inode(15, modeSymlink|0777, 9, 0, []byte("hello.txt"))
+
+	parent := binary.LittleEndian.AppendUint32(nil, rootInode)
+	m.inode(16, modeDir|0755, iBlockSize, flagInlineData, append(parent, dirents([]dirent{{"a.txt", 17, 1}}, iBlockSize-4)...))
+	m.inode(17, modeFile|0644, 3, flagInlineData, []byte("abc"))
+
+	root := m.block(dirents([]dirent{
+		{".", rootInode, 2},
+		{"..", rootInode, 2},
+		{"hello.txt", 12, 1},
+		{"sparse.bin", 13, 1},
+		{"inline.txt", 14, 1},
+		{"link", 15, 7},
+		{"dir", 16, 2},
+	}, testBlock))
+	m.inode(rootInode, modeDir|0755, testBlock, flagExtents, extentNode(0, []run{{0, root, 1}}, false))
+	return m.b
+}
+
+// ext2Image has file with direct and indirect blocks and hole.
+func ext2Image() []byte {
+	m := newImage(0)
+	blocks := make([]byte, iBlockSize)
+	var indirect []byte
+	for i := 0; i < 14; i++ {
+		if i == 5 {
+			continue
+		}
+		n := m.block(fill(byte('A'+i), testBlock))
+		if i < 12 {
+			binary.LittleEndian.PutUint32(blocks[4*i:], uint32(n))
+		} else {
+			indirect = binary.LittleEndian.AppendUint32(indirect, uint32(n))
+		}
+	}
+	binary.LittleEndian.PutUint32(blocks[48:], uint32(m.block(indirect)))
+	m.inode(12, modeFile|0644, 14*testBlock-10, 0, blocks)
+
+	root := m.block(dirents([]dirent{{".", rootInode, 2}, {"..", rootInode, 2}, {"big.bin", 12, 1}}, testBlock))
+	dir := make([]byte, iBlockSize)
+	binary.LittleEndian.PutUint32(dir, uint32(root))
+	m.inode(rootInode, modeDir|0755, testBlock, 0, dir)
+	return m.b
+}
+
+// linkImage has symlink and file with the same name in root
+func linkImage() []byte {
+	m := newImage(0)
+	m.inode(12, modeSymlink|0777, 10, 0, []byte("../escaped"))
+	data := make([]byte, iBlockSize)
+	binary.LittleEndian.PutUint32(data, uint32(m.block([]byte("data"))))
+	m.inode(13, modeFile|0644, 4, 0, data)
+	root := m.block(dirents([]dirent{{".", rootInode, 2}, {"..", rootInode, 2}, {"x", 12, 7}, {"x", 13, 1}}, testBlock))
+	dir := make([]byte, iBlockSize)
+	binary.LittleEndian.PutUint32(dir, uint32(root))
+	m.inode(rootInode, modeDir|0755, testBlock, 0, dir)
+	return m.b
+}
+
+func read(t *testing.T, f *FS, path string) []byte {
+	in, err := f.Lookup(path)
+	require.NoError(t, err)
+	r, err := f.File(in)
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return b
+}
+
+func TestExt4(t *testing.T) {
+	f, err := Open(bytes.NewReader(ext4Image()))
+	require.NoError(t, err)
+	require.Equal(t, "ext4, block 0x400, 256 blocks, 32 inodes, label \"rootfs\"", f.String())
+
+	var names []string
+	err = f.Walk(context.Background(), func(name string, in *Inode, err error) error {
+		require.NoError(t, err)
+		names = append(names, name)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"/hello.txt", "/sparse.bin", "/inline.txt", "/link", "/dir", "/dir/a.txt"}, names)
+
+	require.Equal(t, []byte("hello ext4\n"), read(t, f, "hello.txt"))
+	sparse := append(fill('a', testBlock), fill('b', testBlock)...)
+	sparse = append(sparse, make([]byte, 2*testBlock)...)
+	sparse = append(sparse, fill('c', 100)...)
+	require.Equal(t, sparse, read(t, f, "/sparse.bin"))
+	require.Equal(t, "inline data is longer than sixty bytes of block map, so it continues in xattr", string(read(t, f, "inline.txt")))
+	require.Equal(t, []byte("abc"), read(t, f, "/dir/a.txt"))
+
+	link, err := f.Lookup("link")
+	require.NoError(t, err)
+	target, err := f.Readlink(link)
+	require.NoError(t, err)
+	require.Equal(t, "hello.txt", target)
+
+	_, err = f.Lookup("/dir/missing")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestExt2(t *testing.T) {
+	f, err := Open(bytes.NewReader(ext2Image()))
+	require.NoError(t, err)
+	require.Equal(t, "ext2", f.Kind)
+	var want []byte
+	for i := 0; i < 14; i++ {
+		if i == 5 {
+			want = append(want, make([]byte, testBlock)...)
+			continue
+		}
+		want = append(want, fill(byte('A'+i), testBlock)...)
+	}
+	require.Equal(t, want[:14*testBlock-10], read(t, f, "big.bin"))
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func([]byte)
+		err    error
+	}{
+		{"Magic", func(b []byte) { b[superblockOffset+0x38] = 0 }, ErrExt},
+		{"Block size", func(b []byte) { b[superblockOffset+0x18] = 10 }, ErrExt},
+		{"Encryption", func(b []byte) { b[superblockOffset+0x62] |= 1 }, ErrUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := ext4Image()
+			tt.modify(img)
+			_, err := Open(bytes.NewReader(img))
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "rootfs.img")
+	require.NoError(t, os.WriteFile(input, append(make([]byte, 0x800), ext4Image()...), 0644))
+
+	t.Run("Extract", func(t *testing.T) {
+		e := New(config.Ext4{Offset: 0x800})
+		e.out = &bytes.Buffer{}
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		e.Config.Output = filepath.Join(dir, "out")
+		require.NoError(t, e.Run(context.Background()))
+		got, err := os.ReadFile(filepath.Join(e.Config.Output, "dir", "a.txt"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("abc"), got)
+		target, err := os.Readlink(filepath.Join(e.Config.Output, "link"))
+		require.NoError(t, err)
+		require.Equal(t, "hello.txt", target)
+	})
+	t.Run("Symlink and file with the same name", func(t *testing.T) {
+		input := filepath.Join(dir, "link.img")
+		require.NoError(t, os.WriteFile(input, linkImage(), 0644))
+		e := New(config.Ext4{})
+		e.out = &bytes.Buffer{}
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		e.Config.Output = filepath.Join(dir, "link")
+		require.NoError(t, e.Run(context.Background()))
+		got, err := os.ReadFile(filepath.Join(e.Config.Output, "x"))
+		require.NoError(t, err)
+		require.Equal(t, []byte("data"), got)
+		require.NoFileExists(t, filepath.Join(dir, "escaped"))
+	})
+	t.Run("List", func(t *testing.T) {
+		e := New(config.Ext4{Offset: 0x800, List: true})
+		out := &bytes.Buffer{}
+		e.out = out
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		require.NoError(t, e.Run(context.Background()))
+		require.Contains(t, out.String(), "Lrwxrwxrwx     0     0 0x0000000009 2023-11-14 22:13:20 /link -> hello.txt\n")
+	})
+	t.Run("Cat", func(t *testing.T) {
+		e := New(config.Ext4{Offset: 0x800, Cat: "/hello.txt"})
+		out := &bytes.Buffer{}
+		e.out = out
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		require.NoError(t, e.Run(context.Background()))
+		require.Equal(t, "hello ext4\n", out.String())
+	})
+}
@@ -0,0 +1,577 @@
+package ext4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrExt = errors.New("invalid ext2/3/4")
+var ErrCorrupted = errors.New("corrupted filesystem")
+var ErrUnsupported = errors.New("unsupported feature")
+var ErrNotFound = errors.New("file not found")
+
+const (
+	superblockOffset = 1024
+	magic            = 0xef53
+	rootInode        = 2
+	extentMagic      = 0xf30a
+	xattrMagic       = 0xea020000
+	// size of block map and inline data in inode
+	iBlockSize = 60
+	// limit of nested directories and extent tree
+	maxDepth = 64
+)
+
+// features of superblock
+const (
+	compatHasJournal   = 0x4
+	incompatCompress   = 0x1
+	incompatFiletype   = 0x2
+	incompatRecover    = 0x4
+	incompatMetaBG     = 0x10
+	incompatExtents    = 0x40
+	incompat64Bit      = 0x80
+	incompatFlexBG     = 0x200
+	incompatInlineData = 0x8000
+	incompatEncrypt    = 0x10000
+	roCompatSparse     = 0x1
+)
+
+// flags of inode
+const (
+	flagExtents    = 0x80000
+	flagInlineData = 0x10000000
+)
+
+// types of mode
+const (
+	modeType    = 0xf000
+	modeDir     = 0x4000
+	modeFile    = 0x8000
+	modeSymlink = 0xa000
+)
+
+// FS is ext2, ext3 or ext4 filesystem, which is read without journal.
+type FS struct {
+	r              io.ReaderAt
+	Kind           string
+	Label          string
+	BlockSize      int64
+	Blocks         uint64
+	Inodes         uint32
+	inodesPerGroup uint32
+	blocksPerGroup uint32
+	firstDataBlock uint32
+	inodeSize      int64
+	descSize       int64
+	firstMetaBG    uint32
+	incompat       uint32
+	roCompat       uint32
+	// tables of inodes by groups
+	inodeTables []uint64
+	Warnings    []string
+}
+
+func (f *FS) String() string {
+	s := fmt.Sprintf("%s, block 0x%x, %d blocks, %d inodes", f.Kind, f.BlockSize, f.Blocks, f.Inodes)
+	if f.Label != "" {
+		s += fmt.Sprintf(", label %q", f.Label)
+	}
+	return s
+}
+
+// Open reads superblock and group descriptors, r begins at the start of filesystem.
+func Open(r io.ReaderAt) (*FS, error) {
+	sb := make([]byte, 1024)
+	if _, err := r.ReadAt(sb, superblockOffset); err != nil {
+		return nil, fmt.Errorf("%w: superblock: %w", ErrExt, err)
+	}
+	le := binary.LittleEndian
+	if le.Uint16(sb[0x38:]) != magic {
+		return nil, fmt.Errorf("%w: no magic of superblock", ErrExt)
+	}
+	f := &FS{
+		r:              r,
+		Inodes:         le.Uint32(sb[0x00:]),
+		Blocks:         uint64(le.Uint32(sb[0x04:])),
+		firstDataBlock: le.Uint32(sb[0x14:]),
+		blocksPerGroup: le.Uint32(sb[0x20:]),
+		inodesPerGroup: le.Uint32(sb[0x28:]),
+		inodeSize:      128,
+		descSize:       32,
+		incompat:       le.Uint32(sb[0x60:]),
+		roCompat:       le.Uint32(sb[0x64:]),
+		firstMetaBG:    le.Uint32(sb[0x104:]),
+		Label:          strings.TrimRight(string(sb[0x78:0x88]), "\x00"),
+	}
+	logBlock := le.Uint32(sb[0x18:])
+	if logBlock > 6 {
+		return nil, fmt.Errorf("%w: block size 1024<<%d", ErrExt, logBlock)
+	}
+	f.BlockSize = 1024 << logBlock
+	if le.Uint32(sb[0x4c:]) > 0 {
+		f.inodeSize = int64(le.Uint16(sb[0x58:]))
+	}
+	if f.incompat&incompat64Bit != 0 {
+		f.Blocks |= uint64(le.Uint32(sb[0x150:])) << 32
+		f.descSize = int64(le.Uint16(sb[0xfe:]))
+	}
+	if f.inodeSize < 128 || f.inodeSize > f.BlockSize || f.descSize < 32 || f.descSize > f.BlockSize ||
+		f.blocksPerGroup == 0 || f.inodesPerGroup == 0 {
+		return nil, fmt.Errorf("%w: inode size %d, descriptor size %d, %d blocks and %d inodes per group",
+			ErrExt, f.inodeSize, f.descSize, f.blocksPerGroup, f.inodesPerGroup)
+	}
+	if unsupported := f.incompat & (incompatCompress | incompatEncrypt); unsupported != 0 {
+		return nil, fmt.Errorf("%w: incompatible features 0x%x", ErrUnsupported, unsupported)
+	}
+	if f.incompat&incompatRecover != 0 {
+		f.Warnings = append(f.Warnings, "journal needs recovery, the last changes can be lost")
+	}
+	switch {
+	case f.incompat&(incompatExtents|incompat64Bit|incompatFlexBG|incompatInlineData) != 0:
+		f.Kind = "ext4"
+	case le.Uint32(sb[0x5c:])&compatHasJournal != 0:
+		f.Kind = "ext3"
+	default:
+		f.Kind = "ext2"
+	}
+	if err := f.readGroups(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// hasSuper reports, that group has backup of superblock and descriptors.
+func (f *FS) hasSuper(group uint32) bool {
+	if f.roCompat&roCompatSparse == 0 || group <= 1 {
+		return true
+	}
+	for _, base := range []uint32{3, 5, 7} {
+		n := base
+		for n < group {
+			n *= base
+		}
+		if n == group {
+			return true
+		}
+	}
+	return false
+}
+
+// descBlock returns block with descriptor of group, meta_bg places descriptors
+// in the first groups of meta group.
+func (f *FS) descBlock(group uint32) uint64 {
+	perBlock := uint32(f.BlockSize / f.descSize)
+	meta := group / perBlock
+	if f.incompat&incompatMetaBG == 0 || meta < f.firstMetaBG {
+		return uint64(f.firstDataBlock) + 1 + uint64(meta)
+	}
+	first := meta * perBlock
+	block := uint64(first)*uint64(f.blocksPerGroup) + uint64(f.firstDataBlock)
+	if f.hasSuper(first) {
+		block++
+	}
+	return block
+}
+
+func (f *FS) readGroups() error {
+	groups := (f.Inodes + f.inodesPerGroup - 1) / f.inodesPerGroup
+	perBlock := uint32(f.BlockSize / f.descSize)
+	le := binary.LittleEndian
+	var block []byte
+	var last uint64
+	for g := uint32(0); g < groups; g++ {
+		if b := f.descBlock(g); block == nil || b != last {
+			var err error
+			if block, err = f.readBlock(b); err != nil {
+				return fmt.Errorf("descriptor of group %d: %w", g, err)
+			}
+			last = b
+		}
+		d := block[int64(g%perBlock)*f.descSize:]
+		table := uint64(le.Uint32(d[0x08:]))
+		if f.descSize >= 64 {
+			table |= uint64(le.Uint32(d[0x28:])) << 32
+		}
+		f.inodeTables = append(f.inodeTables, table)
+	}
+	return nil
+}
+
+func (f *FS) readBlock(n uint64) ([]byte, error) {
+	if n >= f.Blocks {
+		return nil, fmt.Errorf("%w: block 0x%x is out of filesystem", ErrCorrupted, n)
+	}
+	b := make([]byte, f.BlockSize)
+	if _, err := f.r.ReadAt(b, int64(n)*f.BlockSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%w: block 0x%x is out of image", ErrCorrupted, n)
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+type Inode struct {
+	Num      uint32
+	Mode     uint16
+	UID      uint32
+	GID      uint32
+	Size     int64
+	Modified time.Time
+	Flags    uint32
+	block    []byte
+	inline   []byte
+}
+
+func (in *Inode) IsDir() bool {
+	return in.Mode&modeType == modeDir
+}
+
+// FileMode converts mode of inode to mode of Go.
+func (in *Inode) FileMode() fs.FileMode {
+	m := fs.FileMode(in.Mode & 0777)
+	switch in.Mode & modeType {
+	case modeDir:
+		m |= fs.ModeDir
+	case modeSymlink:
+		m |= fs.ModeSymlink
+	case 0x2000:
+		m |= fs.ModeDevice | fs.ModeCharDevice
+	case 0x6000:
+		m |= fs.ModeDevice
+	case 0x1000:
+		m |= fs.ModeNamedPipe
+	case 0xc000:
+		m |= fs.ModeSocket
+	}
+	return m
+}
+
+// Inode reads inode by number, inline data is read from extended attribute too.
+func (f *FS) Inode(n uint32) (*Inode, error) {
+	if n == 0 || n > f.Inodes {
+		return nil, fmt.Errorf("%w: inode %d", ErrCorrupted, n)
+	}
+	group, index := (n-1)/f.inodesPerGroup, (n-1)%f.inodesPerGroup
+	b := make([]byte, f.inodeSize)
+	off := int64(f.inodeTables[group])*f.BlockSize + int64(index)*f.inodeSize
+	if _, err := f.r.ReadAt(b, off); err != nil {
+		return nil, fmt.Errorf("%w: inode %d at 0x%x: %w", ErrCorrupted, n, off, err)
+	}
+	le := binary.LittleEndian
+	in := &Inode{
+		Num:      n,
+		Mode:     le.Uint16(b[0x00:]),
+		UID:      uint32(le.Uint16(b[0x02:])) | uint32(le.Uint16(b[0x78:]))<<16,
+		GID:      uint32(le.Uint16(b[0x18:])) | uint32(le.Uint16(b[0x7a:]))<<16,
+		Size:     int64(le.Uint32(b[0x04:])) | int64(le.Uint32(b[0x6c:]))<<32,
+		Modified: time.Unix(int64(int32(le.Uint32(b[0x10:]))), 0).UTC(),
+		Flags:    le.Uint32(b[0x20:]),
+		block:    b[0x28 : 0x28+iBlockSize],
+	}
+	if in.Size < 0 {
+		return nil, fmt.Errorf("%w: size of inode %d", ErrCorrupted, n)
+	}
+	if in.Flags&flagInlineData != 0 {
+		in.inline = append([]byte{}, in.block...)
+		if f.inodeSize > 128 {
+			in.inline = append(in.inline, inlineXattr(b)...)
+		}
+	}
+	return in, nil
+}
+
+// inlineXattr returns value of system.data from extended attributes in inode.
+func inlineXattr(b []byte) []byte {
+	le := binary.LittleEndian
+	start := 128 + int(le.Uint16(b[0x80:]))
+	if start+4 > len(b) || le.Uint32(b[start:]) != xattrMagic {
+		return nil
+	}
+	entries := b[start+4:]
+	for i := 0; i+16 <= len(entries) && le.Uint32(entries[i:]) != 0; {
+		nameLen, index := int(entries[i]), entries[i+1]
+		offs, size := int(le.Uint16(entries[i+2:])), int(le.Uint32(entries[i+8:]))
+		if i+16+nameLen > len(entries) {
+			return nil
+		}
+		// index 7 is system namespace
+		if index == 7 && string(entries[i+16:i+16+nameLen]) == "data" {
+			if offs+size > len(entries) {
+				return nil
+			}
+			return entries[offs : offs+size]
+		}
+		i += (16 + nameLen + 3) &^ 3
+	}
+	return nil
+}
+
+// extent maps logical blocks to physical, uninitialized extents are read as zeros.
+type extent struct {
+	logical  uint64
+	physical uint64
+	length   uint64
+	uninit   bool
+}
+
+func (f *FS) extents(in *Inode) ([]extent, error) {
+	var ext []extent
+	var err error
+	if in.Flags&flagExtents != 0 {
+		ext, err = f.extentTree(in.block, 0)
+	} else {
+		ext, err = f.blockMap(in)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inode %d: %w", in.Num, err)
+	}
+	sort.Slice(ext, func(i, j int) bool { return ext[i].logical < ext[j].logical })
+	return ext, nil
+}
+
+func (f *FS) extentTree(node []byte, depth int) ([]extent, error) {
+	le := binary.LittleEndian
+	if len(node) < 12 || le.Uint16(node) != extentMagic {
+		return nil, fmt.Errorf("%w: extent header", ErrCorrupted)
+	}
+	entries, level := int(le.Uint16(node[2:])), int(le.Uint16(node[6:]))
+	if depth > maxDepth || 12+12*entries > len(node) {
+		return nil, fmt.Errorf("%w: extent node with %d entries at depth %d", ErrCorrupted, entries, depth)
+	}
+	var ext []extent
+	for i := 0; i < entries; i++ {
+		e := node[12+12*i:]
+		if level == 0 {
+			length := uint64(le.Uint16(e[4:]))
+			uninit := length > 32768
+			if uninit {
+				length -= 32768
+			}
+			ext = append(ext, extent{
+				logical:  uint64(le.Uint32(e)),
+				physical: uint64(le.Uint16(e[6:]))<<32 | uint64(le.Uint32(e[8:])),
+				length:   length,
+				uninit:   uninit,
+			})
+			continue
+		}
+		child, err := f.readBlock(uint64(le.Uint16(e[8:]))<<32 | uint64(le.Uint32(e[4:])))
+		if err != nil {
+			return nil, err
+		}
+		sub, err := f.extentTree(child, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		ext = append(ext, sub...)
+	}
+	return ext, nil
+}
+
+// blockMap reads direct and indirect blocks of ext2/3, runs of blocks are joined.
+func (f *FS) blockMap(in *Inode) ([]extent, error) {
+	le := binary.LittleEndian
+	blocks := uint64((in.Size + f.BlockSize - 1) / f.BlockSize)
+	perBlock := uint64(f.BlockSize / 4)
+	var ext []extent
+	add := func(logical, physical uint64) {
+		if physical == 0 {
+			return
+		}
+		if n := len(ext); n > 0 && ext[n-1].logical+ext[n-1].length == logical && ext[n-1].physical+ext[n-1].length == physical {
+			ext[n-1].length++
+			return
+		}
+		ext = append(ext, extent{logical: logical, physical: physical, length: 1})
+	}
+	var logical uint64
+	// indirect walks tree of level with span of logical blocks per entry
+	var indirect func(block uint64, level int) error
+	indirect = func(block uint64, level int) error {
+		span := uint64(1)
+		for i := 0; i < level; i++ {
+			span *= perBlock
+		}
+		if block == 0 {
+			logical += span * perBlock
+			return nil
+		}
+		b, err := f.readBlock(block)
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < perBlock && logical < blocks; i++ {
+			p := uint64(le.Uint32(b[4*i:]))
+			if level == 0 {
+				add(logical, p)
+				logical++
+				continue
+			}
+			if err := indirect(p, level-1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for i := 0; i < 12 && logical < blocks; i++ {
+		add(logical, uint64(le.Uint32(in.block[4*i:])))
+		logical++
+	}
+	for level := 0; level < 3 && logical < blocks; level++ {
+		if err := indirect(uint64(le.Uint32(in.block[48+4*level:])), level); err != nil {
+			return nil, err
+		}
+	}
+	return ext, nil
+}
+
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// File returns reader of contents, holes and uninitialized extents are zeros.
+func (f *FS) File(in *Inode) (io.Reader, error) {
+	if in.inline != nil {
+		if in.Size > int64(len(in.inline)) {
+			return nil, fmt.Errorf("%w: inline data of inode %d is less than size", ErrCorrupted, in.Num)
+		}
+		return bytes.NewReader(in.inline[:in.Size]), nil
+	}
+	// fast symlink is stored in block map
+	if in.Mode&modeType == modeSymlink && in.Size < iBlockSize && in.Flags&flagExtents == 0 {
+		return bytes.NewReader(in.block[:in.Size]), nil
+	}
+	ext, err := f.extents(in)
+	if err != nil {
+		return nil, err
+	}
+	var readers []io.Reader
+	var pos int64
+	for _, e := range ext {
+		start := int64(e.logical) * f.BlockSize
+		if start < pos {
+			return nil, fmt.Errorf("%w: overlapped extents of inode %d", ErrCorrupted, in.Num)
+		}
+		if start >= in.Size {
+			break
+		}
+		if e.physical+e.length > f.Blocks {
+			return nil, fmt.Errorf("%w: extent of inode %d is out of filesystem", ErrCorrupted, in.Num)
+		}
+		readers = append(readers, io.LimitReader(zeros{}, start-pos))
+		size := int64(e.length) * f.BlockSize
+		if e.uninit {
+			readers = append(readers, io.LimitReader(zeros{}, size))
+		} else {
+			readers = append(readers, io.NewSectionReader(f.r, int64(e.physical)*f.BlockSize, size))
+		}
+		pos = start + size
+	}
+	readers = append(readers, zeros{})
+	return io.LimitReader(io.MultiReader(readers...), in.Size), nil
+}
+
+func (f *FS) read(in *Inode) ([]byte, error) {
+	r, err := f.File(in)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// Readlink returns target of symbolic link.
+func (f *FS) Readlink(in *Inode) (string, error) {
+	b, err := f.read(in)
+	return string(b), err
+}
+
+type DirEntry struct {
+	Name  string
+	Inode uint32
+}
+
+// ReadDir parses entries of directory, hash trees are read as linear directories.
+func (f *FS) ReadDir(in *Inode) ([]DirEntry, error) {
+	if !in.IsDir() {
+		return nil, fmt.Errorf("inode %d isn't directory", in.Num)
+	}
+	var b []byte
+	if in.inline != nil {
+		// inline directory begins with inode of parent
+		if len(in.inline) < 4 {
+			return nil, fmt.Errorf("%w: inline directory %d", ErrCorrupted, in.Num)
+		}
+		b = in.inline[4:]
+	} else {
+		var err error
+		if b, err = f.read(in); err != nil {
+			return nil, err
+		}
+	}
+	le := binary.LittleEndian
+	var entries []DirEntry
+	for i := 0; i+8 <= len(b); {
+		n := le.Uint32(b[i:])
+		recLen := int(le.Uint16(b[i+4:]))
+		nameLen := int(b[i+6])
+		if f.incompat&incompatFiletype == 0 {
+			nameLen |= int(b[i+7]) << 8
+		}
+		if recLen < 8 || i+recLen > len(b) || 8+nameLen > recLen {
+			// the rest of block is skipped
+			next := (int64(i)/f.BlockSize + 1) * f.BlockSize
+			f.Warnings = append(f.Warnings, fmt.Sprintf("directory %d: broken entry at 0x%x", in.Num, i))
+			i = int(next)
+			continue
+		}
+		name := string(b[i+8 : i+8+nameLen])
+		if n != 0 && name != "." && name != ".." {
+			entries = append(entries, DirEntry{name, n})
+		}
+		i += recLen
+	}
+	return entries, nil
+}
+
+// Lookup returns inode of path from root.
+func (f *FS) Lookup(path string) (*Inode, error) {
+	in, err := f.Inode(rootInode)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		entries, err := f.ReadDir(in)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		found := false
+		for _, e := range entries {
+			if e.Name == name {
+				if in, err = f.Inode(e.Inode); err != nil {
+					return nil, err
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+	}
+	return in, nil
+}
@@ -0,0 +1,235 @@
+package extract
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrSyntax = errors.New("invalid range")
+var ErrRange = errors.New("range is out of file")
+
+// field types, which can be read from file
+var fieldSizes = map[string]int{
+	"u8":  1,
+	"u16": 2,
+	"u32": 4,
+	"u64": 8,
+}
+
+// Field is an unsigned integer at offset of file.
+type Field struct {
+	Offset    Expr
+	Size      int
+	BigEndian bool
+}
+
+type term struct {
+	neg   bool
+	value int64
+	end   bool
+	field *Field
+}
+
+// Expr is a sum of numbers, end of file and fields.
+type Expr []term
+
+// Eval computes expression for file r with size.
+func (e Expr) Eval(r io.ReaderAt, size int64) (int64, error) {
+	var sum int64
+	for _, t := range e {
+		v := t.value
+		switch {
+		case t.end:
+			v = size
+		case t.field != nil:
+			var err error
+			if v, err = t.field.Read(r, size); err != nil {
+				return 0, err
+			}
+		}
+		if t.neg {
+			v = -v
+		}
+		sum += v
+	}
+	return sum, nil
+}
+
+func (f *Field) Read(r io.ReaderAt, size int64) (int64, error) {
+	off, err := f.Offset.Eval(r, size)
+	if err != nil {
+		return 0, err
+	}
+	if off < 0 || off+int64(f.Size) > size {
+		return 0, fmt.Errorf("%w: field at 0x%x", ErrRange, off)
+	}
+	b := make([]byte, 8)
+	var order binary.ByteOrder = binary.LittleEndian
+	dst := b[:f.Size]
+	if f.BigEndian {
+		order = binary.BigEndian
+		dst = b[8-f.Size:]
+	}
+	if _, err := r.ReadAt(dst, off); err != nil {
+		return 0, err
+	}
+	return int64(order.Uint64(b)), nil
+}
+
+// parser reads expressions from the beginning of s
+type parser struct {
+	s   string
+	pos int
+}
+
+func (p *parser) errorf(what string) error {
+	return fmt.Errorf("%w: %s at %d of '%s'", ErrSyntax, what, p.pos, p.s)
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+// expr parses terms joined by + and -, ops returns positions of + after terms.
+func (p *parser) expr() (Expr, []int, error) {
+	var e Expr
+	var plus []int
+	neg := false
+	for {
+		t, err := p.term()
+		if err != nil {
+			return nil, nil, err
+		}
+		t.neg = neg
+		e = append(e, t)
+		switch p.peek() {
+		case '+':
+			neg = false
+			plus = append(plus, len(e))
+		case '-':
+			neg = true
+		default:
+			return e, plus, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) term() (term, error) {
+	if strings.HasPrefix(p.s[p.pos:], "end") {
+		p.pos += len("end")
+		return term{end: true}, nil
+	}
+	if p.peek() == '@' {
+		p.pos++
+		offset, _, err := p.expr()
+		if err != nil {
+			return term{}, err
+		}
+		if p.peek() != ':' {
+			return term{}, p.errorf("type of field is expected")
+		}
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' || p.s[p.pos] >= '0' && p.s[p.pos] <= '9') {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		f := &Field{Offset: offset}
+		typ := strings.TrimSuffix(strings.TrimSuffix(name, "le"), "be")
+		f.BigEndian = strings.HasSuffix(name, "be")
+		f.Size = fieldSizes[typ]
+		if f.Size == 0 || f.Size > 1 && typ == name {
+			p.pos = start
+			return term{}, p.errorf(fmt.Sprintf("type '%s', want u8, u16le, u16be, u32le, u32be, u64le or u64be", name))
+		}
+		return term{field: f}, nil
+	}
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("0123456789abcdefABCDEFxXoObB_", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	v, err := strconv.ParseInt(p.s[start:p.pos], 0, 64)
+	if err != nil {
+		p.pos = start
+		return term{}, p.errorf("number is expected")
+	}
+	return term{value: v}, nil
+}
+
+// Range is [Start, End) of file, End is size of range with Size.
+type Range struct {
+	Start Expr
+	End   Expr
+	Size  bool
+	text  string
+}
+
+func (r Range) String() string {
+	return r.text
+}
+
+// ParseRange decodes range as <start>:<end>, <start>+<size> or <start> till end
+// of file. Start, end and size are sums of numbers, "end" of file and fields
+// "@<offset>:<type>", type is u8, u16le, u16be, u32le, u32be, u64le or u64be.
+// Without ':' the last '+' separates size: 0x100+0x10+0x20 is 0x20 bytes at 0x110.
+func ParseRange(s string) (Range, error) {
+	r := Range{text: s}
+	p := &parser{s: strings.ReplaceAll(s, " ", "")}
+	if p.peek() == ':' {
+		r.Start = Expr{{}}
+	} else {
+		start, plus, err := p.expr()
+		if err != nil {
+			return r, err
+		}
+		r.Start = start
+		if p.peek() != ':' && len(plus) > 0 {
+			last := plus[len(plus)-1]
+			r.Start, r.End, r.Size = start[:last], start[last:], true
+		}
+	}
+	switch {
+	case r.Size:
+	case p.peek() == ':' && p.pos+1 == len(p.s):
+		p.pos++
+		r.End = Expr{{end: true}}
+	case p.peek() == ':':
+		p.pos++
+		end, _, err := p.expr()
+		if err != nil {
+			return r, err
+		}
+		r.End = end
+	default:
+		r.End = Expr{{end: true}}
+	}
+	if p.pos != len(p.s) {
+		return r, p.errorf("unexpected '" + p.s[p.pos:] + "'")
+	}
+	return r, nil
+}
+
+// Resolve computes offsets of range in file r with size.
+func (r Range) Resolve(f io.ReaderAt, size int64) (start, end int64, err error) {
+	if start, err = r.Start.Eval(f, size); err != nil {
+		return 0, 0, err
+	}
+	if end, err = r.End.Eval(f, size); err != nil {
+		return 0, 0, err
+	}
+	if r.Size {
+		end += start
+	}
+	if start < 0 || start > end || end > size {
+		return start, end, fmt.Errorf("%w: 0x%x-0x%x, size of file is 0x%x", ErrRange, start, end, size)
+	}
+	return start, end, nil
+}
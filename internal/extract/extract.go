@@ -0,0 +1,135 @@
+package extract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+type Extractor struct {
+	input  *os.File
+	size   int64
+	ranges []Range
+	base   string
+	out    io.Writer
+	Config config.Extract
+}
+
+func New(cfg config.Extract) *Extractor {
+	return &Extractor{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (e *Extractor) Open(input string) error {
+	if len(e.Config.Ranges) == 0 {
+		return errors.New("set ranges for extracting")
+	}
+	for _, s := range e.Config.Ranges {
+		r, err := ParseRange(s)
+		if err != nil {
+			return err
+		}
+		e.ranges = append(e.ranges, r)
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for extracting: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for extracting: %w", input, err)
+	}
+	e.input = in
+	e.size = stat.Size()
+	e.base = input[:len(input)-len(filepath.Ext(input))]
+	if e.Config.Concat && e.Config.Output == "" {
+		e.Config.Output = e.base + "-extracted.bin"
+	}
+	return nil
+}
+
+func (e *Extractor) Close() error {
+	if e.input == nil {
+		return nil
+	}
+	return e.input.Close()
+}
+
+type section struct {
+	Range
+	start, end int64
+}
+
+// Run resolves all ranges before writing, so output can't be written partially
+// because of wrong range. Every range is written in own file or all of them are
+// concatenated in output with --concat.
+func (e *Extractor) Run(ctx context.Context) error {
+	sections := make([]section, 0, len(e.ranges))
+	for _, r := range e.ranges {
+		start, end, err := r.Resolve(e.input, e.size)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		sections = append(sections, section{r, start, end})
+	}
+	if !e.Config.Concat {
+		return e.copy(ctx, sections, nil)
+	}
+	f, err := os.OpenFile(e.Config.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("can't create file '%s' for extracting: %w", e.Config.Output, err)
+	}
+	return errors.Join(e.copy(ctx, sections, f), f.Close())
+}
+
+// copy writes sections in concat or in own files without concat
+func (e *Extractor) copy(ctx context.Context, sections []section, concat io.Writer) error {
+	for _, s := range sections {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		r := io.NewSectionReader(e.input, s.start, s.end-s.start)
+		info := fmt.Sprintf("%s: 0x%08x-0x%08x 0x%x bytes", s.Range, s.start, s.end, s.end-s.start)
+		if concat != nil {
+			fmt.Fprintln(e.out, info)
+			if _, err := io.Copy(concat, r); err != nil {
+				return err
+			}
+			continue
+		}
+		name := e.name(s.start, s.end)
+		fmt.Fprintln(e.out, info, "->", name)
+		if err := write(name, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// name of file for range is <output>-<start>-<end>.bin, output is name of input by default
+func (e *Extractor) name(start, end int64) string {
+	base := e.base
+	if e.Config.Output != "" {
+		base = e.Config.Output
+	}
+	return fmt.Sprintf("%s-0x%08x-0x%08x.bin", base, start, end)
+}
+
+func write(name string, r io.Reader) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("can't create file '%s' for extracting: %w", name, err)
+	}
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
@@ -0,0 +1,112 @@
+package extract
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+// image has header with offset and size of payload
+func image() []byte {
+	b := make([]byte, 0x1000)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	binary.LittleEndian.PutUint32(b[0x10:], 0x100)
+	binary.BigEndian.PutUint32(b[0x14:], 0x40)
+	binary.LittleEndian.PutUint16(b[0x18:], 0x14)
+	return b
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		s          string
+		start, end int64
+		err        error
+	}{
+		{"Size", "0x200+0x100", 0x200, 0x300, nil},
+		{"End", "0x200:0x400", 0x200, 0x400, nil},
+		{"End of file", "end-0x100:end", 0xf00, 0x1000, nil},
+		{"Till end", "0xff0", 0xff0, 0x1000, nil},
+		{"Empty end", "0xff0:", 0xff0, 0x1000, nil},
+		{"Empty start", ":16", 0, 0x10, nil},
+		{"Last plus is size", "0x100+0x10+0x20", 0x110, 0x130, nil},
+		{"Arithmetic of size", "0x100+0x20-0x10", 0x100, 0x110, nil},
+		{"Fields", "@0x10:u32le+@0x14:u32be", 0x100, 0x140, nil},
+		{"Field of field", "@@0x18:u16le:u32be:end", 0x40, 0x1000, nil},
+		{"Field offset", "@0x10+4:u32be+0x10", 0x40, 0x50, nil},
+		{"Byte", "@0x18:u8+1", 0x14, 0x15, nil},
+		{"Out of file", "end-0x10+0x20", 0, 0, ErrRange},
+		{"Reversed", "0x200:0x100", 0, 0, ErrRange},
+		{"Field out of file", "@end:u8", 0, 0, ErrRange},
+		{"Type", "@0x10:u32", 0, 0, ErrSyntax},
+		{"Number", "0x10:zz", 0, 0, ErrSyntax},
+		{"Tail", "0x10:0x20:0x30", 0, 0, ErrSyntax},
+	}
+	img := image()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRange(tt.s)
+			if err == nil {
+				var start, end int64
+				start, end, err = r.Resolve(bytes.NewReader(img), int64(len(img)))
+				if tt.err == nil {
+					require.NoError(t, err)
+					require.Equal(t, tt.start, start)
+					require.Equal(t, tt.end, end)
+					return
+				}
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "fw.bin")
+	img := image()
+	require.NoError(t, os.WriteFile(input, img, 0644))
+
+	t.Run("Files", func(t *testing.T) {
+		e := New(config.Extract{Ranges: []string{"@0x10:u32le+@0x14:u32be", "end-0x10:end"}})
+		out := &bytes.Buffer{}
+		e.out = out
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		require.NoError(t, e.Run(context.Background()))
+		base := filepath.Join(dir, "fw")
+		require.Equal(t, "@0x10:u32le+@0x14:u32be: 0x00000100-0x00000140 0x40 bytes -> "+base+"-0x00000100-0x00000140.bin\n"+
+			"end-0x10:end: 0x00000ff0-0x00001000 0x10 bytes -> "+base+"-0x00000ff0-0x00001000.bin\n", out.String())
+		got, err := os.ReadFile(base + "-0x00000100-0x00000140.bin")
+		require.NoError(t, err)
+		require.Equal(t, img[0x100:0x140], got)
+	})
+	t.Run("Concat", func(t *testing.T) {
+		e := New(config.Extract{Ranges: []string{"0x20+0x10", "0x0:0x10"}, Concat: true})
+		e.out = &bytes.Buffer{}
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		require.NoError(t, e.Run(context.Background()))
+		got, err := os.ReadFile(filepath.Join(dir, "fw-extracted.bin"))
+		require.NoError(t, err)
+		require.Equal(t, append(append([]byte{}, img[0x20:0x30]...), img[:0x10]...), got)
+	})
+	t.Run("Wrong range", func(t *testing.T) {
+		output := filepath.Join(dir, "partial.bin")
+		e := New(config.Extract{Ranges: []string{"0x0+0x10", "0x2000+0x10"}, Concat: true, Output: output})
+		e.out = &bytes.Buffer{}
+		require.NoError(t, e.Open(input))
+		defer e.Close()
+		require.ErrorIs(t, e.Run(context.Background()), ErrRange)
+		require.NoFileExists(t, output)
+	})
+}
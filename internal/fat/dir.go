@@ -0,0 +1,370 @@
+package fat
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	attrReadOnly = 0x01
+	attrVolume   = 0x08
+	attrDir      = 0x10
+	attrLFN      = 0x0f
+	deletedMark  = 0xe5
+	// limit of nested directories, broken dumps can have loops
+	maxDepth = 64
+)
+
+// exFAT types of directory entries, deleted entries have cleared bit 7
+const (
+	exFATFile   = 0x85
+	exFATStream = 0xc0
+	exFATName   = 0xc1
+	exFATInUse  = 0x80
+	// stream extension has contiguous clusters without FAT chain
+	exFATNoChain = 0x02
+)
+
+type Entry struct {
+	Path     string    `json:"path"`
+	Dir      bool      `json:"dir"`
+	ReadOnly bool      `json:"read_only"`
+	Deleted  bool      `json:"deleted"`
+	Size     int64     `json:"size"`
+	Cluster  uint32    `json:"cluster"`
+	Modified time.Time `json:"modified"`
+	// clusters of deleted entry are used by other files
+	Lost       bool `json:"lost"`
+	contiguous bool
+}
+
+func (e Entry) String() string {
+	mode := "-"
+	if e.Dir {
+		mode = "d"
+	}
+	s := fmt.Sprintf("%s 0x%010x %s %s", mode, e.Size, e.Modified.Format(time.DateTime), e.Path)
+	switch {
+	case e.Lost:
+		s += " (deleted, clusters are overwritten)"
+	case e.Deleted:
+		s += " (deleted)"
+	}
+	return s
+}
+
+// dosTime decodes date in high and time in low 16 bits
+func dosTime(v uint32) time.Time {
+	d, t := v>>16, v&0xffff
+	return time.Date(int(d>>9)+1980, time.Month(d>>5&15), int(d&31), int(t>>11), int(t>>5&63), int(t&31)*2, 0, time.UTC)
+}
+
+func utf16String(b []byte, n int) string {
+	chars := make([]uint16, 0, n)
+	for i := 0; i < n && 2*i+2 <= len(b); i++ {
+		c := binary.LittleEndian.Uint16(b[2*i:])
+		if c == 0 {
+			break
+		}
+		chars = append(chars, c)
+	}
+	return string(utf16.Decode(chars))
+}
+
+// lfnChecksum is a checksum of short name, which is stored in entries of long name
+func lfnChecksum(name []byte) byte {
+	var sum byte
+	for _, c := range name[:11] {
+		sum = (sum&1)<<7 + sum>>1 + c
+	}
+	return sum
+}
+
+// shortName decodes 8.3 name, bytes above ASCII are Latin-1.
+func shortName(e []byte) string {
+	decode := func(b []byte, lower bool) string {
+		s := strings.TrimRight(string(latin1(b)), " ")
+		if lower {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+	name := decode(e[:8], e[12]&0x08 != 0)
+	if ext := decode(e[8:11], e[12]&0x10 != 0); ext != "" {
+		name += "." + ext
+	}
+	return name
+}
+
+func latin1(b []byte) []rune {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return r
+}
+
+type lfnPart struct {
+	seq   byte
+	sum   byte
+	chars []uint16
+}
+
+// longName joins parts of long name, they are stored in reverse order
+func longName(parts []lfnPart) string {
+	var chars []uint16
+	for i := len(parts) - 1; i >= 0; i-- {
+		chars = append(chars, parts[i].chars...)
+	}
+	for i, c := range chars {
+		if c == 0 {
+			chars = chars[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(chars))
+}
+
+func lfnEntry(e []byte) lfnPart {
+	p := lfnPart{seq: e[0], sum: e[13]}
+	for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+		for i := r[0]; i < r[1]; i += 2 {
+			p.chars = append(p.chars, binary.LittleEndian.Uint16(e[i:]))
+		}
+	}
+	return p
+}
+
+// parseFAT decodes entries of FAT12/16/32 directory, deleted entries are returned,
+// if recovery is enabled. The lost first char of deleted short name is restored by
+// checksum of its long name or it's replaced by '_'.
+func (v *Volume) parseFAT(b []byte, deleted bool) []Entry {
+	le := binary.LittleEndian
+	var entries []Entry
+	var parts []lfnPart
+	for i := 0; i+dirEntrySize <= len(b) && b[i] != 0; i += dirEntrySize {
+		e := b[i : i+dirEntrySize]
+		attr := e[11]
+		if attr == attrLFN {
+			p := lfnEntry(e)
+			if e[0] != deletedMark && p.seq&0x40 != 0 {
+				parts = nil
+			}
+			if len(parts) > 0 && parts[0].sum != p.sum {
+				parts = nil
+			}
+			parts = append(parts, p)
+			continue
+		}
+		lfn := parts
+		parts = nil
+		if attr&attrVolume != 0 || e[0] == '.' {
+			continue
+		}
+		isDeleted := e[0] == deletedMark
+		if isDeleted && !deleted {
+			continue
+		}
+		short := append([]byte{}, e[:11]...)
+		if short[0] == 0x05 {
+			short[0] = deletedMark
+		}
+		name := ""
+		if isDeleted {
+			short[0] = '_'
+			for c := 0x21; c < 0x100 && len(lfn) > 0; c++ {
+				short[0] = byte(c)
+				if lfnChecksum(short) == lfn[0].sum {
+					break
+				}
+				short[0] = '_'
+			}
+		}
+		if len(lfn) > 0 && lfnChecksum(short) == lfn[0].sum {
+			name = longName(lfn)
+		}
+		if name == "" {
+			name = shortName(append(short, e[11:]...))
+		}
+		cluster := uint32(le.Uint16(e[26:]))
+		if v.Kind == FAT32 {
+			cluster |= uint32(le.Uint16(e[20:])) << 16
+		}
+		entries = append(entries, Entry{
+			Path:       name,
+			Dir:        attr&attrDir != 0,
+			ReadOnly:   attr&attrReadOnly != 0,
+			Deleted:    isDeleted,
+			Size:       int64(le.Uint32(e[28:])),
+			Cluster:    cluster,
+			Modified:   dosTime(le.Uint32(e[22:])),
+			contiguous: isDeleted,
+		})
+	}
+	return entries
+}
+
+// parseExFAT decodes sets of file entries, deleted sets keep names and sizes.
+func (v *Volume) parseExFAT(b []byte, deleted bool) []Entry {
+	le := binary.LittleEndian
+	var entries []Entry
+	for i := 0; i+dirEntrySize <= len(b) && b[i] != 0; i += dirEntrySize {
+		if b[i]&^exFATInUse != exFATFile&^exFATInUse {
+			continue
+		}
+		inUse := b[i]&exFATInUse != 0
+		n := int(b[i+1])
+		if n < 2 || i+(n+1)*dirEntrySize > len(b) || !inUse && !deleted {
+			continue
+		}
+		file := b[i : i+dirEntrySize]
+		stream := b[i+dirEntrySize : i+2*dirEntrySize]
+		if stream[0] != exFATStream&^exFATInUse|b[i]&exFATInUse {
+			continue
+		}
+		var name []byte
+		length := int(stream[3])
+		for k := 2; k <= n; k++ {
+			e := b[i+k*dirEntrySize : i+(k+1)*dirEntrySize]
+			if e[0]|exFATInUse == exFATName {
+				name = append(name, e[2:]...)
+			}
+		}
+		attr := le.Uint16(file[4:])
+		entries = append(entries, Entry{
+			Path:       utf16String(name, length),
+			Dir:        attr&attrDir != 0,
+			ReadOnly:   attr&attrReadOnly != 0,
+			Deleted:    !inUse,
+			Size:       int64(le.Uint64(stream[24:])),
+			Cluster:    le.Uint32(stream[20:]),
+			Modified:   dosTime(le.Uint32(file[12:])),
+			contiguous: stream[1]&exFATNoChain != 0,
+		})
+		i += n * dirEntrySize
+	}
+	return entries
+}
+
+// clusters returns clusters of entry, clusters of deleted entries must be free.
+func (v *Volume) clusters(e *Entry) ([]uint32, error) {
+	size := e.Size
+	if e.Dir && v.Kind != ExFAT {
+		size = 0
+	}
+	clusters, err := v.chain(e.Cluster, size, e.contiguous)
+	if err != nil {
+		return nil, err
+	}
+	if e.Deleted {
+		for _, c := range clusters {
+			if !v.free(c) {
+				e.Lost = true
+				break
+			}
+		}
+	}
+	return clusters, nil
+}
+
+// File returns reader of file contents.
+func (v *Volume) File(e Entry) (io.Reader, error) {
+	clusters, err := v.clusters(&e)
+	if err != nil {
+		return nil, err
+	}
+	readers := make([]io.Reader, len(clusters))
+	for i, c := range clusters {
+		readers[i] = io.NewSectionReader(v.r, v.dataOff+int64(c-firstCluster)*v.ClusterSize, v.ClusterSize)
+	}
+	return io.LimitReader(io.MultiReader(readers...), e.Size), nil
+}
+
+func (v *Volume) readDir(e Entry, deleted bool) ([]Entry, error) {
+	var b []byte
+	if e.Path == "/" && v.rootSize > 0 {
+		b = make([]byte, v.rootSize)
+		if _, err := v.r.ReadAt(b, v.rootOff); err != nil {
+			return nil, fmt.Errorf("%w: root directory: %w", ErrCorrupted, err)
+		}
+	} else {
+		clusters, err := v.clusters(&e)
+		if err != nil {
+			return nil, err
+		}
+		b = make([]byte, int64(len(clusters))*v.ClusterSize)
+		for i, c := range clusters {
+			if err := v.readCluster(c, b[int64(i)*v.ClusterSize:][:v.ClusterSize]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v.Kind == ExFAT {
+		return v.parseExFAT(b, deleted), nil
+	}
+	return v.parseFAT(b, deleted), nil
+}
+
+// Walk calls fn for every entry of tree, entries of deleted directories are deleted too.
+// Errors of directories are passed to fn with entry of directory.
+func (v *Volume) Walk(ctx context.Context, deleted bool, fn func(e Entry, err error) error) error {
+	root := Entry{Path: "/", Dir: true, Cluster: v.rootCluster}
+	return v.walk(ctx, root, deleted, 0, map[visit]bool{{v.rootCluster, false}: true}, fn)
+}
+
+// visit is a walked directory, deleted directory can be in cluster of live one,
+// so they are tracked separately
+type visit struct {
+	cluster uint32
+	deleted bool
+}
+
+func (v *Volume) walk(ctx context.Context, dir Entry, deleted bool, depth int, seen map[visit]bool, fn func(Entry, error) error) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	if depth > maxDepth {
+		return fn(dir, fmt.Errorf("%w: too deep directories", ErrCorrupted))
+	}
+	entries, err := v.readDir(dir, deleted)
+	if err != nil {
+		return fn(dir, err)
+	}
+	for _, e := range entries {
+		if e.Path == "" || e.Path == "." || e.Path == ".." || strings.ContainsAny(e.Path, "/\x00") {
+			continue
+		}
+		e.Path = path.Join(dir.Path, e.Path)
+		e.Deleted = e.Deleted || dir.Deleted
+		e.contiguous = e.contiguous || e.Deleted && v.Kind != ExFAT
+		if e.Deleted {
+			if _, err := v.clusters(&e); err != nil {
+				e.Lost = true
+			}
+		}
+		if err := fn(e, nil); err != nil {
+			return err
+		}
+		if !e.Dir || e.Lost {
+			continue
+		}
+		// loops of directories are skipped
+		key := visit{e.Cluster, e.Deleted}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := v.walk(ctx, e, deleted, depth+1, seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package fat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+type Extractor struct {
+	volume *Volume
+	closer io.Closer
+	out    io.Writer
+	Config config.FAT
+}
+
+func New(cfg config.FAT) *Extractor {
+	return &Extractor{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (e *Extractor) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for extracting: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for extracting: %w", input, err)
+	}
+	e.closer = in
+	if e.Config.Offset < 0 || e.Config.Offset >= stat.Size() {
+		return fmt.Errorf("offset 0x%x is out of file", e.Config.Offset)
+	}
+	e.volume, err = Open(io.NewSectionReader(in, e.Config.Offset, stat.Size()-e.Config.Offset), stat.Size()-e.Config.Offset)
+	if err != nil {
+		return err
+	}
+	if e.Config.Output == "" {
+		name := filepath.Base(input)
+		e.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-fat"
+	}
+	return nil
+}
+
+func (e *Extractor) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Run lists or extracts files, deleted files are recovered only with intact clusters.
+// Errors of single files and directories don't stop extraction of others.
+func (e *Extractor) Run(ctx context.Context) error {
+	fmt.Fprintln(e.out, e.volume)
+	var errs error
+	err := e.volume.Walk(ctx, e.Config.Deleted, func(entry Entry, err error) error {
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", entry.Path, err))
+			return nil
+		}
+		if e.Config.List {
+			fmt.Fprintln(e.out, entry)
+			return nil
+		}
+		if entry.Lost {
+			fmt.Fprintf(e.out, "%s isn't recovered, clusters are overwritten\n", entry.Path)
+			return nil
+		}
+		if err := e.extract(entry); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", entry.Path, err))
+		}
+		return nil
+	})
+	return errors.Join(err, errs)
+}
+
+func (e *Extractor) extract(entry Entry) error {
+	name := filepath.Join(e.Config.Output, filepath.FromSlash(entry.Path))
+	if entry.Dir {
+		return os.MkdirAll(name, 0755)
+	}
+	// recovered file doesn't replace existing one with the same name
+	if _, err := os.Stat(name); err == nil && entry.Deleted {
+		name += fmt.Sprintf(".deleted-0x%x", entry.Cluster)
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	r, err := e.volume.File(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if err := errors.Join(err, f.Close()); err != nil {
+		return err
+	}
+	return os.Chtimes(name, entry.Modified, entry.Modified)
+}
@@ -0,0 +1,456 @@
+package fat
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const testSector = 512
+
+var modified = time.Date(2024, 3, 5, 10, 20, 30, 0, time.UTC)
+
+func timestamp() uint32 {
+	date := uint32(2024-1980)<<9 | 3<<5 | 5
+	return date<<16 | 10<<11 | 20<<5 | 15
+}
+
+// image builds volume with clusters of one sector
+type image struct {
+	kind    Kind
+	b       []byte
+	fat     []uint32
+	dataOff int
+	next    uint32
+}
+
+func (m *image) alloc(n int) []uint32 {
+	var c []uint32
+	for i := 0; i < n; i++ {
+		c = append(c, m.next)
+		m.next++
+	}
+	return c
+}
+
+// write stores data in clusters and links them in FAT
+func (m *image) write(clusters []uint32, data []byte) uint32 {
+	for i, c := range clusters {
+		copy(m.b[m.dataOff+int(c-firstCluster)*testSector:][:testSector], data[min(i*testSector, len(data)):])
+		m.fat[c] = 0x0fffffff
+		if i > 0 {
+			m.fat[clusters[i-1]] = c
+		}
+	}
+	if len(clusters) == 0 {
+		return 0
+	}
+	return clusters[0]
+}
+
+func (m *image) file(data []byte) uint32 {
+	return m.write(m.alloc((len(data)+testSector-1)/testSector), data)
+}
+
+func (m *image) free(c uint32) {
+	m.fat[c] = 0
+}
+
+func shortEntry(name string, attr byte, cluster uint32, size int, deleted bool) []byte {
+	e := make([]byte, dirEntrySize)
+	copy(e, name)
+	e[11] = attr
+	binary.LittleEndian.PutUint16(e[20:], uint16(cluster>>16))
+	binary.LittleEndian.PutUint32(e[22:], timestamp())
+	binary.LittleEndian.PutUint16(e[26:], uint16(cluster))
+	binary.LittleEndian.PutUint32(e[28:], uint32(size))
+	if deleted {
+		e[0] = deletedMark
+	}
+	return e
+}
+
+func longEntries(long, short string, deleted bool) []byte {
+	chars := utf16.Encode([]rune(long))
+	chars = append(chars, 0)
+	for len(chars)%13 != 0 {
+		chars = append(chars, 0xffff)
+	}
+	n := len(chars) / 13
+	var b []byte
+	for seq := n; seq >= 1; seq-- {
+		e := make([]byte, dirEntrySize)
+		e[0] = byte(seq)
+		if seq == n {
+			e[0] |= 0x40
+		}
+		if deleted {
+			e[0] = deletedMark
+		}
+		e[11] = attrLFN
+		e[13] = lfnChecksum([]byte(short))
+		part := chars[(seq-1)*13 : seq*13]
+		k := 0
+		for _, r := range [][2]int{{1, 11}, {14, 26}, {28, 32}} {
+			for i := r[0]; i < r[1]; i += 2 {
+				binary.LittleEndian.PutUint16(e[i:], part[k])
+				k++
+			}
+		}
+		b = append(b, e...)
+	}
+	return b
+}
+
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+// fatImage has files, fragmented file with long name, directory,
+// recoverable deleted file and deleted file with reused clusters.
+func fatImage(t *testing.T, kind Kind) []byte {
+	t.Helper()
+	var reserved, fatSize, rootEntries, clusters int
+	switch kind {
+	case FAT12:
+		reserved, fatSize, rootEntries, clusters = 1, 6, 64, 900
+	case FAT32:
+		reserved, fatSize, rootEntries, clusters = 32, 520, 0, 66000
+	}
+	rootSectors := rootEntries * dirEntrySize / testSector
+	total := reserved + fatSize + rootSectors + clusters
+	m := &image{
+		kind:    kind,
+		b:       make([]byte, total*testSector),
+		fat:     make([]uint32, clusters+firstCluster),
+		dataOff: (reserved + fatSize + rootSectors) * testSector,
+		next:    firstCluster,
+	}
+	m.fat[0], m.fat[1] = 0x0ffffff8, 0x0fffffff
+	boot := m.b[:testSector]
+	le := binary.LittleEndian
+	le.PutUint16(boot[0x0b:], testSector)
+	boot[0x0d] = 1
+	le.PutUint16(boot[0x0e:], uint16(reserved))
+	boot[0x10] = 1
+	le.PutUint16(boot[0x11:], uint16(rootEntries))
+	le.PutUint32(boot[0x20:], uint32(total))
+	le.PutUint16(boot[510:], bootSignature)
+	label := boot[0x26:]
+	if kind == FAT32 {
+		label = boot[0x42:]
+	}
+	label[0] = extBootSignature
+	copy(label[5:], "TESTVOL    ")
+
+	readme := m.file([]byte("hello"))
+	// long file is fragmented by other files
+	long := pattern(1500)
+	lc := m.alloc(5)
+	m.write([]uint32{lc[0], lc[2], lc[4]}, long)
+	note := m.file([]byte("note"))
+	photo := pattern(700)
+	deleted := m.file(photo)
+	m.free(deleted)
+	m.free(deleted + 1)
+
+	var docs []byte
+	docs = append(docs, shortEntry(".          ", attrDir, 0, 0, false)...)
+	docs = append(docs, shortEntry("..         ", attrDir, 0, 0, false)...)
+	n := shortEntry("NOTE    TXT", 0, note, 4, false)
+	n[12] = 0x18
+	docs = append(docs, n...)
+	docsCluster := m.file(docs)
+
+	var root []byte
+	root = append(root, shortEntry("TESTVOL    ", attrVolume, 0, 0, false)...)
+	root = append(root, shortEntry("README  TXT", attrReadOnly, readme, 5, false)...)
+	root = append(root, longEntries("Long file name.bin", "LONGFI~1BIN", false)...)
+	root = append(root, shortEntry("LONGFI~1BIN", 0, lc[0], len(long), false)...)
+	d := shortEntry("DOCS       ", attrDir, docsCluster, 0, false)
+	d[12] = 0x08
+	root = append(root, d...)
+	root = append(root, longEntries("Deleted photo.jpg", "DELETE~1JPG", true)...)
+	root = append(root, shortEntry("DELETE~1JPG", 0, deleted, len(photo), true)...)
+	root = append(root, shortEntry("GONE    TXT", 0, readme, 5, true)...)
+
+	switch kind {
+	case FAT12:
+		le.PutUint16(boot[0x13:], uint16(total))
+		le.PutUint16(boot[0x16:], uint16(fatSize))
+		copy(m.b[(reserved+fatSize)*testSector:], root)
+		fat := m.b[reserved*testSector:]
+		for i, v := range m.fat {
+			v &= 0xfff
+			e := le.Uint16(fat[i*3/2:])
+			if i%2 == 0 {
+				e = e&0xf000 | uint16(v)
+			} else {
+				e = e&0x000f | uint16(v)<<4
+			}
+			le.PutUint16(fat[i*3/2:], e)
+		}
+	case FAT32:
+		le.PutUint32(boot[0x24:], uint32(fatSize))
+		le.PutUint32(boot[0x2c:], m.file(root))
+		fat := m.b[reserved*testSector:]
+		for i, v := range m.fat {
+			le.PutUint32(fat[i*4:], v)
+		}
+	}
+	return m.b
+}
+
+func exFATEntries(name string, attr uint16, cluster uint32, size int, contiguous, deleted bool) []byte {
+	chars := utf16.Encode([]rune(name))
+	names := (len(chars) + 14) / 15
+	b := make([]byte, (2+names)*dirEntrySize)
+	le := binary.LittleEndian
+	b[0] = exFATFile
+	b[1] = byte(1 + names)
+	le.PutUint16(b[4:], attr)
+	le.PutUint32(b[12:], timestamp())
+	s := b[dirEntrySize:]
+	s[0] = exFATStream
+	s[1] = 0x01
+	if contiguous {
+		s[1] |= exFATNoChain
+	}
+	s[3] = byte(len(chars))
+	le.PutUint64(s[8:], uint64(size))
+	le.PutUint32(s[20:], cluster)
+	le.PutUint64(s[24:], uint64(size))
+	for i := 0; i < names; i++ {
+		e := b[(2+i)*dirEntrySize:]
+		e[0] = exFATName
+		for k, c := range chars[i*15 : min((i+1)*15, len(chars))] {
+			le.PutUint16(e[2+2*k:], c)
+		}
+	}
+	if deleted {
+		for i := 0; i < len(b); i += dirEntrySize {
+			b[i] &^= exFATInUse
+		}
+	}
+	return b
+}
+
+func exFATImage(t *testing.T) []byte {
+	t.Helper()
+	const clusters = 100
+	m := &image{
+		kind:    ExFAT,
+		b:       make([]byte, (2+clusters)*testSector),
+		fat:     make([]uint32, clusters+firstCluster),
+		dataOff: 2 * testSector,
+		next:    firstCluster,
+	}
+	le := binary.LittleEndian
+	boot := m.b[:testSector]
+	copy(boot[3:], exFATSignature)
+	le.PutUint32(boot[0x50:], 1)
+	le.PutUint32(boot[0x54:], 1)
+	le.PutUint32(boot[0x58:], 2)
+	le.PutUint32(boot[0x5c:], clusters)
+	boot[0x6c] = 9
+	le.PutUint16(boot[510:], bootSignature)
+
+	bitmap := m.alloc(1)
+	unicode := pattern(700)
+	// contiguous file has no FAT chain
+	uc := m.alloc(2)
+	copy(m.b[m.dataOff+int(uc[0]-firstCluster)*testSector:], unicode)
+	inner := pattern(600)
+	ic := m.alloc(3)
+	m.write([]uint32{ic[2], ic[0]}, inner)
+	removed := m.alloc(1)
+	copy(m.b[m.dataOff+int(removed[0]-firstCluster)*testSector:], "removed")
+
+	dir := exFATEntries("inner.bin", 0, ic[2], len(inner), false, false)
+	dirCluster := m.file(dir)
+
+	var root []byte
+	e := make([]byte, dirEntrySize)
+	e[0] = 0x81
+	le.PutUint32(e[20:], bitmap[0])
+	le.PutUint64(e[24:], (clusters+7)/8)
+	root = append(root, e...)
+	e = make([]byte, dirEntrySize)
+	e[0], e[1] = 0x83, 4
+	for i, c := range utf16.Encode([]rune("DISK")) {
+		le.PutUint16(e[2+2*i:], c)
+	}
+	root = append(root, e...)
+	root = append(root, exFATEntries("Ünïcode name with more than 15 chars.txt", attrReadOnly, uc[0], len(unicode), true, false)...)
+	root = append(root, exFATEntries("dir", attrDir, dirCluster, testSector, false, false)...)
+	root = append(root, exFATEntries("removed.log", 0, removed[0], 7, true, true)...)
+	rootCluster := m.file(root)
+	le.PutUint32(boot[0x60:], rootCluster)
+
+	// every allocated cluster except of deleted file
+	bits := m.b[m.dataOff+int(bitmap[0]-firstCluster)*testSector:]
+	for c := uint32(firstCluster); c < m.next; c++ {
+		if c == removed[0] || c == ic[1] {
+			continue
+		}
+		i := c - firstCluster
+		bits[i/8] |= 1 << (i % 8)
+	}
+	m.fat[bitmap[0]] = 0xffffffff
+	fat := m.b[testSector:]
+	for i, v := range m.fat {
+		le.PutUint32(fat[i*4:], v)
+	}
+	return m.b
+}
+
+func walk(t *testing.T, v *Volume, deleted bool) []Entry {
+	var entries []Entry
+	err := v.Walk(context.Background(), deleted, func(e Entry, err error) error {
+		require.NoError(t, err)
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	return entries
+}
+
+func TestFAT(t *testing.T) {
+	for _, kind := range []Kind{FAT12, FAT32} {
+		t.Run(string(kind), func(t *testing.T) {
+			img := fatImage(t, kind)
+			v, err := Open(bytes.NewReader(img), int64(len(img)))
+			require.NoError(t, err)
+			require.Equal(t, kind, v.Kind)
+			require.Equal(t, "TESTVOL", v.Label)
+
+			entries := walk(t, v, true)
+			var paths []string
+			for _, e := range entries {
+				paths = append(paths, e.String())
+			}
+			require.Equal(t, []string{
+				"- 0x0000000005 2024-03-05 10:20:30 /README.TXT",
+				"- 0x00000005dc 2024-03-05 10:20:30 /Long file name.bin",
+				"d 0x0000000000 2024-03-05 10:20:30 /docs",
+				"- 0x0000000004 2024-03-05 10:20:30 /docs/note.txt",
+				"- 0x00000002bc 2024-03-05 10:20:30 /Deleted photo.jpg (deleted)",
+				"- 0x0000000005 2024-03-05 10:20:30 /_ONE.TXT (deleted, clusters are overwritten)",
+			}, paths)
+			require.Len(t, walk(t, v, false), 4)
+
+			for path, want := range map[int][]byte{1: pattern(1500), 4: pattern(700)} {
+				r, err := v.File(entries[path])
+				require.NoError(t, err)
+				got := &bytes.Buffer{}
+				_, err = got.ReadFrom(r)
+				require.NoError(t, err)
+				require.Equal(t, want, got.Bytes())
+			}
+		})
+	}
+}
+
+func TestDeletedLoop(t *testing.T) {
+	img := fatImage(t, FAT12)
+	// free cluster of deleted directory has deleted subdirectories, which point
+	// back to it
+	const cluster = 800
+	root := img[(1+6)*testSector:][:4*testSector]
+	dir := img[(1+6+4+cluster-firstCluster)*testSector:][:testSector]
+	for i := 0; i < 4; i++ {
+		copy(dir[i*dirEntrySize:], shortEntry(fmt.Sprintf("LOOP%d      ", i), attrDir, cluster, 0, true))
+	}
+	free := 0
+	for root[free] != 0 {
+		free += dirEntrySize
+	}
+	copy(root[free:], shortEntry("LOOP       ", attrDir, cluster, 0, true))
+
+	v, err := Open(bytes.NewReader(img), int64(len(img)))
+	require.NoError(t, err)
+	entries := walk(t, v, true)
+	require.Len(t, entries, 6+1+4)
+}
+
+func TestExFAT(t *testing.T) {
+	img := exFATImage(t)
+	v, err := Open(bytes.NewReader(img), int64(len(img)))
+	require.NoError(t, err)
+	require.Equal(t, ExFAT, v.Kind)
+	require.Equal(t, "DISK", v.Label)
+	entries := walk(t, v, true)
+	require.Equal(t, []Entry{
+		{Path: "/Ünïcode name with more than 15 chars.txt", ReadOnly: true, Size: 700, Cluster: 3, Modified: modified, contiguous: true},
+		{Path: "/dir", Dir: true, Size: testSector, Cluster: 9, Modified: modified},
+		{Path: "/dir/inner.bin", Size: 600, Cluster: 7, Modified: modified},
+		{Path: "/removed.log", Deleted: true, Size: 7, Cluster: 8, Modified: modified, contiguous: true},
+	}, entries)
+}
+
+func TestHugeFAT(t *testing.T) {
+	tests := []struct {
+		name   string
+		img    []byte
+		fields map[int]uint32
+	}{
+		// total sectors and sectors of FAT
+		{"FAT32", fatImage(t, FAT32), map[int]uint32{0x20: 0xffffffff, 0x24: 0x2000000}},
+		// length of FAT and count of clusters
+		{"exFAT", exFATImage(t), map[int]uint32{0x54: 0xffffffff, 0x5c: 0xffffffff}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for off, v := range tt.fields {
+				binary.LittleEndian.PutUint32(tt.img[off:], v)
+			}
+			_, err := Open(bytes.NewReader(tt.img), int64(len(tt.img)))
+			require.ErrorIs(t, err, ErrCorrupted)
+		})
+	}
+}
+
+func TestExtractor(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "disk.img")
+	offset := 0x1000
+	img := append(make([]byte, offset), fatImage(t, FAT12)...)
+	require.NoError(t, os.WriteFile(input, img, 0644))
+
+	e := New(config.FAT{Offset: int64(offset), Deleted: true})
+	out := &bytes.Buffer{}
+	e.out = out
+	require.NoError(t, e.Open(input))
+	defer e.Close()
+	e.Config.Output = filepath.Join(dir, "out")
+	require.NoError(t, e.Run(context.Background()))
+	require.Equal(t, "FAT12, cluster 0x200, 900 clusters, label \"TESTVOL\"\n/_ONE.TXT isn't recovered, clusters are overwritten\n", out.String())
+
+	for name, want := range map[string][]byte{
+		"README.TXT":         []byte("hello"),
+		"Long file name.bin": pattern(1500),
+		"docs/note.txt":      []byte("note"),
+		"Deleted photo.jpg":  pattern(700),
+	} {
+		got, err := os.ReadFile(filepath.Join(e.Config.Output, name))
+		require.NoError(t, err)
+		require.Equal(t, want, got, name)
+	}
+	stat, err := os.Stat(filepath.Join(e.Config.Output, "README.TXT"))
+	require.NoError(t, err)
+	require.True(t, stat.ModTime().Equal(modified))
+}
@@ -0,0 +1,279 @@
+package fat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var ErrFAT = errors.New("invalid FAT")
+var ErrCorrupted = errors.New("corrupted filesystem")
+
+type Kind string
+
+const (
+	FAT12 Kind = "FAT12"
+	FAT16 Kind = "FAT16"
+	FAT32 Kind = "FAT32"
+	ExFAT Kind = "exFAT"
+)
+
+const (
+	bootSignature  = 0xaa55
+	exFATSignature = "EXFAT   "
+	// extended boot record has label
+	extBootSignature = 0x29
+	dirEntrySize     = 32
+	// the first cluster of data area
+	firstCluster = 2
+)
+
+// Volume is a FAT12/16/32 or exFAT filesystem, FAT is read in memory.
+type Volume struct {
+	r           io.ReaderAt
+	size        int64
+	Kind        Kind
+	Label       string
+	ClusterSize int64
+	Clusters    uint32
+	dataOff     int64
+	// fixed root directory of FAT12 and FAT16
+	rootOff, rootSize int64
+	rootCluster       uint32
+	fat               []uint32
+	// allocation bitmap of exFAT
+	bitmap []byte
+}
+
+func (v *Volume) String() string {
+	s := fmt.Sprintf("%s, cluster 0x%x, %d clusters", v.Kind, v.ClusterSize, v.Clusters)
+	if v.Label != "" {
+		s += fmt.Sprintf(", label %q", v.Label)
+	}
+	return s
+}
+
+// Open reads boot sector and FAT of volume, r begins at boot sector and has
+// size bytes.
+func Open(r io.ReaderAt, size int64) (*Volume, error) {
+	b := make([]byte, 512)
+	if _, err := r.ReadAt(b, 0); err != nil {
+		return nil, fmt.Errorf("%w: boot sector: %w", ErrFAT, err)
+	}
+	if binary.LittleEndian.Uint16(b[510:]) != bootSignature {
+		return nil, fmt.Errorf("%w: no signature of boot sector", ErrFAT)
+	}
+	v := &Volume{r: r, size: size}
+	var err error
+	if string(b[3:11]) == exFATSignature {
+		err = v.openExFAT(b)
+	} else {
+		err = v.openFAT(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (v *Volume) openFAT(b []byte) error {
+	le := binary.LittleEndian
+	sector := int64(le.Uint16(b[0x0b:]))
+	perCluster := int64(b[0x0d])
+	reserved := int64(le.Uint16(b[0x0e:]))
+	fats := int64(b[0x10])
+	rootEntries := int64(le.Uint16(b[0x11:]))
+	total := int64(le.Uint16(b[0x13:]))
+	if total == 0 {
+		total = int64(le.Uint32(b[0x20:]))
+	}
+	fatSize := int64(le.Uint16(b[0x16:]))
+	if fatSize == 0 {
+		fatSize = int64(le.Uint32(b[0x24:]))
+	}
+	if sector < 512 || sector > 4096 || sector&(sector-1) != 0 || perCluster == 0 || perCluster&(perCluster-1) != 0 {
+		return fmt.Errorf("%w: sector 0x%x, sectors per cluster %d", ErrFAT, sector, perCluster)
+	}
+	if reserved == 0 || fats == 0 || fatSize == 0 {
+		return fmt.Errorf("%w: reserved %d, FATs %d of %d sectors", ErrFAT, reserved, fats, fatSize)
+	}
+	v.ClusterSize = sector * perCluster
+	v.rootOff = (reserved + fats*fatSize) * sector
+	v.rootSize = rootEntries * dirEntrySize
+	v.dataOff = v.rootOff + (v.rootSize+sector-1)/sector*sector
+	data := total*sector - v.dataOff
+	if data <= 0 {
+		return fmt.Errorf("%w: no data area", ErrFAT)
+	}
+	v.Clusters = uint32(data / v.ClusterSize)
+	// type is defined by count of clusters only
+	ext := b[0x26:]
+	switch {
+	case v.Clusters < 4085:
+		v.Kind = FAT12
+	case v.Clusters < 65525:
+		v.Kind = FAT16
+	default:
+		v.Kind = FAT32
+		v.rootCluster = le.Uint32(b[0x2c:])
+		v.rootSize = 0
+		ext = b[0x42:]
+	}
+	if l := strings.TrimRight(string(ext[5:16]), " "); ext[0] == extBootSignature && l != "NO NAME" {
+		v.Label = l
+	}
+	return v.readFAT(reserved*sector, fatSize*sector)
+}
+
+func (v *Volume) openExFAT(b []byte) error {
+	le := binary.LittleEndian
+	sectorShift, clusterShift := b[0x6c], b[0x6d]
+	if sectorShift < 9 || sectorShift > 12 || sectorShift+clusterShift > 25 {
+		return fmt.Errorf("%w: sector shift %d, cluster shift %d", ErrFAT, sectorShift, clusterShift)
+	}
+	sector := int64(1) << sectorShift
+	v.Kind = ExFAT
+	v.ClusterSize = sector << clusterShift
+	v.dataOff = int64(le.Uint32(b[0x58:])) * sector
+	v.Clusters = le.Uint32(b[0x5c:])
+	v.rootCluster = le.Uint32(b[0x60:])
+	if err := v.readFAT(int64(le.Uint32(b[0x50:]))*sector, int64(le.Uint32(b[0x54:]))*sector); err != nil {
+		return err
+	}
+	// bitmap and label are entries of root directory
+	root, err := v.readChain(v.rootCluster, 0, false)
+	if err != nil {
+		return fmt.Errorf("root directory: %w", err)
+	}
+	for i := 0; i+dirEntrySize <= len(root) && root[i] != 0; i += dirEntrySize {
+		e := root[i : i+dirEntrySize]
+		switch e[0] {
+		case 0x81:
+			size := int64(le.Uint64(e[24:]))
+			if size < int64(v.Clusters+7)/8 || size > int64(v.Clusters+7)/8+v.ClusterSize {
+				return fmt.Errorf("%w: size of allocation bitmap 0x%x", ErrCorrupted, size)
+			}
+			if v.bitmap, err = v.readChain(le.Uint32(e[20:]), size, true); err != nil {
+				return fmt.Errorf("allocation bitmap: %w", err)
+			}
+		case 0x83:
+			v.Label = utf16String(e[2:], min(int(e[1]), 11))
+		}
+	}
+	if v.bitmap == nil {
+		return fmt.Errorf("%w: no allocation bitmap", ErrCorrupted)
+	}
+	return nil
+}
+
+// readFAT reads the first FAT at off, entries are extended to 32 bits.
+func (v *Volume) readFAT(off, size int64) error {
+	if off < 0 || size > v.size-off {
+		return fmt.Errorf("%w: FAT 0x%x at 0x%x is out of volume 0x%x", ErrCorrupted, size, off, v.size)
+	}
+	n := int64(v.Clusters) + firstCluster
+	bits := map[Kind]int64{FAT12: 12, FAT16: 16, FAT32: 32, ExFAT: 32}[v.Kind]
+	need := (n*bits + 7) / 8
+	if need > size {
+		return fmt.Errorf("%w: FAT 0x%x is less than 0x%x for %d clusters", ErrFAT, size, need, v.Clusters)
+	}
+	b := make([]byte, need)
+	if _, err := v.r.ReadAt(b, off); err != nil {
+		return fmt.Errorf("%w: FAT at 0x%x: %w", ErrCorrupted, off, err)
+	}
+	le := binary.LittleEndian
+	v.fat = make([]uint32, n)
+	for i := range v.fat {
+		switch v.Kind {
+		case FAT12:
+			e := uint32(le.Uint16(b[i*3/2:]))
+			if i%2 == 0 {
+				v.fat[i] = e & 0xfff
+			} else {
+				v.fat[i] = e >> 4
+			}
+		case FAT16:
+			v.fat[i] = uint32(le.Uint16(b[i*2:]))
+		case FAT32:
+			v.fat[i] = le.Uint32(b[i*4:]) & 0x0fffffff
+		default:
+			v.fat[i] = le.Uint32(b[i*4:])
+		}
+	}
+	return nil
+}
+
+func (v *Volume) valid(c uint32) bool {
+	return c >= firstCluster && int64(c) < int64(v.Clusters)+firstCluster
+}
+
+// free reports, that cluster isn't used by any file.
+func (v *Volume) free(c uint32) bool {
+	if v.Kind == ExFAT {
+		i := c - firstCluster
+		return v.bitmap[i/8]>>(i%8)&1 == 0
+	}
+	return v.fat[c] == 0
+}
+
+// chain returns clusters of file from start, contiguous files don't use FAT,
+// zero size means the whole chain.
+func (v *Volume) chain(start uint32, size int64, contiguous bool) ([]uint32, error) {
+	if start == 0 && size == 0 {
+		return nil, nil
+	}
+	n := (size + v.ClusterSize - 1) / v.ClusterSize
+	var clusters []uint32
+	if contiguous {
+		// directories of FAT have no size
+		n = max(n, 1)
+		if !v.valid(start) || !v.valid(start+uint32(n)-1) {
+			return nil, fmt.Errorf("%w: clusters 0x%x-0x%x are out of volume", ErrCorrupted, start, start+uint32(n)-1)
+		}
+		for i := uint32(0); i < uint32(n); i++ {
+			clusters = append(clusters, start+i)
+		}
+		return clusters, nil
+	}
+	for c := start; v.valid(c); c = v.fat[c] {
+		if len(clusters) > int(v.Clusters) {
+			return nil, fmt.Errorf("%w: loop in FAT at cluster 0x%x", ErrCorrupted, start)
+		}
+		clusters = append(clusters, c)
+		if size > 0 && int64(len(clusters)) == n {
+			break
+		}
+	}
+	if len(clusters) == 0 || int64(len(clusters)) < n {
+		return nil, fmt.Errorf("%w: chain from cluster 0x%x has %d of %d clusters", ErrCorrupted, start, len(clusters), n)
+	}
+	return clusters, nil
+}
+
+func (v *Volume) readChain(start uint32, size int64, contiguous bool) ([]byte, error) {
+	clusters, err := v.chain(start, size, contiguous)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, int64(len(clusters))*v.ClusterSize)
+	for i, c := range clusters {
+		if err := v.readCluster(c, b[int64(i)*v.ClusterSize:][:v.ClusterSize]); err != nil {
+			return nil, err
+		}
+	}
+	if size > 0 {
+		b = b[:size]
+	}
+	return b, nil
+}
+
+func (v *Volume) readCluster(c uint32, b []byte) error {
+	off := v.dataOff + int64(c-firstCluster)*v.ClusterSize
+	_, err := v.r.ReadAt(b, off)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: cluster 0x%x is out of image", ErrCorrupted, c)
+	}
+	return err
+}
@@ -0,0 +1,343 @@
+package ftl
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrGeometry = errors.New("invalid page geometry")
+var ErrLayout = errors.New("invalid layout of spare area")
+var ErrMapper = errors.New("unknown mapper")
+
+// Layout is a geometry of dump with positions of FTL fields in spare area.
+type Layout struct {
+	config.Cut
+	Order binary.ByteOrder
+}
+
+func (l Layout) page() int {
+	return l.PageSize + l.SkipSize
+}
+
+// Field reads field of spare area, erased field isn't valid.
+func (l Layout) Field(spare []byte, offset, size int) (uint64, bool) {
+	b := spare[offset : offset+size]
+	if erased(b) {
+		return 0, false
+	}
+	var v uint64
+	for i := range b {
+		if l.Order == binary.LittleEndian {
+			v |= uint64(b[i]) << (8 * i)
+		} else {
+			v = v<<8 | uint64(b[i])
+		}
+	}
+	return v, true
+}
+
+func erased(b []byte) bool {
+	for _, c := range b {
+		if c != 0xff {
+			return false
+		}
+	}
+	return true
+}
+
+func (l Layout) check() error {
+	if l.PageSize <= 0 || l.SkipSize <= 0 || l.PagesPerBlock <= 0 {
+		return fmt.Errorf("%w: page 0x%x, spare 0x%x, %d pages in block", ErrGeometry, l.PageSize, l.SkipSize, l.PagesPerBlock)
+	}
+	fields := []struct {
+		name         string
+		offset, size int
+	}{
+		{"LBA", l.LBAOffset, l.LBASize},
+		{"version", l.VersionOffset, l.VersionSize},
+	}
+	for i, f := range fields {
+		// version is optional
+		if i > 0 && f.size == 0 {
+			continue
+		}
+		if f.size <= 0 || f.size > 8 || f.offset < 0 || f.offset+f.size > l.SkipSize {
+			return fmt.Errorf("%w: %s at 0x%x with size %d, spare is 0x%x", ErrLayout, f.name, f.offset, f.size, l.SkipSize)
+		}
+	}
+	return nil
+}
+
+// Mapping is a logical number of physical block.
+type Mapping struct {
+	LBA     uint64
+	Version uint64
+	// Pages is a number of programmed pages
+	Pages int
+}
+
+// Mapper finds logical number of physical block by its pages with spare areas,
+// block without logical number is free.
+type Mapper func(block []byte, layout Layout) (m Mapping, ok bool)
+
+// Mappers are supported FTLs by names
+var Mappers = map[string]Mapper{
+	"lba": LBAInSpare,
+}
+
+// LBAInSpare is generic FTL, which writes logical number and optional version
+// in spare area of pages. Number of block is chosen by majority of its pages,
+// so bit flips in some spare areas don't break mapping.
+func LBAInSpare(block []byte, layout Layout) (Mapping, bool) {
+	votes := make(map[Mapping]int)
+	pages := 0
+	for off := 0; off+layout.page() <= len(block); off += layout.page() {
+		page := block[off : off+layout.page()]
+		if erased(page) {
+			continue
+		}
+		pages++
+		spare := page[layout.PageSize:]
+		lba, ok := layout.Field(spare, layout.LBAOffset, layout.LBASize)
+		if !ok {
+			continue
+		}
+		m := Mapping{LBA: lba}
+		if layout.VersionSize > 0 {
+			m.Version, _ = layout.Field(spare, layout.VersionOffset, layout.VersionSize)
+		}
+		votes[m]++
+	}
+	var best Mapping
+	most := 0
+	for m, n := range votes {
+		if n > most || n == most && (m.LBA < best.LBA || m.LBA == best.LBA && m.Version > best.Version) {
+			best, most = m, n
+		}
+	}
+	best.Pages = pages
+	return best, most > 0
+}
+
+// Block is a physical block with its mapping.
+type Block struct {
+	Physical int64
+	Mapping
+}
+
+// Table is a map of logical blocks, duplicates are older copies of blocks.
+type Table struct {
+	Physical   int64
+	Free       int64
+	Logical    map[uint64]Block
+	Duplicates []Block
+}
+
+// newer decides, which copy of logical block is actual: the highest version,
+// then more programmed pages, then the last physical block.
+func newer(a, b Block) bool {
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+	if a.Pages != b.Pages {
+		return a.Pages > b.Pages
+	}
+	return a.Physical > b.Physical
+}
+
+// Blocks returns number of logical blocks, it's the highest mapped LBA plus one.
+func (t *Table) Blocks() uint64 {
+	var n uint64
+	for lba := range t.Logical {
+		n = max(n, lba+1)
+	}
+	return n
+}
+
+func (t *Table) String() string {
+	missing := t.Blocks() - uint64(len(t.Logical))
+	return fmt.Sprintf("0x%x physical blocks: 0x%x mapped, 0x%x free, 0x%x duplicates; 0x%x logical blocks, 0x%x missing",
+		t.Physical, len(t.Logical), t.Free, len(t.Duplicates), t.Blocks(), missing)
+}
+
+type Reconstructor struct {
+	input  io.ReaderAt
+	size   int64
+	closer io.Closer
+	output io.WriteCloser
+	mapper Mapper
+	out    io.Writer
+	Config config.FTL
+	Cut    config.Cut
+}
+
+func New(cfg config.FTL, geometry config.Cut) *Reconstructor {
+	return &Reconstructor{
+		Config: cfg,
+		Cut:    geometry,
+		out:    os.Stdout,
+	}
+}
+
+func (r *Reconstructor) layout() Layout {
+	l := Layout{Cut: r.Cut, Order: binary.LittleEndian}
+	if r.Config.BigEndian {
+		l.Order = binary.BigEndian
+	}
+	return l
+}
+
+func (r *Reconstructor) Open(input string) error {
+	if err := r.layout().check(); err != nil {
+		return err
+	}
+	mapper, ok := Mappers[r.Config.Mapper]
+	if !ok {
+		names := make([]string, 0, len(Mappers))
+		for name := range Mappers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%w: %s, supported: %s", ErrMapper, r.Config.Mapper, strings.Join(names, ", "))
+	}
+	r.mapper = mapper
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for reconstruction: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for reconstruction: %w", input, err)
+	}
+	r.input = in
+	r.size = stat.Size()
+	r.closer = in
+	if r.Config.Map {
+		return nil
+	}
+	if r.Config.Output == "" {
+		r.Config.Output = strings.TrimSuffix(input, ".bin") + "-logical.bin"
+	}
+	r.output, err = os.OpenFile(r.Config.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("can't create file '%s' for reconstruction: %w", r.Config.Output, err)
+	}
+	return nil
+}
+
+func (r *Reconstructor) Close() error {
+	var err error
+	if r.closer != nil {
+		err = r.closer.Close()
+	}
+	if r.output != nil {
+		err = errors.Join(err, r.output.Close())
+	}
+	return err
+}
+
+// Run builds table of logical blocks and writes logical image, with --map
+// only table is printed.
+func (r *Reconstructor) Run(ctx context.Context) error {
+	t, err := r.Scan(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, t)
+	if r.Config.Map {
+		lbas := make([]uint64, 0, len(t.Logical))
+		for lba := range t.Logical {
+			lbas = append(lbas, lba)
+		}
+		sort.Slice(lbas, func(i, j int) bool { return lbas[i] < lbas[j] })
+		for _, lba := range lbas {
+			b := t.Logical[lba]
+			fmt.Fprintf(r.out, "0x%06x -> 0x%06x version 0x%x, %d pages\n", lba, b.Physical, b.Version, b.Pages)
+		}
+		for _, b := range t.Duplicates {
+			fmt.Fprintf(r.out, "0x%06x duplicate 0x%06x version 0x%x, %d pages\n", b.LBA, b.Physical, b.Version, b.Pages)
+		}
+		return nil
+	}
+	return r.Write(ctx, t, r.output)
+}
+
+// Scan reads blocks of dump one by one and maps them to logical blocks.
+func (r *Reconstructor) Scan(ctx context.Context) (*Table, error) {
+	l := r.layout()
+	block := make([]byte, l.page()*l.PagesPerBlock)
+	t := &Table{Logical: make(map[uint64]Block)}
+	for off := int64(0); off+int64(len(block)) <= r.size; off += int64(len(block)) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if _, err := r.input.ReadAt(block, off); err != nil {
+			return nil, err
+		}
+		b := Block{Physical: t.Physical}
+		t.Physical++
+		var ok bool
+		b.Mapping, ok = r.mapper(block, l)
+		// logical space can't be bigger than physical one
+		if !ok || b.LBA >= uint64(r.size/int64(len(block))) {
+			t.Free++
+			continue
+		}
+		old, ok := t.Logical[b.LBA]
+		if ok && newer(old, b) {
+			t.Duplicates = append(t.Duplicates, b)
+			continue
+		}
+		if ok {
+			t.Duplicates = append(t.Duplicates, old)
+		}
+		t.Logical[b.LBA] = b
+	}
+	return t, nil
+}
+
+// Write writes data areas of logical blocks in order, missing blocks are erased.
+func (r *Reconstructor) Write(ctx context.Context, t *Table, w io.Writer) error {
+	l := r.layout()
+	block := make([]byte, l.page()*l.PagesPerBlock)
+	data := make([]byte, 0, l.PageSize*l.PagesPerBlock)
+	missing := bytes.Repeat([]byte{0xff}, cap(data))
+	blocks := t.Blocks()
+	for lba := uint64(0); lba < blocks; lba++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		b, ok := t.Logical[lba]
+		if !ok {
+			if _, err := w.Write(missing); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := r.input.ReadAt(block, b.Physical*int64(len(block))); err != nil {
+			return err
+		}
+		data = data[:0]
+		for off := 0; off < len(block); off += l.page() {
+			data = append(data, block[off:off+l.PageSize]...)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package ftl
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const (
+	testPage   = 16
+	testSpare  = 8
+	testPages  = 4
+	blockSize  = testPage * testPages
+	lbaOffset  = 2
+	verOffset  = 4
+	noVersion  = -1
+	erasedPage = -1
+)
+
+var geometry = config.Cut{
+	PageSize:      testPage,
+	SkipSize:      testSpare,
+	PagesPerBlock: testPages,
+	LBAOffset:     lbaOffset,
+	LBASize:       2,
+	VersionOffset: verOffset,
+	VersionSize:   2,
+}
+
+type testBlock struct {
+	fill    byte
+	lba     []int
+	version int
+}
+
+// dump builds blocks, lba of every page is written in little-endian, -1 is erased page
+func dump(blocks ...testBlock) []byte {
+	var b []byte
+	for _, blk := range blocks {
+		for i := 0; i < testPages; i++ {
+			page := bytes.Repeat([]byte{0xff}, testPage+testSpare)
+			lba := erasedPage
+			if i < len(blk.lba) {
+				lba = blk.lba[i]
+			}
+			if lba != erasedPage {
+				copy(page, bytes.Repeat([]byte{blk.fill}, testPage))
+				spare := page[testPage:]
+				spare[lbaOffset], spare[lbaOffset+1] = byte(lba), byte(lba>>8)
+				if blk.version != noVersion {
+					spare[verOffset], spare[verOffset+1] = byte(blk.version), byte(blk.version>>8)
+				}
+			}
+			b = append(b, page...)
+		}
+	}
+	return b
+}
+
+func logical(fills ...byte) []byte {
+	var b []byte
+	for _, f := range fills {
+		b = append(b, bytes.Repeat([]byte{f}, blockSize)...)
+	}
+	return b
+}
+
+func TestReconstructor(t *testing.T) {
+	tests := []struct {
+		name  string
+		cut   config.Cut
+		dump  []byte
+		table string
+		want  []byte
+	}{
+		{
+			name: "Order of blocks",
+			cut:  geometry,
+			dump: dump(
+				testBlock{'b', []int{1, 1, 1, 1}, 0},
+				testBlock{0, nil, 0},
+				testBlock{'a', []int{0, 0, 0, 0}, 0},
+			),
+			table: "0x3 physical blocks: 0x2 mapped, 0x1 free, 0x0 duplicates; 0x2 logical blocks, 0x0 missing",
+			want:  logical('a', 'b'),
+		},
+		{
+			name: "Version",
+			cut:  geometry,
+			dump: dump(
+				testBlock{'n', []int{0, 0, 0, 0}, 2},
+				testBlock{'o', []int{0, 0, 0, 0}, 1},
+			),
+			table: "0x2 physical blocks: 0x1 mapped, 0x0 free, 0x1 duplicates; 0x1 logical blocks, 0x0 missing",
+			want:  logical('n'),
+		},
+		{
+			name: "Programmed pages without version",
+			cut:  config.Cut{PageSize: testPage, SkipSize: testSpare, PagesPerBlock: testPages, LBAOffset: lbaOffset, LBASize: 2},
+			dump: dump(
+				testBlock{'o', []int{0, 0}, noVersion},
+				testBlock{'n', []int{0, 0, 0}, noVersion},
+			),
+			table: "0x2 physical blocks: 0x1 mapped, 0x0 free, 0x1 duplicates; 0x1 logical blocks, 0x0 missing",
+			want:  append(bytes.Repeat([]byte{'n'}, 3*testPage), bytes.Repeat([]byte{0xff}, testPage)...),
+		},
+		{
+			name: "Bit flip and missing block",
+			cut:  geometry,
+			dump: dump(
+				testBlock{'c', []int{2, 2, 0x102, 2}, 0},
+				testBlock{'a', []int{0, 0, 0, 0}, 0},
+				testBlock{0, nil, 0},
+			),
+			table: "0x3 physical blocks: 0x2 mapped, 0x1 free, 0x0 duplicates; 0x3 logical blocks, 0x1 missing",
+			want:  append(append(logical('a'), bytes.Repeat([]byte{0xff}, blockSize)...), logical('c')...),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "nand.bin")
+			require.NoError(t, os.WriteFile(input, tt.dump, 0644))
+			r := New(config.FTL{Mapper: "lba", Output: filepath.Join(dir, "logical.bin")}, tt.cut)
+			out := &bytes.Buffer{}
+			r.out = out
+			require.NoError(t, r.Open(input))
+			require.NoError(t, r.Run(context.Background()))
+			require.NoError(t, r.Close())
+			require.Equal(t, tt.table+"\n", out.String())
+			got, err := os.ReadFile(r.Config.Output)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "nand.bin")
+	require.NoError(t, os.WriteFile(input, dump(
+		testBlock{'o', []int{0, 0, 0, 0}, 1},
+		testBlock{'n', []int{0, 0, 0, 0}, 0x102},
+	), 0644))
+	r := New(config.FTL{Mapper: "lba", BigEndian: true, Map: true}, geometry)
+	out := &bytes.Buffer{}
+	r.out = out
+	require.NoError(t, r.Open(input))
+	defer r.Close()
+	require.NoError(t, r.Run(context.Background()))
+	require.Equal(t, `0x2 physical blocks: 0x1 mapped, 0x0 free, 0x1 duplicates; 0x1 logical blocks, 0x0 missing
+0x000000 -> 0x000001 version 0x201, 4 pages
+0x000000 duplicate 0x000000 version 0x100, 4 pages
+`, out.String())
+}
+
+func TestOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.FTL
+		modify func(*config.Cut)
+		err    error
+	}{
+		{"Mapper", config.FTL{Mapper: "unknown"}, func(c *config.Cut) {}, ErrMapper},
+		{"Geometry", config.FTL{Mapper: "lba"}, func(c *config.Cut) { c.PagesPerBlock = 0 }, ErrGeometry},
+		{"LBA out of spare", config.FTL{Mapper: "lba"}, func(c *config.Cut) { c.LBAOffset = 7 }, ErrLayout},
+		{"Version size", config.FTL{Mapper: "lba"}, func(c *config.Cut) { c.VersionSize = 9 }, ErrLayout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cut := geometry
+			tt.modify(&cut)
+			tt.cfg.Output = filepath.Join(dir, "logical.bin")
+			r := New(tt.cfg, cut)
+			err := r.Open(filepath.Join(dir, "nand.bin"))
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package hexdump
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+	"github.com/Nexadis/fw-tools/internal/swap"
+)
+
+var ErrColor = errors.New("unknown color mode")
+
+// ANSI escape codes
+const (
+	colorReset = "\x1b[0m"
+	colorSpare = "\x1b[33m"
+	colorMark  = "\x1b[36m"
+)
+
+type Dumper struct {
+	input   io.ReadCloser
+	out     io.Writer
+	color   bool
+	swapper *swap.Swapper
+	Config  config.Hexdump
+	Cut     config.Cut
+}
+
+func New(cfg config.Hexdump, geometry config.Cut) *Dumper {
+	return &Dumper{
+		Config: cfg,
+		Cut:    geometry,
+		out:    os.Stdout,
+	}
+}
+
+func (d *Dumper) Open(input string) error {
+	if err := d.check(); err != nil {
+		return err
+	}
+	f, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for dump: %w", input, err)
+	}
+	d.input = f
+	if _, err := f.Seek(d.Config.Start, io.SeekStart); err != nil {
+		return err
+	}
+	return nil
+}
+
+// check validates config and prepares swapper and colors
+func (d *Dumper) check() error {
+	switch d.Config.Word {
+	case 1, 2, 4, 8:
+	default:
+		return fmt.Errorf("invalid size of word %d", d.Config.Word)
+	}
+	if d.Config.Width <= 0 || d.Config.Width%d.Config.Word != 0 {
+		return fmt.Errorf("width %d isn't multiple of word %d", d.Config.Width, d.Config.Word)
+	}
+	s, unit, err := swap.Parse(d.Config.Swap)
+	if err != nil {
+		return err
+	}
+	if unit > d.Config.Word {
+		return fmt.Errorf("%w: swapped unit %d is bigger than word", swap.ErrAlign, unit)
+	}
+	if len(d.Config.Swap) > 0 {
+		d.swapper = swap.New(s)
+	}
+	if !d.Config.Plain && (d.Cut.PageSize <= 0 || d.Cut.SkipSize < 0) {
+		return fmt.Errorf("invalid geometry: page 0x%x, spare 0x%x", d.Cut.PageSize, d.Cut.SkipSize)
+	}
+	switch d.Config.Color {
+	case "always":
+		d.color = true
+	case "auto":
+		f, ok := d.out.(*os.File)
+		if ok {
+			fi, err := f.Stat()
+			d.color = err == nil && fi.Mode()&os.ModeCharDevice != 0
+		}
+	case "never", "":
+	default:
+		return fmt.Errorf("%w: %s", ErrColor, d.Config.Color)
+	}
+	return nil
+}
+
+func (d *Dumper) Close() error {
+	if d.input == nil {
+		return nil
+	}
+	return d.input.Close()
+}
+
+func (d *Dumper) Run(ctx context.Context) error {
+	r := io.Reader(bufio.NewReader(d.input))
+	if d.Config.Length > 0 {
+		r = io.LimitReader(r, d.Config.Length)
+	}
+	return d.Dump(ctx, r, d.Config.Start)
+}
+
+func (d *Dumper) page() int64 {
+	return int64(d.Cut.PageSize + d.Cut.SkipSize)
+}
+
+// boundaries returns offsets in page, where page, sub-pages and spare begin
+func (d *Dumper) boundaries() []int64 {
+	b := []int64{0}
+	if d.Config.SubPage > 0 {
+		for o := int64(d.Config.SubPage); o < int64(d.Cut.PageSize); o += int64(d.Config.SubPage) {
+			b = append(b, o)
+		}
+	}
+	if d.Cut.SkipSize > 0 {
+		b = append(b, int64(d.Cut.PageSize))
+	}
+	return b
+}
+
+// Dump prints data from r, which begins at offset start. Lines don't cross
+// boundaries of pages, sub-pages and spare areas.
+func (d *Dumper) Dump(ctx context.Context, r io.Reader, start int64) error {
+	w := bufio.NewWriter(d.out)
+	defer w.Flush()
+	bounds := d.boundaries()
+	buf := make([]byte, d.Config.Width)
+	words := make([]byte, d.Config.Width)
+	for pos, first := start, true; ; first = false {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		end := pos + int64(d.Config.Width)
+		spare, mark := false, -1
+		if !d.Config.Plain {
+			page := pos / d.page()
+			off := pos % d.page()
+			spare = off >= int64(d.Cut.PageSize)
+			cur := 0
+			for cur+1 < len(bounds) && bounds[cur+1] <= off {
+				cur++
+			}
+			next := d.page()
+			if cur+1 < len(bounds) {
+				next = bounds[cur+1]
+			}
+			if first || bounds[cur] == off {
+				mark = cur
+			}
+			end = min(end, page*d.page()+next)
+		}
+		n, err := io.ReadFull(r, buf[:end-pos])
+		if n == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		if mark >= 0 {
+			d.mark(w, pos/d.page(), mark, spare)
+		}
+		copy(words, buf[:n])
+		if d.swapper != nil {
+			d.swapper.Transform(words[:n])
+		}
+		d.line(w, pos, buf[:n], words[:n], spare)
+		pos += int64(n)
+	}
+}
+
+// mark prints annotation of region, which begins at bound-th boundary of page
+func (d *Dumper) mark(w io.Writer, page int64, bound int, spare bool) {
+	var s string
+	switch {
+	case spare:
+		s = fmt.Sprintf("spare of page 0x%x", page)
+	case bound == 0:
+		s = fmt.Sprintf("page 0x%x", page)
+		if d.Cut.PagesPerBlock > 0 {
+			s += fmt.Sprintf(", block 0x%x", page/int64(d.Cut.PagesPerBlock))
+		}
+	default:
+		s = fmt.Sprintf("sub-page %d of page 0x%x", bound, page)
+	}
+	s = fmt.Sprintf("---- %s ----", s)
+	if d.color {
+		s = colorMark + s + colorReset
+	}
+	fmt.Fprintln(w, s)
+}
+
+func (d *Dumper) line(w *bufio.Writer, pos int64, raw, words []byte, spare bool) {
+	fmt.Fprintf(w, "%08x ", pos)
+	if d.color && spare {
+		w.WriteString(colorSpare)
+	}
+	for i := 0; i < d.Config.Width; i += d.Config.Word {
+		w.WriteByte(' ')
+		if i >= len(words) {
+			w.WriteString(strings.Repeat("  ", d.Config.Word))
+			continue
+		}
+		fmt.Fprintf(w, "%x", words[i:min(i+d.Config.Word, len(words))])
+		if pad := i + d.Config.Word - len(words); pad > 0 {
+			w.WriteString(strings.Repeat("  ", pad))
+		}
+	}
+	w.WriteString("  |")
+	for _, c := range raw {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		w.WriteByte(c)
+	}
+	w.WriteString("|")
+	if d.color && spare {
+		w.WriteString(colorReset)
+	}
+	w.WriteByte('\n')
+}
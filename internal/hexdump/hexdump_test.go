@@ -0,0 +1,92 @@
+package hexdump
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var geometry = config.Cut{PageSize: 0x8, SkipSize: 0x4, PagesPerBlock: 2}
+
+func TestDumper_Dump(t *testing.T) {
+	data := []byte("ABCDEFGH\x00\x01\x02\x03abcdefgh\xff\xff\xff\xff")
+	tests := []struct {
+		name  string
+		cfg   config.Hexdump
+		start int64
+		data  []byte
+		want  string
+	}{
+		{
+			name: "pages",
+			cfg:  config.Hexdump{Width: 8, Word: 1},
+			data: data,
+			want: `---- page 0x0, block 0x0 ----
+00000000  41 42 43 44 45 46 47 48  |ABCDEFGH|
+---- spare of page 0x0 ----
+00000008  00 01 02 03              |....|
+---- page 0x1, block 0x0 ----
+0000000c  61 62 63 64 65 66 67 68  |abcdefgh|
+---- spare of page 0x1 ----
+00000014  ff ff ff ff              |....|
+`,
+		},
+		{
+			name:  "sub-pages from middle",
+			cfg:   config.Hexdump{Width: 8, Word: 2, SubPage: 4},
+			start: 0x1d,
+			data:  []byte("FGH\x00\x01\x02\x03"),
+			want: `---- sub-page 1 of page 0x2 ----
+0000001d  4647 48              |FGH|
+---- spare of page 0x2 ----
+00000020  0001 0203            |....|
+`,
+		},
+		{
+			name: "swapped words",
+			cfg:  config.Hexdump{Width: 4, Word: 4, Swap: []string{"bytes", "words"}, Plain: true},
+			data: data[:10],
+			want: `00000000  44434241  |ABCD|
+00000004  48474645  |EFGH|
+00000008  0100      |..|
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			d := New(tt.cfg, geometry)
+			d.out = out
+			require.NoError(t, d.check())
+			require.NoError(t, d.Dump(context.TODO(), bytes.NewReader(tt.data), tt.start))
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func TestDumper_Run(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "dump.bin")
+	require.NoError(t, os.WriteFile(input, bytes.Repeat([]byte{0x55}, 0x30), 0o644))
+
+	out := &bytes.Buffer{}
+	d := New(config.Hexdump{Start: 0xc, Length: 0x8, Width: 16, Word: 1, Color: "always"}, geometry)
+	d.out = out
+	require.NoError(t, d.Open(input))
+	defer d.Close()
+	require.NoError(t, d.Run(context.TODO()))
+	require.Equal(t, colorMark+"---- page 0x1, block 0x0 ----"+colorReset+"\n"+
+		"0000000c  55 55 55 55 55 55 55 55                          |UUUUUUUU|\n", out.String())
+
+	d = New(config.Hexdump{Width: 6, Word: 4, Color: "never"}, geometry)
+	require.Error(t, d.Open(input))
+	d = New(config.Hexdump{Width: 8, Word: 2, Swap: []string{"dwords"}, Color: "never"}, geometry)
+	require.Error(t, d.Open(input))
+	d = New(config.Hexdump{Width: 8, Word: 1, Color: "rainbow"}, geometry)
+	require.ErrorIs(t, d.Open(input), ErrColor)
+}
@@ -13,6 +13,7 @@ import (
 
 var ErrSize = errors.New("size of file is not the same")
 var ErrAlign = errors.New("invalid align of input")
+var ErrUnknown = errors.New("unknown merge")
 
 type Merger struct {
 	inputs []io.ReadCloser
@@ -26,6 +27,23 @@ func New(cfg config.Merge) *Merger {
 	}
 }
 
+// Parse converts name of mode (bit, byte, word, dword) to config.
+func Parse(name string) (cfg config.Merge, err error) {
+	switch name {
+	case "bit":
+		cfg.ByBit = true
+	case "byte":
+		cfg.ByByte = true
+	case "word":
+		cfg.ByWord = true
+	case "dword":
+		cfg.ByDword = true
+	default:
+		return cfg, fmt.Errorf("%w: %s", ErrUnknown, name)
+	}
+	return cfg, nil
+}
+
 func (m *Merger) Open(inputs []string, output string) error {
 	// size - size of file, must be the same for all files
 	var size int64 = -1
@@ -85,6 +103,28 @@ func (m *Merger) Run(ctx context.Context) error {
 	}
 }
 
+// Merge interleaves inputs in output by configured mode, it's used
+// for merging of streams without files.
+func (m *Merger) Merge(ctx context.Context, inputs []io.Reader, output io.Writer) error {
+	t := &Merger{
+		inputs: make([]io.ReadCloser, 0, len(inputs)),
+		output: writeNopCloser{output},
+		Config: m.Config,
+	}
+	for _, i := range inputs {
+		t.inputs = append(t.inputs, io.NopCloser(i))
+	}
+	return t.Run(ctx)
+}
+
+type writeNopCloser struct {
+	io.Writer
+}
+
+func (writeNopCloser) Close() error {
+	return nil
+}
+
 func (m *Merger) bytes(ctx context.Context, size int) error {
 	for {
 		select {
@@ -0,0 +1,382 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type decoder struct {
+	r    io.ReaderAt
+	size int64
+}
+
+// Decode applies template at offset of r with size.
+func (t *Template) Decode(r io.ReaderAt, size, offset int64) (Object, error) {
+	d := &decoder{r: r, size: size}
+	order, _ := order(t.Endian, binary.LittleEndian)
+	obj, _, err := d.structure(t.Fields, offset, order, nil)
+	return obj, err
+}
+
+func (d *decoder) read(off, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > d.size {
+		return nil, fmt.Errorf("%w: 0x%x bytes at 0x%x", ErrRange, n, off)
+	}
+	b := make([]byte, n)
+	_, err := d.r.ReadAt(b, off)
+	return b, err
+}
+
+// structure decodes fields from base and returns end of the last field
+func (d *decoder) structure(fields []Field, base int64, def binary.ByteOrder, parent *scope) (Object, int64, error) {
+	s := newScope(parent)
+	end, pos := base, base
+	for i := range fields {
+		f := &fields[i]
+		if f.Offset != "" {
+			off, err := s.eval(f.Offset)
+			if err != nil {
+				return s.object, 0, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			pos = base + off
+		}
+		start := pos
+		order, _ := order(f.Endian, def)
+		count, err := f.count(s)
+		if err != nil {
+			return s.object, 0, err
+		}
+		var v any
+		if count < 0 {
+			v, pos, err = d.value(f, pos, order, s)
+		} else {
+			values := make([]any, 0, count)
+			for n := int64(0); n < count && err == nil; n++ {
+				var e any
+				e, pos, err = d.value(f, pos, order, s)
+				values = append(values, e)
+			}
+			v = values
+		}
+		if err != nil {
+			return s.object, 0, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		if err := f.check(v); err != nil {
+			return s.object, 0, err
+		}
+		s.add(f.Name, v, start-base)
+		end = max(end, pos)
+	}
+	return s.object, end, nil
+}
+
+// count returns number of values of array, -1 for single value
+func (f *Field) count(s *scope) (int64, error) {
+	if f.Count == "" {
+		return -1, nil
+	}
+	n, err := s.eval(f.Count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	if n < 0 || n > maxCount {
+		return 0, fmt.Errorf("%w: %s has %d values", ErrValue, f.Name, n)
+	}
+	return n, nil
+}
+
+func (f *Field) size(s *scope) (int64, bool, error) {
+	if f.Size == "" {
+		return 0, false, nil
+	}
+	n, err := s.eval(f.Size)
+	if err == nil && n < 0 {
+		err = fmt.Errorf("%w: size %d", ErrValue, n)
+	}
+	return n, true, err
+}
+
+func (d *decoder) value(f *Field, pos int64, order binary.ByteOrder, s *scope) (any, int64, error) {
+	size, sized, err := f.size(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch f.Type {
+	case "string":
+		if !sized {
+			// string without size ends with NUL
+			b, err := d.read(pos, min(maxString, d.size-pos))
+			if err != nil {
+				return nil, 0, err
+			}
+			n := bytes.IndexByte(b, 0)
+			if n < 0 {
+				return nil, 0, fmt.Errorf("%w: string without NUL at 0x%x", ErrValue, pos)
+			}
+			return string(b[:n]), pos + int64(n) + 1, nil
+		}
+		b, err := d.read(pos, size)
+		if err != nil {
+			return nil, 0, err
+		}
+		b, _, _ = bytes.Cut(b, []byte{0})
+		return string(b), pos + size, nil
+	case "bytes":
+		b, err := d.read(pos, size)
+		return hex.EncodeToString(b), pos + size, err
+	case "struct":
+		obj, end, err := d.structure(f.Fields, pos, order, s)
+		if sized {
+			end = pos + size
+		}
+		return obj, end, err
+	}
+	n := int64(f.bits / 8)
+	b, err := d.read(pos, n)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f.integerValue(b, order), pos + n, nil
+}
+
+func (f *Field) integerValue(b []byte, order binary.ByteOrder) any {
+	var u uint64
+	switch f.bits {
+	case 8:
+		u = uint64(b[0])
+	case 16:
+		u = uint64(order.Uint16(b))
+	case 32:
+		u = uint64(order.Uint32(b))
+	default:
+		u = order.Uint64(b)
+	}
+	if !f.signed {
+		return u
+	}
+	// sign extension
+	shift := 64 - f.bits
+	return int64(u<<shift) >> shift
+}
+
+// check compares decoded value with constant of template
+func (f *Field) check(v any) error {
+	if f.Value == "" {
+		return nil
+	}
+	want, err := f.constant()
+	if err != nil {
+		return err
+	}
+	if fmt.Sprint(want) != fmt.Sprint(v) {
+		return fmt.Errorf("%w: %s is %v, expected %v", ErrValue, f.Name, v, want)
+	}
+	return nil
+}
+
+// constant converts value of template to type of field
+func (f *Field) constant() (any, error) {
+	return f.convert(string(f.Value))
+}
+
+// convert decodes value of JSON document or template for field
+func (f *Field) convert(v any) (any, error) {
+	switch f.Type {
+	case "string":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "bytes":
+		if s, ok := v.(string); ok {
+			b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s isn't hex", ErrValue, f.Name)
+			}
+			return hex.EncodeToString(b), nil
+		}
+	case "struct":
+	default:
+		var s string
+		switch v := v.(type) {
+		case json.Number:
+			s = v.String()
+		case string:
+			s = v
+		default:
+			return nil, fmt.Errorf("%w: %s isn't integer", ErrValue, f.Name)
+		}
+		if f.signed {
+			n, err := strconv.ParseInt(s, 0, f.bits)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %w", ErrValue, f.Name, err)
+			}
+			return n, nil
+		}
+		n, err := strconv.ParseUint(s, 0, f.bits)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrValue, f.Name, err)
+		}
+		return n, nil
+	}
+	return nil, fmt.Errorf("%w: %s has type %T", ErrValue, f.Name, v)
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) write(off int64, b []byte) error {
+	end := off + int64(len(b))
+	if off < 0 || end > maxBuild {
+		return fmt.Errorf("%w: 0x%x bytes at 0x%x", ErrRange, len(b), off)
+	}
+	if end > int64(len(e.buf)) {
+		e.buf = append(e.buf, make([]byte, end-int64(len(e.buf)))...)
+	}
+	copy(e.buf[off:], b)
+	return nil
+}
+
+// Encode serializes JSON document by template, missing fields are constants
+// of template or zeros.
+func (t *Template) Encode(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v map[string]any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrValue, err)
+	}
+	e := &encoder{}
+	order, _ := order(t.Endian, binary.LittleEndian)
+	_, _, err := e.structure(t.Fields, 0, order, nil, v)
+	return e.buf, err
+}
+
+func (e *encoder) structure(fields []Field, base int64, def binary.ByteOrder, parent *scope, doc map[string]any) (Object, int64, error) {
+	s := newScope(parent)
+	end, pos := base, base
+	for i := range fields {
+		f := &fields[i]
+		if f.Offset != "" {
+			off, err := s.eval(f.Offset)
+			if err != nil {
+				return s.object, 0, fmt.Errorf("%s: %w", f.Name, err)
+			}
+			pos = base + off
+		}
+		start := pos
+		order, _ := order(f.Endian, def)
+		count, err := f.count(s)
+		if err != nil {
+			return s.object, 0, err
+		}
+		in, ok := doc[f.Name]
+		var v any
+		if count < 0 {
+			v, pos, err = e.value(f, pos, order, s, in, ok)
+		} else {
+			values, _ := in.([]any)
+			if ok && int64(len(values)) != count {
+				return s.object, 0, fmt.Errorf("%w: %s has %d values instead of %d", ErrValue, f.Name, len(values), count)
+			}
+			out := make([]any, 0, count)
+			for n := int64(0); n < count && err == nil; n++ {
+				var item any
+				if ok {
+					item = values[n]
+				}
+				var ev any
+				ev, pos, err = e.value(f, pos, order, s, item, ok)
+				out = append(out, ev)
+			}
+			v = out
+		}
+		if err != nil {
+			return s.object, 0, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		s.add(f.Name, v, start-base)
+		end = max(end, pos)
+	}
+	return s.object, end, e.write(end, nil)
+}
+
+func (e *encoder) value(f *Field, pos int64, order binary.ByteOrder, s *scope, in any, ok bool) (any, int64, error) {
+	size, sized, err := f.size(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	if f.Type == "struct" {
+		doc, _ := in.(map[string]any)
+		if ok && doc == nil {
+			return nil, 0, fmt.Errorf("%w: %s isn't object", ErrValue, f.Name)
+		}
+		obj, end, err := e.structure(f.Fields, pos, order, s, doc)
+		if sized && err == nil {
+			end = pos + size
+			err = e.write(end, nil)
+		}
+		return obj, end, err
+	}
+	var v any
+	switch {
+	case ok:
+		v, err = f.convert(in)
+	case f.Value != "":
+		v, err = f.constant()
+	case f.Type == "string" || f.Type == "bytes":
+		v = ""
+	default:
+		v, err = f.convert("0")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	var b []byte
+	switch f.Type {
+	case "string":
+		b = []byte(v.(string))
+		if !sized {
+			b = append(b, 0)
+		}
+	case "bytes":
+		b, _ = hex.DecodeString(v.(string))
+	default:
+		b = make([]byte, 8)
+		var u uint64
+		if n, ok := v.(int64); ok {
+			u = uint64(n)
+		} else {
+			u = v.(uint64)
+		}
+		switch f.bits {
+		case 8:
+			b[0] = byte(u)
+		case 16:
+			order.PutUint16(b, uint16(u))
+		case 32:
+			order.PutUint32(b, uint32(u))
+		default:
+			order.PutUint64(b, u)
+		}
+		b = b[:f.bits/8]
+	}
+	if sized {
+		if int64(len(b)) > size {
+			return nil, 0, fmt.Errorf("%w: %s is longer than %d bytes", ErrValue, f.Name, size)
+		}
+		if size > maxBuild {
+			return nil, 0, fmt.Errorf("%w: %s has size 0x%x", ErrRange, f.Name, size)
+		}
+		b = append(b, make([]byte, size-int64(len(b)))...)
+	}
+	if err := e.write(pos, b); err != nil {
+		return nil, 0, err
+	}
+	return v, pos + int64(len(b)), nil
+}
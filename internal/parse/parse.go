@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+type Parser struct {
+	template *Template
+	input    *os.File
+	size     int64
+	out      io.Writer
+	Config   config.Parse
+}
+
+func New(cfg config.Parse) *Parser {
+	return &Parser{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (p *Parser) Open(input string) error {
+	if p.Config.Template == "" {
+		return errors.New("set template for parsing")
+	}
+	b, err := os.ReadFile(p.Config.Template)
+	if err != nil {
+		return fmt.Errorf("can't read template '%s': %w", p.Config.Template, err)
+	}
+	if p.template, err = LoadTemplate(b); err != nil {
+		return fmt.Errorf("%s: %w", p.Config.Template, err)
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for parsing: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for parsing: %w", input, err)
+	}
+	p.input = in
+	p.size = stat.Size()
+	if p.Config.Build && p.Config.Output == "" {
+		p.Config.Output = input[:len(input)-len(filepath.Ext(input))] + "-built.bin"
+	}
+	return nil
+}
+
+func (p *Parser) Close() error {
+	if p.input == nil {
+		return nil
+	}
+	return p.input.Close()
+}
+
+// Run prints struct at offset of input as JSON or serializes JSON input to
+// output with --build.
+func (p *Parser) Run(ctx context.Context) error {
+	if p.Config.Build {
+		return p.build()
+	}
+	obj, err := p.template.Decode(p.input, p.size, p.Config.Offset)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	if p.Config.Output == "" {
+		_, err = fmt.Fprintln(p.out, string(b))
+		return err
+	}
+	return os.WriteFile(p.Config.Output, append(b, '\n'), 0666)
+}
+
+func (p *Parser) build() error {
+	doc, err := io.ReadAll(p.input)
+	if err != nil {
+		return err
+	}
+	b, err := p.template.Encode(doc)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.Config.Output, b, 0666); err != nil {
+		return fmt.Errorf("can't write file '%s': %w", p.Config.Output, err)
+	}
+	fmt.Fprintf(p.out, "%s: 0x%x bytes -> %s\n", p.template.Name, len(b), p.Config.Output)
+	return nil
+}
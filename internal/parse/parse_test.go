@@ -0,0 +1,222 @@
+package parse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const header = `
+name: header
+endian: be
+fields:
+  - {name: magic, type: string, size: 4, value: HDR0}
+  - {name: count, type: u16}
+  - {name: size, type: u32le}
+  - name: parts
+    type: struct
+    count: count
+    fields:
+      - {name: offset, type: u32}
+      - {name: length, type: u32}
+  - {name: name, type: string}
+  - {name: delta, type: i8}
+  - {name: payload, type: bytes, size: size, offset: 0x20}
+  - {name: crc, type: u16, offset: "&payload + size + parts.0.length - 4"}
+`
+
+func data() []byte {
+	b := []byte("HDR0")
+	b = append(b, 0, 2)
+	b = append(b, 3, 0, 0, 0)
+	b = append(b, 0, 0, 0, 0x20, 0, 0, 0, 4)
+	b = append(b, 0, 1, 0, 0, 0, 0, 0, 8)
+	b = append(b, "fw\x00"...)
+	b = append(b, 0xfe)
+	b = append(b, make([]byte, 0x20-len(b))...)
+	return append(b, 0xaa, 0xbb, 0xcc, 0x12, 0x34)
+}
+
+const decoded = `{
+  "magic": "HDR0",
+  "count": 2,
+  "size": 3,
+  "parts": [
+    {
+      "offset": 32,
+      "length": 4
+    },
+    {
+      "offset": 65536,
+      "length": 8
+    }
+  ],
+  "name": "fw",
+  "delta": -2,
+  "payload": "aabbcc",
+  "crc": 4660
+}`
+
+func TestDecode(t *testing.T) {
+	tmpl, err := LoadTemplate([]byte(header))
+	require.NoError(t, err)
+	tests := []struct {
+		name   string
+		data   []byte
+		offset int64
+		want   string
+		err    error
+	}{
+		{
+			name: "Header",
+			data: data(),
+			want: decoded,
+		},
+		{
+			name:   "Offset",
+			data:   append(make([]byte, 0x100), data()...),
+			offset: 0x100,
+			want:   decoded,
+		},
+		{
+			name: "Magic",
+			data: append([]byte("HDR1"), data()[4:]...),
+			err:  ErrValue,
+		},
+		{
+			name: "Short",
+			data: data()[:0x21],
+			err:  ErrRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := tmpl.Decode(bytes.NewReader(tt.data), int64(len(tt.data)), tt.offset)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			b, err := json.MarshalIndent(obj, "", "  ")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(b))
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tmpl, err := LoadTemplate([]byte(header))
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		doc  string
+		want []byte
+		err  error
+	}{
+		{
+			name: "Round trip",
+			doc:  decoded,
+			want: data(),
+		},
+		{
+			name: "Constants",
+			doc:  `{"count": 1, "size": 1, "parts": [{"length": 4}], "payload": "ff", "crc": "0x1234"}`,
+			want: append(append([]byte("HDR0\x00\x01\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x04"), make([]byte, 0x0e)...), 0xff, 0x12, 0x34),
+		},
+		{
+			name: "Count",
+			doc:  `{"count": 1, "parts": []}`,
+			err:  ErrValue,
+		},
+		{
+			name: "Long string",
+			doc:  `{"magic": "HEADER"}`,
+			err:  ErrValue,
+		},
+		{
+			name: "Overflow",
+			doc:  `{"count": 65536}`,
+			err:  ErrValue,
+		},
+		{
+			name: "Huge offset",
+			doc:  `{"size": 0, "count": 1, "parts": [{"length": 4000000000}], "payload": ""}`,
+			err:  ErrRange,
+		},
+		{
+			name: "Huge size",
+			doc:  `{"size": 4000000000, "count": 0, "parts": []}`,
+			err:  ErrRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tmpl.Encode([]byte(tt.doc))
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	tmpl, err = LoadTemplate([]byte(`fields: [{name: a, type: u32, offset: -4}]`))
+	require.NoError(t, err)
+	_, err = tmpl.Encode([]byte(`{"a": 1}`))
+	require.ErrorIs(t, err, ErrRange)
+}
+
+func TestLoadTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{"Type", `fields: [{name: a, type: u24}]`},
+		{"Endian", `fields: [{name: a, type: u16, endian: middle}]`},
+		{"Bytes without size", `fields: [{name: a, type: bytes}]`},
+		{"Duplicate", `fields: [{name: a, type: u8}, {name: a, type: u8}]`},
+		{"Name", `fields: [{name: a.b, type: u8}]`},
+		{"Empty struct", `fields: [{name: a, type: struct}]`},
+		{"Fields of integer", `fields: [{name: a, type: u8, fields: [{name: b, type: u8}]}]`},
+		{"Expression", `fields: [{name: a, type: u8, size: {x: 1}}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadTemplate([]byte(tt.template))
+			require.ErrorIs(t, err, ErrTemplate)
+		})
+	}
+}
+
+func TestParser(t *testing.T) {
+	dir := t.TempDir()
+	template := filepath.Join(dir, "header.yaml")
+	input := filepath.Join(dir, "firmware.bin")
+	require.NoError(t, os.WriteFile(template, []byte(header), 0644))
+	require.NoError(t, os.WriteFile(input, data(), 0644))
+
+	p := New(config.Parse{Template: template, Output: filepath.Join(dir, "header.json")})
+	require.NoError(t, p.Open(input))
+	require.NoError(t, p.Run(context.Background()))
+	require.NoError(t, p.Close())
+
+	out := &bytes.Buffer{}
+	p = New(config.Parse{Template: template, Build: true})
+	p.out = out
+	require.NoError(t, p.Open(filepath.Join(dir, "header.json")))
+	require.NoError(t, p.Run(context.Background()))
+	require.NoError(t, p.Close())
+	require.Equal(t, filepath.Join(dir, "header-built.bin"), p.Config.Output)
+	require.Equal(t, "header: 0x25 bytes -> "+p.Config.Output+"\n", out.String())
+	got, err := os.ReadFile(p.Config.Output)
+	require.NoError(t, err)
+	require.Equal(t, data(), got)
+}
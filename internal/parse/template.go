@@ -0,0 +1,274 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrTemplate = errors.New("invalid template")
+var ErrExpr = errors.New("invalid expression")
+var ErrValue = errors.New("invalid value")
+var ErrRange = errors.New("field is out of file")
+
+const (
+	// limits of arrays and strings without size
+	maxCount  = 1 << 20
+	maxString = 0x10000
+	// limit of built struct
+	maxBuild = 1 << 30
+)
+
+// Scalar is a number or string of template, expressions are stored as strings.
+type Scalar string
+
+func (s *Scalar) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.ScalarNode {
+		return fmt.Errorf("%w: line %d: scalar is expected", ErrTemplate, n.Line)
+	}
+	*s = Scalar(n.Value)
+	return nil
+}
+
+// Field describes value of binary format. Size, Count and Offset are expressions
+// with numbers, values of previous fields like header.size or parts.0.length and
+// their offsets &name, Offset is relative to beginning of struct.
+type Field struct {
+	Name   string  `yaml:"name"`
+	Type   string  `yaml:"type"`
+	Endian string  `yaml:"endian"`
+	Size   Scalar  `yaml:"size"`
+	Count  Scalar  `yaml:"count"`
+	Offset Scalar  `yaml:"offset"`
+	Value  Scalar  `yaml:"value"`
+	Fields []Field `yaml:"fields"`
+
+	bits   int
+	signed bool
+}
+
+type Template struct {
+	Name   string  `yaml:"name"`
+	Endian string  `yaml:"endian"`
+	Fields []Field `yaml:"fields"`
+}
+
+// LoadTemplate decodes template in YAML or JSON.
+func LoadTemplate(b []byte) (*Template, error) {
+	t := &Template{}
+	if err := yaml.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrTemplate, err)
+	}
+	if _, err := order(t.Endian, binary.LittleEndian); err != nil {
+		return nil, err
+	}
+	if err := check(t.Fields, ""); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func order(endian string, def binary.ByteOrder) (binary.ByteOrder, error) {
+	switch endian {
+	case "":
+		return def, nil
+	case "little", "le":
+		return binary.LittleEndian, nil
+	case "big", "be":
+		return binary.BigEndian, nil
+	}
+	return nil, fmt.Errorf("%w: endian '%s'", ErrTemplate, endian)
+}
+
+func check(fields []Field, path string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: struct %s without fields", ErrTemplate, path)
+	}
+	names := make(map[string]bool)
+	for i := range fields {
+		f := &fields[i]
+		name := path + f.Name
+		if f.Name == "" || strings.ContainsAny(f.Name, ".&+- ") || names[f.Name] {
+			return fmt.Errorf("%w: name '%s' of field %d in %s", ErrTemplate, f.Name, i, path)
+		}
+		names[f.Name] = true
+		if _, err := order(f.Endian, nil); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		switch f.Type {
+		case "string":
+		case "bytes":
+			if f.Size == "" {
+				return fmt.Errorf("%w: %s of bytes without size", ErrTemplate, name)
+			}
+		case "struct":
+			if err := check(f.Fields, name+"."); err != nil {
+				return err
+			}
+		default:
+			if err := f.integer(); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if f.Type != "struct" && len(f.Fields) != 0 {
+			return fmt.Errorf("%w: %s has fields, but it isn't struct", ErrTemplate, name)
+		}
+	}
+	return nil
+}
+
+// integer decodes type like u8, i16, u32le or u64be, suffix sets endian of field.
+func (f *Field) integer() error {
+	t := f.Type
+	switch {
+	case strings.HasSuffix(t, "le"):
+		f.Endian, t = "le", strings.TrimSuffix(t, "le")
+	case strings.HasSuffix(t, "be"):
+		f.Endian, t = "be", strings.TrimSuffix(t, "be")
+	}
+	if len(t) < 2 || t[0] != 'u' && t[0] != 'i' {
+		return fmt.Errorf("%w: type '%s'", ErrTemplate, f.Type)
+	}
+	f.signed = t[0] == 'i'
+	switch t[1:] {
+	case "8", "16", "32", "64":
+		f.bits, _ = strconv.Atoi(t[1:])
+	default:
+		return fmt.Errorf("%w: type '%s'", ErrTemplate, f.Type)
+	}
+	return nil
+}
+
+// Member is a decoded field.
+type Member struct {
+	Name  string
+	Value any
+}
+
+// Object is a decoded struct, it keeps order of fields in JSON.
+type Object []Member
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(m.Name)
+		value, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (o Object) Get(name string) (any, bool) {
+	for _, m := range o {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return nil, false
+}
+
+// scope is a struct being decoded, names of expressions are searched in it
+// and in parent structs.
+type scope struct {
+	parent  *scope
+	object  Object
+	offsets map[string]int64
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, offsets: make(map[string]int64)}
+}
+
+func (s *scope) add(name string, v any, offset int64) {
+	s.object = append(s.object, Member{name, v})
+	s.offsets[name] = offset
+}
+
+func (s *scope) lookup(path string) (int64, error) {
+	name, rest, _ := strings.Cut(path, ".")
+	for sc := s; sc != nil; sc = sc.parent {
+		if strings.HasPrefix(name, "&") {
+			if off, ok := sc.offsets[name[1:]]; ok && rest == "" {
+				return off, nil
+			}
+			continue
+		}
+		v, ok := sc.object.Get(name)
+		if !ok {
+			continue
+		}
+		for rest != "" {
+			prev := name
+			name, rest, _ = strings.Cut(rest, ".")
+			switch value := v.(type) {
+			case Object:
+				if v, ok = value.Get(name); !ok {
+					return 0, fmt.Errorf("%w: unknown field %s", ErrExpr, path)
+				}
+			case []any:
+				// element of array by index
+				i, err := strconv.Atoi(name)
+				if err != nil || i < 0 || i >= len(value) {
+					return 0, fmt.Errorf("%w: index %s of %s", ErrExpr, name, prev)
+				}
+				v = value[i]
+			default:
+				return 0, fmt.Errorf("%w: %s isn't struct or array", ErrExpr, prev)
+			}
+		}
+		switch v := v.(type) {
+		case uint64:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		}
+		return 0, fmt.Errorf("%w: %s isn't integer", ErrExpr, path)
+	}
+	return 0, fmt.Errorf("%w: unknown field %s", ErrExpr, path)
+}
+
+// eval computes sum and differences of numbers and fields
+func (s *scope) eval(expr Scalar) (int64, error) {
+	e := strings.ReplaceAll(string(expr), " ", "")
+	var sum int64
+	neg := false
+	for e != "" {
+		i := strings.IndexAny(e[1:], "+-") + 1
+		if i == 0 {
+			i = len(e)
+		}
+		t := e[:i]
+		v, err := strconv.ParseInt(t, 0, 64)
+		if err != nil {
+			if v, err = s.lookup(t); err != nil {
+				return 0, fmt.Errorf("'%s': %w", expr, err)
+			}
+		}
+		if neg {
+			v = -v
+		}
+		sum += v
+		if i == len(e) {
+			return sum, nil
+		}
+		neg = e[i] == '-'
+		e = e[i+1:]
+	}
+	return 0, fmt.Errorf("%w: '%s'", ErrExpr, expr)
+}
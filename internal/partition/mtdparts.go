@@ -0,0 +1,183 @@
+package partition
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrSyntax = errors.New("invalid mtdparts")
+
+// SizeRemaining is size of partition, which takes rest of device ("-" in mtdparts).
+const SizeRemaining = -1
+
+type Partition struct {
+	Name     string
+	Offset   int64
+	Size     int64
+	ReadOnly bool
+}
+
+func (p Partition) End() int64 {
+	return p.Offset + p.Size
+}
+
+// Layout is a partition map of one device.
+type Layout struct {
+	Device     string
+	Partitions []Partition
+}
+
+// String returns layout in mtdparts syntax of kernel command line.
+func (l Layout) String() string {
+	parts := make([]string, 0, len(l.Partitions))
+	for _, p := range l.Partitions {
+		size := "-"
+		if p.Size != SizeRemaining {
+			size = fmt.Sprintf("0x%x", p.Size)
+		}
+		s := fmt.Sprintf("%s@0x%x(%s)", size, p.Offset, p.Name)
+		if p.ReadOnly {
+			s += "ro"
+		}
+		parts = append(parts, s)
+	}
+	return fmt.Sprintf("mtdparts=%s:%s", l.Device, strings.Join(parts, ","))
+}
+
+// Resolve returns partitions of device with given size, remaining partition
+// gets the rest of device.
+func (l Layout) Resolve(size int64) ([]Partition, error) {
+	parts := make([]Partition, len(l.Partitions))
+	copy(parts, l.Partitions)
+	for i, p := range parts {
+		if p.Size == SizeRemaining {
+			parts[i].Size = size - p.Offset
+		}
+		if p.Offset > size || parts[i].End() > size || parts[i].Size < 0 {
+			return nil, fmt.Errorf("partition '%s' 0x%x@0x%x is out of device with size 0x%x", p.Name, parts[i].Size, p.Offset, size)
+		}
+	}
+	return parts, nil
+}
+
+// ParseMTDParts decodes mtdparts definition of one or more devices:
+//
+//	mtdparts=<mtd-id>:<size>[@<offset>][(<name>)][ro][lk][,...][;<mtd-id>:...]
+//
+// s can be whole kernel command line, then only mtdparts= argument is used.
+func ParseMTDParts(s string) ([]Layout, error) {
+	s = strings.TrimSpace(s)
+	for _, arg := range strings.Fields(s) {
+		if v, ok := strings.CutPrefix(arg, "mtdparts="); ok {
+			s = v
+			break
+		}
+	}
+	var res []Layout
+	for _, def := range strings.Split(s, ";") {
+		if def == "" {
+			continue
+		}
+		dev, parts, ok := strings.Cut(def, ":")
+		if !ok || dev == "" {
+			return nil, fmt.Errorf("%w: no device in '%s'", ErrSyntax, def)
+		}
+		l := Layout{Device: dev}
+		var err error
+		l.Partitions, err = parseParts(parts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dev, err)
+		}
+		res = append(res, l)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("%w: empty definition", ErrSyntax)
+	}
+	return res, nil
+}
+
+func parseParts(s string) ([]Partition, error) {
+	var res []Partition
+	var next int64
+	for i := 0; ; i++ {
+		p := Partition{Offset: next}
+		var err error
+		if rest, ok := strings.CutPrefix(s, "-"); ok {
+			p.Size = SizeRemaining
+			s = rest
+		} else if p.Size, s, err = number(s); err != nil {
+			return nil, err
+		}
+		if rest, ok := strings.CutPrefix(s, "@"); ok {
+			if p.Offset, s, err = number(rest); err != nil {
+				return nil, err
+			}
+		}
+		if rest, ok := strings.CutPrefix(s, "("); ok {
+			name, after, ok := strings.Cut(rest, ")")
+			if !ok {
+				return nil, fmt.Errorf("%w: unclosed name", ErrSyntax)
+			}
+			p.Name, s = name, after
+		} else {
+			p.Name = fmt.Sprintf("Partition_%03d", i)
+		}
+		for {
+			if rest, ok := strings.CutPrefix(s, "ro"); ok {
+				p.ReadOnly, s = true, rest
+			} else if rest, ok := strings.CutPrefix(s, "lk"); ok {
+				s = rest
+			} else if rest, ok := strings.CutPrefix(s, "slc"); ok {
+				s = rest
+			} else {
+				break
+			}
+		}
+		res = append(res, p)
+		next = p.End()
+		if s == "" {
+			return res, nil
+		}
+		if s[0] != ',' {
+			return nil, fmt.Errorf("%w: unexpected '%s'", ErrSyntax, s)
+		}
+		if p.Size == SizeRemaining {
+			return nil, fmt.Errorf("%w: partition after remaining one", ErrSyntax)
+		}
+		s = s[1:]
+	}
+}
+
+// number parses size like kernel memparse: decimal, octal or hex with k, m, g suffix.
+func number(s string) (int64, string, error) {
+	end := 0
+	digits := "0123456789"
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		end = 2
+		digits += "abcdefABCDEF"
+	}
+	for end < len(s) && strings.IndexByte(digits, s[end]) >= 0 {
+		end++
+	}
+	v, err := strconv.ParseInt(s[:end], 0, 64)
+	if err != nil {
+		return 0, s, fmt.Errorf("%w: number '%s'", ErrSyntax, s)
+	}
+	s = s[end:]
+	if s != "" {
+		switch s[0] {
+		case 'k', 'K':
+			v <<= 10
+		case 'm', 'M':
+			v <<= 20
+		case 'g', 'G':
+			v <<= 30
+		default:
+			return v, s, nil
+		}
+		s = s[1:]
+	}
+	return v, s, nil
+}
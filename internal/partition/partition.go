@@ -0,0 +1,244 @@
+package partition
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrDevice = errors.New("unknown device")
+var ErrOverlap = errors.New("partitions overlap")
+
+const erased = 0xFF
+
+type Splitter struct {
+	input    string
+	out      io.Writer
+	warnings []string
+	Layout   Layout
+	Config   config.Partition
+}
+
+func New(cfg config.Partition) *Splitter {
+	return &Splitter{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+// Open parses layout and checks input: image for splitting or directory
+// with partitions for joining. Layout is read from partition table of image,
+// if table is set.
+func (s *Splitter) Open(input string) error {
+	if _, err := os.Stat(input); err != nil {
+		return fmt.Errorf("can't open '%s': %w", input, err)
+	}
+	switch {
+	case s.Config.Table != "" && s.Config.Join:
+		return errors.New("partition table can't be read from directory, set mtdparts for joining")
+	case s.Config.Table != "":
+		if err := s.readTable(input); err != nil {
+			return err
+		}
+	case s.Config.MTDParts != "":
+		layouts, err := ParseMTDParts(s.Config.MTDParts)
+		if err != nil {
+			return err
+		}
+		s.Layout, err = Select(layouts, s.Config.Device)
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("set mtdparts or partition table")
+	}
+	s.input = input
+	if s.Config.Output == "" {
+		if s.Config.Join {
+			s.Config.Output = strings.TrimSuffix(input, "-parts") + "-joined.bin"
+		} else {
+			s.Config.Output = strings.TrimSuffix(input, ".bin") + "-parts"
+		}
+	}
+	return nil
+}
+
+func (s *Splitter) readTable(input string) error {
+	f, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	t, err := ReadTable(f, fi.Size(), s.Config.Table, s.Config.Sector)
+	if err != nil {
+		return err
+	}
+	s.Layout, s.warnings = t.Layout, t.Warnings
+	return nil
+}
+
+func (s *Splitter) Close() error {
+	return nil
+}
+
+// Select returns layout of device or the only one layout, if device isn't set.
+func Select(layouts []Layout, device string) (Layout, error) {
+	names := make([]string, 0, len(layouts))
+	for _, l := range layouts {
+		if l.Device == device || device == "" && len(layouts) == 1 {
+			return l, nil
+		}
+		names = append(names, l.Device)
+	}
+	return Layout{}, fmt.Errorf("%w '%s', choose one of: %s", ErrDevice, device, strings.Join(names, ", "))
+}
+
+func (s *Splitter) Run(ctx context.Context) error {
+	if s.Config.Join {
+		f, err := os.OpenFile(s.Config.Output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		return errors.Join(s.Join(ctx, s.input, f), f.Close())
+	}
+	for _, w := range s.warnings {
+		fmt.Fprintf(s.out, "%s: %s\n", s.Layout.Device, w)
+	}
+	f, err := os.Open(s.input)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return s.Split(ctx, f, fi.Size(), s.Config.Output)
+}
+
+// FileName returns name of file with i-th partition, index keeps names unique.
+func FileName(i int, p Partition) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, p.Name)
+	return fmt.Sprintf("%02d-%s.bin", i, name)
+}
+
+// Split writes every partition of image in own file in dir.
+func (s *Splitter) Split(ctx context.Context, r io.ReaderAt, size int64, dir string) error {
+	parts, err := s.Layout.Resolve(size)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	for i, p := range parts {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		name := filepath.Join(dir, FileName(i, p))
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, io.NewSectionReader(r, p.Offset, p.Size))
+		if err := errors.Join(err, f.Close()); err != nil {
+			return err
+		}
+		s.print(p, name)
+	}
+	return nil
+}
+
+// Join writes partitions from dir at their offsets, gaps and tails
+// of partitions are filled with 0xFF.
+func (s *Splitter) Join(ctx context.Context, dir string, w io.Writer) error {
+	type file struct {
+		Partition
+		name string
+	}
+	files := make([]file, 0, len(s.Layout.Partitions))
+	for i, p := range s.Layout.Partitions {
+		f := file{p, filepath.Join(dir, FileName(i, p))}
+		fi, err := os.Stat(f.name)
+		if err != nil {
+			return err
+		}
+		if p.Size == SizeRemaining {
+			f.Size = fi.Size()
+		}
+		if fi.Size() > f.Size {
+			return fmt.Errorf("size of '%s' 0x%x is bigger than partition 0x%x", f.name, fi.Size(), f.Size)
+		}
+		files = append(files, f)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Offset < files[j].Offset
+	})
+	var pos int64
+	for _, f := range files {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if f.Offset < pos {
+			return fmt.Errorf("%w: '%s' at 0x%x", ErrOverlap, f.Name, f.Offset)
+		}
+		if err := fill(w, f.Offset-pos); err != nil {
+			return err
+		}
+		in, err := os.Open(f.name)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(w, in)
+		if err := errors.Join(err, in.Close()); err != nil {
+			return err
+		}
+		if err := fill(w, f.Size-n); err != nil {
+			return err
+		}
+		pos = f.End()
+		s.print(f.Partition, f.name)
+	}
+	return nil
+}
+
+func fill(w io.Writer, n int64) error {
+	buf := bytes.Repeat([]byte{erased}, 0x10000)
+	for n > 0 {
+		m, err := w.Write(buf[:min(n, int64(len(buf)))])
+		if err != nil {
+			return err
+		}
+		n -= int64(m)
+	}
+	return nil
+}
+
+func (s *Splitter) print(p Partition, name string) {
+	ro := ""
+	if p.ReadOnly {
+		ro = " ro"
+	}
+	fmt.Fprintf(s.out, "0x%08x 0x%08x %-16s%s %s\n", p.Offset, p.Size, p.Name, ro, name)
+}
@@ -0,0 +1,235 @@
+package partition
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func TestParseMTDParts(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []Layout
+		wantErr bool
+	}{
+		{
+			"Sizes and remainder",
+			"mtdparts=spi0.0:256k(u-boot)ro,0x10000(env),1M@0x100000(kernel),-(rootfs)",
+			[]Layout{{"spi0.0", []Partition{
+				{"u-boot", 0, 0x40000, true},
+				{"env", 0x40000, 0x10000, false},
+				{"kernel", 0x100000, 0x100000, false},
+				{"rootfs", 0x200000, SizeRemaining, false},
+			}}},
+			false,
+		},
+		{
+			"Command line with many devices",
+			"console=ttyS0,115200 mtdparts=nor:512k(boot)rolk;nand:64m(ubi),- root=/dev/mtdblock2",
+			[]Layout{
+				{"nor", []Partition{{"boot", 0, 0x80000, true}}},
+				{"nand", []Partition{{"ubi", 0, 0x4000000, false}, {"Partition_001", 0x4000000, SizeRemaining, false}}},
+			},
+			false,
+		},
+		{"No device", "256k(boot)", nil, true},
+		{"Bad size", "nor:boot", nil, true},
+		{"After remainder", "nor:-(a),1k(b)", nil, true},
+		{"Unclosed name", "nor:1k(a", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMTDParts(tt.s)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrSyntax)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+	l, err := ParseMTDParts("nor:1k(a)ro,-(b)")
+	require.NoError(t, err)
+	require.Equal(t, "mtdparts=nor:0x400@0x0(a)ro,-@0x400(b)", l[0].String())
+}
+
+func TestSplitter(t *testing.T) {
+	img := append(bytes.Repeat([]byte{1}, 0x400), bytes.Repeat([]byte{2}, 0x200)...)
+	img = append(img, bytes.Repeat([]byte{0xFF}, 0x200)...)
+	img = append(img, bytes.Repeat([]byte{3}, 0x300)...)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "flash.bin")
+	require.NoError(t, os.WriteFile(name, img, 0666))
+
+	s := New(config.Partition{MTDParts: "nor:1k(boot)ro,0x200(env),-@0x800(rootfs)"})
+	out := &bytes.Buffer{}
+	s.out = out
+	require.NoError(t, s.Open(name))
+	require.NoError(t, s.Run(context.TODO()))
+	require.Equal(t, filepath.Join(dir, "flash-parts"), s.Config.Output)
+	require.Contains(t, out.String(), "0x00000800 0x00000300 rootfs")
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"00-boot.bin", img[:0x400]},
+		{"01-env.bin", img[0x400:0x600]},
+		{"02-rootfs.bin", img[0x800:]},
+	} {
+		data, err := os.ReadFile(filepath.Join(s.Config.Output, f.name))
+		require.NoError(t, err)
+		require.Equal(t, f.data, data)
+	}
+
+	// shorter env is padded on joining
+	require.NoError(t, os.WriteFile(filepath.Join(s.Config.Output, "01-env.bin"), img[0x400:0x500], 0666))
+	j := New(config.Partition{MTDParts: s.Config.MTDParts, Join: true})
+	j.out = out
+	require.NoError(t, j.Open(s.Config.Output))
+	require.NoError(t, j.Run(context.TODO()))
+	require.Equal(t, filepath.Join(dir, "flash-joined.bin"), j.Config.Output)
+	data, err := os.ReadFile(j.Config.Output)
+	require.NoError(t, err)
+	want := append([]byte{}, img...)
+	copy(want[0x500:0x600], bytes.Repeat([]byte{0xFF}, 0x100))
+	require.Equal(t, want, data)
+
+	small := New(config.Partition{MTDParts: "nor:4k(boot)"})
+	require.NoError(t, small.Open(name))
+	require.Error(t, small.Run(context.TODO()))
+
+	many := New(config.Partition{MTDParts: "nor:1k;nand:1k"})
+	require.ErrorIs(t, many.Open(name), ErrDevice)
+	many.Config.Device = "nand"
+	require.NoError(t, many.Open(name))
+}
+
+func mbrEntries(b []byte, entries ...mbrEntry) {
+	for i, e := range entries {
+		p := b[0x1be+16*i:]
+		p[4] = e.Type
+		binary.LittleEndian.PutUint32(p[8:], e.Start)
+		binary.LittleEndian.PutUint32(p[12:], e.Sectors)
+	}
+	binary.LittleEndian.PutUint16(b[510:], mbrSignature)
+}
+
+// gptImage makes image of sectors with primary and backup GPT of 4 entries
+func gptImage(sectors int64, names ...string) []byte {
+	img := make([]byte, sectors*512)
+	le := binary.LittleEndian
+	entries := make([]byte, 4*128)
+	for i, name := range names {
+		e := entries[i*128:]
+		e[0] = 0xaf // type
+		e[16] = byte(i + 1)
+		le.PutUint64(e[32:], uint64(34+8*i))
+		le.PutUint64(e[40:], uint64(34+8*i+7))
+		if i == 0 {
+			le.PutUint64(e[48:], gptReadOnly)
+		}
+		for j, c := range utf16.Encode([]rune(name)) {
+			le.PutUint16(e[56+2*j:], c)
+		}
+	}
+	header := func(lba, backup, entriesLBA int64) {
+		copy(img[entriesLBA*512:], entries)
+		h := img[lba*512 : lba*512+92]
+		copy(h, gptSignature)
+		le.PutUint32(h[8:], 0x10000)
+		le.PutUint32(h[12:], 92)
+		le.PutUint64(h[24:], uint64(lba))
+		le.PutUint64(h[32:], uint64(backup))
+		le.PutUint64(h[40:], 34)
+		le.PutUint64(h[48:], uint64(sectors-34))
+		h[56] = 0x42
+		le.PutUint64(h[72:], uint64(entriesLBA))
+		le.PutUint32(h[80:], 4)
+		le.PutUint32(h[84:], 128)
+		le.PutUint32(h[88:], crc32.ChecksumIEEE(entries))
+		le.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+	}
+	header(1, sectors-1, 2)
+	header(sectors-1, 1, sectors-2)
+	mbrEntries(img, mbrEntry{0xee, 1, uint32(sectors - 1)})
+	return img
+}
+
+// resign updates CRCs of GPT header at lba after changes
+func resign(img []byte, lba int64) {
+	le := binary.LittleEndian
+	h := img[lba*512 : lba*512+92]
+	entries := int64(le.Uint64(h[72:])) * 512
+	le.PutUint32(h[88:], crc32.ChecksumIEEE(img[entries:entries+4*128]))
+	le.PutUint32(h[16:], 0)
+	le.PutUint32(h[16:], crc32.ChecksumIEEE(h))
+}
+
+func TestReadTable(t *testing.T) {
+	img := make([]byte, 0x40*512)
+	mbrEntries(img, mbrEntry{0x0c, 1, 7}, mbrEntry{0x05, 8, 0x30})
+	// extended partition has two logical partitions
+	mbrEntries(img[8*512:], mbrEntry{0x83, 2, 6}, mbrEntry{0x05, 0x10, 0x10})
+	mbrEntries(img[0x18*512:], mbrEntry{0x82, 1, 0xf})
+	tbl, err := ReadTable(bytes.NewReader(img), int64(len(img)), "auto", 0)
+	require.NoError(t, err)
+	require.Equal(t, "mbr", tbl.Device)
+	require.Equal(t, []Partition{
+		{"p1-fat32", 0x200, 0xe00, false},
+		{"p5-linux", 0x1400, 0xc00, false},
+		{"p6-swap", 0x3200, 0x1e00, false},
+	}, tbl.Partitions)
+	require.Empty(t, tbl.Warnings)
+
+	img = gptImage(0x40, "boot", "rootfs")
+	tbl, err = ReadTable(bytes.NewReader(img), int64(len(img)), "auto", 0)
+	require.NoError(t, err)
+	require.Equal(t, "gpt", tbl.Device)
+	require.Equal(t, []Partition{
+		{"boot", 34 * 512, 8 * 512, true},
+		{"rootfs", 42 * 512, 8 * 512, false},
+	}, tbl.Partitions)
+	require.Empty(t, tbl.Warnings)
+
+	// backup has other name of partition and broken CRC of entries
+	copy(img[(0x40-2)*512+128+56:], []byte{'R', 0})
+	tbl, err = ReadGPT(bytes.NewReader(img), int64(len(img)), 512)
+	require.NoError(t, err)
+	require.Len(t, tbl.Partitions, 2)
+	require.Equal(t, []string{"backup: invalid partition table: CRC of GPT entries at LBA 62"}, tbl.Warnings)
+	resign(img, 0x40-1)
+	tbl, err = ReadGPT(bytes.NewReader(img), int64(len(img)), 512)
+	require.NoError(t, err)
+	require.Equal(t, []string{`entry 2 differs: "rootfs" != "Rootfs"`}, tbl.Warnings)
+
+	// primary is broken, partitions are taken from backup
+	img = gptImage(0x40, "boot", "rootfs")
+	img[512+24]++
+	tbl, err = ReadGPT(bytes.NewReader(img), int64(len(img)), 512)
+	require.NoError(t, err)
+	require.Equal(t, "boot", tbl.Partitions[0].Name)
+	require.Equal(t, []string{"primary: invalid partition table: CRC of GPT header at LBA 1"}, tbl.Warnings)
+
+	_, err = ReadTable(bytes.NewReader(make([]byte, 0x4000)), 0x4000, "auto", 0)
+	require.ErrorIs(t, err, ErrTable)
+
+	for _, sector := range []int64{-512, 1, 100, 256, 1000, 8192} {
+		for _, kind := range []string{"mbr", "gpt", "auto"} {
+			_, err = ReadTable(bytes.NewReader(img), int64(len(img)), kind, sector)
+			require.ErrorIs(t, err, ErrTable, "%s with sector %d", kind, sector)
+		}
+	}
+	tbl, err = ReadTable(bytes.NewReader(img), int64(len(img)), "gpt", 512)
+	require.NoError(t, err)
+	require.Len(t, tbl.Partitions, 2)
+}
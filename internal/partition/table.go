@@ -0,0 +1,351 @@
+package partition
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"unicode/utf16"
+)
+
+var ErrTable = errors.New("invalid partition table")
+
+const (
+	mbrSignature = 0xaa55
+	gptSignature = "EFI PART"
+	gptEntryName = 72
+	// GPT attribute of read-only partition in Microsoft basic data
+	gptReadOnly = 1 << 60
+	// limit of extended boot records in chain, chain can be looped in broken dumps
+	maxEBR = 128
+)
+
+var mbrTypes = map[byte]string{
+	0x01: "fat12",
+	0x04: "fat16",
+	0x06: "fat16",
+	0x07: "ntfs",
+	0x0b: "fat32",
+	0x0c: "fat32",
+	0x0e: "fat16",
+	0x82: "swap",
+	0x83: "linux",
+	0x8e: "lvm",
+	0xda: "data",
+	0xef: "efi",
+	0xfd: "raid",
+}
+
+// Table is a partition table of block device, warnings describe damaged
+// or mismatched structures, which are skipped.
+type Table struct {
+	Layout
+	Warnings []string
+}
+
+// checkSector allows sizes of sectors from 512 to 4096 bytes, which are powers of two
+func checkSector(sector int64) error {
+	if sector < 512 || sector > 4096 || sector&(sector-1) != 0 {
+		return fmt.Errorf("%w: sector size %d", ErrTable, sector)
+	}
+	return nil
+}
+
+// ReadTable parses partition table of kind mbr, gpt or auto, zero sector size is detected by GPT
+// or it's 512 bytes.
+func ReadTable(r io.ReaderAt, size int64, kind string, sector int64) (Table, error) {
+	if sector == 0 {
+		sector = 512
+		hdr := make([]byte, len(gptSignature))
+		for _, s := range []int64{512, 4096} {
+			if _, err := r.ReadAt(hdr, s); err == nil && string(hdr) == gptSignature {
+				sector = s
+				break
+			}
+		}
+	}
+	if err := checkSector(sector); err != nil {
+		return Table{}, err
+	}
+	switch kind {
+	case "mbr":
+		return ReadMBR(r, sector)
+	case "gpt":
+		return ReadGPT(r, size, sector)
+	case "auto":
+		t, err := ReadGPT(r, size, sector)
+		if err == nil {
+			return t, nil
+		}
+		mbr, merr := ReadMBR(r, sector)
+		if merr != nil {
+			return mbr, errors.Join(err, merr)
+		}
+		return mbr, nil
+	}
+	return Table{}, fmt.Errorf("%w: unknown kind '%s'", ErrTable, kind)
+}
+
+type mbrEntry struct {
+	Type    byte
+	Start   uint32
+	Sectors uint32
+}
+
+func readMBREntries(r io.ReaderAt, off int64) ([4]mbrEntry, error) {
+	var entries [4]mbrEntry
+	b := make([]byte, 512)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return entries, fmt.Errorf("%w: boot record at 0x%x: %w", ErrTable, off, err)
+	}
+	if binary.LittleEndian.Uint16(b[510:]) != mbrSignature {
+		return entries, fmt.Errorf("%w: no signature of boot record at 0x%x", ErrTable, off)
+	}
+	for i := range entries {
+		e := b[0x1be+16*i:]
+		entries[i] = mbrEntry{e[4], binary.LittleEndian.Uint32(e[8:]), binary.LittleEndian.Uint32(e[12:])}
+	}
+	return entries, nil
+}
+
+func extended(typ byte) bool {
+	return typ == 0x05 || typ == 0x0f || typ == 0x85
+}
+
+func mbrName(n int, typ byte) string {
+	if name, ok := mbrTypes[typ]; ok {
+		return fmt.Sprintf("p%d-%s", n, name)
+	}
+	return fmt.Sprintf("p%d-0x%02x", n, typ)
+}
+
+// ReadMBR parses MBR with logical partitions of extended partition,
+// primary partitions have numbers 1-4 and logical ones from 5.
+func ReadMBR(r io.ReaderAt, sector int64) (Table, error) {
+	t := Table{Layout: Layout{Device: "mbr"}}
+	if err := checkSector(sector); err != nil {
+		return t, err
+	}
+	entries, err := readMBREntries(r, 0)
+	if err != nil {
+		return t, err
+	}
+	var ext []mbrEntry
+	for i, e := range entries {
+		switch {
+		case e.Type == 0 || e.Sectors == 0:
+			continue
+		case e.Type == 0xee:
+			t.Warnings = append(t.Warnings, "MBR is protective, image has GPT")
+		case extended(e.Type):
+			ext = append(ext, e)
+			continue
+		}
+		t.Partitions = append(t.Partitions, Partition{
+			Name:   mbrName(i+1, e.Type),
+			Offset: int64(e.Start) * sector,
+			Size:   int64(e.Sectors) * sector,
+		})
+	}
+	n := 5
+	for _, e := range ext {
+		ebr := int64(e.Start)
+		for i := 0; ; i++ {
+			if i == maxEBR {
+				t.Warnings = append(t.Warnings, fmt.Sprintf("chain of extended boot records is longer than %d", maxEBR))
+				break
+			}
+			logical, err := readMBREntries(r, ebr*sector)
+			if err != nil {
+				t.Warnings = append(t.Warnings, err.Error())
+				break
+			}
+			if l := logical[0]; l.Type != 0 && l.Sectors != 0 {
+				t.Partitions = append(t.Partitions, Partition{
+					Name:   mbrName(n, l.Type),
+					Offset: (ebr + int64(l.Start)) * sector,
+					Size:   int64(l.Sectors) * sector,
+				})
+				n++
+			}
+			// the next record is relative to the beginning of extended partition
+			next := logical[1]
+			if !extended(next.Type) || next.Start == 0 {
+				break
+			}
+			ebr = int64(e.Start) + int64(next.Start)
+		}
+	}
+	if len(t.Partitions) == 0 {
+		return t, fmt.Errorf("%w: MBR has no partitions", ErrTable)
+	}
+	return t, nil
+}
+
+// GUID is stored in mixed endian, the first three fields are little-endian.
+type GUID [16]byte
+
+func (g GUID) String() string {
+	le := binary.LittleEndian
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", le.Uint32(g[0:]), le.Uint16(g[4:]), le.Uint16(g[6:]), g[8:10], g[10:])
+}
+
+type GPTHeader struct {
+	CurrentLBA uint64
+	BackupLBA  uint64
+	FirstLBA   uint64
+	LastLBA    uint64
+	DiskGUID   GUID
+	EntriesLBA uint64
+	Entries    uint32
+	EntrySize  uint32
+	EntriesCRC uint32
+	entries    []byte
+}
+
+// readGPTHeader reads and validates header and array of entries at lba.
+func readGPTHeader(r io.ReaderAt, lba, sector int64) (*GPTHeader, error) {
+	b := make([]byte, sector)
+	if _, err := r.ReadAt(b, lba*sector); err != nil {
+		return nil, fmt.Errorf("%w: GPT header at LBA %d: %w", ErrTable, lba, err)
+	}
+	if string(b[:8]) != gptSignature {
+		return nil, fmt.Errorf("%w: no GPT signature at LBA %d", ErrTable, lba)
+	}
+	le := binary.LittleEndian
+	size := le.Uint32(b[12:])
+	if size < 92 || int64(size) > sector {
+		return nil, fmt.Errorf("%w: size of GPT header %d at LBA %d", ErrTable, size, lba)
+	}
+	crc := le.Uint32(b[16:])
+	le.PutUint32(b[16:], 0)
+	if crc32.ChecksumIEEE(b[:size]) != crc {
+		return nil, fmt.Errorf("%w: CRC of GPT header at LBA %d", ErrTable, lba)
+	}
+	h := &GPTHeader{
+		CurrentLBA: le.Uint64(b[24:]),
+		BackupLBA:  le.Uint64(b[32:]),
+		FirstLBA:   le.Uint64(b[40:]),
+		LastLBA:    le.Uint64(b[48:]),
+		EntriesLBA: le.Uint64(b[72:]),
+		Entries:    le.Uint32(b[80:]),
+		EntrySize:  le.Uint32(b[84:]),
+		EntriesCRC: le.Uint32(b[88:]),
+	}
+	copy(h.DiskGUID[:], b[56:72])
+	if h.EntrySize < 128 || h.Entries > 1024 {
+		return nil, fmt.Errorf("%w: %d entries of %d bytes at LBA %d", ErrTable, h.Entries, h.EntrySize, lba)
+	}
+	h.entries = make([]byte, int64(h.Entries)*int64(h.EntrySize))
+	if _, err := r.ReadAt(h.entries, int64(h.EntriesLBA)*sector); err != nil {
+		return nil, fmt.Errorf("%w: GPT entries at LBA %d: %w", ErrTable, h.EntriesLBA, err)
+	}
+	if crc32.ChecksumIEEE(h.entries) != h.EntriesCRC {
+		return h, fmt.Errorf("%w: CRC of GPT entries at LBA %d", ErrTable, h.EntriesLBA)
+	}
+	return h, nil
+}
+
+// compare returns differences of primary and backup headers.
+func (h *GPTHeader) compare(b *GPTHeader) []string {
+	var diff []string
+	if h.DiskGUID != b.DiskGUID {
+		diff = append(diff, fmt.Sprintf("disk GUID %s != %s", h.DiskGUID, b.DiskGUID))
+	}
+	if h.FirstLBA != b.FirstLBA || h.LastLBA != b.LastLBA {
+		diff = append(diff, fmt.Sprintf("usable LBA %d-%d != %d-%d", h.FirstLBA, h.LastLBA, b.FirstLBA, b.LastLBA))
+	}
+	if h.CurrentLBA != b.BackupLBA || h.BackupLBA != b.CurrentLBA {
+		diff = append(diff, fmt.Sprintf("LBA of headers %d/%d != %d/%d", h.CurrentLBA, h.BackupLBA, b.BackupLBA, b.CurrentLBA))
+	}
+	if h.Entries != b.Entries || h.EntrySize != b.EntrySize {
+		diff = append(diff, fmt.Sprintf("entries %dx%d != %dx%d", h.Entries, h.EntrySize, b.Entries, b.EntrySize))
+	} else if !bytes.Equal(h.entries, b.entries) {
+		for i := 0; i < int(h.Entries); i++ {
+			a, c := h.entry(i), b.entry(i)
+			if !bytes.Equal(a, c) {
+				diff = append(diff, fmt.Sprintf("entry %d differs: %q != %q", i+1, gptName(a), gptName(c)))
+			}
+		}
+	}
+	return diff
+}
+
+func (h *GPTHeader) entry(i int) []byte {
+	return h.entries[i*int(h.EntrySize) : (i+1)*int(h.EntrySize)]
+}
+
+func gptName(e []byte) string {
+	name := make([]uint16, 0, gptEntryName/2)
+	for i := 56; i+2 <= 56+gptEntryName; i += 2 {
+		c := binary.LittleEndian.Uint16(e[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+	return string(utf16.Decode(name))
+}
+
+// partitions returns used entries of GPT.
+func (h *GPTHeader) partitions(sector int64) []Partition {
+	var parts []Partition
+	le := binary.LittleEndian
+	for i := 0; i < int(h.Entries); i++ {
+		e := h.entry(i)
+		if bytes.Equal(e[:16], make([]byte, 16)) {
+			continue
+		}
+		first, last := int64(le.Uint64(e[32:])), int64(le.Uint64(e[40:]))
+		name := gptName(e)
+		if name == "" {
+			name = fmt.Sprintf("p%d", i+1)
+		}
+		parts = append(parts, Partition{
+			Name:     name,
+			Offset:   first * sector,
+			Size:     (last - first + 1) * sector,
+			ReadOnly: le.Uint64(e[48:])&gptReadOnly != 0,
+		})
+	}
+	return parts
+}
+
+// ReadGPT parses primary and backup GPT, partitions are taken from the valid one,
+// differences between them are warnings.
+func ReadGPT(r io.ReaderAt, size, sector int64) (Table, error) {
+	t := Table{Layout: Layout{Device: "gpt"}}
+	if err := checkSector(sector); err != nil {
+		return t, err
+	}
+	primary, perr := readGPTHeader(r, 1, sector)
+	if perr != nil {
+		t.Warnings = append(t.Warnings, "primary: "+perr.Error())
+	}
+	// backup is at the end of device, primary header knows it
+	lba := size/sector - 1
+	if primary != nil && primary.BackupLBA != uint64(lba) {
+		t.Warnings = append(t.Warnings, fmt.Sprintf("primary: backup LBA %d isn't the last LBA %d", primary.BackupLBA, lba))
+		if int64(primary.BackupLBA) < lba {
+			lba = int64(primary.BackupLBA)
+		}
+	}
+	backup, berr := readGPTHeader(r, lba, sector)
+	if berr != nil {
+		t.Warnings = append(t.Warnings, "backup: "+berr.Error())
+	}
+	switch {
+	case perr == nil && berr == nil:
+		t.Warnings = append(t.Warnings, primary.compare(backup)...)
+		t.Partitions = primary.partitions(sector)
+	case perr == nil:
+		t.Partitions = primary.partitions(sector)
+	case berr == nil:
+		t.Partitions = backup.partitions(sector)
+	default:
+		return t, errors.Join(perr, berr)
+	}
+	return t, nil
+}
@@ -0,0 +1,187 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var ErrChecksum = errors.New("checksum mismatch")
+
+const (
+	ipsMagic = "PATCH"
+	ipsEOF   = "EOF"
+	bpsMagic = "BPS1"
+)
+
+// Truncate cuts image to size, it's an extension of IPS.
+type Truncate int64
+
+func (t Truncate) Apply(image []byte) ([]byte, error) {
+	if int64(t) > int64(len(image)) {
+		return nil, fmt.Errorf("%w: truncate to 0x%x", ErrRange, int64(t))
+	}
+	return image[:t], nil
+}
+
+func (t Truncate) String() string {
+	return fmt.Sprintf("truncate to 0x%x", int64(t))
+}
+
+// ParseIPS decodes records of IPS patch, RLE records are expanded.
+func ParseIPS(b []byte) ([]Patch, error) {
+	var patches []Patch
+	pos := len(ipsMagic)
+	read := func(n int) ([]byte, error) {
+		if pos+n > len(b) {
+			return nil, fmt.Errorf("%w: unexpected end of IPS", ErrSyntax)
+		}
+		pos += n
+		return b[pos-n : pos], nil
+	}
+	for {
+		rec, err := read(3)
+		if err != nil {
+			return nil, err
+		}
+		if string(rec) == ipsEOF {
+			break
+		}
+		off := int64(rec[0])<<16 | int64(rec[1])<<8 | int64(rec[2])
+		size, err := read(2)
+		if err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint16(size))
+		var data []byte
+		if n == 0 {
+			rle, err := read(3)
+			if err != nil {
+				return nil, err
+			}
+			data = bytes.Repeat(rle[2:], int(binary.BigEndian.Uint16(rle)))
+		} else if data, err = read(n); err != nil {
+			return nil, err
+		}
+		patches = append(patches, Bytes{off, data})
+	}
+	if trunc, err := read(3); err == nil {
+		patches = append(patches, Truncate(int64(trunc[0])<<16|int64(trunc[1])<<8|int64(trunc[2])))
+	}
+	return patches, nil
+}
+
+// BPS is a patch in beat format, it creates new image from source one.
+type BPS []byte
+
+func (p BPS) String() string {
+	return fmt.Sprintf("BPS patch 0x%x bytes", len(p))
+}
+
+func (p BPS) Apply(source []byte) ([]byte, error) {
+	const footer = 12
+	if len(p) < len(bpsMagic)+footer {
+		return nil, fmt.Errorf("%w: BPS is too short", ErrSyntax)
+	}
+	body := p[:len(p)-footer]
+	sums := p[len(p)-footer:]
+	if crc32.ChecksumIEEE(p[:len(p)-4]) != binary.LittleEndian.Uint32(sums[8:]) {
+		return nil, fmt.Errorf("%w: BPS patch", ErrChecksum)
+	}
+	if crc32.ChecksumIEEE(source) != binary.LittleEndian.Uint32(sums[0:]) {
+		return nil, fmt.Errorf("%w: source of BPS", ErrChecksum)
+	}
+	r := &bpsReader{b: body, pos: len(bpsMagic)}
+	sourceSize := r.number()
+	targetSize := r.number()
+	metadata := r.number()
+	if r.err == nil && metadata > uint64(len(body)-r.pos) {
+		r.err = ErrSyntax
+	}
+	r.pos += int(metadata)
+	if r.err != nil || sourceSize != uint64(len(source)) {
+		return nil, fmt.Errorf("%w: BPS header", ErrSyntax)
+	}
+	target := make([]byte, 0, min(targetSize, uint64(len(source)+len(p))))
+	var sourceRel, targetRel int64
+	for r.pos < len(body) && r.err == nil {
+		action := r.number()
+		n := int64(action>>2) + 1
+		if uint64(len(target))+uint64(n) > targetSize {
+			return nil, fmt.Errorf("%w: BPS target is too long", ErrSyntax)
+		}
+		switch action & 3 {
+		case 0: // source read
+			off := int64(len(target))
+			if off+n > int64(len(source)) {
+				return nil, fmt.Errorf("%w: source read", ErrRange)
+			}
+			target = append(target, source[off:off+n]...)
+		case 1: // target read
+			if r.pos+int(n) > len(body) {
+				return nil, fmt.Errorf("%w: target read", ErrSyntax)
+			}
+			target = append(target, body[r.pos:r.pos+int(n)]...)
+			r.pos += int(n)
+		case 2: // source copy
+			sourceRel += r.signed()
+			if sourceRel < 0 || sourceRel+n > int64(len(source)) {
+				return nil, fmt.Errorf("%w: source copy", ErrRange)
+			}
+			target = append(target, source[sourceRel:sourceRel+n]...)
+			sourceRel += n
+		case 3: // target copy, ranges can overlap
+			targetRel += r.signed()
+			if targetRel < 0 || targetRel >= int64(len(target)) {
+				return nil, fmt.Errorf("%w: target copy", ErrRange)
+			}
+			for i := int64(0); i < n; i++ {
+				target = append(target, target[targetRel])
+				targetRel++
+			}
+		}
+	}
+	if r.err != nil || uint64(len(target)) != targetSize {
+		return nil, fmt.Errorf("%w: BPS actions", ErrSyntax)
+	}
+	if crc32.ChecksumIEEE(target) != binary.LittleEndian.Uint32(sums[4:]) {
+		return nil, fmt.Errorf("%w: target of BPS", ErrChecksum)
+	}
+	return target, nil
+}
+
+type bpsReader struct {
+	b   []byte
+	pos int
+	err error
+}
+
+// number decodes variable-length number of beat format
+func (r *bpsReader) number() uint64 {
+	var v uint64
+	shift := uint64(1)
+	for {
+		if r.pos < 0 || r.pos >= len(r.b) {
+			r.err = ErrSyntax
+			return 0
+		}
+		x := r.b[r.pos]
+		r.pos++
+		v += uint64(x&0x7f) * shift
+		if x&0x80 != 0 {
+			return v
+		}
+		shift <<= 7
+		v += shift
+	}
+}
+
+func (r *bpsReader) signed() int64 {
+	v := r.number()
+	if v&1 != 0 {
+		return -int64(v >> 1)
+	}
+	return int64(v >> 1)
+}
@@ -0,0 +1,250 @@
+package patch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/checksum"
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrSyntax = errors.New("invalid patch")
+var ErrRange = errors.New("range is out of image")
+
+// Patch changes image and returns new one.
+type Patch interface {
+	Apply(image []byte) ([]byte, error)
+	String() string
+}
+
+// Bytes writes data at offset, image grows if data continues after its end.
+// Data can't start after the end of image, so typo in offset can't create
+// huge image.
+type Bytes struct {
+	Offset int64
+	Data   []byte
+}
+
+func (b Bytes) Apply(image []byte) ([]byte, error) {
+	if b.Offset < 0 || b.Offset > int64(len(image)) {
+		return nil, fmt.Errorf("%w: 0x%x, size of image is 0x%x", ErrRange, b.Offset, len(image))
+	}
+	end := b.Offset + int64(len(b.Data))
+	if end > int64(len(image)) {
+		image = append(image, make([]byte, end-int64(len(image)))...)
+	}
+	copy(image[b.Offset:], b.Data)
+	return image, nil
+}
+
+func (b Bytes) String() string {
+	return fmt.Sprintf("0x%08x: 0x%x bytes", b.Offset, len(b.Data))
+}
+
+// Fixup computes checksum of range [Start, End) and writes it at Offset,
+// negative End is end of image.
+type Fixup struct {
+	Algorithm checksum.Algorithm
+	Start     int64
+	End       int64
+	Offset    int64
+	BigEndian bool
+}
+
+// ParseFixup decodes fixup as <algorithm>:<start>-[<end>]@<offset>[:le|be],
+// checksum is little-endian by default.
+func ParseFixup(s string) (Fixup, error) {
+	f := Fixup{End: -1}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return f, fmt.Errorf("%w: fixup '%s'", ErrSyntax, s)
+	}
+	var err error
+	f.Algorithm, err = checksum.Lookup(parts[0])
+	if err != nil {
+		return f, err
+	}
+	rng, off, ok := strings.Cut(parts[1], "@")
+	start, end, ok2 := strings.Cut(rng, "-")
+	if !ok || !ok2 {
+		return f, fmt.Errorf("%w: range of fixup '%s'", ErrSyntax, s)
+	}
+	if f.Start, err = strconv.ParseInt(start, 0, 64); err != nil {
+		return f, fmt.Errorf("%w: start of fixup '%s'", ErrSyntax, s)
+	}
+	if end != "" {
+		if f.End, err = strconv.ParseInt(end, 0, 64); err != nil {
+			return f, fmt.Errorf("%w: end of fixup '%s'", ErrSyntax, s)
+		}
+	}
+	if f.Offset, err = strconv.ParseInt(off, 0, 64); err != nil {
+		return f, fmt.Errorf("%w: offset of fixup '%s'", ErrSyntax, s)
+	}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "le":
+		case "be":
+			f.BigEndian = true
+		default:
+			return f, fmt.Errorf("%w: byte order of fixup '%s'", ErrSyntax, s)
+		}
+	}
+	return f, nil
+}
+
+// Apply writes checksum in image and returns its value.
+func (f Fixup) Apply(image []byte) ([]byte, error) {
+	end := f.End
+	if end < 0 {
+		end = int64(len(image))
+	}
+	if f.Start < 0 || f.Start > end || end > int64(len(image)) {
+		return nil, fmt.Errorf("%w: 0x%x-0x%x", ErrRange, f.Start, end)
+	}
+	sum := f.Algorithm.Sum(image[f.Start:end], f.BigEndian)
+	if f.Offset < 0 || f.Offset+int64(len(sum)) > int64(len(image)) {
+		return nil, fmt.Errorf("%w: checksum at 0x%x", ErrRange, f.Offset)
+	}
+	copy(image[f.Offset:], sum)
+	return sum, nil
+}
+
+func (f Fixup) String() string {
+	order := "le"
+	if f.BigEndian {
+		order = "be"
+	}
+	end := ""
+	if f.End >= 0 {
+		end = fmt.Sprintf("0x%x", f.End)
+	}
+	return fmt.Sprintf("%s:0x%x-%s@0x%x:%s", f.Algorithm.Name, f.Start, end, f.Offset, order)
+}
+
+// Parse decodes IPS, BPS or text patch. Text patch has lines with offset and hex data
+// or fixups, # starts comment:
+//
+//	0x100: de ad be ef
+//	fixup crc32:0x0-0xfffc@0xfffc:le
+func Parse(b []byte) ([]Patch, []Fixup, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte(ipsMagic)):
+		p, err := ParseIPS(b)
+		return p, nil, err
+	case bytes.HasPrefix(b, []byte(bpsMagic)):
+		return []Patch{BPS(b)}, nil, nil
+	}
+	var patches []Patch
+	var fixups []Fixup
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "fixup" && len(fields) == 2 {
+			f, err := ParseFixup(fields[1])
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			fixups = append(fixups, f)
+			continue
+		}
+		off, err := strconv.ParseInt(strings.TrimSuffix(fields[0], ":"), 0, 64)
+		if err != nil || off < 0 {
+			return nil, nil, fmt.Errorf("%w: offset at line %d", ErrSyntax, n)
+		}
+		data, err := hex.DecodeString(strings.Join(fields[1:], ""))
+		if err != nil || len(data) == 0 {
+			return nil, nil, fmt.Errorf("%w: data at line %d", ErrSyntax, n)
+		}
+		patches = append(patches, Bytes{off, data})
+	}
+	return patches, fixups, sc.Err()
+}
+
+type Patcher struct {
+	image   []byte
+	patches []Patch
+	fixups  []Fixup
+	out     io.Writer
+	Config  config.Patch
+}
+
+func New(cfg config.Patch) *Patcher {
+	return &Patcher{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (p *Patcher) Open(input string) error {
+	var err error
+	p.image, err = os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for patching: %w", input, err)
+	}
+	if p.Config.Patches != "" {
+		b, err := os.ReadFile(p.Config.Patches)
+		if err != nil {
+			return fmt.Errorf("can't open patch '%s': %w", p.Config.Patches, err)
+		}
+		p.patches, p.fixups, err = Parse(b)
+		if err != nil {
+			return fmt.Errorf("%s: %w", p.Config.Patches, err)
+		}
+	}
+	for _, s := range p.Config.Fixups {
+		f, err := ParseFixup(s)
+		if err != nil {
+			return err
+		}
+		p.fixups = append(p.fixups, f)
+	}
+	if len(p.patches) == 0 && len(p.fixups) == 0 {
+		return errors.New("nothing to patch, set patch or fixups")
+	}
+	if p.Config.Output == "" {
+		p.Config.Output = strings.TrimSuffix(input, ".bin") + "-patched.bin"
+	}
+	return nil
+}
+
+func (p *Patcher) Close() error {
+	return nil
+}
+
+func (p *Patcher) Run(ctx context.Context) error {
+	image := p.image
+	var err error
+	for _, patch := range p.patches {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		image, err = patch.Apply(image)
+		if err != nil {
+			return fmt.Errorf("%s: %w", patch, err)
+		}
+		fmt.Fprintln(p.out, patch)
+	}
+	// fixups are applied in order, so checksum can cover previous one
+	for _, f := range p.fixups {
+		sum, err := f.Apply(image)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f, err)
+		}
+		fmt.Fprintf(p.out, "%s = %x\n", f, sum)
+	}
+	return os.WriteFile(p.Config.Output, image, 0666)
+}
@@ -0,0 +1,213 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func bpsNumber(v uint64) []byte {
+	var b []byte
+	for {
+		x := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, 0x80|x)
+		}
+		b = append(b, x)
+		v--
+	}
+}
+
+func bps(source, target []byte, actions ...[]byte) []byte {
+	return bpsMetadata(source, target, 0, actions...)
+}
+
+// bpsMetadata builds patch with size of metadata, metadata itself isn't written
+func bpsMetadata(source, target []byte, metadata uint64, actions ...[]byte) []byte {
+	b := []byte(bpsMagic)
+	b = append(b, bpsNumber(uint64(len(source)))...)
+	b = append(b, bpsNumber(uint64(len(target)))...)
+	b = append(b, bpsNumber(metadata)...)
+	for _, a := range actions {
+		b = append(b, a...)
+	}
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(source))
+	b = binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+func TestParse(t *testing.T) {
+	image := []byte("0123456789abcdef")
+	tests := []struct {
+		name    string
+		patch   []byte
+		want    []byte
+		fixups  int
+		wantErr error
+	}{
+		{
+			"Text",
+			[]byte("# header\n0x2: 41 42 # AB\n4 4344\nfixup sum8:0x0-0x8@0xf\n"),
+			[]byte("01ABCD6789abcdef"),
+			1,
+			nil,
+		},
+		{
+			"Text grows image",
+			[]byte("0x10: ffff"),
+			append([]byte("0123456789abcdef"), 0xff, 0xff),
+			0,
+			nil,
+		},
+		{
+			"IPS with RLE and truncate",
+			[]byte("PATCH\x00\x00\x01\x00\x02XY\x00\x00\x04\x00\x00\x00\x03Z" + "EOF\x00\x00\x0a"),
+			[]byte("0XY3ZZZ789"),
+			0,
+			nil,
+		},
+		{
+			"BPS",
+			bps(image, []byte("01234567!!cdef0123"),
+				bpsNumber(7<<2|0),
+				bpsNumber(1<<2|1), []byte("!!"),
+				bpsNumber(3<<2|2), bpsNumber(12<<1),
+				bpsNumber(3<<2|3), bpsNumber(0),
+			),
+			[]byte("01234567!!cdef0123"),
+			0,
+			nil,
+		},
+		{
+			"BPS for other source",
+			bps([]byte("other"), []byte("x"), bpsNumber(0<<2|1), []byte("x")),
+			nil,
+			0,
+			ErrChecksum,
+		},
+		{
+			"BPS with huge metadata",
+			bpsMetadata(image, []byte("x"), 0xff00000000000000, bpsNumber(0<<2|1), []byte("x")),
+			nil,
+			0,
+			ErrSyntax,
+		},
+		{
+			"Text after end of image",
+			[]byte("0x7fffffffffffffff: 00"),
+			nil,
+			0,
+			ErrRange,
+		},
+		{
+			"Text with gap after end of image",
+			[]byte("0x11: 00"),
+			nil,
+			0,
+			ErrRange,
+		},
+		{
+			"Bad hex",
+			[]byte("0x10: fg"),
+			nil,
+			0,
+			ErrSyntax,
+		},
+		{
+			"Short IPS",
+			[]byte("PATCH\x00\x00"),
+			nil,
+			0,
+			ErrSyntax,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patches, fixups, err := Parse(tt.patch)
+			if err == nil {
+				img := append([]byte{}, image...)
+				for _, p := range patches {
+					if img, err = p.Apply(img); err != nil {
+						break
+					}
+				}
+				if len(tt.want) > 0 {
+					require.Equal(t, string(tt.want), string(img))
+				}
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, fixups, tt.fixups)
+		})
+	}
+}
+
+func TestFixup(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []byte
+		wantErr error
+	}{
+		{"crc32:0x0-0xc@0xc", []byte{0x3a, 0x72, 0xab, 0xff}, nil},
+		{"crc32:0x0-0xc@0xc:be", []byte{0xff, 0xab, 0x72, 0x3a}, nil},
+		{"crc16-ccitt:0x0-@0xe:be", nil, nil},
+		{"sum16-2c:0x0-0xc@0xc:le", []byte{0x78, 0xfb}, nil},
+		{"sum8:0x0-0x20@0x0", nil, ErrRange},
+		{"sum8:0x0-0x4@0x10:be", nil, ErrRange},
+		{"sum8:0x0@0x0", nil, ErrSyntax},
+		{"sum8:0x0-0x4@0x0:me", nil, ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			img := append([]byte("hello, world"), 0, 0, 0, 0)
+			f, err := ParseFixup(tt.spec)
+			if err == nil {
+				_, err = f.Apply(img)
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			if tt.want != nil {
+				require.Equal(t, tt.want, img[12:12+len(tt.want)])
+			}
+		})
+	}
+}
+
+func TestPatcher_Run(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "fw.bin")
+	require.NoError(t, os.WriteFile(name, bytes.Repeat([]byte{0xFF}, 0x20), 0666))
+	patch := filepath.Join(dir, "fw.patch")
+	require.NoError(t, os.WriteFile(patch, []byte("0x0: 00 01 02 03\nfixup crc32:0x8-0x1c@0x1c:be\n"), 0666))
+
+	p := New(config.Patch{Patches: patch, Fixups: []string{"xor8:0x0-0x4@0x4"}})
+	out := &bytes.Buffer{}
+	p.out = out
+	require.NoError(t, p.Open(name))
+	require.NoError(t, p.Run(context.TODO()))
+	require.Equal(t, filepath.Join(dir, "fw-patched.bin"), p.Config.Output)
+	require.Contains(t, out.String(), "0x00000000: 0x4 bytes\n")
+	require.Contains(t, out.String(), "xor8:0x0-0x4@0x4:le = 00\n")
+
+	data, err := os.ReadFile(p.Config.Output)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0, 1, 2, 3, 0, 0xFF}, data[:6])
+	require.Equal(t, crc32.ChecksumIEEE(data[0x8:0x1c]), binary.BigEndian.Uint32(data[0x1c:]))
+
+	require.Error(t, New(config.Patch{}).Open(name))
+}
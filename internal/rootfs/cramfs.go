@@ -0,0 +1,200 @@
+package rootfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	crSignature   = "Compressed ROMFS"
+	crRootOffset  = 64
+	crInodeSize   = 12
+	crPageSize    = 4096
+	crFlagVersion = 0x1
+	// block pointers with flags of uncompressed and direct blocks
+	crFlagExtBlockPointers = 0x800
+	crBlockUncompressed    = 1 << 31
+	crBlockDirect          = 1 << 30
+	crMaxDepth             = 64
+)
+
+// mode bits of inode
+const (
+	sIFMT  = 0o170000
+	sIFDIR = 0o040000
+	sIFREG = 0o100000
+	sIFLNK = 0o120000
+)
+
+type crInode struct {
+	mode   uint16
+	size   uint32
+	offset int64
+	// length of name padded to 4 bytes
+	nameLen int
+	name    string
+}
+
+func probeCramfs(b []byte, order binary.ByteOrder) (Superblock, bool) {
+	sb := Superblock{
+		Type:       CramFS,
+		BigEndian:  order == binary.BigEndian,
+		Compressor: "zlib",
+		Version:    "1",
+	}
+	if string(b[16:32]) != crSignature {
+		return sb, false
+	}
+	sb.Size = int64(order.Uint32(b[4:8]))
+	if order.Uint32(b[8:12])&crFlagVersion != 0 {
+		sb.Version = "2"
+	}
+	return sb, true
+}
+
+func extractCramfs(ctx context.Context, r io.ReaderAt, sb Superblock, dir string) error {
+	if sb.BigEndian {
+		return fmt.Errorf("%w: big-endian cramfs can't be extracted", ErrUnsupported)
+	}
+	b, err := readAt(r, 0, crRootOffset+crInodeSize)
+	if err != nil {
+		return err
+	}
+	c := &cramfs{
+		r:     r,
+		flags: binary.LittleEndian.Uint32(b[8:12]),
+	}
+	root := c.parseInode(b[crRootOffset:])
+	if err := c.extractDir(ctx, root, dir, 0); err != nil {
+		return err
+	}
+	return c.links.create()
+}
+
+type cramfs struct {
+	r     io.ReaderAt
+	flags uint32
+	links symlinks
+}
+
+func (c *cramfs) parseInode(b []byte) crInode {
+	le := binary.LittleEndian
+	sizeGID := le.Uint32(b[4:8])
+	nameOffset := le.Uint32(b[8:12])
+	return crInode{
+		mode:    le.Uint16(b[0:2]),
+		size:    sizeGID & 0xFFFFFF,
+		offset:  int64(nameOffset>>6) * 4,
+		nameLen: int(nameOffset&0x3F) * 4,
+	}
+}
+
+func (c *cramfs) extractDir(ctx context.Context, in crInode, dir string, depth int) error {
+	if depth > crMaxDepth {
+		return fmt.Errorf("%w: too deep directories", ErrCorrupted)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for pos := in.offset; pos < in.offset+int64(in.size); {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		b, err := readAt(c.r, pos, crInodeSize)
+		if err != nil {
+			return err
+		}
+		child := c.parseInode(b)
+		name, err := readAt(c.r, pos+crInodeSize, child.nameLen)
+		if err != nil {
+			return err
+		}
+		child.name = string(bytes.TrimRight(name, "\x00"))
+		pos += crInodeSize + int64(len(name))
+		if !safeName(child.name) {
+			return fmt.Errorf("%w: name '%s'", ErrCorrupted, child.name)
+		}
+		p := filepath.Join(dir, child.name)
+		switch child.mode & sIFMT {
+		case sIFDIR:
+			err = c.extractDir(ctx, child, p, depth+1)
+		case sIFREG:
+			err = c.extractFile(child, p)
+		case sIFLNK:
+			buf := &bytes.Buffer{}
+			if err = c.copyFile(child, buf); err == nil {
+				c.links.add(buf.String(), p)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cramfs) extractFile(in crInode, name string) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(c.copyFile(in, f), f.Close())
+}
+
+func (c *cramfs) copyFile(in crInode, w io.Writer) error {
+	if in.size == 0 {
+		return nil
+	}
+	blocks := int((in.size-1)/crPageSize + 1)
+	b, err := readAt(c.r, in.offset, blocks*4)
+	if err != nil {
+		return err
+	}
+	start := in.offset + int64(blocks)*4
+	left := int(in.size)
+	for i := 0; i < blocks; i++ {
+		ptr := binary.LittleEndian.Uint32(b[i*4:])
+		uncompressed := false
+		if c.flags&crFlagExtBlockPointers != 0 {
+			if ptr&crBlockDirect != 0 {
+				return fmt.Errorf("%w: direct blocks of cramfs", ErrUnsupported)
+			}
+			uncompressed = ptr&crBlockUncompressed != 0
+			ptr &^= crBlockUncompressed | crBlockDirect
+		}
+		n := min(left, crPageSize)
+		var data []byte
+		switch {
+		case int64(ptr) == start:
+			// hole
+			data = make([]byte, n)
+		case int64(ptr) < start:
+			return fmt.Errorf("%w: block pointer 0x%x", ErrCorrupted, ptr)
+		default:
+			data, err = readAt(c.r, start, int(int64(ptr)-start))
+			if err == nil && !uncompressed {
+				data, err = inflate(data)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if len(data) < n {
+			return fmt.Errorf("%w: short block at 0x%x", ErrCorrupted, start)
+		}
+		if _, err := w.Write(data[:n]); err != nil {
+			return err
+		}
+		left -= n
+		start = int64(ptr)
+	}
+	return nil
+}
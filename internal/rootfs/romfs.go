@@ -0,0 +1,147 @@
+package rootfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	romHeaderSize = 16
+	romMaxName    = 256
+	romMaxDepth   = 64
+)
+
+// file types of romfs
+const (
+	romHardlink = iota
+	romDir
+	romFile
+	romSymlink
+)
+
+func probeRomfs(b []byte) (Superblock, bool) {
+	sb := Superblock{
+		Type:       RomFS,
+		BigEndian:  true,
+		Compressor: "none",
+		Version:    "1",
+	}
+	sb.Size = int64(binary.BigEndian.Uint32(b[8:12]))
+	return sb, sb.Size > romHeaderSize
+}
+
+type romEntry struct {
+	next int64
+	typ  uint32
+	spec int64
+	size int64
+	name string
+	data int64
+}
+
+func extractRomfs(ctx context.Context, r io.ReaderAt, dir string) error {
+	name, err := romName(r, romHeaderSize)
+	if err != nil {
+		return err
+	}
+	first := align16(romHeaderSize + int64(len(name)) + 1)
+	var links symlinks
+	if err := romDirectory(ctx, r, first, dir, 0, &links); err != nil {
+		return err
+	}
+	return links.create()
+}
+
+func romDirectory(ctx context.Context, r io.ReaderAt, pos int64, dir string, depth int, links *symlinks) error {
+	if depth > romMaxDepth {
+		return fmt.Errorf("%w: too deep directories", ErrCorrupted)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// files of directory are a linked list
+	for seen := map[int64]bool{}; pos != 0; {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if seen[pos] {
+			return fmt.Errorf("%w: loop in directory at 0x%x", ErrCorrupted, pos)
+		}
+		seen[pos] = true
+		f, err := romHeader(r, pos)
+		if err != nil {
+			return err
+		}
+		pos = f.next
+		if f.name == "." || f.name == ".." {
+			continue
+		}
+		if !safeName(f.name) {
+			return fmt.Errorf("%w: name '%s'", ErrCorrupted, f.name)
+		}
+		p := filepath.Join(dir, f.name)
+		switch f.typ {
+		case romDir:
+			err = romDirectory(ctx, r, f.spec, p, depth+1, links)
+		case romFile:
+			err = writeFile(p, io.NewSectionReader(r, f.data, f.size))
+		case romSymlink:
+			var target []byte
+			target, err = readAt(r, f.data, int(min(f.size, romMaxName)))
+			if err == nil {
+				links.add(string(target), p)
+			}
+		case romHardlink:
+			var link romEntry
+			link, err = romHeader(r, f.spec)
+			if err == nil && link.typ == romFile {
+				err = writeFile(p, io.NewSectionReader(r, link.data, link.size))
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func romHeader(r io.ReaderAt, pos int64) (romEntry, error) {
+	b, err := readAt(r, pos, romHeaderSize)
+	if err != nil {
+		return romEntry{}, err
+	}
+	next := binary.BigEndian.Uint32(b[0:4])
+	f := romEntry{
+		next: int64(next &^ 0xF),
+		typ:  next & 0x7,
+		spec: int64(binary.BigEndian.Uint32(b[4:8])),
+		size: int64(binary.BigEndian.Uint32(b[8:12])),
+	}
+	f.name, err = romName(r, pos+romHeaderSize)
+	f.data = align16(pos + romHeaderSize + int64(len(f.name)) + 1)
+	return f, err
+}
+
+func romName(r io.ReaderAt, pos int64) (string, error) {
+	b := make([]byte, romMaxName)
+	n, err := r.ReadAt(b, pos)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	i := bytes.IndexByte(b[:n], 0)
+	if i < 0 {
+		return "", fmt.Errorf("%w: name at 0x%x", ErrCorrupted, pos)
+	}
+	return string(b[:i]), nil
+}
+
+func align16(v int64) int64 {
+	return (v + 15) &^ 15
+}
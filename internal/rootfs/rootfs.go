@@ -0,0 +1,252 @@
+package rootfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrUnsupported = errors.New("unsupported filesystem")
+var ErrCompressor = errors.New("unsupported compressor")
+var ErrCorrupted = errors.New("corrupted filesystem")
+
+type Type string
+
+const (
+	SquashFS Type = "squashfs"
+	CramFS   Type = "cramfs"
+	RomFS    Type = "romfs"
+)
+
+const (
+	chunkSize = 1 << 20
+	// longest superblock, which is read for probing
+	probeSize = 96
+)
+
+type Superblock struct {
+	Type       Type   `json:"type"`
+	Offset     int64  `json:"offset"`
+	Size       int64  `json:"size"`
+	BigEndian  bool   `json:"big_endian"`
+	Version    string `json:"version"`
+	Compressor string `json:"compressor"`
+}
+
+func (sb Superblock) String() string {
+	order := "little-endian"
+	if sb.BigEndian {
+		order = "big-endian"
+	}
+	return fmt.Sprintf("0x%08x %s %s, %s, size %d, compressor %s",
+		sb.Offset, sb.Type, sb.Version, order, sb.Size, sb.Compressor)
+}
+
+var magics = [][]byte{
+	[]byte("hsqs"),
+	[]byte("sqsh"),
+	{0x45, 0x3d, 0xcd, 0x28},
+	{0x28, 0xcd, 0x3d, 0x45},
+	[]byte("-rom1fs-"),
+}
+
+// Probe checks superblock at beginning of b.
+func Probe(b []byte) (Superblock, bool) {
+	if len(b) < probeSize {
+		return Superblock{}, false
+	}
+	switch {
+	case bytes.HasPrefix(b, magics[0]):
+		return probeSquashfs(b, binary.LittleEndian)
+	case bytes.HasPrefix(b, magics[1]):
+		return probeSquashfs(b, binary.BigEndian)
+	case bytes.HasPrefix(b, magics[2]):
+		return probeCramfs(b, binary.LittleEndian)
+	case bytes.HasPrefix(b, magics[3]):
+		return probeCramfs(b, binary.BigEndian)
+	case bytes.HasPrefix(b, magics[4]):
+		return probeRomfs(b)
+	}
+	return Superblock{}, false
+}
+
+// Detect looks for superblocks at any offset of image.
+func Detect(ctx context.Context, r io.ReaderAt, size int64) ([]Superblock, error) {
+	var found []Superblock
+	buf := make([]byte, chunkSize+probeSize)
+	for off := int64(0); off < size; off += chunkSize {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		n, err := r.ReadAt(buf, off)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		b := buf[:n]
+		for i := 0; i < min(n, chunkSize); i++ {
+			if !hasMagic(b[i:]) {
+				continue
+			}
+			sb, ok := Probe(b[i:])
+			if !ok {
+				continue
+			}
+			sb.Offset = off + int64(i)
+			found = append(found, sb)
+		}
+	}
+	return found, nil
+}
+
+func hasMagic(b []byte) bool {
+	for _, m := range magics {
+		if bytes.HasPrefix(b, m) {
+			return true
+		}
+	}
+	return false
+}
+
+type Extractor struct {
+	input  io.ReaderAt
+	size   int64
+	closer io.Closer
+	out    io.Writer
+	Config config.RootFS
+}
+
+func New(cfg config.RootFS) *Extractor {
+	return &Extractor{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (e *Extractor) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for extracting: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for extracting: %w", input, err)
+	}
+	e.input = in
+	e.size = stat.Size()
+	e.closer = in
+	if e.Config.Output == "" {
+		name := filepath.Base(input)
+		e.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-rootfs"
+	}
+	return nil
+}
+
+func (e *Extractor) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// Run prints all found filesystems and extracts them in subdirectories of output.
+// Errors of single filesystem don't stop extraction of others.
+func (e *Extractor) Run(ctx context.Context) error {
+	found, err := Detect(ctx, e.input, e.size)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, sb := range found {
+		fmt.Fprintln(e.out, sb)
+		if e.Config.DetectOnly {
+			continue
+		}
+		dir := filepath.Join(e.Config.Output, fmt.Sprintf("%08x-%s", sb.Offset, sb.Type))
+		if err := e.Extract(ctx, sb, dir); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s at 0x%x: %w", sb.Type, sb.Offset, err))
+		}
+	}
+	return errs
+}
+
+// Extract writes contents of filesystem with superblock sb into dir.
+func (e *Extractor) Extract(ctx context.Context, sb Superblock, dir string) error {
+	size := e.size - sb.Offset
+	if sb.Size > 0 && sb.Size < size {
+		size = sb.Size
+	}
+	r := io.NewSectionReader(e.input, sb.Offset, size)
+	switch sb.Type {
+	case SquashFS:
+		return extractSquashfs(ctx, r, sb, dir)
+	case CramFS:
+		return extractCramfs(ctx, r, sb, dir)
+	case RomFS:
+		return extractRomfs(ctx, r, dir)
+	}
+	return fmt.Errorf("%w: %s", ErrUnsupported, sb.Type)
+}
+
+func writeFile(name string, r io.Reader) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
+
+// symlinks are created after all files and directories of filesystem, so files
+// can't be written through them. Symlink with name of existing file is skipped.
+type symlinks []symlink
+
+type symlink struct {
+	target, name string
+}
+
+func (l *symlinks) add(target, name string) {
+	*l = append(*l, symlink{target, name})
+}
+
+func (l symlinks) create() error {
+	for _, s := range l {
+		if err := os.Symlink(s.target, s.name); err != nil && !errors.Is(err, os.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// safeName rejects names, which can escape from output directory
+func safeName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+}
+
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := r.ReadAt(b, off)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: read out of image at 0x%x", ErrCorrupted, off)
+	}
+	return b, err
+}
+
+func inflate(b []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrCorrupted, err)
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
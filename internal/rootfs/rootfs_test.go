@@ -0,0 +1,281 @@
+package rootfs
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func deflate(b []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := zlib.NewWriter(buf)
+	zw.Write(b)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func squashfsImage(compressor uint16, name string, content []byte) []byte {
+	le := binary.LittleEndian
+	data := deflate(content)
+
+	inodes := &bytes.Buffer{}
+	binary.Write(inodes, le, sqInodeHeader{Type: sqDir, Mode: 0755, Number: 1})
+	binary.Write(inodes, le, struct {
+		BlockIndex, LinkCount uint32
+		FileSize, BlockOffset uint16
+		Parent                uint32
+	}{0, 2, uint16(12 + 8 + len(name) + 3), 0, 3})
+	fileRef := inodes.Len()
+	binary.Write(inodes, le, sqInodeHeader{Type: sqFile, Mode: 0644, Number: 2})
+	binary.Write(inodes, le, struct {
+		BlocksStart, Fragment, BlockOffset, FileSize uint32
+	}{probeSize, sqNoFragment, 0, uint32(len(content))})
+	binary.Write(inodes, le, uint32(len(data)))
+
+	dirs := &bytes.Buffer{}
+	binary.Write(dirs, le, struct{ Count, Start, Number uint32 }{0, 0, 2})
+	binary.Write(dirs, le, struct {
+		Offset      uint16
+		InodeOffset int16
+		Type        uint16
+		NameSize    uint16
+	}{uint16(fileRef), 0, sqFile, uint16(len(name) - 1)})
+	dirs.WriteString(name)
+
+	img := &bytes.Buffer{}
+	img.Write(make([]byte, probeSize))
+	img.Write(data)
+	meta := func(b []byte) {
+		binary.Write(img, le, uint16(len(b))|sqMetaUncompressed)
+		img.Write(b)
+	}
+	inodeStart := img.Len()
+	meta(inodes.Bytes())
+	dirStart := img.Len()
+	meta(dirs.Bytes())
+	end := uint64(img.Len())
+	sb := sqSuperblock{
+		Magic:           0x73717368,
+		InodeCount:      2,
+		BlockSize:       1 << 17,
+		Compressor:      compressor,
+		BlockLog:        17,
+		VersionMajor:    4,
+		BytesUsed:       end,
+		IDTableStart:    end,
+		InodeTableStart: uint64(inodeStart),
+		DirTableStart:   uint64(dirStart),
+		FragTableStart:  end,
+	}
+	b := img.Bytes()
+	sbuf := &bytes.Buffer{}
+	binary.Write(sbuf, le, sb)
+	copy(b, sbuf.Bytes())
+	return b
+}
+
+func cramfsImage(name string, content []byte) []byte {
+	le := binary.LittleEndian
+	padded := make([]byte, (len(name)+3)/4*4)
+	copy(padded, name)
+	dataOff := crRootOffset + crInodeSize + crInodeSize + len(padded)
+	data := deflate(content)
+
+	img := &bytes.Buffer{}
+	binary.Write(img, le, []uint32{0x28cd3d45, 0, 0, 0})
+	img.WriteString(crSignature)
+	img.Write(make([]byte, 32))
+	inode := func(mode uint16, size int, nameLen int, offset int) {
+		binary.Write(img, le, mode)
+		binary.Write(img, le, uint16(0))
+		binary.Write(img, le, uint32(size))
+		binary.Write(img, le, uint32(nameLen/4)|uint32(offset/4)<<6)
+	}
+	inode(sIFDIR|0755, crInodeSize+len(padded), 0, crRootOffset+crInodeSize)
+	inode(sIFREG|0644, len(content), len(padded), dataOff)
+	img.Write(padded)
+	binary.Write(img, le, uint32(dataOff+4+len(data)))
+	img.Write(data)
+	b := img.Bytes()
+	le.PutUint32(b[4:8], uint32(len(b)))
+	return b
+}
+
+func romfsEntry(next uint32, typ uint32, spec uint32, name string, data []byte) []byte {
+	b := &bytes.Buffer{}
+	binary.Write(b, binary.BigEndian, []uint32{next | typ, spec, uint32(len(data)), 0})
+	b.WriteString(name)
+	b.Write(make([]byte, int(align16(int64(len(name)+1)))-len(name)))
+	b.Write(data)
+	b.Write(make([]byte, int(align16(int64(len(data))))-len(data)))
+	return b.Bytes()
+}
+
+func romfsImage() []byte {
+	img := &bytes.Buffer{}
+	img.WriteString("-rom1fs-")
+	binary.Write(img, binary.BigEndian, []uint32{160, 0})
+	img.WriteString("vol")
+	img.Write(make([]byte, 13))
+	img.Write(romfsEntry(112, romDir, 64, "sub", nil))
+	img.Write(romfsEntry(0, romFile, 0, "b", []byte("B")))
+	img.Write(romfsEntry(0, romFile, 0, "a.txt", []byte("hello")))
+	img.Write(make([]byte, probeSize))
+	return img.Bytes()
+}
+
+// romfsLinkImage has symlink and file with the same name
+func romfsLinkImage() []byte {
+	img := &bytes.Buffer{}
+	img.WriteString("-rom1fs-")
+	binary.Write(img, binary.BigEndian, []uint32{128, 0})
+	img.WriteString("vol")
+	img.Write(make([]byte, 13))
+	img.Write(romfsEntry(80, romSymlink, 0, "x", []byte("../escaped")))
+	img.Write(romfsEntry(0, romFile, 0, "x", []byte("data")))
+	img.Write(make([]byte, probeSize))
+	return img.Bytes()
+}
+
+// squashfsLoop has directory entry, which points to root
+func squashfsLoop() []byte {
+	b := squashfsImage(1, "loop", []byte("a"))
+	dirs := binary.LittleEndian.Uint64(b[72:80])
+	binary.LittleEndian.PutUint16(b[dirs+2+12:], 0)
+	binary.LittleEndian.PutUint16(b[dirs+2+12+4:], sqDir)
+	return b
+}
+
+// squashfsHuge has file, which size needs more blocks than image has
+func squashfsHuge() []byte {
+	b := squashfsImage(1, "huge", []byte("a"))
+	inodes := binary.LittleEndian.Uint64(b[64:72])
+	binary.LittleEndian.PutUint32(b[inodes+2+60:], 0xffffffff)
+	return b
+}
+
+func readTree(t *testing.T, dir string) map[string]string {
+	got := map[string]string{}
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		rel, _ := filepath.Rel(dir, p)
+		got[filepath.ToSlash(rel)] = string(data)
+		return err
+	})
+	return got
+}
+
+func TestDetect(t *testing.T) {
+	v3 := make([]byte, probeSize)
+	copy(v3, "sqsh")
+	binary.BigEndian.PutUint16(v3[28:30], 3)
+	binary.BigEndian.PutUint16(v3[30:32], 1)
+	binary.BigEndian.PutUint64(v3[63:71], 0x1000)
+
+	img := make([]byte, 3*chunkSize)
+	copy(img[0x1234:], squashfsImage(1, "a", []byte("a")))
+	copy(img[chunkSize-2:], v3)
+	copy(img[2*chunkSize+0x10:], cramfsImage("a", []byte("a")))
+	copy(img[2*chunkSize+0x1000:], []byte("hsqs but not a superblock"))
+
+	found, err := Detect(context.TODO(), bytes.NewReader(img), int64(len(img)))
+	require.NoError(t, err)
+	require.Len(t, found, 3)
+	require.Equal(t, SquashFS, found[0].Type)
+	require.Equal(t, int64(0x1234), found[0].Offset)
+	require.Equal(t, "gzip", found[0].Compressor)
+	require.Equal(t, Superblock{
+		Type:       SquashFS,
+		Offset:     chunkSize - 2,
+		Size:       0x1000,
+		BigEndian:  true,
+		Version:    "3.1",
+		Compressor: "gzip",
+	}, found[1])
+	require.Equal(t, CramFS, found[2].Type)
+	require.Equal(t, int64(2*chunkSize+0x10), found[2].Offset)
+}
+
+func TestExtractor_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		image   []byte
+		want    map[string]string
+		wantErr error
+	}{
+		{
+			"SquashFS with gzip",
+			squashfsImage(1, "hello.txt", bytes.Repeat([]byte("squash"), 100)),
+			map[string]string{"00000010-squashfs/hello.txt": string(bytes.Repeat([]byte("squash"), 100))},
+			nil,
+		},
+		{
+			"SquashFS with xz",
+			squashfsImage(4, "hello.txt", []byte("squash")),
+			map[string]string{},
+			ErrCompressor,
+		},
+		{
+			"CramFS",
+			cramfsImage("file.bin", bytes.Repeat([]byte{1, 2, 3}, 1000)),
+			map[string]string{"00000010-cramfs/file.bin": string(bytes.Repeat([]byte{1, 2, 3}, 1000))},
+			nil,
+		},
+		{
+			"SquashFS with loop",
+			squashfsLoop(),
+			map[string]string{},
+			ErrCorrupted,
+		},
+		{
+			"SquashFS with huge file",
+			squashfsHuge(),
+			map[string]string{},
+			ErrCorrupted,
+		},
+		{
+			"RomFS symlink and file with the same name",
+			romfsLinkImage(),
+			map[string]string{"00000010-romfs/x": "data"},
+			nil,
+		},
+		{
+			"RomFS",
+			romfsImage(),
+			map[string]string{
+				"00000010-romfs/a.txt": "hello",
+				"00000010-romfs/sub/b": "B",
+			},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := append(make([]byte, 0x10), tt.image...)
+			e := New(config.RootFS{Output: filepath.Join(t.TempDir(), "out")})
+			e.input = bytes.NewReader(img)
+			e.size = int64(len(img))
+			e.out = io.Discard
+			err := e.Run(context.TODO())
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.want, readTree(t, e.Config.Output))
+			require.NoFileExists(t, filepath.Join(e.Config.Output, "00000010-romfs", "..", "escaped"))
+		})
+	}
+}
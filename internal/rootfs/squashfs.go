@@ -0,0 +1,475 @@
+package rootfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	sqMetadataSize     = 8192
+	sqMetaUncompressed = 0x8000
+	sqDataUncompressed = 1 << 24
+	sqNoFragment       = 0xFFFFFFFF
+	sqFragmentsInBlock = sqMetadataSize / 16
+	sqMaxDepth         = 64
+)
+
+// inode types
+const (
+	sqDir = iota + 1
+	sqFile
+	sqSymlink
+	sqBlockDev
+	sqCharDev
+	sqFifo
+	sqSocket
+	sqExtDir
+	sqExtFile
+	sqExtSymlink
+)
+
+var sqCompressors = map[uint16]string{
+	1: "gzip",
+	2: "lzma",
+	3: "lzo",
+	4: "xz",
+	5: "lz4",
+	6: "zstd",
+}
+
+type sqSuperblock struct {
+	Magic             uint32
+	InodeCount        uint32
+	ModTime           uint32
+	BlockSize         uint32
+	FragCount         uint32
+	Compressor        uint16
+	BlockLog          uint16
+	Flags             uint16
+	IDCount           uint16
+	VersionMajor      uint16
+	VersionMinor      uint16
+	RootInode         uint64
+	BytesUsed         uint64
+	IDTableStart      uint64
+	XattrIDTableStart uint64
+	InodeTableStart   uint64
+	DirTableStart     uint64
+	FragTableStart    uint64
+	ExportTableStart  uint64
+}
+
+func probeSquashfs(b []byte, order binary.ByteOrder) (Superblock, bool) {
+	sb := Superblock{
+		Type:       SquashFS,
+		BigEndian:  order == binary.BigEndian,
+		Compressor: "gzip",
+	}
+	major := order.Uint16(b[28:30])
+	minor := order.Uint16(b[30:32])
+	sb.Version = fmt.Sprintf("%d.%d", major, minor)
+	switch {
+	case major == 4:
+		blockSize := order.Uint32(b[12:16])
+		blockLog := order.Uint16(b[22:24])
+		if blockLog < 12 || blockLog > 20 || blockSize != 1<<blockLog {
+			return sb, false
+		}
+		c, ok := sqCompressors[order.Uint16(b[20:22])]
+		if !ok {
+			return sb, false
+		}
+		sb.Compressor = c
+		sb.Size = int64(order.Uint64(b[40:48]))
+	case major == 3:
+		sb.Size = int64(order.Uint64(b[63:71]))
+	case major >= 1 && major < 3:
+		sb.Size = int64(order.Uint32(b[8:12]))
+	default:
+		return sb, false
+	}
+	return sb, sb.Size > 0
+}
+
+type squashfs struct {
+	r     io.ReaderAt
+	sb    sqSuperblock
+	frags []sqFragment
+	links symlinks
+}
+
+type sqFragment struct {
+	Start  uint64
+	Size   uint32
+	Unused uint32
+}
+
+type sqInodeHeader struct {
+	Type   uint16
+	Mode   uint16
+	UID    uint16
+	GID    uint16
+	Mtime  uint32
+	Number uint32
+}
+
+type sqInode struct {
+	sqInodeHeader
+	size      uint64
+	blocks    uint64
+	fragment  uint32
+	fragOff   uint32
+	sizes     []uint32
+	dirBlock  uint32
+	dirOffset uint16
+	target    string
+}
+
+func extractSquashfs(ctx context.Context, r io.ReaderAt, sb Superblock, dir string) error {
+	if sb.Version != "4.0" || sb.BigEndian {
+		return fmt.Errorf("%w: squashfs %s, only little-endian 4.0 can be extracted", ErrUnsupported, sb.Version)
+	}
+	if sb.Compressor != "gzip" {
+		return fmt.Errorf("%w: %s", ErrCompressor, sb.Compressor)
+	}
+	s := &squashfs{r: r}
+	err := binary.Read(io.NewSectionReader(r, 0, probeSize), binary.LittleEndian, &s.sb)
+	if err != nil {
+		return err
+	}
+	if err := s.readFragments(); err != nil {
+		return err
+	}
+	root, err := s.inode(s.sb.RootInode)
+	if err != nil {
+		return err
+	}
+	if err := s.extractDir(ctx, root, dir, 0); err != nil {
+		return err
+	}
+	return s.links.create()
+}
+
+// metadata reads one metadata block and returns it with position of next block
+func (s *squashfs) metadata(pos int64) ([]byte, int64, error) {
+	h, err := readAt(s.r, pos, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := binary.LittleEndian.Uint16(h)
+	size := int(header &^ sqMetaUncompressed)
+	if size > sqMetadataSize {
+		return nil, 0, fmt.Errorf("%w: metadata block at 0x%x", ErrCorrupted, pos)
+	}
+	b, err := readAt(s.r, pos+2, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header&sqMetaUncompressed == 0 {
+		b, err = inflate(b)
+	}
+	return b, pos + 2 + int64(size), err
+}
+
+type metaReader struct {
+	s    *squashfs
+	next int64
+	buf  []byte
+}
+
+// meta returns reader of metadata, which starts in block at pos with offset.
+func (s *squashfs) meta(pos int64, offset int) (*metaReader, error) {
+	m := &metaReader{s: s, next: pos}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	if offset > len(m.buf) {
+		return nil, fmt.Errorf("%w: metadata offset %d", ErrCorrupted, offset)
+	}
+	m.buf = m.buf[offset:]
+	return m, nil
+}
+
+func (m *metaReader) load() error {
+	b, next, err := m.s.metadata(m.next)
+	if err != nil {
+		return err
+	}
+	m.buf, m.next = b, next
+	return nil
+}
+
+func (m *metaReader) Read(p []byte) (int, error) {
+	if len(m.buf) == 0 {
+		if err := m.load(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+func (s *squashfs) readFragments() error {
+	if s.sb.FragCount == 0 {
+		return nil
+	}
+	blocks := (int(s.sb.FragCount) + sqFragmentsInBlock - 1) / sqFragmentsInBlock
+	index, err := readAt(s.r, int64(s.sb.FragTableStart), blocks*8)
+	if err != nil {
+		return err
+	}
+	s.frags = make([]sqFragment, 0, s.sb.FragCount)
+	for i := 0; i < blocks; i++ {
+		b, _, err := s.metadata(int64(binary.LittleEndian.Uint64(index[i*8:])))
+		if err != nil {
+			return err
+		}
+		entries := make([]sqFragment, min(len(b)/16, int(s.sb.FragCount)-len(s.frags)))
+		if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, entries); err != nil {
+			return err
+		}
+		s.frags = append(s.frags, entries...)
+	}
+	return nil
+}
+
+func (s *squashfs) inode(ref uint64) (*sqInode, error) {
+	m, err := s.meta(int64(s.sb.InodeTableStart+ref>>16), int(ref&0xFFFF))
+	if err != nil {
+		return nil, err
+	}
+	in := &sqInode{}
+	le := binary.LittleEndian
+	if err := binary.Read(m, le, &in.sqInodeHeader); err != nil {
+		return nil, err
+	}
+	switch in.Type {
+	case sqDir:
+		var d struct {
+			BlockIndex  uint32
+			LinkCount   uint32
+			FileSize    uint16
+			BlockOffset uint16
+			Parent      uint32
+		}
+		err = binary.Read(m, le, &d)
+		in.dirBlock, in.size, in.dirOffset = d.BlockIndex, uint64(d.FileSize), d.BlockOffset
+	case sqExtDir:
+		var d struct {
+			LinkCount   uint32
+			FileSize    uint32
+			BlockIndex  uint32
+			Parent      uint32
+			IndexCount  uint16
+			BlockOffset uint16
+			XattrIndex  uint32
+		}
+		err = binary.Read(m, le, &d)
+		in.dirBlock, in.size, in.dirOffset = d.BlockIndex, uint64(d.FileSize), d.BlockOffset
+	case sqFile:
+		var f struct {
+			BlocksStart uint32
+			Fragment    uint32
+			BlockOffset uint32
+			FileSize    uint32
+		}
+		err = binary.Read(m, le, &f)
+		in.blocks, in.fragment, in.fragOff, in.size = uint64(f.BlocksStart), f.Fragment, f.BlockOffset, uint64(f.FileSize)
+	case sqExtFile:
+		var f struct {
+			BlocksStart uint64
+			FileSize    uint64
+			Sparse      uint64
+			LinkCount   uint32
+			Fragment    uint32
+			BlockOffset uint32
+			XattrIndex  uint32
+		}
+		err = binary.Read(m, le, &f)
+		in.blocks, in.fragment, in.fragOff, in.size = f.BlocksStart, f.Fragment, f.BlockOffset, f.FileSize
+	case sqSymlink, sqExtSymlink:
+		var l struct {
+			LinkCount  uint32
+			TargetSize uint32
+		}
+		if err = binary.Read(m, le, &l); err != nil {
+			return nil, err
+		}
+		if l.TargetSize > 4096 {
+			return nil, fmt.Errorf("%w: symlink size %d", ErrCorrupted, l.TargetSize)
+		}
+		target := make([]byte, l.TargetSize)
+		_, err = io.ReadFull(m, target)
+		in.target = string(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.Type == sqFile || in.Type == sqExtFile {
+		count := in.size / uint64(s.sb.BlockSize)
+		if in.fragment == sqNoFragment && in.size%uint64(s.sb.BlockSize) != 0 {
+			count++
+		}
+		// every block has size in inode table, even sparse one
+		if count > s.sb.BytesUsed {
+			return nil, fmt.Errorf("%w: file size 0x%x", ErrCorrupted, in.size)
+		}
+		in.sizes = make([]uint32, count)
+		err = binary.Read(m, le, in.sizes)
+	}
+	return in, err
+}
+
+type sqEntry struct {
+	name string
+	ref  uint64
+}
+
+func (s *squashfs) entries(in *sqInode) ([]sqEntry, error) {
+	// size of listing includes 3 bytes for "." and ".."
+	if in.size <= 3 {
+		return nil, nil
+	}
+	m, err := s.meta(int64(s.sb.DirTableStart)+int64(in.dirBlock), int(in.dirOffset))
+	if err != nil {
+		return nil, err
+	}
+	r := io.LimitReader(m, int64(in.size-3))
+	le := binary.LittleEndian
+	var entries []sqEntry
+	for {
+		var h struct {
+			Count  uint32
+			Start  uint32
+			Number uint32
+		}
+		if err := binary.Read(r, le, &h); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i <= h.Count; i++ {
+			var e struct {
+				Offset      uint16
+				InodeOffset int16
+				Type        uint16
+				NameSize    uint16
+			}
+			if err := binary.Read(r, le, &e); err != nil {
+				return nil, err
+			}
+			name := make([]byte, int(e.NameSize)+1)
+			if _, err := io.ReadFull(r, name); err != nil {
+				return nil, err
+			}
+			entries = append(entries, sqEntry{string(name), uint64(h.Start)<<16 | uint64(e.Offset)})
+		}
+	}
+}
+
+func (s *squashfs) extractDir(ctx context.Context, in *sqInode, dir string, depth int) error {
+	if depth > sqMaxDepth {
+		return fmt.Errorf("%w: too deep directories", ErrCorrupted)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	entries, err := s.entries(in)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if !safeName(e.name) {
+			return fmt.Errorf("%w: name '%s'", ErrCorrupted, e.name)
+		}
+		child, err := s.inode(e.ref)
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, e.name)
+		switch child.Type {
+		case sqDir, sqExtDir:
+			err = s.extractDir(ctx, child, name, depth+1)
+		case sqFile, sqExtFile:
+			err = s.extractFile(child, name)
+		case sqSymlink, sqExtSymlink:
+			s.links.add(child.target, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *squashfs) extractFile(in *sqInode, name string) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(s.copyFile(in, f), f.Close())
+}
+
+func (s *squashfs) copyFile(in *sqInode, w io.Writer) error {
+	pos := int64(in.blocks)
+	left := in.size
+	for _, size := range in.sizes {
+		n := min(left, uint64(s.sb.BlockSize))
+		if size == 0 {
+			// sparse block
+			if _, err := w.Write(make([]byte, n)); err != nil {
+				return err
+			}
+			left -= n
+			continue
+		}
+		b, err := s.block(pos, size)
+		if err != nil {
+			return err
+		}
+		if uint64(len(b)) < n {
+			return fmt.Errorf("%w: short data block at 0x%x", ErrCorrupted, pos)
+		}
+		if _, err := w.Write(b[:n]); err != nil {
+			return err
+		}
+		pos += int64(size &^ sqDataUncompressed)
+		left -= n
+	}
+	if in.fragment == sqNoFragment || left == 0 {
+		return nil
+	}
+	if int(in.fragment) >= len(s.frags) {
+		return fmt.Errorf("%w: fragment %d", ErrCorrupted, in.fragment)
+	}
+	frag := s.frags[in.fragment]
+	b, err := s.block(int64(frag.Start), frag.Size)
+	if err != nil {
+		return err
+	}
+	if uint64(len(b)) < uint64(in.fragOff)+left {
+		return fmt.Errorf("%w: short fragment %d", ErrCorrupted, in.fragment)
+	}
+	_, err = w.Write(b[in.fragOff : uint64(in.fragOff)+left])
+	return err
+}
+
+func (s *squashfs) block(pos int64, size uint32) ([]byte, error) {
+	b, err := readAt(s.r, pos, int(size&^sqDataUncompressed))
+	if err != nil || size&sqDataUncompressed != 0 {
+		return b, err
+	}
+	return inflate(b)
+}
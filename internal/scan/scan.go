@@ -0,0 +1,252 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const (
+	chunkSize = 1 << 20
+	// bytes after magic, which are available for parsing of header
+	lookahead = 0x1000
+)
+
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Hit struct {
+	Offset int64   `json:"offset"`
+	Size   int64   `json:"size,omitempty"`
+	Name   string  `json:"name"`
+	Fields []Field `json:"fields,omitempty"`
+}
+
+func (h Hit) String() string {
+	s := fmt.Sprintf("0x%08x %-12s", h.Offset, h.Name)
+	if h.Size > 0 {
+		s += fmt.Sprintf(" size=0x%x", h.Size)
+	}
+	for _, f := range h.Fields {
+		s += fmt.Sprintf(" %s=%s", f.Name, f.Value)
+	}
+	return s
+}
+
+// Signature describes a header, which can be found in a dump.
+type Signature struct {
+	Name string
+	// Ext is used for names of carved files
+	Ext   string
+	Magic []byte
+	// MagicOffset is an offset of magic from beginning of header
+	MagicOffset int
+	// Chain merges hits, which follow each other, like nodes of JFFS2 or
+	// entries of archives.
+	Chain bool
+	// Parse validates header at beginning of b and decodes it,
+	// size is 0 when it's unknown.
+	Parse func(b []byte) (size int64, fields []Field, ok bool)
+}
+
+// Register adds signature to the default table.
+func Register(sig Signature) {
+	Signatures = append(Signatures, sig)
+}
+
+type Scanner struct {
+	input      io.ReaderAt
+	size       int64
+	closer     io.Closer
+	out        io.Writer
+	Signatures []Signature
+	Config     config.Scan
+}
+
+func New(cfg config.Scan) *Scanner {
+	return &Scanner{
+		Config:     cfg,
+		Signatures: Signatures,
+		out:        os.Stdout,
+	}
+}
+
+func (s *Scanner) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for scanning: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for scanning: %w", input, err)
+	}
+	s.input = in
+	s.size = stat.Size()
+	s.closer = in
+	if s.Config.Carve && s.Config.Output == "" {
+		name := filepath.Base(input)
+		s.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-carved"
+	}
+	return nil
+}
+
+func (s *Scanner) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func (s *Scanner) Run(ctx context.Context) error {
+	hits, err := Scan(ctx, s.input, s.size, s.Signatures)
+	if err != nil {
+		return err
+	}
+	if s.Config.JSON {
+		enc := json.NewEncoder(s.out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(hits); err != nil {
+			return err
+		}
+	} else {
+		for _, h := range hits {
+			fmt.Fprintln(s.out, h)
+		}
+	}
+	if !s.Config.Carve {
+		return nil
+	}
+	return s.carve(hits)
+}
+
+// Scan finds all signatures in r and returns hits ordered by offset.
+func Scan(ctx context.Context, r io.ReaderAt, size int64, sigs []Signature) ([]Hit, error) {
+	var hits []Hit
+	maxOffset := 0
+	for _, sig := range sigs {
+		maxOffset = max(maxOffset, sig.MagicOffset)
+	}
+	// beginning of chunk includes bytes before magic
+	buf := make([]byte, maxOffset+chunkSize+lookahead)
+	for off := int64(0); off < size; off += chunkSize {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		start := max(0, off-int64(maxOffset))
+		n, err := r.ReadAt(buf, start)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		b := buf[:n]
+		// position of off in buffer
+		base := int(off - start)
+		for _, sig := range sigs {
+			found := b[base:min(n, base+chunkSize+sig.MagicOffset)]
+			for i := 0; ; i++ {
+				j := bytes.Index(found[i:], sig.Magic)
+				if j < 0 {
+					break
+				}
+				i += j
+				// headers, which start in other chunks, are found there
+				hdr := i - sig.MagicOffset
+				if hdr < 0 || hdr >= chunkSize {
+					continue
+				}
+				pos := base + hdr
+				header := b[pos:min(n, pos+sig.MagicOffset+lookahead)]
+				hsize, fields, ok := sig.Parse(header)
+				if !ok {
+					continue
+				}
+				hits = append(hits, Hit{
+					Offset: start + int64(pos),
+					Size:   hsize,
+					Name:   sig.Name,
+					Fields: fields,
+				})
+			}
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Offset < hits[j].Offset
+	})
+	return chain(hits, sigs), nil
+}
+
+// chain merges sequences of hits of signatures with Chain flag
+func chain(hits []Hit, sigs []Signature) []Hit {
+	chained := map[string]bool{}
+	for _, sig := range sigs {
+		chained[sig.Name] = sig.Chain
+	}
+	res := make([]Hit, 0, len(hits))
+	count := 1
+	for _, h := range hits {
+		if len(res) > 0 {
+			last := &res[len(res)-1]
+			end := last.Offset + last.Size
+			// nodes and entries are aligned
+			if chained[h.Name] && last.Name == h.Name && last.Size > 0 && h.Offset >= end && h.Offset <= (end+3)&^3 {
+				last.Size = h.Offset + h.Size - last.Offset
+				count++
+				continue
+			}
+			if count > 1 {
+				last.Fields = append(last.Fields, Field{"entries", fmt.Sprint(count)})
+			}
+		}
+		count = 1
+		res = append(res, h)
+	}
+	if count > 1 {
+		last := &res[len(res)-1]
+		last.Fields = append(last.Fields, Field{"entries", fmt.Sprint(count)})
+	}
+	return res
+}
+
+// carve writes every hit in own file, hits with unknown size end on next hit.
+func (s *Scanner) carve(hits []Hit) error {
+	if err := os.MkdirAll(s.Config.Output, 0755); err != nil {
+		return err
+	}
+	exts := map[string]string{}
+	for _, sig := range s.Signatures {
+		exts[sig.Name] = sig.Ext
+	}
+	for i, h := range hits {
+		size := h.Size
+		if size == 0 {
+			size = s.size - h.Offset
+			if i+1 < len(hits) {
+				size = hits[i+1].Offset - h.Offset
+			}
+		}
+		size = min(size, s.size-h.Offset)
+		name := fmt.Sprintf("%08x-%s.%s", h.Offset, strings.ReplaceAll(h.Name, "/", "-"), exts[h.Name])
+		f, err := os.OpenFile(filepath.Join(s.Config.Output, name), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, io.NewSectionReader(s.input, h.Offset, size))
+		if err = errors.Join(err, f.Close()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
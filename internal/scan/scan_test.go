@@ -0,0 +1,180 @@
+package scan
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func uImage(name string, data []byte) []byte {
+	hdr := make([]byte, 64)
+	be := binary.BigEndian
+	be.PutUint32(hdr[0:4], 0x27051956)
+	be.PutUint32(hdr[12:16], uint32(len(data)))
+	be.PutUint32(hdr[16:20], 0x80008000)
+	be.PutUint32(hdr[20:24], 0x80008000)
+	be.PutUint32(hdr[24:28], crc32.ChecksumIEEE(data))
+	hdr[28], hdr[29], hdr[30], hdr[31] = 5, 2, 2, 0
+	copy(hdr[32:], name)
+	be.PutUint32(hdr[4:8], crc32.ChecksumIEEE(hdr))
+	return append(hdr, data...)
+}
+
+func gzipped(name string, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	zw.Name = name
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func tarball() []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, name := range []string{"a", "b"} {
+		tw.WriteHeader(&tar.Header{Name: name, Size: 3, Mode: 0644, Format: tar.FormatUSTAR})
+		tw.Write([]byte("abc"))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func jffs2Node(typ uint16, size int) []byte {
+	b := make([]byte, size)
+	binary.LittleEndian.PutUint16(b[0:2], 0x1985)
+	binary.LittleEndian.PutUint16(b[2:4], typ)
+	binary.LittleEndian.PutUint32(b[4:8], uint32(size))
+	binary.LittleEndian.PutUint32(b[8:12], crc32Raw(0, b[:8]))
+	return b
+}
+
+func fields(h Hit) map[string]string {
+	m := map[string]string{}
+	for _, f := range h.Fields {
+		m[f.Name] = f.Value
+	}
+	return m
+}
+
+func TestScan(t *testing.T) {
+	img := make([]byte, 2*chunkSize)
+	kernel := uImage("Linux-6.1", bytes.Repeat([]byte{0xAA}, 100))
+	copy(img[0x100:], kernel)
+	copy(img[0x1000:], gzipped("rootfs.cpio", []byte("hello")))
+	copy(img[chunkSize-100:], tarball())
+	copy(img[0x20000:], jffs2Node(0x2003, 12))
+	copy(img[0x2000c:], jffs2Node(0xE002, 70))
+	copy(img[0x20054:], jffs2Node(0xE001, 40))
+	copy(img[0x30000:], "-----BEGIN CERTIFICATE-----\nMII=\n-----END CERTIFICATE-----\n")
+	// broken header
+	bad := uImage("bad", []byte{1})
+	bad[40] = 'X'
+	copy(img[0x40000:], bad)
+
+	hits, err := Scan(context.TODO(), bytes.NewReader(img), int64(len(img)), Signatures)
+	require.NoError(t, err)
+	names := []string{}
+	for _, h := range hits {
+		names = append(names, h.Name)
+	}
+	require.Equal(t, []string{"uImage", "gzip", "JFFS2", "PEM", "TAR"}, names)
+
+	require.Equal(t, int64(0x100), hits[0].Offset)
+	require.Equal(t, int64(len(kernel)), hits[0].Size)
+	require.Equal(t, `"Linux-6.1"`, fields(hits[0])["name"])
+	require.Equal(t, "0x80008000", fields(hits[0])["load"])
+
+	require.Equal(t, `"rootfs.cpio"`, fields(hits[1])["name"])
+
+	require.Equal(t, int64(0x20000), hits[2].Offset)
+	require.Equal(t, int64(12+72+40), hits[2].Size)
+	require.Equal(t, "3", fields(hits[2])["entries"])
+
+	require.Equal(t, `"CERTIFICATE"`, fields(hits[3])["type"])
+
+	require.Equal(t, int64(chunkSize-100), hits[4].Offset)
+	require.Equal(t, int64(2*1024), hits[4].Size)
+}
+
+func TestParseBzip2(t *testing.T) {
+	block := "\x31\x41\x59\x26\x53\x59"
+	tests := []struct {
+		name  string
+		data  string
+		block string
+		ok    bool
+	}{
+		{"Level 1", "BZh1" + block, "100k", true},
+		{"Level 3", "BZh3" + block, "300k", true},
+		{"Level 9", "BZh9" + block, "900k", true},
+		{"Level 0", "BZh0" + block, "", false},
+		{"Block magic", "BZh9" + "\x17\x72\x45\x38\x50\x90", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fields, ok := parseBzip2([]byte(tt.data))
+			require.Equal(t, tt.ok, ok)
+			if tt.ok {
+				require.Equal(t, []Field{{"block", tt.block}}, fields)
+			}
+		})
+	}
+}
+
+func TestScanner_Run(t *testing.T) {
+	img := append(make([]byte, 0x10), uImage("kernel", []byte("payload"))...)
+	img = append(img, gzipped("", []byte("data"))...)
+	tests := []struct {
+		name  string
+		cfg   config.Scan
+		check func(t *testing.T, out string, dir string)
+	}{
+		{
+			"Text",
+			config.Scan{},
+			func(t *testing.T, out string, dir string) {
+				require.Contains(t, out, "0x00000010 uImage       size=0x47 name=\"kernel\"")
+				require.Contains(t, out, "0x00000057 gzip")
+			},
+		},
+		{
+			"JSON and carve",
+			config.Scan{JSON: true, Carve: true},
+			func(t *testing.T, out string, dir string) {
+				var hits []Hit
+				require.NoError(t, json.Unmarshal([]byte(out), &hits))
+				require.Len(t, hits, 2)
+				data, err := os.ReadFile(filepath.Join(dir, "00000010-uImage.uimg"))
+				require.NoError(t, err)
+				require.Equal(t, img[0x10:0x57], data)
+				data, err = os.ReadFile(filepath.Join(dir, "00000057-gzip.gz"))
+				require.NoError(t, err)
+				require.Equal(t, img[0x57:], data)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.cfg)
+			s.Config.Output = t.TempDir()
+			s.input = bytes.NewReader(img)
+			s.size = int64(len(img))
+			out := &bytes.Buffer{}
+			s.out = out
+			require.NoError(t, s.Run(context.TODO()))
+			tt.check(t, out.String(), s.Config.Output)
+		})
+	}
+}
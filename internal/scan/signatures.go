@@ -0,0 +1,416 @@
+package scan
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nexadis/fw-tools/internal/rootfs"
+	"github.com/Nexadis/fw-tools/internal/uimage"
+)
+
+// Signatures is a default table of known headers.
+var Signatures = []Signature{
+	{Name: "uImage", Ext: "uimg", Magic: []byte{0x27, 0x05, 0x19, 0x56}, Parse: parseUImage},
+	{Name: "DTB/FIT", Ext: "dtb", Magic: []byte{0xd0, 0x0d, 0xfe, 0xed}, Parse: parseDTB},
+	{Name: "ELF", Ext: "elf", Magic: []byte("\x7fELF"), Parse: parseELF},
+	{Name: "gzip", Ext: "gz", Magic: []byte{0x1f, 0x8b, 0x08}, Parse: parseGzip},
+	{Name: "zlib", Ext: "zlib", Magic: []byte{0x78, 0x01}, Parse: parseZlib},
+	{Name: "zlib", Ext: "zlib", Magic: []byte{0x78, 0x5e}, Parse: parseZlib},
+	{Name: "zlib", Ext: "zlib", Magic: []byte{0x78, 0x9c}, Parse: parseZlib},
+	{Name: "zlib", Ext: "zlib", Magic: []byte{0x78, 0xda}, Parse: parseZlib},
+	{Name: "LZMA", Ext: "lzma", Magic: []byte{0x5d, 0x00, 0x00}, Parse: parseLZMA},
+	{Name: "xz", Ext: "xz", Magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, Parse: parseXZ},
+	{Name: "bzip2", Ext: "bz2", Magic: []byte("BZh"), Parse: parseBzip2},
+	{Name: "LZ4", Ext: "lz4", Magic: []byte{0x04, 0x22, 0x4d, 0x18}, Parse: parseLZ4},
+	{Name: "zstd", Ext: "zst", Magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, Parse: parseZstd},
+	{Name: "SquashFS", Ext: "sqfs", Magic: []byte("hsqs"), Parse: parseRootFS},
+	{Name: "SquashFS", Ext: "sqfs", Magic: []byte("sqsh"), Parse: parseRootFS},
+	{Name: "UBI", Ext: "ubi", Magic: []byte("UBI#"), Chain: true, Parse: parseUBI},
+	{Name: "JFFS2", Ext: "jffs2", Magic: []byte{0x85, 0x19}, Chain: true, Parse: parseJFFS2(binary.LittleEndian)},
+	{Name: "JFFS2", Ext: "jffs2", Magic: []byte{0x19, 0x85}, Chain: true, Parse: parseJFFS2(binary.BigEndian)},
+	{Name: "CPIO", Ext: "cpio", Magic: []byte("070701"), Chain: true, Parse: parseCPIO},
+	{Name: "CPIO", Ext: "cpio", Magic: []byte("070702"), Chain: true, Parse: parseCPIO},
+	{Name: "TAR", Ext: "tar", Magic: []byte("ustar"), MagicOffset: 257, Chain: true, Parse: parseTAR},
+	{Name: "PEM", Ext: "pem", Magic: []byte("-----BEGIN "), Parse: parsePEM},
+	{Name: "DER", Ext: "der", Magic: []byte{0x30, 0x82}, Parse: parseDER},
+	{Name: "Android boot", Ext: "img", Magic: []byte("ANDROID!"), Parse: parseAndroid},
+	{Name: "U-Boot", Ext: "bin", Magic: []byte("U-Boot 20"), Parse: parseString},
+	{Name: "Barebox", Ext: "bin", Magic: []byte("barebox"), MagicOffset: 0x20, Parse: parseBarebox},
+	{Name: "ARM TF FIP", Ext: "fip", Magic: []byte{0x01, 0x00, 0x64, 0xaa}, Parse: parseFIP},
+	{Name: "Intel IFD", Ext: "ifd", Magic: []byte{0x5a, 0xa5, 0xf0, 0x0f}, MagicOffset: 0x10, Parse: parseIFD},
+}
+
+func hex(v uint64) string {
+	return "0x" + strconv.FormatUint(v, 16)
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strconv.Quote(string(b))
+}
+
+// raw CRC32 without inversions, like crc32_le in linux
+func crc32Raw(seed uint32, b []byte) uint32 {
+	return ^crc32.Update(^seed, crc32.IEEETable, b)
+}
+
+func parseUImage(b []byte) (int64, []Field, bool) {
+	h, err := uimage.Parse(b)
+	if err != nil {
+		return 0, nil, false
+	}
+	return uimage.HeaderSize + int64(h.Size), []Field{
+		{"name", strconv.Quote(h.ImageName())},
+		{"load", hex(uint64(h.Load))},
+		{"entry", hex(uint64(h.Entry))},
+		{"os", uimage.OSName(h.OS)},
+		{"arch", uimage.ArchName(h.Arch)},
+		{"type", uimage.TypeName(h.Type)},
+		{"comp", uimage.CompName(h.Comp)},
+		{"time", time.Unix(int64(h.Time), 0).UTC().Format(time.DateTime)},
+	}, true
+}
+
+func parseDTB(b []byte) (int64, []Field, bool) {
+	if len(b) < 40 {
+		return 0, nil, false
+	}
+	be := binary.BigEndian
+	size := be.Uint32(b[4:8])
+	version := be.Uint32(b[20:24])
+	last := be.Uint32(b[24:28])
+	structOff := be.Uint32(b[8:12])
+	stringsOff := be.Uint32(b[12:16])
+	if version < 16 || version > 17 || last > version || structOff >= size || stringsOff >= size {
+		return 0, nil, false
+	}
+	return int64(size), []Field{
+		{"version", fmt.Sprint(version)},
+		{"boot_cpu", fmt.Sprint(be.Uint32(b[28:32]))},
+	}, true
+}
+
+var elfMachines = map[uint16]string{
+	0x03: "x86", 0x08: "MIPS", 0x14: "PowerPC", 0x28: "ARM", 0x2a: "SuperH",
+	0x3e: "x86-64", 0x5e: "Xtensa", 0xb7: "AArch64", 0xf3: "RISC-V",
+}
+
+func parseELF(b []byte) (int64, []Field, bool) {
+	if len(b) < 52 {
+		return 0, nil, false
+	}
+	class, data := b[4], b[5]
+	if class < 1 || class > 2 || data < 1 || data > 2 || b[6] != 1 {
+		return 0, nil, false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data == 2 {
+		order = binary.BigEndian
+	}
+	machine := order.Uint16(b[18:20])
+	var entry, shoff uint64
+	var shentsize, shnum uint16
+	if class == 1 {
+		entry = uint64(order.Uint32(b[24:28]))
+		shoff = uint64(order.Uint32(b[32:36]))
+		shentsize, shnum = order.Uint16(b[46:48]), order.Uint16(b[48:50])
+	} else {
+		if len(b) < 64 {
+			return 0, nil, false
+		}
+		entry = order.Uint64(b[24:32])
+		shoff = order.Uint64(b[40:48])
+		shentsize, shnum = order.Uint16(b[58:60]), order.Uint16(b[60:62])
+	}
+	name, ok := elfMachines[machine]
+	if !ok {
+		name = hex(uint64(machine))
+	}
+	var size int64
+	if shoff != 0 {
+		size = int64(shoff) + int64(shentsize)*int64(shnum)
+	}
+	return size, []Field{
+		{"class", fmt.Sprint(32 * int(class))},
+		{"endian", map[byte]string{1: "little", 2: "big"}[data]},
+		{"machine", name},
+		{"type", fmt.Sprint(order.Uint16(b[16:18]))},
+		{"entry", hex(entry)},
+	}, true
+}
+
+func parseGzip(b []byte) (int64, []Field, bool) {
+	if len(b) < 10 || b[3]&0xE0 != 0 {
+		return 0, nil, false
+	}
+	fields := []Field{
+		{"mtime", fmt.Sprint(binary.LittleEndian.Uint32(b[4:8]))},
+		{"os", fmt.Sprint(b[9])},
+	}
+	pos := 10
+	if b[3]&0x04 != 0 && len(b) > pos+2 {
+		// extra field
+		pos += 2 + int(binary.LittleEndian.Uint16(b[pos:]))
+	}
+	if b[3]&0x08 != 0 && pos < len(b) {
+		fields = append(fields, Field{"name", cString(b[pos:])})
+	}
+	return 0, fields, true
+}
+
+// inflates beginning of stream to drop false positives of short magics
+func inflates(b []byte) bool {
+	fr := flate.NewReader(bytes.NewReader(b))
+	defer fr.Close()
+	n, err := io.CopyN(io.Discard, fr, 64)
+	return n > 0 && (err == nil || err == io.EOF || err == io.ErrUnexpectedEOF)
+}
+
+func parseZlib(b []byte) (int64, []Field, bool) {
+	if len(b) < 4 || (uint16(b[0])<<8|uint16(b[1]))%31 != 0 || !inflates(b[2:]) {
+		return 0, nil, false
+	}
+	levels := []string{"fastest", "fast", "default", "best"}
+	return 0, []Field{{"level", levels[b[1]>>6]}}, true
+}
+
+func parseLZMA(b []byte) (int64, []Field, bool) {
+	if len(b) < 13 {
+		return 0, nil, false
+	}
+	dict := binary.LittleEndian.Uint32(b[1:5])
+	size := binary.LittleEndian.Uint64(b[5:13])
+	// dictionary sizes are 2^n or 2^n+2^(n-1)
+	pow2 := func(v uint32) bool { return v != 0 && v&(v-1) == 0 }
+	if dict < 1<<12 || dict > 3<<29 || !(pow2(dict) || dict%3 == 0 && pow2(dict/3)) {
+		return 0, nil, false
+	}
+	if size != ^uint64(0) && size > 1<<34 {
+		return 0, nil, false
+	}
+	fields := []Field{{"dict", hex(uint64(dict))}}
+	if size != ^uint64(0) {
+		fields = append(fields, Field{"uncompressed", hex(size)})
+	}
+	return 0, fields, true
+}
+
+var xzChecks = map[byte]string{0: "none", 1: "crc32", 4: "crc64", 10: "sha256"}
+
+func parseXZ(b []byte) (int64, []Field, bool) {
+	if len(b) < 12 || b[6] != 0 || crc32.ChecksumIEEE(b[6:8]) != binary.LittleEndian.Uint32(b[8:12]) {
+		return 0, nil, false
+	}
+	check, ok := xzChecks[b[7]&0x0F]
+	if !ok {
+		check = fmt.Sprint(b[7] & 0x0F)
+	}
+	return 0, []Field{{"check", check}}, true
+}
+
+func parseBzip2(b []byte) (int64, []Field, bool) {
+	// level and magic of the first block, pi in BCD
+	if len(b) < 10 || b[3] < '1' || b[3] > '9' || !bytes.Equal(b[4:10], []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) {
+		return 0, nil, false
+	}
+	return 0, []Field{{"block", fmt.Sprintf("%dk", int(b[3]-'0')*100)}}, true
+}
+
+func parseLZ4(b []byte) (int64, []Field, bool) {
+	// version 01 and reserved bits of frame descriptor
+	if len(b) < 7 || b[4]>>6 != 1 || b[4]&0x02 != 0 || b[5]&0x8f != 0 || b[5]>>4 < 4 {
+		return 0, nil, false
+	}
+	fields := []Field{{"block", hex(1 << (8 + 2*uint64(b[5]>>4)))}}
+	if b[4]&0x08 != 0 && len(b) >= 14 {
+		fields = append(fields, Field{"uncompressed", hex(binary.LittleEndian.Uint64(b[6:14]))})
+	}
+	return 0, fields, true
+}
+
+func parseZstd(b []byte) (int64, []Field, bool) {
+	// reserved bit of frame header descriptor
+	if len(b) < 6 || b[4]&0x08 != 0 {
+		return 0, nil, false
+	}
+	return 0, nil, true
+}
+
+func parseRootFS(b []byte) (int64, []Field, bool) {
+	sb, ok := rootfs.Probe(b)
+	if !ok {
+		return 0, nil, false
+	}
+	order := "little"
+	if sb.BigEndian {
+		order = "big"
+	}
+	return sb.Size, []Field{
+		{"version", sb.Version},
+		{"endian", order},
+		{"compressor", sb.Compressor},
+	}, true
+}
+
+func parseUBI(b []byte) (int64, []Field, bool) {
+	if len(b) < 64 || crc32Raw(0xFFFFFFFF, b[:60]) != binary.BigEndian.Uint32(b[60:64]) {
+		return 0, nil, false
+	}
+	be := binary.BigEndian
+	fields := []Field{
+		{"version", fmt.Sprint(b[4])},
+		{"ec", fmt.Sprint(be.Uint64(b[8:16]))},
+		{"vid_offset", hex(uint64(be.Uint32(b[16:20])))},
+		{"data_offset", hex(uint64(be.Uint32(b[20:24])))},
+		{"image_seq", hex(uint64(be.Uint32(b[24:28])))},
+	}
+	// size of eraseblock is unknown from EC header, it's found by chaining
+	return 0, fields, true
+}
+
+var jffs2Nodes = map[uint16]string{
+	0xE001: "dirent", 0xE002: "inode", 0x2003: "cleanmarker",
+	0x2004: "padding", 0xE006: "summary", 0xE008: "xattr", 0xE009: "xref",
+}
+
+func parseJFFS2(order binary.ByteOrder) func(b []byte) (int64, []Field, bool) {
+	return func(b []byte) (int64, []Field, bool) {
+		if len(b) < 12 {
+			return 0, nil, false
+		}
+		node, ok := jffs2Nodes[order.Uint16(b[2:4])]
+		if !ok || crc32Raw(0, b[:8]) != order.Uint32(b[8:12]) {
+			return 0, nil, false
+		}
+		endian := "little"
+		if order == binary.BigEndian {
+			endian = "big"
+		}
+		return int64(order.Uint32(b[4:8])), []Field{{"endian", endian}, {"node", node}}, true
+	}
+}
+
+func parseCPIO(b []byte) (int64, []Field, bool) {
+	if len(b) < 110 {
+		return 0, nil, false
+	}
+	field := func(i int) (int64, bool) {
+		v, err := strconv.ParseUint(string(b[6+i*8:14+i*8]), 16, 32)
+		return int64(v), err == nil
+	}
+	fileSize, ok1 := field(6)
+	nameSize, ok2 := field(11)
+	if !ok1 || !ok2 || nameSize == 0 || 110+nameSize > int64(len(b)) {
+		return 0, nil, false
+	}
+	align := func(v int64) int64 { return (v + 3) &^ 3 }
+	return align(110+nameSize) + align(fileSize), []Field{
+		{"name", cString(b[110 : 110+nameSize])},
+	}, true
+}
+
+func parseTAR(b []byte) (int64, []Field, bool) {
+	if len(b) < 512 {
+		return 0, nil, false
+	}
+	octal := func(f []byte) (int64, bool) {
+		s := strings.Trim(string(f), " \x00")
+		v, err := strconv.ParseInt(s, 8, 64)
+		return v, err == nil
+	}
+	sum, ok := octal(b[148:156])
+	if !ok {
+		return 0, nil, false
+	}
+	var calc int64
+	for i, c := range b[:512] {
+		if i >= 148 && i < 156 {
+			c = ' '
+		}
+		calc += int64(c)
+	}
+	size, ok := octal(b[124:136])
+	if !ok || calc != sum {
+		return 0, nil, false
+	}
+	return 512 + (size+511)&^511, []Field{{"name", cString(b[:100])}}, true
+}
+
+func parsePEM(b []byte) (int64, []Field, bool) {
+	line, _, ok := bytes.Cut(b[len("-----BEGIN "):], []byte("-----"))
+	if !ok || len(line) == 0 || len(line) > 64 {
+		return 0, nil, false
+	}
+	end := []byte("-----END " + string(line) + "-----")
+	i := bytes.Index(b, end)
+	var size int64
+	if i > 0 {
+		size = int64(i + len(end))
+	}
+	return size, []Field{{"type", strconv.Quote(string(line))}}, true
+}
+
+func parseDER(b []byte) (int64, []Field, bool) {
+	// certificate is SEQUENCE of tbsCertificate SEQUENCE with long lengths
+	if len(b) < 8 || b[4] != 0x30 || b[5] != 0x82 {
+		return 0, nil, false
+	}
+	size := int64(binary.BigEndian.Uint16(b[2:4]))
+	inner := int64(binary.BigEndian.Uint16(b[6:8]))
+	if inner+4 > size || size < 0x100 {
+		return 0, nil, false
+	}
+	return size + 4, []Field{{"type", "certificate"}}, true
+}
+
+func parseAndroid(b []byte) (int64, []Field, bool) {
+	if len(b) < 48 {
+		return 0, nil, false
+	}
+	le := binary.LittleEndian
+	return 0, []Field{
+		{"kernel_size", hex(uint64(le.Uint32(b[8:12])))},
+		{"ramdisk_size", hex(uint64(le.Uint32(b[16:20])))},
+		{"header_version", fmt.Sprint(le.Uint32(b[40:44]))},
+	}, true
+}
+
+func parseString(b []byte) (int64, []Field, bool) {
+	end := bytes.IndexAny(b, "\x00\n")
+	if end < 0 || end > 128 {
+		return 0, nil, false
+	}
+	return 0, []Field{{"version", strconv.Quote(string(b[:end]))}}, true
+}
+
+func parseBarebox(b []byte) (int64, []Field, bool) {
+	// ARM barebox image starts with vector table, image size is after magic
+	if len(b) < 0x30 {
+		return 0, nil, false
+	}
+	return 0, nil, true
+}
+
+func parseFIP(b []byte) (int64, []Field, bool) {
+	if len(b) < 16 || binary.LittleEndian.Uint32(b[4:8]) != 0x12345678 {
+		return 0, nil, false
+	}
+	return 0, nil, true
+}
+
+func parseIFD(b []byte) (int64, []Field, bool) {
+	if len(b) < 0x20 {
+		return 0, nil, false
+	}
+	regions := (binary.LittleEndian.Uint32(b[0x14:0x18]) >> 24) & 0x7
+	return 0, []Field{{"regions", fmt.Sprint(regions + 1)}}, true
+}
@@ -0,0 +1,229 @@
+package sfdp
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// minimal length of basic flash parameter table of JESD216 in dwords
+const bfptMinLength = 9
+
+type EraseType struct {
+	Size    int64         `json:"size"`
+	Opcode  byte          `json:"opcode"`
+	Typical time.Duration `json:"typical_ns,omitempty"`
+	Max     time.Duration `json:"max_ns,omitempty"`
+}
+
+type FastRead struct {
+	Mode   string `json:"mode"`
+	Opcode byte   `json:"opcode"`
+	Dummy  int    `json:"dummy"`
+	Clocks int    `json:"mode_clocks"`
+}
+
+type Timing struct {
+	Typical time.Duration `json:"typical_ns"`
+	Max     time.Duration `json:"max_ns"`
+}
+
+// BFPT is a decoded JEDEC basic flash parameter table.
+type BFPT struct {
+	Density       int64       `json:"density"`
+	AddressBytes  string      `json:"address_bytes"`
+	Erase4K       bool        `json:"erase_4k"`
+	Erase4KOpcode byte        `json:"erase_4k_opcode,omitempty"`
+	WriteBuffer   bool        `json:"write_buffer"`
+	DTR           bool        `json:"dtr"`
+	FastReads     []FastRead  `json:"fast_reads,omitempty"`
+	EraseTypes    []EraseType `json:"erase_types,omitempty"`
+	PageSize      int         `json:"page_size,omitempty"`
+	PageProgram   *Timing     `json:"page_program,omitempty"`
+	ByteProgram   *Timing     `json:"byte_program,omitempty"`
+	ChipErase     *Timing     `json:"chip_erase,omitempty"`
+	QuadEnable    string      `json:"quad_enable,omitempty"`
+	Enter4Byte    []string    `json:"enter_4byte,omitempty"`
+	Exit4Byte     []string    `json:"exit_4byte,omitempty"`
+}
+
+var addressBytes = []string{"3", "3 or 4", "4", "reserved"}
+
+// quad enable requirements of DWORD 15
+var quadEnable = []string{
+	"no QE bit",
+	"bit 1 of status register 2, write 2 bytes by 01h, writing 1 byte clears status register 2",
+	"bit 6 of status register 1, write 1 byte by 01h",
+	"bit 7 of status register 2, write by 3Eh, read by 3Fh",
+	"bit 1 of status register 2, write 2 bytes by 01h",
+	"bit 1 of status register 2, read by 35h, write 2 bytes by 01h",
+	"bit 1 of status register 2, read by 35h, write 1 byte by 31h",
+	"reserved",
+}
+
+var enter4Byte = []string{
+	"B7h",
+	"06h then B7h",
+	"extended address register C5h/C8h",
+	"bit 7 of bank register 17h/16h",
+	"nonvolatile configuration register B1h/B5h",
+	"dedicated 4-byte instructions",
+	"always 4-byte",
+}
+
+var exit4Byte = []string{
+	"E9h",
+	"06h then E9h",
+	"extended address register C5h/C8h",
+	"bit 7 of bank register 17h/16h",
+	"hardware reset",
+	"software reset",
+	"power cycle",
+	"reserved",
+}
+
+// units of typical times
+var (
+	eraseUnits     = []time.Duration{time.Millisecond, 16 * time.Millisecond, 128 * time.Millisecond, time.Second}
+	chipEraseUnits = []time.Duration{16 * time.Millisecond, 256 * time.Millisecond, 4 * time.Second, 64 * time.Second}
+)
+
+func bits(v uint32, lo, n int) uint32 {
+	return v >> lo & (1<<n - 1)
+}
+
+func flags(v uint32, lo int, names []string) []string {
+	var s []string
+	for i, name := range names {
+		if v>>(lo+i)&1 != 0 {
+			s = append(s, name)
+		}
+	}
+	return s
+}
+
+// timing decodes typical time of count and unit, maximal time is typical multiplied by 2*(mult+1)
+func timing(count uint32, unit time.Duration, mult uint32) *Timing {
+	t := time.Duration(count+1) * unit
+	return &Timing{t, t * time.Duration(2*(mult+1))}
+}
+
+// parseBFPT decodes table of dwords, the first 9 dwords are required,
+// the next ones are decoded, if they are in table.
+func parseBFPT(dw []uint32) (*BFPT, error) {
+	if len(dw) < bfptMinLength {
+		return nil, fmt.Errorf("%w: basic flash parameter table has %d dwords", ErrSFDP, len(dw))
+	}
+	b := &BFPT{
+		AddressBytes: addressBytes[bits(dw[0], 17, 2)],
+		Erase4K:      bits(dw[0], 0, 2) == 1,
+		WriteBuffer:  bits(dw[0], 2, 1) == 1,
+		DTR:          bits(dw[0], 19, 1) == 1,
+	}
+	if b.Erase4K {
+		b.Erase4KOpcode = byte(bits(dw[0], 8, 8))
+	}
+	if dw[1]&(1<<31) == 0 {
+		b.Density = (int64(dw[1]) + 1) / 8
+	} else {
+		n := dw[1] &^ (1 << 31)
+		if n < 3 || n > 62 {
+			return nil, fmt.Errorf("%w: density 2^%d bits", ErrSFDP, n)
+		}
+		b.Density = 1 << (n - 3)
+	}
+	fastRead := func(supported bool, mode string, v uint32) {
+		if !supported {
+			return
+		}
+		b.FastReads = append(b.FastReads, FastRead{
+			Mode:   mode,
+			Opcode: byte(bits(v, 8, 8)),
+			Dummy:  int(bits(v, 0, 5)),
+			Clocks: int(bits(v, 5, 3)),
+		})
+	}
+	fastRead(bits(dw[0], 16, 1) == 1, "1-1-2", dw[3])
+	fastRead(bits(dw[0], 20, 1) == 1, "1-2-2", dw[3]>>16)
+	fastRead(bits(dw[0], 22, 1) == 1, "1-1-4", dw[2]>>16)
+	fastRead(bits(dw[0], 21, 1) == 1, "1-4-4", dw[2])
+	fastRead(bits(dw[4], 0, 1) == 1, "2-2-2", dw[5]>>16)
+	fastRead(bits(dw[4], 4, 1) == 1, "4-4-4", dw[6]>>16)
+
+	for i := 0; i < 4; i++ {
+		v := dw[7+i/2] >> (16 * (i % 2))
+		n := bits(v, 0, 8)
+		if n == 0 {
+			continue
+		}
+		if n > 31 {
+			return nil, fmt.Errorf("%w: erase type %d size 2^%d", ErrSFDP, i+1, n)
+		}
+		e := EraseType{Size: 1 << n, Opcode: byte(bits(v, 8, 8))}
+		if len(dw) > 9 {
+			t := bits(dw[9], 4+7*i, 7)
+			tm := timing(bits(t, 0, 5), eraseUnits[bits(t, 5, 2)], bits(dw[9], 0, 4))
+			e.Typical, e.Max = tm.Typical, tm.Max
+		}
+		b.EraseTypes = append(b.EraseTypes, e)
+	}
+	if len(dw) > 10 {
+		v := dw[10]
+		mult := bits(v, 0, 4)
+		b.PageSize = 1 << bits(v, 4, 4)
+		b.PageProgram = timing(bits(v, 8, 5), []time.Duration{8 * time.Microsecond, 64 * time.Microsecond}[bits(v, 13, 1)], mult)
+		b.ByteProgram = timing(bits(v, 14, 4), []time.Duration{time.Microsecond, 8 * time.Microsecond}[bits(v, 18, 1)], mult)
+		b.ChipErase = timing(bits(v, 24, 5), chipEraseUnits[bits(v, 29, 2)], mult)
+	}
+	if len(dw) > 14 {
+		b.QuadEnable = quadEnable[bits(dw[14], 20, 3)]
+	}
+	if len(dw) > 15 {
+		b.Enter4Byte = flags(dw[15], 24, enter4Byte)
+		b.Exit4Byte = flags(dw[15], 14, exit4Byte)
+	}
+	return b, nil
+}
+
+func (b *BFPT) String() string {
+	s := fmt.Sprintf("basic flash parameters:\n  density:          0x%x bytes (%d Mbit)\n", b.Density, b.Density>>17)
+	s += fmt.Sprintf("  address bytes:    %s\n", b.AddressBytes)
+	if b.PageSize > 0 {
+		s += fmt.Sprintf("  page size:        0x%x\n", b.PageSize)
+	}
+	if b.Erase4K {
+		s += fmt.Sprintf("  erase 4K:         opcode %02Xh\n", b.Erase4KOpcode)
+	}
+	for i, e := range b.EraseTypes {
+		s += fmt.Sprintf("  erase type %d:     0x%x bytes, opcode %02Xh", i+1, e.Size, e.Opcode)
+		if e.Typical > 0 {
+			s += fmt.Sprintf(", typical %s, max %s", e.Typical, e.Max)
+		}
+		s += "\n"
+	}
+	for _, r := range b.FastReads {
+		s += fmt.Sprintf("  fast read %s:  opcode %02Xh, dummy %d, mode clocks %d\n", r.Mode, r.Opcode, r.Dummy, r.Clocks)
+	}
+	for _, t := range []struct {
+		name string
+		t    *Timing
+	}{
+		{"page program", b.PageProgram},
+		{"byte program", b.ByteProgram},
+		{"chip erase", b.ChipErase},
+	} {
+		if t.t != nil {
+			s += fmt.Sprintf("  %-17s typical %s, max %s\n", t.name+":", t.t.Typical, t.t.Max)
+		}
+	}
+	if b.QuadEnable != "" {
+		s += fmt.Sprintf("  quad enable:      %s\n", b.QuadEnable)
+	}
+	if len(b.Enter4Byte) > 0 {
+		s += fmt.Sprintf("  enter 4-byte:     %s\n", strings.Join(b.Enter4Byte, "; "))
+	}
+	if len(b.Exit4Byte) > 0 {
+		s += fmt.Sprintf("  exit 4-byte:      %s\n", strings.Join(b.Exit4Byte, "; "))
+	}
+	return s
+}
@@ -0,0 +1,304 @@
+package sfdp
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrSFDP = errors.New("invalid SFDP")
+
+const (
+	Signature  = "SFDP"
+	headerSize = 8
+)
+
+// IDs of JEDEC parameter tables
+const (
+	IDBasic     = 0xff00
+	IDSectorMap = 0xff81
+	IDFourByte  = 0xff84
+)
+
+var jedecTables = map[uint16]string{
+	IDBasic:     "JEDEC basic flash parameters",
+	IDSectorMap: "JEDEC sector map",
+	0xff03:      "JEDEC replay protected monotonic counters",
+	IDFourByte:  "JEDEC 4-byte address instructions",
+	0xff05:      "JEDEC xSPI profile 1.0",
+	0xff06:      "JEDEC xSPI profile 2.0",
+	0xff87:      "JEDEC status, control and configuration registers",
+	0xff88:      "JEDEC status, control and configuration registers of multi-chip",
+	0xff09:      "JEDEC command sequences to change to octal DDR",
+	0xff0a:      "JEDEC long latency NVM media specific",
+	0xff0c:      "JEDEC quad I/O with DS",
+}
+
+// Manufacturers by JEP106 ID, vendor tables have it in LSB of parameter ID.
+var Manufacturers = map[byte]string{
+	0x01: "Spansion/Cypress",
+	0x0b: "XTX",
+	0x1f: "Adesto/Atmel",
+	0x20: "Micron",
+	0x5e: "Zbit",
+	0x68: "Boya",
+	0x85: "Puya",
+	0x9d: "ISSI",
+	0xbf: "SST/Microchip",
+	0xc2: "Macronix",
+	0xc8: "GigaDevice",
+	0xef: "Winbond",
+}
+
+// instructions of 4-byte address instruction table by bits of DWORD 1
+var fourByteInstructions = []string{
+	"read 13h",
+	"fast read 0Ch",
+	"1-1-2 read 3Ch",
+	"1-2-2 read BCh",
+	"1-1-4 read 6Ch",
+	"1-4-4 read ECh",
+	"page program 12h",
+	"1-1-4 program 34h",
+	"1-4-4 program 3Eh",
+	"erase type 1",
+	"erase type 2",
+	"erase type 3",
+	"erase type 4",
+	"1-1-1 DTR read 0Eh",
+	"1-2-2 DTR read BEh",
+	"1-4-4 DTR read EEh",
+}
+
+// Param is a header of parameter table with its dwords.
+type Param struct {
+	ID      uint16   `json:"id"`
+	Name    string   `json:"name"`
+	Major   byte     `json:"major"`
+	Minor   byte     `json:"minor"`
+	Pointer uint32   `json:"pointer"`
+	DWords  []uint32 `json:"dwords"`
+}
+
+func paramName(id uint16) string {
+	// old revisions have MSB of basic table 0x00
+	if id&0xff == 0 {
+		return jedecTables[IDBasic]
+	}
+	if name, ok := jedecTables[id]; ok {
+		return name
+	}
+	if name, ok := Manufacturers[byte(id)]; ok {
+		return "vendor " + name
+	}
+	return fmt.Sprintf("vendor 0x%02x", byte(id))
+}
+
+func (p Param) String() string {
+	return fmt.Sprintf("0x%04x %-45s %d.%d, %d dwords", p.Pointer, p.Name, p.Major, p.Minor, len(p.DWords))
+}
+
+// FourByte is a decoded 4-byte address instruction table.
+type FourByte struct {
+	Instructions []string `json:"instructions"`
+	EraseOpcodes [4]byte  `json:"erase_opcodes"`
+}
+
+// Geometry is suggested for commands with pages and erase blocks,
+// NOR flash has no spare area.
+type Geometry struct {
+	PageSize      int   `json:"page_size"`
+	BlockSize     int64 `json:"block_size"`
+	PagesPerBlock int   `json:"pages_per_block"`
+	SectorSize    int64 `json:"sector_size"`
+}
+
+func (g Geometry) String() string {
+	return fmt.Sprintf("suggested geometry: --page 0x%x --skip 0 --block-pages %d (erase block 0x%x, sector 0x%x)",
+		g.PageSize, g.PagesPerBlock, g.BlockSize, g.SectorSize)
+}
+
+type SFDP struct {
+	Major    byte      `json:"major"`
+	Minor    byte      `json:"minor"`
+	Access   byte      `json:"access_protocol"`
+	Params   []Param   `json:"params"`
+	Basic    *BFPT     `json:"basic,omitempty"`
+	FourByte *FourByte `json:"four_byte,omitempty"`
+	Geometry *Geometry `json:"geometry,omitempty"`
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// Parse decodes SFDP area, pointers of tables are relative to its start.
+// Damaged tables except of basic one are skipped with warnings.
+func Parse(data []byte) (*SFDP, error) {
+	if len(data) < headerSize || string(data[:4]) != Signature {
+		return nil, fmt.Errorf("%w: no signature", ErrSFDP)
+	}
+	s := &SFDP{Minor: data[4], Major: data[5], Access: data[7]}
+	n := int(data[6]) + 1
+	le := binary.LittleEndian
+	for i := 0; i < n; i++ {
+		off := headerSize * (i + 1)
+		if off+headerSize > len(data) {
+			return nil, fmt.Errorf("%w: parameter header %d is out of data", ErrSFDP, i)
+		}
+		h := data[off : off+headerSize]
+		p := Param{
+			ID:      uint16(h[7])<<8 | uint16(h[0]),
+			Minor:   h[1],
+			Major:   h[2],
+			Pointer: le.Uint32(h[4:]) & 0xffffff,
+		}
+		p.Name = paramName(p.ID)
+		length := int(h[3])
+		if end := int(p.Pointer) + 4*length; end > len(data) {
+			if i == 0 {
+				return nil, fmt.Errorf("%w: %s at 0x%x is out of data", ErrSFDP, p.Name, p.Pointer)
+			}
+			s.Warnings = append(s.Warnings, fmt.Sprintf("%s at 0x%x is out of data", p.Name, p.Pointer))
+			continue
+		}
+		for k := 0; k < length; k++ {
+			p.DWords = append(p.DWords, le.Uint32(data[int(p.Pointer)+4*k:]))
+		}
+		s.Params = append(s.Params, p)
+	}
+	// the first header is always basic table
+	if s.Params[0].ID&0xff != 0 {
+		return nil, fmt.Errorf("%w: the first table has ID 0x%04x", ErrSFDP, s.Params[0].ID)
+	}
+	for _, p := range s.Params {
+		switch {
+		case p.ID&0xff == 0:
+			// newer revisions of basic table are in the next headers
+			b, err := parseBFPT(p.DWords)
+			if err != nil {
+				return nil, err
+			}
+			s.Basic = b
+		case p.ID == IDFourByte:
+			if len(p.DWords) < 2 {
+				s.Warnings = append(s.Warnings, fmt.Sprintf("%s has %d dwords", p.Name, len(p.DWords)))
+				continue
+			}
+			f := &FourByte{Instructions: flags(p.DWords[0], 0, fourByteInstructions)}
+			le.PutUint32(f.EraseOpcodes[:], p.DWords[1])
+			s.FourByte = f
+		}
+	}
+	s.Geometry = s.suggest()
+	return s, nil
+}
+
+// suggest returns geometry with the largest erase type as block,
+// page size is 256 bytes for old tables without it.
+func (s *SFDP) suggest() *Geometry {
+	if s.Basic == nil {
+		return nil
+	}
+	g := &Geometry{PageSize: s.Basic.PageSize}
+	if g.PageSize == 0 {
+		g.PageSize = 0x100
+	}
+	for _, e := range s.Basic.EraseTypes {
+		g.BlockSize = max(g.BlockSize, e.Size)
+		if g.SectorSize == 0 || e.Size < g.SectorSize {
+			g.SectorSize = e.Size
+		}
+	}
+	if g.BlockSize == 0 && s.Basic.Erase4K {
+		g.BlockSize, g.SectorSize = 0x1000, 0x1000
+	}
+	if g.BlockSize < int64(g.PageSize) {
+		return nil
+	}
+	g.PagesPerBlock = int(g.BlockSize / int64(g.PageSize))
+	return g
+}
+
+func (s *SFDP) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "SFDP revision %d.%d, %d parameter tables\n", s.Major, s.Minor, len(s.Params))
+	for _, p := range s.Params {
+		fmt.Fprintf(&b, "  %s\n", p)
+	}
+	if s.Basic != nil {
+		b.WriteString(s.Basic.String())
+	}
+	if s.FourByte != nil {
+		fmt.Fprintf(&b, "4-byte address instructions:\n  %s\n", strings.Join(s.FourByte.Instructions, "; "))
+		fmt.Fprintf(&b, "  erase opcodes:    % X\n", s.FourByte.EraseOpcodes)
+	}
+	// tables without decoder are printed as dwords
+	for _, p := range s.Params {
+		if p.ID&0xff == 0 || p.ID == IDFourByte {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d.%d:\n", p.Name, p.Major, p.Minor)
+		for i := 0; i < len(p.DWords); i += 4 {
+			fmt.Fprintf(&b, "  0x%02x:", 4*i)
+			for _, dw := range p.DWords[i:min(i+4, len(p.DWords))] {
+				fmt.Fprintf(&b, " %08x", dw)
+			}
+			b.WriteString("\n")
+		}
+	}
+	for _, w := range s.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	if s.Geometry != nil {
+		fmt.Fprintln(&b, s.Geometry)
+	}
+	return b.String()
+}
+
+type Decoder struct {
+	data   []byte
+	out    io.Writer
+	Config config.SFDP
+}
+
+func New(cfg config.SFDP) *Decoder {
+	return &Decoder{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (d *Decoder) Open(input string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' with SFDP: %w", input, err)
+	}
+	if d.Config.Offset < 0 || d.Config.Offset > int64(len(data)) {
+		return fmt.Errorf("offset 0x%x is out of file", d.Config.Offset)
+	}
+	d.data = data[d.Config.Offset:]
+	return nil
+}
+
+func (d *Decoder) Close() error {
+	return nil
+}
+
+func (d *Decoder) Run(ctx context.Context) error {
+	s, err := Parse(d.data)
+	if err != nil {
+		return err
+	}
+	if d.Config.JSON {
+		enc := json.NewEncoder(d.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	_, err = fmt.Fprint(d.out, s)
+	return err
+}
@@ -0,0 +1,111 @@
+package sfdp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+// SFDP of W25Q128JV with vendor and 4-byte address tables
+func image(t *testing.T) []byte {
+	t.Helper()
+	data := make([]byte, 0x100)
+	for i := range data {
+		data[i] = 0xff
+	}
+	for off, s := range map[int]string{
+		0x00: "53464450050102ff" + "00050110800000ff" + "ef000102c00000ff" + "84000102d00000ff",
+		0x80: "e520f9ffffffff0744eb086b083b42bbfeffffffffff0000ffff40eb0c200f5210d8000036" +
+			"02a60082ea14c9e96376337a757a75f7a2d55c19f74dffe930f880",
+		0xc0: "0102030405060708",
+		0xd0: "430e0000215cdc00",
+	} {
+		b, err := hex.DecodeString(s)
+		require.NoError(t, err)
+		copy(data[off:], b)
+	}
+	return data
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse(image(t))
+	require.NoError(t, err)
+	require.Equal(t, byte(1), s.Major)
+	require.Equal(t, byte(5), s.Minor)
+	require.Len(t, s.Params, 3)
+	require.Equal(t, "vendor Winbond", s.Params[1].Name)
+	require.Equal(t, []uint32{0x04030201, 0x08070605}, s.Params[1].DWords)
+
+	b := s.Basic
+	require.Equal(t, int64(16<<20), b.Density)
+	require.Equal(t, "3", b.AddressBytes)
+	require.True(t, b.Erase4K)
+	require.Equal(t, byte(0x20), b.Erase4KOpcode)
+	require.Equal(t, []EraseType{
+		{0x1000, 0x20, 64 * time.Millisecond, 896 * time.Millisecond},
+		{0x8000, 0x52, 128 * time.Millisecond, 1792 * time.Millisecond},
+		{0x10000, 0xd8, 160 * time.Millisecond, 2240 * time.Millisecond},
+	}, b.EraseTypes)
+	require.Equal(t, []FastRead{
+		{"1-1-2", 0x3b, 8, 0},
+		{"1-2-2", 0xbb, 2, 2},
+		{"1-1-4", 0x6b, 8, 0},
+		{"1-4-4", 0xeb, 4, 2},
+		{"4-4-4", 0xeb, 0, 2},
+	}, b.FastReads)
+	require.Equal(t, 0x100, b.PageSize)
+	require.Equal(t, &Timing{704 * time.Microsecond, 4224 * time.Microsecond}, b.PageProgram)
+	require.Equal(t, &Timing{40 * time.Second, 240 * time.Second}, b.ChipErase)
+	require.Equal(t, quadEnable[4], b.QuadEnable)
+	require.Empty(t, b.Enter4Byte)
+	// DWORD16 is 0x80f830e9
+	require.Equal(t, []string{"software reset", "power cycle", "reserved"}, b.Exit4Byte)
+
+	require.Equal(t, &FourByte{
+		Instructions: []string{"read 13h", "fast read 0Ch", "page program 12h", "erase type 1", "erase type 2", "erase type 3"},
+		EraseOpcodes: [4]byte{0x21, 0x5c, 0xdc, 0x00},
+	}, s.FourByte)
+	require.Equal(t, &Geometry{PageSize: 0x100, BlockSize: 0x10000, PagesPerBlock: 256, SectorSize: 0x1000}, s.Geometry)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func([]byte) []byte
+		err    error
+		warn   string
+	}{
+		{"Signature", func(b []byte) []byte { b[0] = 0; return b }, ErrSFDP, ""},
+		{"Short basic table", func(b []byte) []byte { b[11] = 8; return b }, ErrSFDP, ""},
+		{"Basic table out of data", func(b []byte) []byte { return b[:0xa0] }, ErrSFDP, ""},
+		{"Vendor table out of data", func(b []byte) []byte { b[0x14] = 0xfc; return b }, nil, "vendor Winbond at 0xfc is out of data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.modify(image(t)))
+			require.ErrorIs(t, err, tt.err)
+			if tt.warn != "" {
+				require.Equal(t, []string{tt.warn}, s.Warnings)
+			}
+		})
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	d := New(config.SFDP{})
+	out := &bytes.Buffer{}
+	d.out = out
+	d.data = image(t)
+	require.NoError(t, d.Run(context.Background()))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, "SFDP revision 1.5, 3 parameter tables", lines[0])
+	require.Contains(t, out.String(), "vendor Winbond 1.0:\n  0x00: 04030201 08070605\n")
+	require.Equal(t, "suggested geometry: --page 0x100 --skip 0 --block-pages 256 (erase block 0x10000, sector 0x1000)", lines[len(lines)-1])
+}
@@ -0,0 +1,506 @@
+package sparse
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrMagic = errors.New("invalid magic of sparse image")
+var ErrCorrupted = errors.New("corrupted sparse image")
+var ErrCRC = errors.New("invalid CRC32 of sparse image")
+
+const (
+	Magic = 0xed26ff3a
+
+	headerSize      = 28
+	chunkHeaderSize = 12
+	// raw data is buffered up to this size before chunk is written
+	maxRaw = 0x1000000
+)
+
+const (
+	ChunkRaw      = 0xcac1
+	ChunkFill     = 0xcac2
+	ChunkDontCare = 0xcac3
+	ChunkCRC      = 0xcac4
+)
+
+type Header struct {
+	Magic           uint32
+	Major           uint16
+	Minor           uint16
+	HeaderSize      uint16
+	ChunkHeaderSize uint16
+	BlockSize       uint32
+	Blocks          uint32
+	Chunks          uint32
+	Checksum        uint32
+}
+
+func (h Header) String() string {
+	return fmt.Sprintf("sparse image v%d.%d, block 0x%x, 0x%x blocks (0x%x bytes), %d chunks",
+		h.Major, h.Minor, h.BlockSize, h.Blocks, int64(h.Blocks)*int64(h.BlockSize), h.Chunks)
+}
+
+type Chunk struct {
+	Type uint16
+	// Offset in raw image
+	Offset int64
+	Blocks uint32
+	// Value is pattern of fill chunk or CRC32 of CRC chunk
+	Value uint32
+}
+
+func (c Chunk) String() string {
+	s := fmt.Sprintf("0x%010x 0x%08x blocks ", c.Offset, c.Blocks)
+	switch c.Type {
+	case ChunkRaw:
+		return s + "raw"
+	case ChunkFill:
+		return s + fmt.Sprintf("fill 0x%08x", c.Value)
+	case ChunkDontCare:
+		return s + "don't care"
+	case ChunkCRC:
+		return s + fmt.Sprintf("crc32 0x%08x", c.Value)
+	}
+	return s + fmt.Sprintf("0x%04x", c.Type)
+}
+
+// Reader reads chunks of sparse image one by one.
+type Reader struct {
+	r      io.Reader
+	Header Header
+	chunk  uint32
+	offset int64
+	data   *io.LimitedReader
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	s := &Reader{r: r}
+	if err := binary.Read(r, binary.LittleEndian, &s.Header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMagic, err)
+	}
+	h := s.Header
+	if h.Magic != Magic {
+		return nil, ErrMagic
+	}
+	if h.Major != 1 || h.HeaderSize < headerSize || h.ChunkHeaderSize < chunkHeaderSize ||
+		h.BlockSize == 0 || h.BlockSize%4 != 0 {
+		return nil, fmt.Errorf("%w: header", ErrCorrupted)
+	}
+	if err := skip(r, int64(h.HeaderSize-headerSize)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func skip(r io.Reader, n int64) error {
+	_, err := io.CopyN(io.Discard, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Next returns next chunk and reader of its data for raw chunk, unread data is
+// skipped by following call. Returns io.EOF after the last chunk.
+func (s *Reader) Next() (Chunk, io.Reader, error) {
+	if s.data != nil {
+		if err := skip(s.data, s.data.N); err != nil {
+			return Chunk{}, nil, err
+		}
+		s.data = nil
+	}
+	if s.chunk == s.Header.Chunks {
+		if s.offset != int64(s.Header.Blocks)*int64(s.Header.BlockSize) {
+			return Chunk{}, nil, fmt.Errorf("%w: chunks have 0x%x bytes instead of 0x%x", ErrCorrupted,
+				s.offset, int64(s.Header.Blocks)*int64(s.Header.BlockSize))
+		}
+		return Chunk{}, nil, io.EOF
+	}
+	var h struct {
+		Type     uint16
+		Reserved uint16
+		Blocks   uint32
+		Size     uint32
+	}
+	if err := binary.Read(s.r, binary.LittleEndian, &h); err != nil {
+		return Chunk{}, nil, fmt.Errorf("%w: chunk %d: %w", ErrCorrupted, s.chunk, err)
+	}
+	if err := skip(s.r, int64(s.Header.ChunkHeaderSize-chunkHeaderSize)); err != nil {
+		return Chunk{}, nil, err
+	}
+	c := Chunk{Type: h.Type, Offset: s.offset, Blocks: h.Blocks}
+	data := int64(h.Size) - int64(s.Header.ChunkHeaderSize)
+	size := int64(h.Blocks) * int64(s.Header.BlockSize)
+	var want int64
+	switch h.Type {
+	case ChunkRaw:
+		want = size
+	case ChunkFill, ChunkCRC:
+		want = 4
+	case ChunkDontCare:
+	default:
+		return c, nil, fmt.Errorf("%w: chunk %d has type 0x%04x", ErrCorrupted, s.chunk, h.Type)
+	}
+	if data != want {
+		return c, nil, fmt.Errorf("%w: chunk %d has 0x%x bytes of data", ErrCorrupted, s.chunk, data)
+	}
+	s.chunk++
+	s.offset += size
+	if h.Type == ChunkRaw {
+		s.data = &io.LimitedReader{R: s.r, N: size}
+		return c, s.data, nil
+	}
+	if want != 0 {
+		if err := binary.Read(s.r, binary.LittleEndian, &c.Value); err != nil {
+			return c, nil, fmt.Errorf("%w: chunk %d: %w", ErrCorrupted, s.chunk-1, err)
+		}
+	}
+	return c, nil, nil
+}
+
+// truncater is output file, where skipped blocks become holes
+type truncater interface {
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// copyN copies n bytes by parts to check cancel between them
+func copyN(ctx context.Context, w io.Writer, r io.Reader, n int64) error {
+	for n > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		part := min(n, maxRaw)
+		if _, err := io.CopyN(w, r, part); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		n -= part
+	}
+	return nil
+}
+
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
+
+// Unsparse writes raw image of sparse image r to w. Blocks of don't care chunks
+// are zeros, they and zero fill chunks are holes if w is a file.
+func Unsparse(ctx context.Context, r io.Reader, w io.Writer) (Header, error) {
+	s, err := NewReader(r)
+	if err != nil {
+		return Header{}, err
+	}
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(w, crc)
+	f, seekable := w.(truncater)
+	var hole int64
+	for {
+		c, data, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return s.Header, err
+		}
+		size := int64(c.Blocks) * int64(s.Header.BlockSize)
+		// zeros of file are holes
+		zero := c.Type == ChunkDontCare || c.Type == ChunkFill && c.Value == 0
+		switch {
+		case c.Type == ChunkRaw:
+			err = copyN(ctx, out, data, size)
+		case zero && !seekable:
+			err = copyN(ctx, out, zeros{}, size)
+		case c.Type == ChunkFill && !zero:
+			pattern := bytes.Repeat(binary.LittleEndian.AppendUint32(nil, c.Value), int(s.Header.BlockSize/4))
+			err = copyN(ctx, out, &repeat{pattern: pattern}, size)
+		case zero:
+			_, err = f.Seek(size, io.SeekCurrent)
+			if err == nil {
+				err = copyN(ctx, crc, zeros{}, size)
+			}
+			hole = s.offset
+		case c.Type == ChunkCRC:
+			if crc.Sum32() != c.Value {
+				err = fmt.Errorf("%w: 0x%08x at 0x%x, expected 0x%08x", ErrCRC, crc.Sum32(), c.Offset, c.Value)
+			}
+		}
+		if err != nil {
+			return s.Header, err
+		}
+	}
+	// file is extended, if it ends with hole
+	if seekable && hole == s.offset {
+		if err := f.Truncate(s.offset); err != nil {
+			return s.Header, err
+		}
+	}
+	if s.Header.Checksum != 0 && s.Header.Checksum != crc.Sum32() {
+		return s.Header, fmt.Errorf("%w: 0x%08x, expected 0x%08x", ErrCRC, crc.Sum32(), s.Header.Checksum)
+	}
+	return s.Header, nil
+}
+
+type repeat struct {
+	pattern []byte
+	pos     int
+}
+
+func (r *repeat) Read(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		c := copy(b[n:], r.pattern[r.pos:])
+		n += c
+		r.pos = (r.pos + c) % len(r.pattern)
+	}
+	return n, nil
+}
+
+// writer merges blocks into chunks
+type writer struct {
+	w      io.Writer
+	header Header
+	typ    uint16
+	fill   uint32
+	blocks uint32
+	raw    []byte
+}
+
+func (s *writer) block(b []byte) error {
+	fill := binary.LittleEndian.Uint32(b)
+	typ := uint16(ChunkFill)
+	for i := 4; i < len(b); i += 4 {
+		if binary.LittleEndian.Uint32(b[i:]) != fill {
+			typ = ChunkRaw
+			break
+		}
+	}
+	if s.blocks != 0 && (typ != s.typ || typ == ChunkFill && fill != s.fill || len(s.raw)+len(b) > maxRaw) {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	s.typ, s.fill = typ, fill
+	s.blocks++
+	if typ == ChunkRaw {
+		s.raw = append(s.raw, b...)
+	}
+	return nil
+}
+
+func (s *writer) flush() error {
+	if s.blocks == 0 {
+		return nil
+	}
+	size := chunkHeaderSize + uint32(len(s.raw))
+	if s.typ == ChunkFill {
+		size += 4
+	}
+	h := []any{s.typ, uint16(0), s.blocks, size}
+	if s.typ == ChunkFill {
+		h = append(h, s.fill)
+	}
+	for _, v := range h {
+		if err := binary.Write(s.w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if _, err := s.w.Write(s.raw); err != nil {
+		return err
+	}
+	s.header.Blocks += s.blocks
+	s.header.Chunks++
+	s.blocks = 0
+	s.raw = s.raw[:0]
+	return nil
+}
+
+// Sparse writes sparse image of raw image r to w, header is written after chunks.
+// Blocks with repeated 4 bytes become fill chunks, tail of r is padded with zeros.
+func Sparse(ctx context.Context, r io.Reader, w io.WriteSeeker, blockSize uint32) (Header, error) {
+	if blockSize == 0 || blockSize%4 != 0 {
+		return Header{}, fmt.Errorf("block size 0x%x isn't multiple of 4", blockSize)
+	}
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return Header{}, err
+	}
+	buf := bufio.NewWriterSize(w, 0x100000)
+	s := &writer{
+		w: buf,
+		header: Header{
+			Magic:           Magic,
+			Major:           1,
+			HeaderSize:      headerSize,
+			ChunkHeaderSize: chunkHeaderSize,
+			BlockSize:       blockSize,
+		},
+	}
+	if err := binary.Write(buf, binary.LittleEndian, s.header); err != nil {
+		return Header{}, err
+	}
+	b := make([]byte, blockSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return s.header, ctx.Err()
+		default:
+		}
+		n, err := io.ReadFull(r, b)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return s.header, err
+		}
+		clear(b[n:])
+		if err := s.block(b); err != nil {
+			return s.header, err
+		}
+		if n < len(b) {
+			break
+		}
+	}
+	if err := s.flush(); err != nil {
+		return s.header, err
+	}
+	if err := buf.Flush(); err != nil {
+		return s.header, err
+	}
+	if _, err := w.Seek(start, io.SeekStart); err != nil {
+		return s.header, err
+	}
+	if err := binary.Write(w, binary.LittleEndian, s.header); err != nil {
+		return s.header, err
+	}
+	_, err = w.Seek(0, io.SeekEnd)
+	return s.header, err
+}
+
+type Converter struct {
+	in     *os.File
+	dst    *os.File
+	sparse bool
+	out    io.Writer
+	Config config.Sparse
+}
+
+func New(cfg config.Sparse) *Converter {
+	return &Converter{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+// Open detects direction of conversion by magic of input.
+func (c *Converter) Open(input string) error {
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for converting: %w", input, err)
+	}
+	c.in = in
+	var magic uint32
+	err = binary.Read(in, binary.LittleEndian, &magic)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	c.sparse = magic == Magic
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if c.Config.Info {
+		if !c.sparse {
+			return ErrMagic
+		}
+		return nil
+	}
+	if c.Config.Output == "" {
+		ext := filepath.Ext(input)
+		suffix := "-sparse"
+		if c.sparse {
+			suffix = "-raw"
+		}
+		c.Config.Output = input[:len(input)-len(ext)] + suffix + ext
+	}
+	c.dst, err = os.OpenFile(c.Config.Output, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0666)
+	if err != nil {
+		return fmt.Errorf("can't create file '%s' for converting: %w", c.Config.Output, err)
+	}
+	return nil
+}
+
+func (c *Converter) Close() error {
+	var err error
+	if c.in != nil {
+		err = c.in.Close()
+	}
+	if c.dst != nil {
+		err = errors.Join(err, c.dst.Close())
+	}
+	return err
+}
+
+// Run converts sparse image to raw one or raw to sparse, with --info chunks
+// of sparse image are printed.
+func (c *Converter) Run(ctx context.Context) error {
+	r := bufio.NewReaderSize(c.in, 0x100000)
+	switch {
+	case c.Config.Info:
+		return c.info(ctx, r)
+	case c.sparse:
+		h, err := Unsparse(ctx, r, c.dst)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, h)
+	default:
+		h, err := Sparse(ctx, r, c.dst, c.Config.Block)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, h)
+	}
+	return nil
+}
+
+func (c *Converter) info(ctx context.Context, r io.Reader) error {
+	s, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, s.Header)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		chunk, _, err := s.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.out, chunk)
+	}
+}
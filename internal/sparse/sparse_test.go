@@ -0,0 +1,168 @@
+package sparse
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const block = 0x1000
+
+type chunk struct {
+	typ    uint16
+	blocks uint32
+	data   []byte
+}
+
+// image builds sparse image like img2simg
+func image(blocks uint32, checksum uint32, chunks ...chunk) []byte {
+	le := binary.LittleEndian
+	b := make([]byte, headerSize)
+	le.PutUint32(b, Magic)
+	le.PutUint16(b[4:], 1)
+	le.PutUint16(b[8:], headerSize)
+	le.PutUint16(b[10:], chunkHeaderSize)
+	le.PutUint32(b[12:], block)
+	le.PutUint32(b[16:], blocks)
+	le.PutUint32(b[20:], uint32(len(chunks)))
+	le.PutUint32(b[24:], checksum)
+	for _, c := range chunks {
+		b = le.AppendUint16(b, c.typ)
+		b = le.AppendUint16(b, 0)
+		b = le.AppendUint32(b, c.blocks)
+		b = le.AppendUint32(b, uint32(chunkHeaderSize+len(c.data)))
+		b = append(b, c.data...)
+	}
+	return b
+}
+
+func fill(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+func raw() []byte {
+	b := make([]byte, 3*block)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	b = append(b, bytes.Repeat([]byte{0xaa, 0xbb, 0xcc, 0xdd}, 2*block/4)...)
+	b = append(b, make([]byte, 2*block)...)
+	return append(b, "tail"...)
+}
+
+func TestUnsparse(t *testing.T) {
+	data := raw()[:3*block]
+	want := append(append(append([]byte{}, data...), bytes.Repeat([]byte{0x78, 0x56, 0x34, 0x12}, block/4)...), make([]byte, 2*block)...)
+	tests := []struct {
+		name   string
+		sparse []byte
+		want   []byte
+		err    error
+	}{
+		{
+			name: "Chunks",
+			sparse: image(6, crc32.ChecksumIEEE(want),
+				chunk{ChunkRaw, 3, data},
+				chunk{ChunkCRC, 0, fill(crc32.ChecksumIEEE(data))},
+				chunk{ChunkFill, 1, fill(0x12345678)},
+				chunk{ChunkDontCare, 2, nil},
+			),
+			want: want,
+		},
+		{
+			name:   "Chunk CRC",
+			sparse: image(3, 0, chunk{ChunkRaw, 3, data}, chunk{ChunkCRC, 0, fill(1)}),
+			err:    ErrCRC,
+		},
+		{
+			name:   "Image CRC",
+			sparse: image(3, 1, chunk{ChunkRaw, 3, data}),
+			err:    ErrCRC,
+		},
+		{
+			name:   "Blocks",
+			sparse: image(4, 0, chunk{ChunkRaw, 3, data}),
+			err:    ErrCorrupted,
+		},
+		{
+			name:   "Size of chunk",
+			sparse: image(3, 0, chunk{ChunkRaw, 3, data[:block]}),
+			err:    ErrCorrupted,
+		},
+		{
+			name:   "Magic",
+			sparse: make([]byte, 0x100),
+			err:    ErrMagic,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			_, err := Unsparse(context.Background(), bytes.NewReader(tt.sparse), out)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, out.Bytes())
+		})
+	}
+}
+
+func TestConverter(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "super.img")
+	require.NoError(t, os.WriteFile(input, raw(), 0644))
+
+	c := New(config.Sparse{Block: block})
+	c.out = &bytes.Buffer{}
+	require.NoError(t, c.Open(input))
+	require.NoError(t, c.Run(context.Background()))
+	require.NoError(t, c.Close())
+	require.Equal(t, filepath.Join(dir, "super-sparse.img"), c.Config.Output)
+	require.Equal(t, "sparse image v1.0, block 0x1000, 0x8 blocks (0x8000 bytes), 4 chunks\n", c.out.(*bytes.Buffer).String())
+
+	c = New(config.Sparse{Info: true})
+	out := &bytes.Buffer{}
+	c.out = out
+	require.NoError(t, c.Open(filepath.Join(dir, "super-sparse.img")))
+	require.NoError(t, c.Run(context.Background()))
+	require.NoError(t, c.Close())
+	require.Equal(t, `sparse image v1.0, block 0x1000, 0x8 blocks (0x8000 bytes), 4 chunks
+0x0000000000 0x00000003 blocks raw
+0x0000003000 0x00000002 blocks fill 0xddccbbaa
+0x0000005000 0x00000002 blocks fill 0x00000000
+0x0000007000 0x00000001 blocks raw
+`, out.String())
+
+	c = New(config.Sparse{})
+	c.out = &bytes.Buffer{}
+	require.NoError(t, c.Open(filepath.Join(dir, "super-sparse.img")))
+	require.NoError(t, c.Run(context.Background()))
+	require.NoError(t, c.Close())
+	got, err := os.ReadFile(filepath.Join(dir, "super-sparse-raw.img"))
+	require.NoError(t, err)
+	want := append(raw(), make([]byte, block-4)...)
+	require.Equal(t, want, got)
+}
+
+func TestHole(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "raw.img")
+	f, err := os.Create(output)
+	require.NoError(t, err)
+	defer f.Close()
+	sparse := image(3, 0, chunk{ChunkFill, 1, fill(0xffffffff)}, chunk{ChunkDontCare, 2, nil})
+	_, err = Unsparse(context.Background(), bytes.NewReader(sparse), f)
+	require.NoError(t, err)
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, append(bytes.Repeat([]byte{0xff}, block), make([]byte, 2*block)...), got)
+}
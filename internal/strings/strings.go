@@ -0,0 +1,334 @@
+package strings
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+	"github.com/Nexadis/fw-tools/internal/merge"
+	"github.com/Nexadis/fw-tools/internal/swap"
+)
+
+var ErrEncoding = errors.New("unknown encoding")
+
+const (
+	ASCII   = "ascii"
+	UTF8    = "utf8"
+	UTF16LE = "utf16le"
+	UTF16BE = "utf16be"
+)
+
+// utf16Limit is a limit of UTF-16 characters, only alphabetic scripts are found,
+// otherwise random data looks like CJK text
+const utf16Limit = 0x800
+
+type String struct {
+	Offset   int64
+	Encoding string
+	// Size is a size of string in bytes
+	Size int
+	Text string
+}
+
+func printable(r rune, utf bool) bool {
+	if r == '\t' || (r >= 0x20 && r < 0x7f) {
+		return true
+	}
+	return utf && r >= 0xa0 && r != utf8.RuneError && unicode.IsPrint(r)
+}
+
+// Find returns strings of encoding with min characters or more.
+func Find(data []byte, encoding string, min int) ([]String, error) {
+	switch encoding {
+	case ASCII:
+		return findBytes(data, false, min), nil
+	case UTF8:
+		return findBytes(data, true, min), nil
+	case UTF16LE:
+		return findUTF16(data, binary.LittleEndian, min), nil
+	case UTF16BE:
+		return findUTF16(data, binary.BigEndian, min), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrEncoding, encoding)
+}
+
+func findBytes(data []byte, utf bool, min int) []String {
+	encoding := ASCII
+	if utf {
+		encoding = UTF8
+	}
+	var res []String
+	start, chars := 0, 0
+	flush := func(end int) {
+		if chars >= min {
+			res = append(res, String{int64(start), encoding, end - start, string(data[start:end])})
+		}
+		chars = 0
+	}
+	for i := 0; i < len(data); {
+		r, size := rune(data[i]), 1
+		if utf && r >= utf8.RuneSelf {
+			r, size = utf8.DecodeRune(data[i:])
+		}
+		if !printable(r, utf) {
+			flush(i)
+		} else if chars++; chars == 1 {
+			start = i
+		}
+		i += size
+	}
+	flush(len(data))
+	return res
+}
+
+func findUTF16(data []byte, order binary.ByteOrder, min int) []String {
+	encoding := UTF16LE
+	if order == binary.BigEndian {
+		encoding = UTF16BE
+	}
+	var res []String
+	// strings can be aligned on odd offsets too
+	for phase := 0; phase < 2; phase++ {
+		var text []uint16
+		start := 0
+		flush := func() {
+			if len(text) >= min {
+				res = append(res, String{int64(start), encoding, len(text) * 2, string(utf16.Decode(text))})
+			}
+			text = text[:0]
+		}
+		for i := phase; i+2 <= len(data); i += 2 {
+			c := order.Uint16(data[i:])
+			if c >= utf16Limit || !printable(rune(c), true) {
+				flush()
+				continue
+			}
+			if len(text) == 0 {
+				start = i
+			}
+			text = append(text, c)
+		}
+		flush()
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Offset < res[j].Offset })
+	return res
+}
+
+// Candidate is a transformation of inputs with its yield of readable strings
+type Candidate struct {
+	Name    string
+	Strings int
+	Bytes   int64
+	Size    int64
+}
+
+func (c Candidate) String() string {
+	var yield float64
+	if c.Size > 0 {
+		yield = float64(c.Bytes) * 100 / float64(c.Size)
+	}
+	return fmt.Sprintf("%-24s %8d strings %10d bytes %6.2f%%", c.Name, c.Strings, c.Bytes, yield)
+}
+
+// swaps are combinations of swaps, which are checked by score
+var swaps = [][]string{
+	nil,
+	{"bits"},
+	{"halfs"},
+	{"bytes"},
+	{"words"},
+	{"dwords"},
+	{"bytes", "words"},
+	{"words", "dwords"},
+	{"bytes", "words", "dwords"},
+}
+
+var merges = []string{"bit", "byte", "word", "dword"}
+
+type Finder struct {
+	names  []string
+	data   [][]byte
+	out    io.Writer
+	Config config.Strings
+	Cut    config.Cut
+}
+
+func New(cfg config.Strings, geometry config.Cut) *Finder {
+	return &Finder{
+		Config: cfg,
+		Cut:    geometry,
+		out:    os.Stdout,
+	}
+}
+
+func (f *Finder) Open(inputs []string) error {
+	if len(inputs) > 1 && !f.Config.Score {
+		return errors.New("set one file or score merges of files")
+	}
+	if f.Config.Min <= 0 {
+		return fmt.Errorf("invalid minimal length %d", f.Config.Min)
+	}
+	if len(f.Config.Encodings) == 0 {
+		f.Config.Encodings = []string{ASCII}
+	}
+	for _, e := range f.Config.Encodings {
+		if _, err := Find(nil, e, f.Config.Min); err != nil {
+			return err
+		}
+	}
+	// UTF-8 strings include ASCII ones
+	if slices.Contains(f.Config.Encodings, UTF8) {
+		f.Config.Encodings = slices.DeleteFunc(slices.Clone(f.Config.Encodings), func(e string) bool { return e == ASCII })
+	}
+	if f.Config.Pages && f.page() <= 0 {
+		return fmt.Errorf("invalid geometry: page 0x%x", f.page())
+	}
+	for _, in := range inputs {
+		data, err := os.ReadFile(in)
+		if err != nil {
+			return fmt.Errorf("can't open file '%s' for strings: %w", in, err)
+		}
+		f.data = append(f.data, data)
+	}
+	f.names = inputs
+	return nil
+}
+
+func (f *Finder) Close() error {
+	return nil
+}
+
+func (f *Finder) page() int {
+	return f.Cut.PageSize + f.Cut.SkipSize
+}
+
+func (f *Finder) Run(ctx context.Context) error {
+	if f.Config.Score {
+		candidates, err := f.Score(ctx)
+		if err != nil {
+			return err
+		}
+		for _, c := range candidates {
+			fmt.Fprintln(f.out, c)
+		}
+		return nil
+	}
+	strs, err := f.Strings(ctx, f.data[0])
+	if err != nil {
+		return err
+	}
+	for _, s := range strs {
+		fmt.Fprintf(f.out, "%s %-7s %s\n", f.offset(s.Offset), s.Encoding, escape(s.Text))
+	}
+	return nil
+}
+
+// Strings finds strings of all configured encodings sorted by offset.
+func (f *Finder) Strings(ctx context.Context, data []byte) ([]String, error) {
+	var res []String
+	for _, e := range f.Config.Encodings {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		strs, err := Find(data, e, f.Config.Min)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, strs...)
+	}
+	sort.SliceStable(res, func(i, j int) bool { return res[i].Offset < res[j].Offset })
+	return res, nil
+}
+
+// offset formats offset with page and offset in page, if it's configured
+func (f *Finder) offset(off int64) string {
+	if !f.Config.Pages {
+		return fmt.Sprintf("0x%08x", off)
+	}
+	page, in := off/int64(f.page()), off%int64(f.page())
+	area := "data "
+	if in >= int64(f.Cut.PageSize) {
+		area, in = "spare", in-int64(f.Cut.PageSize)
+	}
+	return fmt.Sprintf("0x%08x page 0x%06x %s+0x%04x", off, page, area, in)
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(s, "\t", `\t`)
+}
+
+// Score transforms inputs by swaps or merges and swaps, if there are several inputs,
+// and sorts candidates by yield of strings.
+func (f *Finder) Score(ctx context.Context) ([]Candidate, error) {
+	type source struct {
+		name string
+		data []byte
+	}
+	sources := []source{{"", f.data[0]}}
+	if len(f.data) > 1 {
+		sources = sources[:0]
+		for _, name := range merges {
+			m, err := merge.Parse(name)
+			if err != nil {
+				return nil, err
+			}
+			rs := make([]io.Reader, 0, len(f.data))
+			for _, d := range f.data {
+				rs = append(rs, bytes.NewReader(d))
+			}
+			var b bytes.Buffer
+			if err := merge.New(m).Merge(ctx, rs, &b); err != nil {
+				return nil, fmt.Errorf("merge by %s: %w", name, err)
+			}
+			sources = append(sources, source{"merge " + name, b.Bytes()})
+		}
+	}
+	var candidates []Candidate
+	for _, src := range sources {
+		for _, names := range swaps {
+			s, _, err := swap.Parse(names)
+			if err != nil {
+				return nil, err
+			}
+			data := src.data
+			if len(names) > 0 {
+				data = bytes.Clone(src.data)
+				swap.New(s).Transform(data)
+			}
+			strs, err := f.Strings(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			parts := []string{}
+			if src.name != "" {
+				parts = append(parts, src.name)
+			}
+			if len(names) > 0 {
+				parts = append(parts, "swap "+strings.Join(names, "+"))
+			}
+			if len(parts) == 0 {
+				parts = append(parts, "as is")
+			}
+			c := Candidate{Name: strings.Join(parts, ", "), Strings: len(strs), Size: int64(len(data))}
+			for _, s := range strs {
+				c.Bytes += int64(s.Size)
+			}
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Bytes > candidates[j].Bytes })
+	return candidates, nil
+}
@@ -0,0 +1,69 @@
+package strings
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var geometry = config.Cut{PageSize: 0x20, SkipSize: 0x8}
+
+func TestFind(t *testing.T) {
+	data := []byte("\x00\x01boot\tcmd\xff\xfe\x00h\xc3\xa9llo\x00ab\x00U\x00-\x00B\x00o\x00o\x00t\x00\x00\x00e\x00n\x00v\x00\x00")
+	tests := []struct {
+		encoding string
+		min      int
+		want     []String
+		err      error
+	}{
+		{ASCII, 4, []String{{2, ASCII, 8, "boot\tcmd"}}, nil},
+		{UTF8, 4, []String{{2, UTF8, 8, "boot\tcmd"}, {13, UTF8, 6, "héllo"}}, nil},
+		{UTF16LE, 3, []String{{21, UTF16LE, 14, "bU-Boot"}, {37, UTF16LE, 6, "env"}}, nil},
+		{UTF16BE, 3, []String{{22, UTF16BE, 12, "U-Boot"}, {36, UTF16BE, 6, "env"}}, nil},
+		{"ebcdic", 4, nil, ErrEncoding},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			strs, err := Find(data, tt.encoding, tt.min)
+			require.ErrorIs(t, err, tt.err)
+			require.Equal(t, tt.want, strs)
+		})
+	}
+}
+
+func TestFinder_Run(t *testing.T) {
+	dir := t.TempDir()
+	text := []byte("bootargs=console=ttyS0,115200 root=/dev/mtdblock2\x00")
+	data := append(make([]byte, 0x30), text...)
+	input := filepath.Join(dir, "dump.bin")
+	require.NoError(t, os.WriteFile(input, data, 0o644))
+
+	out := &bytes.Buffer{}
+	f := New(config.Strings{Min: 8, Pages: true}, geometry)
+	f.out = out
+	require.NoError(t, f.Open([]string{input}))
+	require.NoError(t, f.Run(context.TODO()))
+	require.Equal(t, "0x00000030 page 0x000001 data +0x0008 ascii   bootargs=console=ttyS0,115200 root=/dev/mtdblock2\n", out.String())
+
+	// halves of bytes are split between chips
+	chip0, chip1 := make([]byte, len(data)/2), make([]byte, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		chip0[i/2], chip1[i/2] = data[i+1], data[i]
+	}
+	inputs := []string{filepath.Join(dir, "chip0.bin"), filepath.Join(dir, "chip1.bin")}
+	require.NoError(t, os.WriteFile(inputs[0], chip0, 0o644))
+	require.NoError(t, os.WriteFile(inputs[1], chip1, 0o644))
+	f = New(config.Strings{Min: 8, Score: true}, geometry)
+	require.NoError(t, f.Open(inputs))
+	candidates, err := f.Score(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, candidates, len(merges)*len(swaps))
+	require.Equal(t, "merge byte, swap bytes", candidates[0].Name)
+	require.Equal(t, int64(len(text)-1), candidates[0].Bytes)
+}
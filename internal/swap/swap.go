@@ -16,6 +16,7 @@ import (
 )
 
 var ErrAlign = errors.New("invalid align of input")
+var ErrUnknown = errors.New("unknown swap")
 
 type Swapper struct {
 	inputs  []io.ReadCloser
@@ -81,51 +82,80 @@ func (s *Swapper) swap(ctx context.Context, i io.Reader, o io.Writer) error {
 		default:
 		}
 
-		if s.Config.Bits {
-			for i, b := range buf {
-				buf[i] = InverseBits(b)
-			}
+		s.Transform(buf[:n])
+
+		_, err := o.Write(buf[:n])
+		if err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if s.Config.Halfs {
-			for i, b := range buf {
-				buf[i] = SwapHalf(b)
-			}
+// Transform applies configured swaps to buf in place, tail of buf shorter
+// than swapped unit isn't changed.
+func (s *Swapper) Transform(buf []byte) {
+	if s.Config.Bits {
+		for i, b := range buf {
+			buf[i] = InverseBits(b)
 		}
+	}
 
-		if s.Config.Bytes {
-			var w uint16
-			for i := 0; i < n; i += 2 {
-				w = binary.BigEndian.Uint16(buf[i : i+2])
-				w = SwapBytes(w)
-				binary.BigEndian.PutUint16(buf[i:i+2], w)
-			}
+	if s.Config.Halfs {
+		for i, b := range buf {
+			buf[i] = SwapHalf(b)
 		}
+	}
 
-		if s.Config.Words {
-			var w uint32
-			for i := 0; i < n; i += 4 {
-				w = binary.BigEndian.Uint32(buf[i : i+4])
-				w = SwapWords(w)
-				binary.BigEndian.PutUint32(buf[i:i+4], w)
-			}
+	if s.Config.Bytes {
+		var w uint16
+		for i := 0; i+2 <= len(buf); i += 2 {
+			w = binary.BigEndian.Uint16(buf[i : i+2])
+			w = SwapBytes(w)
+			binary.BigEndian.PutUint16(buf[i:i+2], w)
 		}
+	}
 
-		if s.Config.Dwords {
-			var w uint64
-			for i := 0; i < n; i += 8 {
-				w = binary.BigEndian.Uint64(buf[i : i+8])
-				w = SwapUInt(w)
-				binary.BigEndian.PutUint64(buf[i:i+8], w)
-			}
+	if s.Config.Words {
+		var w uint32
+		for i := 0; i+4 <= len(buf); i += 4 {
+			w = binary.BigEndian.Uint32(buf[i : i+4])
+			w = SwapWords(w)
+			binary.BigEndian.PutUint32(buf[i:i+4], w)
 		}
+	}
 
-		_, err := o.Write(buf[:n])
-		if err != nil {
-			return err
+	if s.Config.Dwords {
+		var w uint64
+		for i := 0; i+8 <= len(buf); i += 8 {
+			w = binary.BigEndian.Uint64(buf[i : i+8])
+			w = SwapUInt(w)
+			binary.BigEndian.PutUint64(buf[i:i+8], w)
 		}
 	}
-	return nil
+}
+
+// Parse converts names of swaps (bits, halfs, bytes, words, dwords) to config,
+// unit is the biggest swapped unit in bytes.
+func Parse(names []string) (cfg config.Swap, unit int, err error) {
+	unit = 1
+	for _, name := range names {
+		switch name {
+		case "bits":
+			cfg.Bits = true
+		case "halfs":
+			cfg.Halfs = true
+		case "bytes":
+			cfg.Bytes, unit = true, max(unit, 2)
+		case "words":
+			cfg.Words, unit = true, max(unit, 4)
+		case "dwords":
+			cfg.Dwords, unit = true, max(unit, 8)
+		default:
+			return cfg, 0, fmt.Errorf("%w: %s", ErrUnknown, name)
+		}
+	}
+	return cfg, unit, nil
 }
 
 func (s Swapper) checkLen(size int64) error {
@@ -0,0 +1,256 @@
+package toelf
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+)
+
+// Region is a part of image, which is loaded at address
+type Region struct {
+	Name  string
+	Addr  uint64
+	Flags elf.ProgFlag
+	Data  []byte
+}
+
+type Symbol struct {
+	Name  string
+	Value uint64
+	Size  uint64
+	Type  elf.SymType
+}
+
+// ELF is an executable file with loadable regions and symbols.
+type ELF struct {
+	Machine   elf.Machine
+	Class     elf.Class
+	ByteOrder binary.ByteOrder
+	Flags     uint32
+	Entry     uint64
+	Regions   []Region
+	Symbols   []Symbol
+}
+
+// writer writes fields of ELF structures, addresses and offsets depend on class
+type writer struct {
+	bytes.Buffer
+	order binary.ByteOrder
+	is64  bool
+}
+
+func (w *writer) u8(v uint8) {
+	w.WriteByte(v)
+}
+
+func (w *writer) u16(v uint16) {
+	binary.Write(w, w.order, v)
+}
+
+func (w *writer) u32(v uint32) {
+	binary.Write(w, w.order, v)
+}
+
+func (w *writer) addr(v uint64) {
+	if w.is64 {
+		binary.Write(w, w.order, v)
+		return
+	}
+	w.u32(uint32(v))
+}
+
+func (w *writer) pad(align int) {
+	for w.Len()%align != 0 {
+		w.WriteByte(0)
+	}
+}
+
+// strtab is a table of null-terminated strings
+type strtab struct {
+	bytes.Buffer
+}
+
+func (s *strtab) add(name string) uint32 {
+	if s.Len() == 0 {
+		s.WriteByte(0)
+	}
+	if name == "" {
+		return 0
+	}
+	off := uint32(s.Len())
+	s.WriteString(name)
+	s.WriteByte(0)
+	return off
+}
+
+type section struct {
+	name    uint32
+	typ     elf.SectionType
+	flags   elf.SectionFlag
+	addr    uint64
+	off     uint64
+	size    uint64
+	link    uint32
+	info    uint32
+	align   uint64
+	entsize uint64
+}
+
+// Bytes encodes ELF with program headers for regions, sections for regions
+// and table of symbols, if it has symbols.
+func (e *ELF) Bytes() ([]byte, error) {
+	is64 := e.Class == elf.ELFCLASS64
+	ehsize, phentsize, shentsize, symsize := 52, 32, 40, 16
+	if is64 {
+		ehsize, phentsize, shentsize, symsize = 64, 56, 64, 24
+	}
+	for _, r := range e.Regions {
+		if !is64 && r.Addr+uint64(len(r.Data)) > 1<<32 {
+			return nil, fmt.Errorf("%w: region %s at 0x%x for 32-bit ELF", ErrRegion, r.Name, r.Addr)
+		}
+	}
+	w := &writer{order: e.ByteOrder, is64: is64}
+	w.Write(make([]byte, ehsize+phentsize*len(e.Regions)))
+
+	var shstr strtab
+	shstr.add("")
+	sections := []section{{}}
+	for _, r := range e.Regions {
+		w.pad(16)
+		flags := elf.SHF_ALLOC
+		if r.Flags&elf.PF_W != 0 {
+			flags |= elf.SHF_WRITE
+		}
+		if r.Flags&elf.PF_X != 0 {
+			flags |= elf.SHF_EXECINSTR
+		}
+		sections = append(sections, section{
+			name:  shstr.add(r.Name),
+			typ:   elf.SHT_PROGBITS,
+			flags: flags,
+			addr:  r.Addr,
+			off:   uint64(w.Len()),
+			size:  uint64(len(r.Data)),
+			align: 1,
+		})
+		w.Write(r.Data)
+	}
+
+	if len(e.Symbols) > 0 {
+		var str strtab
+		str.add("")
+		syms := &writer{order: e.ByteOrder, is64: is64}
+		syms.Write(make([]byte, symsize))
+		for _, s := range e.Symbols {
+			shndx := uint16(elf.SHN_ABS)
+			for i, r := range e.Regions {
+				if s.Value >= r.Addr && s.Value < r.Addr+uint64(len(r.Data)) {
+					shndx = uint16(i + 1)
+				}
+			}
+			name := str.add(s.Name)
+			info := uint8(elf.STB_GLOBAL)<<4 | uint8(s.Type)
+			if is64 {
+				syms.u32(name)
+				syms.u8(info)
+				syms.u8(0)
+				syms.u16(shndx)
+				syms.addr(s.Value)
+				syms.addr(s.Size)
+			} else {
+				syms.u32(name)
+				syms.addr(s.Value)
+				syms.u32(uint32(s.Size))
+				syms.u8(info)
+				syms.u8(0)
+				syms.u16(shndx)
+			}
+		}
+		w.pad(8)
+		sections = append(sections, section{
+			name:    shstr.add(".symtab"),
+			typ:     elf.SHT_SYMTAB,
+			off:     uint64(w.Len()),
+			size:    uint64(syms.Len()),
+			link:    uint32(len(sections) + 1),
+			info:    1, // index of the first global symbol
+			align:   8,
+			entsize: uint64(symsize),
+		})
+		w.Write(syms.Bytes())
+		sections = append(sections, section{
+			name:  shstr.add(".strtab"),
+			typ:   elf.SHT_STRTAB,
+			off:   uint64(w.Len()),
+			size:  uint64(str.Len()),
+			align: 1,
+		})
+		w.Write(str.Bytes())
+	}
+	shstrndx := len(sections)
+	name := shstr.add(".shstrtab")
+	sections = append(sections, section{
+		name:  name,
+		typ:   elf.SHT_STRTAB,
+		off:   uint64(w.Len()),
+		size:  uint64(shstr.Len()),
+		align: 1,
+	})
+	w.Write(shstr.Bytes())
+
+	w.pad(8)
+	shoff := w.Len()
+	for _, s := range sections {
+		w.u32(s.name)
+		w.u32(uint32(s.typ))
+		w.addr(uint64(s.flags))
+		w.addr(s.addr)
+		w.addr(s.off)
+		w.addr(s.size)
+		w.u32(s.link)
+		w.u32(s.info)
+		w.addr(s.align)
+		w.addr(s.entsize)
+	}
+
+	h := &writer{order: e.ByteOrder, is64: is64}
+	data := elf.ELFDATA2LSB
+	if e.ByteOrder == binary.BigEndian {
+		data = elf.ELFDATA2MSB
+	}
+	h.Write([]byte{0x7f, 'E', 'L', 'F', byte(e.Class), byte(data), byte(elf.EV_CURRENT)})
+	h.Write(make([]byte, elf.EI_NIDENT-h.Len()))
+	h.u16(uint16(elf.ET_EXEC))
+	h.u16(uint16(e.Machine))
+	h.u32(uint32(elf.EV_CURRENT))
+	h.addr(e.Entry)
+	h.addr(uint64(ehsize))
+	h.addr(uint64(shoff))
+	h.u32(e.Flags)
+	h.u16(uint16(ehsize))
+	h.u16(uint16(phentsize))
+	h.u16(uint16(len(e.Regions)))
+	h.u16(uint16(shentsize))
+	h.u16(uint16(len(sections)))
+	h.u16(uint16(shstrndx))
+	for i, r := range e.Regions {
+		s := sections[i+1]
+		h.u32(uint32(elf.PT_LOAD))
+		if is64 {
+			h.u32(uint32(r.Flags))
+		}
+		h.addr(s.off)
+		h.addr(r.Addr)
+		h.addr(r.Addr)
+		h.addr(s.size)
+		h.addr(s.size)
+		if !is64 {
+			h.u32(uint32(r.Flags))
+		}
+		h.addr(1)
+	}
+	out := w.Bytes()
+	copy(out, h.Bytes())
+	return out, nil
+}
@@ -0,0 +1,252 @@
+package toelf
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrRegion = errors.New("invalid region")
+var ErrMachine = errors.New("unknown machine")
+var ErrSymbol = errors.New("invalid symbol")
+
+var machines = map[string]elf.Machine{
+	"arm":     elf.EM_ARM,
+	"aarch64": elf.EM_AARCH64,
+	"arm64":   elf.EM_AARCH64,
+	"mips":    elf.EM_MIPS,
+	"x86":     elf.EM_386,
+	"386":     elf.EM_386,
+	"x86_64":  elf.EM_X86_64,
+	"amd64":   elf.EM_X86_64,
+	"riscv":   elf.EM_RISCV,
+	"ppc":     elf.EM_PPC,
+	"powerpc": elf.EM_PPC,
+	"ppc64":   elf.EM_PPC64,
+	"sh":      elf.EM_SH,
+	"sparc":   elf.EM_SPARC,
+	"m68k":    elf.EM_68K,
+	"avr":     elf.EM_AVR,
+	"msp430":  elf.EM_MSP430,
+	"xtensa":  elf.EM_XTENSA,
+	"arc":     elf.EM_ARC,
+	"tricore": elf.EM_TRICORE,
+	"8051":    elf.EM_8051,
+}
+
+// EABI version 5 of ARM, disassemblers expect it
+const armEABI5 = 0x05000000
+
+// Machine returns machine by name or number.
+func Machine(name string) (elf.Machine, error) {
+	if m, ok := machines[strings.ToLower(name)]; ok {
+		return m, nil
+	}
+	n, err := strconv.ParseUint(name, 0, 16)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrMachine, name)
+	}
+	return elf.Machine(n), nil
+}
+
+// ParseRegion decodes region of image as <address>[:<start>-[<end>]][:<flags>],
+// empty end is end of image, flags are r, w and x, all by default.
+func ParseRegion(s string, image []byte) (Region, error) {
+	r := Region{Flags: elf.PF_R | elf.PF_W | elf.PF_X}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return r, fmt.Errorf("%w: %s", ErrRegion, s)
+	}
+	addr, err := strconv.ParseUint(parts[0], 0, 64)
+	if err != nil {
+		return r, fmt.Errorf("%w: address of '%s'", ErrRegion, s)
+	}
+	r.Addr = addr
+	start, end := int64(0), int64(len(image))
+	for _, p := range parts[1:] {
+		if from, to, ok := strings.Cut(p, "-"); ok {
+			if start, err = strconv.ParseInt(from, 0, 64); err != nil {
+				return r, fmt.Errorf("%w: start of '%s'", ErrRegion, s)
+			}
+			if to != "" {
+				if end, err = strconv.ParseInt(to, 0, 64); err != nil {
+					return r, fmt.Errorf("%w: end of '%s'", ErrRegion, s)
+				}
+			}
+			continue
+		}
+		r.Flags = 0
+		for _, c := range p {
+			switch c {
+			case 'r':
+				r.Flags |= elf.PF_R
+			case 'w':
+				r.Flags |= elf.PF_W
+			case 'x':
+				r.Flags |= elf.PF_X
+			default:
+				return r, fmt.Errorf("%w: flags of '%s'", ErrRegion, s)
+			}
+		}
+	}
+	if start < 0 || start >= end || end > int64(len(image)) {
+		return r, fmt.Errorf("%w: range 0x%x-0x%x of '%s' is out of image", ErrRegion, start, end, s)
+	}
+	r.Data = image[start:end]
+	return r, nil
+}
+
+// ParseSymbols decodes CSV with lines <name>,<address>[,<size>[,<type>]],
+// type is func, object or empty, # starts comment. The first line can be a header.
+func ParseSymbols(r io.Reader) ([]Symbol, error) {
+	c := csv.NewReader(r)
+	c.Comment = '#'
+	c.FieldsPerRecord = -1
+	c.TrimLeadingSpace = true
+	records, err := c.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSymbol, err)
+	}
+	var syms []Symbol
+	for i, rec := range records {
+		if len(rec) < 2 || len(rec) > 4 {
+			return nil, fmt.Errorf("%w: line %d", ErrSymbol, i+1)
+		}
+		s := Symbol{Name: strings.TrimSpace(rec[0])}
+		s.Value, err = strconv.ParseUint(strings.TrimSpace(rec[1]), 0, 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("%w: address at line %d", ErrSymbol, i+1)
+		}
+		if len(rec) > 2 && strings.TrimSpace(rec[2]) != "" {
+			if s.Size, err = strconv.ParseUint(strings.TrimSpace(rec[2]), 0, 64); err != nil {
+				return nil, fmt.Errorf("%w: size at line %d", ErrSymbol, i+1)
+			}
+		}
+		if len(rec) > 3 {
+			switch strings.ToLower(strings.TrimSpace(rec[3])) {
+			case "func", "function":
+				s.Type = elf.STT_FUNC
+			case "object", "data":
+				s.Type = elf.STT_OBJECT
+			case "":
+			default:
+				return nil, fmt.Errorf("%w: type at line %d", ErrSymbol, i+1)
+			}
+		}
+		syms = append(syms, s)
+	}
+	return syms, nil
+}
+
+type Builder struct {
+	elf    ELF
+	out    io.Writer
+	Config config.ToELF
+}
+
+func New(cfg config.ToELF) *Builder {
+	return &Builder{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (b *Builder) Open(input string) error {
+	image, err := os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for ELF: %w", input, err)
+	}
+	e := &b.elf
+	if e.Machine, err = Machine(b.Config.Machine); err != nil {
+		return err
+	}
+	switch b.Config.Class {
+	case 0:
+		e.Class = elf.ELFCLASS32
+		switch e.Machine {
+		case elf.EM_AARCH64, elf.EM_X86_64, elf.EM_PPC64:
+			e.Class = elf.ELFCLASS64
+		}
+	case 32:
+		e.Class = elf.ELFCLASS32
+	case 64:
+		e.Class = elf.ELFCLASS64
+	default:
+		return fmt.Errorf("invalid class %d, should be 32 or 64", b.Config.Class)
+	}
+	e.ByteOrder = binary.LittleEndian
+	if b.Config.BigEndian {
+		e.ByteOrder = binary.BigEndian
+	}
+	if e.Machine == elf.EM_ARM {
+		e.Flags = armEABI5
+	}
+	regions := b.Config.Regions
+	if len(regions) == 0 {
+		regions = []string{fmt.Sprintf("0x%x", b.Config.Base)}
+	}
+	for i, s := range regions {
+		r, err := ParseRegion(s, image)
+		if err != nil {
+			return err
+		}
+		r.Name = fmt.Sprintf(".region%d", i)
+		for _, o := range e.Regions {
+			if r.Addr < o.Addr+uint64(len(o.Data)) && o.Addr < r.Addr+uint64(len(r.Data)) {
+				return fmt.Errorf("%w: '%s' overlaps %s", ErrRegion, s, o.Name)
+			}
+		}
+		e.Regions = append(e.Regions, r)
+	}
+	e.Entry = e.Regions[0].Addr
+	if b.Config.Entry >= 0 {
+		e.Entry = uint64(b.Config.Entry)
+	}
+	if b.Config.Symbols != "" {
+		data, err := os.ReadFile(b.Config.Symbols)
+		if err != nil {
+			return fmt.Errorf("can't open symbols '%s': %w", b.Config.Symbols, err)
+		}
+		if e.Symbols, err = ParseSymbols(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("%s: %w", b.Config.Symbols, err)
+		}
+	}
+	if b.Config.Output == "" {
+		b.Config.Output = strings.TrimSuffix(input, ".bin") + ".elf"
+	}
+	return nil
+}
+
+func (b *Builder) Close() error {
+	return nil
+}
+
+func (b *Builder) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+	data, err := b.elf.Bytes()
+	if err != nil {
+		return err
+	}
+	for _, r := range b.elf.Regions {
+		fmt.Fprintf(b.out, "%s 0x%08x-0x%08x %s\n", r.Name, r.Addr, r.Addr+uint64(len(r.Data)), r.Flags)
+	}
+	fmt.Fprintf(b.out, "%s %s, entry 0x%x, %d symbols\n", b.elf.Machine, b.elf.Class, b.elf.Entry, len(b.elf.Symbols))
+	return os.WriteFile(b.Config.Output, data, 0666)
+}
@@ -0,0 +1,123 @@
+package toelf
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func TestParseRegion(t *testing.T) {
+	image := make([]byte, 0x100)
+	tests := []struct {
+		s    string
+		want Region
+		err  error
+	}{
+		{"0x8000000", Region{Addr: 0x8000000, Flags: elf.PF_R | elf.PF_W | elf.PF_X, Data: image}, nil},
+		{"0x20000000:0x80-:rw", Region{Addr: 0x20000000, Flags: elf.PF_R | elf.PF_W, Data: image[0x80:]}, nil},
+		{"0x0:0x10-0x20", Region{Addr: 0, Flags: elf.PF_R | elf.PF_W | elf.PF_X, Data: image[0x10:0x20]}, nil},
+		{"0x0:0x10-0x200", Region{}, ErrRegion},
+		{"0x0:rz", Region{}, ErrRegion},
+		{"base", Region{}, ErrRegion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			r, err := ParseRegion(tt.s, image)
+			require.ErrorIs(t, err, tt.err)
+			if tt.err == nil {
+				require.Equal(t, tt.want, r)
+			}
+		})
+	}
+}
+
+func TestParseSymbols(t *testing.T) {
+	syms, err := ParseSymbols(strings.NewReader("name,address,size,type\n# vectors\nreset, 0x8000101, 0x20, func\nstack,0x20001000\nconfig,0x8000400,16,object\n"))
+	require.NoError(t, err)
+	require.Equal(t, []Symbol{
+		{"reset", 0x8000101, 0x20, elf.STT_FUNC},
+		{"stack", 0x20001000, 0, elf.STT_NOTYPE},
+		{"config", 0x8000400, 16, elf.STT_OBJECT},
+	}, syms)
+
+	_, err = ParseSymbols(strings.NewReader("reset,0x0\nmain,main\n"))
+	require.ErrorIs(t, err, ErrSymbol)
+}
+
+func TestELF_Bytes(t *testing.T) {
+	for _, class := range []elf.Class{elf.ELFCLASS32, elf.ELFCLASS64} {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			t.Run(class.String()+" "+order.String(), func(t *testing.T) {
+				e := ELF{
+					Machine:   elf.EM_MIPS,
+					Class:     class,
+					ByteOrder: order,
+					Entry:     0x80000100,
+					Regions: []Region{
+						{".region0", 0x80000000, elf.PF_R | elf.PF_X, bytes.Repeat([]byte{0x11}, 0x203)},
+						{".region1", 0x80010000, elf.PF_R | elf.PF_W, []byte("data")},
+					},
+					Symbols: []Symbol{{"start", 0x80000100, 8, elf.STT_FUNC}, {"uart", 0xb8000000, 0, elf.STT_NOTYPE}},
+				}
+				data, err := e.Bytes()
+				require.NoError(t, err)
+				f, err := elf.NewFile(bytes.NewReader(data))
+				require.NoError(t, err)
+				require.Equal(t, elf.EM_MIPS, f.Machine)
+				require.Equal(t, class, f.Class)
+				require.Equal(t, order, f.ByteOrder)
+				require.Equal(t, uint64(0x80000100), f.Entry)
+				require.Len(t, f.Progs, 2)
+				for i, p := range f.Progs {
+					require.Equal(t, elf.PT_LOAD, p.Type)
+					require.Equal(t, e.Regions[i].Addr, p.Vaddr)
+					require.Equal(t, e.Regions[i].Flags, p.Flags)
+					b := make([]byte, p.Filesz)
+					_, err := p.ReadAt(b, 0)
+					require.NoError(t, err)
+					require.Equal(t, e.Regions[i].Data, b)
+				}
+				s := f.Section(".region1")
+				require.NotNil(t, s)
+				require.Equal(t, elf.SHF_ALLOC|elf.SHF_WRITE, s.Flags)
+				syms, err := f.Symbols()
+				require.NoError(t, err)
+				require.Len(t, syms, 2)
+				require.Equal(t, "start", syms[0].Name)
+				require.Equal(t, elf.SectionIndex(1), syms[0].Section)
+				require.Equal(t, elf.STT_FUNC, elf.ST_TYPE(syms[0].Info))
+				require.Equal(t, elf.SHN_ABS, syms[1].Section)
+			})
+		}
+	}
+}
+
+func TestBuilder_Run(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "boot.bin")
+	require.NoError(t, os.WriteFile(input, make([]byte, 0x100), 0o644))
+
+	b := New(config.ToELF{Machine: "arm", Base: 0x8000000, Entry: -1})
+	b.out = &bytes.Buffer{}
+	require.NoError(t, b.Open(input))
+	require.NoError(t, b.Run(context.TODO()))
+	f, err := elf.Open(filepath.Join(dir, "boot.elf"))
+	require.NoError(t, err)
+	defer f.Close()
+	require.Equal(t, elf.EM_ARM, f.Machine)
+	require.Equal(t, uint64(0x8000000), f.Entry)
+
+	b = New(config.ToELF{Machine: "arm", Regions: []string{"0x0:0x0-0x80", "0x40:0x80-"}, Entry: -1})
+	require.ErrorIs(t, b.Open(input), ErrRegion)
+	b = New(config.ToELF{Machine: "vax", Entry: -1})
+	require.ErrorIs(t, b.Open(input), ErrMachine)
+}
@@ -0,0 +1,331 @@
+package uimage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrMagic = errors.New("invalid magic of uImage")
+var ErrHeaderCRC = errors.New("invalid header CRC")
+var ErrDataCRC = errors.New("invalid data CRC")
+var ErrSize = errors.New("invalid size of uImage")
+var ErrUnknown = errors.New("unknown value")
+
+const (
+	Magic      = 0x27051956
+	HeaderSize = 64
+	nameSize   = 32
+)
+
+var OSNames = []string{
+	"invalid", "openbsd", "netbsd", "freebsd", "4_4bsd", "linux", "svr4", "esix",
+	"solaris", "irix", "sco", "dell", "ncr", "lynxos", "vxworks", "psos", "qnx",
+	"u-boot", "rtems", "artos", "unity", "integrity", "ose", "plan9", "openrtos",
+	"arm-trusted-firmware", "tee", "opensbi", "efi",
+}
+
+var ArchNames = []string{
+	"invalid", "alpha", "arm", "x86", "ia64", "mips", "mips64", "powerpc", "s390",
+	"sh", "sparc", "sparc64", "m68k", "nios", "microblaze", "nios2", "blackfin",
+	"avr32", "st200", "sandbox", "nds32", "or1k", "arm64", "arc", "x86_64",
+	"xtensa", "riscv",
+}
+
+var TypeNames = []string{
+	"invalid", "standalone", "kernel", "ramdisk", "multi", "firmware", "script",
+	"filesystem", "flat_dt", "kwbimage", "imximage", "ubl", "omapimage",
+	"aisimage", "kernel_noload", "pblimage", "mxsimage", "gpimage", "atmelimage",
+}
+
+var CompNames = []string{"none", "gzip", "bzip2", "lzma", "lzo", "lz4", "zstd"}
+
+const TypeMulti = 4
+
+type Header struct {
+	Magic     uint32
+	HeaderCRC uint32
+	Time      uint32
+	Size      uint32
+	Load      uint32
+	Entry     uint32
+	DataCRC   uint32
+	OS        uint8
+	Arch      uint8
+	Type      uint8
+	Comp      uint8
+	Name      [nameSize]byte
+}
+
+func name(names []string, v uint8) string {
+	if int(v) < len(names) {
+		return names[v]
+	}
+	return fmt.Sprint(v)
+}
+
+func OSName(v uint8) string   { return name(OSNames, v) }
+func ArchName(v uint8) string { return name(ArchNames, v) }
+func TypeName(v uint8) string { return name(TypeNames, v) }
+func CompName(v uint8) string { return name(CompNames, v) }
+
+func lookup(names []string, s string) (uint8, error) {
+	for i, n := range names {
+		if n == s {
+			return uint8(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s", ErrUnknown, s)
+}
+
+func (h *Header) ImageName() string {
+	n, _, _ := bytes.Cut(h.Name[:], []byte{0})
+	return string(n)
+}
+
+func (h *Header) String() string {
+	return fmt.Sprintf(`Image Name:   %s
+Created:      %s
+Image Type:   %s %s %s (%s)
+Data Size:    %d Bytes
+Load Address: 0x%08x
+Entry Point:  0x%08x`,
+		h.ImageName(),
+		time.Unix(int64(h.Time), 0).UTC().Format(time.DateTime),
+		ArchName(h.Arch), OSName(h.OS), TypeName(h.Type), CompName(h.Comp),
+		h.Size, h.Load, h.Entry)
+}
+
+func (h *Header) Bytes() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, HeaderSize))
+	binary.Write(buf, binary.BigEndian, h)
+	return buf.Bytes()
+}
+
+// Parse decodes header at beginning of b and checks its CRC.
+func Parse(b []byte) (*Header, error) {
+	if len(b) < HeaderSize {
+		return nil, ErrSize
+	}
+	h := &Header{}
+	binary.Read(bytes.NewReader(b), binary.BigEndian, h)
+	if h.Magic != Magic {
+		return nil, ErrMagic
+	}
+	crc := h.HeaderCRC
+	h.HeaderCRC = 0
+	if crc32.ChecksumIEEE(h.Bytes()) != crc {
+		h.HeaderCRC = crc
+		return h, ErrHeaderCRC
+	}
+	h.HeaderCRC = crc
+	return h, nil
+}
+
+// Verify checks CRC of data, which follows header.
+func (h *Header) Verify(data []byte) error {
+	if uint32(len(data)) < h.Size {
+		return ErrSize
+	}
+	if crc32.ChecksumIEEE(data[:h.Size]) != h.DataCRC {
+		return ErrDataCRC
+	}
+	return nil
+}
+
+// Images splits data of multi-file image, data of others is a single image.
+func (h *Header) Images(data []byte) ([][]byte, error) {
+	data = data[:h.Size]
+	if h.Type != TypeMulti {
+		return [][]byte{data}, nil
+	}
+	var sizes []uint32
+	pos := 0
+	for {
+		if pos+4 > len(data) {
+			return nil, fmt.Errorf("%w: list of images", ErrSize)
+		}
+		s := binary.BigEndian.Uint32(data[pos:])
+		pos += 4
+		if s == 0 {
+			break
+		}
+		sizes = append(sizes, s)
+	}
+	images := make([][]byte, 0, len(sizes))
+	for _, s := range sizes {
+		if pos+int(s) > len(data) {
+			return nil, fmt.Errorf("%w: image %d", ErrSize, len(images))
+		}
+		images = append(images, data[pos:pos+int(s)])
+		// every image is aligned on 4 bytes
+		pos += (int(s) + 3) &^ 3
+	}
+	return images, nil
+}
+
+// Build creates image around payloads, many payloads make multi-file image.
+func Build(h Header, payloads ...[]byte) []byte {
+	var data []byte
+	if len(payloads) == 1 && h.Type != TypeMulti {
+		data = payloads[0]
+	} else {
+		h.Type = TypeMulti
+		buf := &bytes.Buffer{}
+		for _, p := range payloads {
+			binary.Write(buf, binary.BigEndian, uint32(len(p)))
+		}
+		binary.Write(buf, binary.BigEndian, uint32(0))
+		for _, p := range payloads {
+			buf.Write(p)
+			buf.Write(make([]byte, (4-len(p)%4)%4))
+		}
+		data = buf.Bytes()
+	}
+	h.Magic = Magic
+	h.Size = uint32(len(data))
+	h.DataCRC = crc32.ChecksumIEEE(data)
+	h.HeaderCRC = 0
+	h.HeaderCRC = crc32.ChecksumIEEE(h.Bytes())
+	return append(h.Bytes(), data...)
+}
+
+type Tool struct {
+	inputs []string
+	out    io.Writer
+	Config config.UImage
+}
+
+func New(cfg config.UImage) *Tool {
+	return &Tool{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (t *Tool) Open(inputs []string) error {
+	for _, in := range inputs {
+		if _, err := os.Stat(in); err != nil {
+			return fmt.Errorf("can't open file '%s': %w", in, err)
+		}
+	}
+	t.inputs = inputs
+	return nil
+}
+
+func (t *Tool) Close() error {
+	return nil
+}
+
+func (t *Tool) Run(ctx context.Context) error {
+	if t.Config.Create {
+		return t.create()
+	}
+	var errs error
+	for _, in := range t.inputs {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if err := t.info(in); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", in, err))
+		}
+	}
+	return errs
+}
+
+func (t *Tool) info(input string) error {
+	b, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	h, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(t.out, h)
+	data := b[HeaderSize:]
+	if err := h.Verify(data); err != nil {
+		return err
+	}
+	fmt.Fprintln(t.out, "Verifying Checksum ... OK")
+	images, err := h.Images(data)
+	if err != nil {
+		return err
+	}
+	if h.Type == TypeMulti {
+		fmt.Fprintln(t.out, "Contents:")
+		for i, img := range images {
+			fmt.Fprintf(t.out, "   Image %d: %d Bytes\n", i, len(img))
+		}
+	}
+	if !t.Config.Extract {
+		return nil
+	}
+	base := strings.TrimSuffix(input, filepath.Ext(input))
+	if t.Config.Output != "" {
+		base = t.Config.Output
+	}
+	for i, img := range images {
+		name := base + ".payload"
+		if h.Type == TypeMulti {
+			name = fmt.Sprintf("%s-%d.payload", base, i)
+		}
+		if err := os.WriteFile(name, img, 0666); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tool) create() error {
+	h := Header{
+		Time:  uint32(time.Now().Unix()),
+		Load:  t.Config.Load,
+		Entry: t.Config.Entry,
+	}
+	if len(t.Config.Name) > nameSize {
+		return fmt.Errorf("%w: name is longer than %d", ErrSize, nameSize)
+	}
+	copy(h.Name[:], t.Config.Name)
+	var err error
+	if h.OS, err = lookup(OSNames, t.Config.OS); err != nil {
+		return err
+	}
+	if h.Arch, err = lookup(ArchNames, t.Config.Arch); err != nil {
+		return err
+	}
+	if h.Type, err = lookup(TypeNames, t.Config.Type); err != nil {
+		return err
+	}
+	if h.Comp, err = lookup(CompNames, t.Config.Comp); err != nil {
+		return err
+	}
+	payloads := make([][]byte, 0, len(t.inputs))
+	for _, in := range t.inputs {
+		p, err := os.ReadFile(in)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, p)
+	}
+	output := t.Config.Output
+	if output == "" {
+		output = "uImage"
+	}
+	img := Build(h, payloads...)
+	h2, _ := Parse(img)
+	fmt.Fprintln(t.out, h2)
+	return os.WriteFile(output, img, 0666)
+}
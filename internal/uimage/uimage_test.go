@@ -0,0 +1,113 @@
+package uimage
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+func TestBuildParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   Header
+		payloads [][]byte
+		want     [][]byte
+	}{
+		{
+			"Kernel",
+			Header{Load: 0x80008000, Entry: 0x80008000, OS: 5, Arch: 2, Type: 2},
+			[][]byte{[]byte("kernel")},
+			[][]byte{[]byte("kernel")},
+		},
+		{
+			"Multi-file",
+			Header{OS: 5, Arch: 2},
+			[][]byte{[]byte("kernel"), []byte("ramdisk!"), []byte("dtb")},
+			[][]byte{[]byte("kernel"), []byte("ramdisk!"), []byte("dtb")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := Build(tt.header, tt.payloads...)
+			h, err := Parse(img)
+			require.NoError(t, err)
+			require.NoError(t, h.Verify(img[HeaderSize:]))
+			images, err := h.Images(img[HeaderSize:])
+			require.NoError(t, err)
+			require.Equal(t, tt.want, images)
+			require.Equal(t, tt.header.Load, h.Load)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	img := Build(Header{Type: 2}, []byte("data"))
+	_, err := Parse(img[:10])
+	require.ErrorIs(t, err, ErrSize)
+
+	bad := bytes.Clone(img)
+	bad[0] = 0
+	_, err = Parse(bad)
+	require.ErrorIs(t, err, ErrMagic)
+
+	bad = bytes.Clone(img)
+	bad[40] ^= 1
+	_, err = Parse(bad)
+	require.ErrorIs(t, err, ErrHeaderCRC)
+
+	bad = bytes.Clone(img)
+	bad[HeaderSize] ^= 1
+	h, err := Parse(bad)
+	require.NoError(t, err)
+	require.ErrorIs(t, h.Verify(bad[HeaderSize:]), ErrDataCRC)
+}
+
+func TestTool_Run(t *testing.T) {
+	dir := t.TempDir()
+	kernel := filepath.Join(dir, "zImage")
+	dtb := filepath.Join(dir, "board.dtb")
+	require.NoError(t, os.WriteFile(kernel, []byte("kernel"), 0666))
+	require.NoError(t, os.WriteFile(dtb, []byte("dtb"), 0666))
+	output := filepath.Join(dir, "uImage")
+
+	create := New(config.UImage{
+		Create: true,
+		Output: output,
+		Name:   "Linux",
+		OS:     "linux",
+		Arch:   "arm",
+		Type:   "multi",
+		Comp:   "none",
+		Load:   0x8000,
+		Entry:  0x8000,
+	})
+	create.out = &bytes.Buffer{}
+	require.NoError(t, create.Open([]string{kernel, dtb}))
+	require.NoError(t, create.Run(context.TODO()))
+
+	out := &bytes.Buffer{}
+	extract := New(config.UImage{Extract: true})
+	extract.out = out
+	require.NoError(t, extract.Open([]string{output}))
+	require.NoError(t, extract.Run(context.TODO()))
+	require.Contains(t, out.String(), "Image Name:   Linux\n")
+	require.Contains(t, out.String(), "Image Type:   arm linux multi (none)\n")
+	require.Contains(t, out.String(), "Verifying Checksum ... OK\n")
+	require.Contains(t, out.String(), "   Image 1: 3 Bytes\n")
+	data, err := os.ReadFile(output + "-0.payload")
+	require.NoError(t, err)
+	require.Equal(t, []byte("kernel"), data)
+	data, err = os.ReadFile(output + "-1.payload")
+	require.NoError(t, err)
+	require.Equal(t, []byte("dtb"), data)
+
+	bad := New(config.UImage{Create: true, Output: output, OS: "linux", Arch: "z80", Type: "kernel", Comp: "none"})
+	require.NoError(t, bad.Open([]string{kernel}))
+	require.ErrorIs(t, bad.Run(context.TODO()), ErrUnknown)
+}
@@ -0,0 +1,144 @@
+package unpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrLZ4 = errors.New("invalid LZ4")
+
+const (
+	lz4Magic = 0x184d2204
+	// window of linked blocks
+	lz4Window = 64 << 10
+)
+
+// decodeLZ4 decodes LZ4 frame, checksums are skipped.
+func decodeLZ4(r *bytes.Reader, w io.Writer) error {
+	var hdr [6]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return err
+	}
+	flg, bd := hdr[4], hdr[5]
+	if binary.LittleEndian.Uint32(hdr[:]) != lz4Magic || flg>>6 != 1 || bd>>4&7 < 4 {
+		return fmt.Errorf("%w: frame header", ErrLZ4)
+	}
+	if flg&0x01 != 0 {
+		return fmt.Errorf("%w: dictionary isn't supported", ErrLZ4)
+	}
+	maxBlock := 1 << (8 + 2*(bd>>4&7))
+	skip := 1 // checksum of header
+	if flg&0x08 != 0 {
+		skip += 8
+	}
+	if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+		return err
+	}
+	var history []byte
+	buf := make([]byte, maxBlock)
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.LittleEndian, &size); err != nil {
+			return err
+		}
+		if size == 0 {
+			break
+		}
+		raw := size&(1<<31) != 0
+		size &^= 1 << 31
+		if int(size) > maxBlock {
+			return fmt.Errorf("%w: block size 0x%x", ErrLZ4, size)
+		}
+		block := buf[:size]
+		if _, err := io.ReadFull(r, block); err != nil {
+			return err
+		}
+		if flg&0x10 != 0 {
+			if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+				return err
+			}
+		}
+		start := len(history)
+		out := history
+		if raw {
+			out = append(out, block...)
+		} else {
+			var err error
+			if out, err = lz4Block(block, out); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(out[start:]); err != nil {
+			return err
+		}
+		// independent blocks don't refer to previous ones
+		history = out[:0]
+		if flg&0x20 == 0 {
+			history = append(history, out[max(0, len(out)-lz4Window):]...)
+		}
+	}
+	if flg&0x04 != 0 {
+		if _, err := io.CopyN(io.Discard, r, 4); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lz4Block decodes block and appends it to dst, dst has previous data for matches.
+func lz4Block(src, dst []byte) ([]byte, error) {
+	length := func(i *int, n int) (int, error) {
+		if n != 15 {
+			return n, nil
+		}
+		for {
+			if *i >= len(src) {
+				return 0, fmt.Errorf("%w: length is out of block", ErrLZ4)
+			}
+			b := src[*i]
+			*i++
+			n += int(b)
+			if b != 255 {
+				return n, nil
+			}
+		}
+	}
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+		lit, err := length(&i, int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		if i+lit > len(src) {
+			return nil, fmt.Errorf("%w: literals are out of block", ErrLZ4)
+		}
+		dst = append(dst, src[i:i+lit]...)
+		i += lit
+		// the last sequence has literals only
+		if i == len(src) {
+			break
+		}
+		if i+2 > len(src) {
+			return nil, fmt.Errorf("%w: offset is out of block", ErrLZ4)
+		}
+		off := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if off == 0 || off > len(dst) {
+			return nil, fmt.Errorf("%w: offset 0x%x", ErrLZ4, off)
+		}
+		match, err := length(&i, int(token&15))
+		if err != nil {
+			return nil, err
+		}
+		// match can overlap copied bytes
+		pos := len(dst) - off
+		for k := 0; k < match+4; k++ {
+			dst = append(dst, dst[pos+k])
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,264 @@
+package unpack
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+	"github.com/Nexadis/fw-tools/internal/scan"
+)
+
+var ErrUnsupported = errors.New("unsupported format")
+var ErrFormat = errors.New("unknown format")
+
+// Decoder decompresses stream from r to w, r is positioned after the stream on success.
+type Decoder func(r *bytes.Reader, w io.Writer) error
+
+// Decoders are supported formats by names of scan signatures,
+// raw deflate has no signature and it's tried at every offset only.
+var Decoders = map[string]Decoder{
+	"gzip": func(r *bytes.Reader, w io.Writer) error {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		zr.Multistream(false)
+		return copyClose(w, zr)
+	},
+	"zlib": func(r *bytes.Reader, w io.Writer) error {
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return err
+		}
+		return copyClose(w, zr)
+	},
+	"bzip2": func(r *bytes.Reader, w io.Writer) error {
+		_, err := io.Copy(w, bzip2.NewReader(r))
+		// data after the stream isn't the next stream
+		var serr bzip2.StructuralError
+		if errors.As(err, &serr) && serr == "bad magic value in continuation file" {
+			_, err = r.Seek(-2, io.SeekCurrent)
+		}
+		return err
+	},
+	"LZ4": decodeLZ4,
+	"deflate": func(r *bytes.Reader, w io.Writer) error {
+		return copyClose(w, flate.NewReader(r))
+	},
+}
+
+func copyClose(w io.Writer, rc io.ReadCloser) error {
+	_, err := io.Copy(w, rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Unsupported are compressed formats, which are found by scan, but can't be decoded
+var Unsupported = []string{"LZMA", "xz", "zstd"}
+
+// order of formats for every offset
+var formats = []string{"gzip", "zlib", "bzip2", "LZ4", "deflate"}
+
+type Stream struct {
+	Offset   int64
+	Format   string
+	Consumed int64
+	Produced int64
+	Err      error
+}
+
+func (s Stream) String() string {
+	if s.Err != nil {
+		return fmt.Sprintf("0x%08x %-7s %s", s.Offset, s.Format, s.Err)
+	}
+	return fmt.Sprintf("0x%08x %-7s consumed 0x%x produced 0x%x", s.Offset, s.Format, s.Consumed, s.Produced)
+}
+
+// Name is a name of file with decompressed stream
+func (s Stream) Name() string {
+	return fmt.Sprintf("0x%08x-%s.bin", s.Offset, s.Format)
+}
+
+type counter int64
+
+func (c *counter) Write(b []byte) (int, error) {
+	*c += counter(len(b))
+	return len(b), nil
+}
+
+// Decode decompresses stream at offset of data in format to w.
+func Decode(data []byte, off int64, format string, w io.Writer) Stream {
+	s := Stream{Offset: off, Format: format}
+	if slices.Contains(Unsupported, format) {
+		s.Err = fmt.Errorf("%w: %s", ErrUnsupported, format)
+		return s
+	}
+	decode, ok := Decoders[format]
+	if !ok {
+		s.Err = fmt.Errorf("%w: %s", ErrFormat, format)
+		return s
+	}
+	r := bytes.NewReader(data[off:])
+	var c counter
+	s.Err = decode(r, io.MultiWriter(w, &c))
+	s.Consumed = r.Size() - int64(r.Len())
+	s.Produced = int64(c)
+	return s
+}
+
+type Unpacker struct {
+	data   []byte
+	out    io.Writer
+	Config config.Unpack
+}
+
+func New(cfg config.Unpack) *Unpacker {
+	return &Unpacker{
+		Config: cfg,
+		out:    os.Stdout,
+	}
+}
+
+func (u *Unpacker) Open(input string) error {
+	for _, f := range u.Config.Formats {
+		if _, ok := Decoders[f]; !ok && !slices.Contains(Unsupported, f) {
+			return fmt.Errorf("%w: %s", ErrFormat, f)
+		}
+	}
+	if len(u.Config.Formats) == 0 {
+		u.Config.Formats = append(slices.Clone(formats), Unsupported...)
+	}
+	if u.Config.Every && u.Config.Step <= 0 {
+		return fmt.Errorf("invalid step %d", u.Config.Step)
+	}
+	var err error
+	u.data, err = os.ReadFile(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for unpacking: %w", input, err)
+	}
+	if u.Config.Output == "" {
+		name := filepath.Base(input)
+		u.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-unpacked"
+	}
+	return nil
+}
+
+func (u *Unpacker) Close() error {
+	return nil
+}
+
+func (u *Unpacker) Run(ctx context.Context) error {
+	var streams []Stream
+	var err error
+	if u.Config.Every {
+		streams, err = u.Every(ctx)
+	} else {
+		streams, err = u.Scan(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range streams {
+		fmt.Fprintln(u.out, s)
+	}
+	if u.Config.List {
+		return nil
+	}
+	if err := os.MkdirAll(u.Config.Output, 0o755); err != nil {
+		return err
+	}
+	for _, s := range streams {
+		if s.Err != nil {
+			continue
+		}
+		if err := u.write(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *Unpacker) write(s Stream) error {
+	f, err := os.Create(filepath.Join(u.Config.Output, s.Name()))
+	if err != nil {
+		return err
+	}
+	if s = Decode(u.data, s.Offset, s.Format, f); s.Err != nil {
+		return errors.Join(s.Err, f.Close())
+	}
+	return f.Close()
+}
+
+// Scan decodes streams at offsets of compression signatures, hits inside
+// of decoded streams are skipped.
+func (u *Unpacker) Scan(ctx context.Context) ([]Stream, error) {
+	var sigs []scan.Signature
+	for _, sig := range scan.Signatures {
+		if slices.Contains(u.Config.Formats, sig.Name) {
+			sigs = append(sigs, sig)
+		}
+	}
+	hits, err := scan.Scan(ctx, bytes.NewReader(u.data), int64(len(u.data)), sigs)
+	if err != nil {
+		return nil, err
+	}
+	var streams []Stream
+	var end int64
+	for _, h := range hits {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if h.Offset < end {
+			continue
+		}
+		s := Decode(u.data, h.Offset, h.Name, io.Discard)
+		if s.Err == nil {
+			end = h.Offset + s.Consumed
+		}
+		streams = append(streams, s)
+	}
+	return streams, nil
+}
+
+// Every tries decoders at every offset with step, only valid streams with
+// at least minimal size of output are returned.
+func (u *Unpacker) Every(ctx context.Context) ([]Stream, error) {
+	var streams []Stream
+	step := int64(u.Config.Step)
+	for off := int64(0); off < int64(len(u.data)); {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		next := off + step
+		for _, f := range u.Config.Formats {
+			if _, ok := Decoders[f]; !ok {
+				continue
+			}
+			s := Decode(u.data, off, f, io.Discard)
+			if s.Err == nil && s.Produced >= u.Config.MinSize && s.Produced > 0 {
+				streams = append(streams, s)
+				// the next stream can't start inside of this one
+				next = off + (s.Consumed+step-1)/step*step
+				break
+			}
+		}
+		off = next
+	}
+	return streams, nil
+}
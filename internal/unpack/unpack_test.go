@@ -0,0 +1,125 @@
+package unpack
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var text = bytes.Repeat([]byte("hello bzip2 "), 8)
+
+// bzip2 of text
+var bzipped, _ = hex.DecodeString("425a6839314159265359a406d8d60000179980400010001264c01020003100300aa81a69ea58d0f0d8e8c882c40820f8bb9229c284852036c6b0")
+
+// LZ4 frame with one block: literals "abcd", match of 12 bytes at offset 4 and literal "!"
+var lz4Frame = []byte{
+	0x04, 0x22, 0x4d, 0x18, 0x60, 0x40, 0x82,
+	0x09, 0x00, 0x00, 0x00, 0x48, 'a', 'b', 'c', 'd', 0x04, 0x00, 0x10, '!',
+	0x00, 0x00, 0x00, 0x00,
+}
+
+func compress(t *testing.T, format string, data []byte) []byte {
+	var b bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch format {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "zlib":
+		w = zlib.NewWriter(&b)
+	case "deflate":
+		var err error
+		w, err = flate.NewWriter(&b, flate.BestCompression)
+		require.NoError(t, err)
+	}
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		format string
+		stream []byte
+		want   []byte
+		err    error
+	}{
+		{"gzip", compress(t, "gzip", text), text, nil},
+		{"zlib", compress(t, "zlib", text), text, nil},
+		{"deflate", compress(t, "deflate", text), text, nil},
+		{"bzip2", bzipped, text, nil},
+		{"LZ4", lz4Frame, []byte("abcdabcdabcdabcd!"), nil},
+		{"LZMA", []byte{0x5d, 0, 0, 0x80, 0}, nil, ErrUnsupported},
+		{"rar", []byte("Rar!"), nil, ErrFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			// stream is followed by garbage
+			data := append(append([]byte{0xaa, 0xbb}, tt.stream...), "\x00trailer"...)
+			var out bytes.Buffer
+			s := Decode(data, 2, tt.format, &out)
+			require.ErrorIs(t, s.Err, tt.err)
+			if tt.err != nil {
+				return
+			}
+			require.Equal(t, tt.want, out.Bytes())
+			require.Equal(t, int64(len(tt.stream)), s.Consumed)
+			require.Equal(t, int64(len(tt.want)), s.Produced)
+		})
+	}
+
+	// truncated stream
+	s := Decode(lz4Frame[:len(lz4Frame)-6], 0, "LZ4", &bytes.Buffer{})
+	require.Error(t, s.Err)
+}
+
+func TestUnpacker_Run(t *testing.T) {
+	dir := t.TempDir()
+	img := make([]byte, 0x1000)
+	gz := compress(t, "gzip", text)
+	copy(img[0x100:], gz)
+	copy(img[0x400:], bzipped)
+	copy(img[0x801:], compress(t, "deflate", text))
+	// broken zlib stream
+	copy(img[0xc00:], compress(t, "zlib", text)[:20])
+	input := filepath.Join(dir, "flash.bin")
+	require.NoError(t, os.WriteFile(input, img, 0o644))
+
+	out := &bytes.Buffer{}
+	u := New(config.Unpack{Output: filepath.Join(dir, "out")})
+	u.out = out
+	require.NoError(t, u.Open(input))
+	require.NoError(t, u.Run(context.TODO()))
+	require.Contains(t, out.String(), fmt.Sprintf("0x00000100 gzip    consumed 0x%x produced 0x60\n", len(gz)))
+	require.Contains(t, out.String(), "0x00000400 bzip2   consumed 0x3a produced 0x60\n")
+	require.Contains(t, out.String(), "0x00000c00 zlib    flate: corrupt input")
+	data, err := os.ReadFile(filepath.Join(dir, "out", "0x00000400-bzip2.bin"))
+	require.NoError(t, err)
+	require.Equal(t, text, data)
+	_, err = os.Stat(filepath.Join(dir, "out", "0x00000c00-zlib.bin"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	u = New(config.Unpack{Every: true, Step: 1, MinSize: 0x40, Formats: []string{"deflate"}, List: true})
+	require.NoError(t, u.Open(input))
+	streams, err := u.Every(context.TODO())
+	require.NoError(t, err)
+	offsets := []int64{}
+	for _, s := range streams {
+		offsets = append(offsets, s.Offset)
+	}
+	require.Contains(t, offsets, int64(0x801))
+}
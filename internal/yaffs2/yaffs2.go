@@ -0,0 +1,400 @@
+package yaffs2
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+var ErrGeometry = errors.New("invalid page geometry")
+var ErrTags = errors.New("tags don't fit in spare area")
+var ErrHeader = errors.New("invalid object header")
+
+const (
+	// packed tags2: sequence number, object id, chunk id, bytes in chunk
+	tagsSize = 16
+	// yaffs_ecc_other: column parity, line parity, line parity prime, it's
+	// only skipped and isn't checked
+	tagsECCSize = 12
+	headerSize  = 512
+	nameSize    = 256
+	aliasSize   = 160
+	maxDepth    = 256
+)
+
+const (
+	extraHeaderInfoFlag = 0x80000000
+	extraObjectTypeMask = 0xF0000000
+	erased              = 0xFFFFFFFF
+)
+
+// reserved objects of filesystem
+const (
+	rootID      = 1
+	lostFoundID = 2
+	unlinkedID  = 3
+	deletedID   = 4
+)
+
+type ObjectType uint32
+
+const (
+	TypeUnknown ObjectType = iota
+	TypeFile
+	TypeSymlink
+	TypeDirectory
+	TypeHardlink
+	TypeSpecial
+)
+
+type Tags struct {
+	SeqNumber uint32
+	ObjectID  uint32
+	ChunkID   uint32
+	NBytes    uint32
+}
+
+type Object struct {
+	ID       uint32
+	Type     ObjectType
+	ParentID uint32
+	Name     string
+	Mode     uint32
+	Size     int64
+	EquivID  uint32
+	Alias    string
+
+	seq    uint32
+	chunks map[uint32]chunk
+}
+
+type chunk struct {
+	seq    uint32
+	offset int64
+	nbytes uint32
+}
+
+type Extractor struct {
+	input  io.ReaderAt
+	size   int64
+	closer io.Closer
+	Config config.Yaffs2
+	Cut    config.Cut
+}
+
+func New(cfg config.Yaffs2, geometry config.Cut) *Extractor {
+	return &Extractor{
+		Config: cfg,
+		Cut:    geometry,
+	}
+}
+
+func (e *Extractor) Open(input string) error {
+	if err := e.checkGeometry(); err != nil {
+		return err
+	}
+	in, err := os.Open(input)
+	if err != nil {
+		return fmt.Errorf("can't open file '%s' for extracting: %w", input, err)
+	}
+	stat, err := in.Stat()
+	if err != nil {
+		in.Close()
+		return fmt.Errorf("can't get file stat '%s' for extracting: %w", input, err)
+	}
+	e.input = in
+	e.size = stat.Size()
+	e.closer = in
+	if e.Config.Output == "" {
+		name := filepath.Base(input)
+		e.Config.Output = name[:len(name)-len(filepath.Ext(name))] + "-yaffs2"
+	}
+	return nil
+}
+
+func (e *Extractor) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+func (e *Extractor) Run(ctx context.Context) error {
+	objects, err := e.Scan(ctx)
+	if err != nil {
+		return err
+	}
+	return e.Extract(ctx, objects, e.Config.Output)
+}
+
+// Scan reads all chunks with their spare areas and keeps the newest copy
+// of every chunk by sequence number.
+func (e *Extractor) Scan(ctx context.Context) (map[uint32]*Object, error) {
+	if err := e.checkGeometry(); err != nil {
+		return nil, err
+	}
+	objects := make(map[uint32]*Object)
+	page := make([]byte, e.Cut.PageSize+e.Cut.SkipSize)
+	r := io.NewSectionReader(e.input, 0, e.size)
+	for off := int64(0); ; off += int64(len(page)) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		_, err := io.ReadFull(r, page)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		tags, ok := e.tags(page[e.Cut.PageSize:])
+		if !ok {
+			continue
+		}
+		obj, ok := objects[tags.ObjectID]
+		if !ok {
+			obj = &Object{ID: tags.ObjectID, chunks: make(map[uint32]chunk)}
+			objects[tags.ObjectID] = obj
+		}
+		if tags.ChunkID == 0 {
+			if tags.SeqNumber < obj.seq {
+				continue
+			}
+			if err := obj.parseHeader(page[:e.Cut.PageSize], e.order()); err != nil {
+				// broken page, older copy of header can be still valid
+				continue
+			}
+			obj.seq = tags.SeqNumber
+			continue
+		}
+		if c, ok := obj.chunks[tags.ChunkID]; ok && tags.SeqNumber < c.seq {
+			continue
+		}
+		obj.chunks[tags.ChunkID] = chunk{
+			seq:    tags.SeqNumber,
+			offset: off,
+			nbytes: tags.NBytes,
+		}
+	}
+	return objects, nil
+}
+
+// Extract rebuilds files and directories of objects in dir, symlinks are created
+// the last, so files can't be written through them.
+func (e *Extractor) Extract(ctx context.Context, objects map[uint32]*Object, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var links []*Object
+	for _, obj := range objects {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if obj.Type == TypeSymlink {
+			links = append(links, obj)
+			continue
+		}
+		if err := e.extract(objects, obj, dir); err != nil {
+			return err
+		}
+	}
+	for _, obj := range links {
+		if err := e.extract(objects, obj, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Extractor) extract(objects map[uint32]*Object, obj *Object, dir string) error {
+	if obj.ID <= deletedID {
+		return nil
+	}
+	p, ok := path(objects, obj)
+	if !ok {
+		return nil
+	}
+	name := filepath.Join(dir, p)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	var err error
+	switch obj.Type {
+	case TypeDirectory:
+		err = os.MkdirAll(name, 0755)
+	case TypeFile:
+		err = e.writeFile(obj, name)
+	case TypeSymlink:
+		err = os.Symlink(obj.Alias, name)
+	case TypeHardlink:
+		equiv, ok := objects[obj.EquivID]
+		if !ok || equiv.Type != TypeFile {
+			return nil
+		}
+		err = e.writeFile(equiv, name)
+	}
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("can't extract '%s': %w", p, err)
+	}
+	return nil
+}
+
+// ReadFile returns contents of file object.
+func (e *Extractor) ReadFile(obj *Object) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := e.copyFile(obj, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *Extractor) writeFile(obj *Object, name string) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	return errors.Join(e.copyFile(obj, f), f.Close())
+}
+
+// copyFile writes chunks of file, missing chunks are holes. Size of file is
+// limited by its last chunk or size of image, so broken header can't produce
+// endless holes.
+func (e *Extractor) copyFile(obj *Object, w io.Writer) error {
+	last := uint32(0)
+	for id := range obj.chunks {
+		last = max(last, id)
+	}
+	if obj.Size > int64(last)*int64(e.Cut.PageSize) && obj.Size > e.size {
+		return fmt.Errorf("%w: size 0x%x of object %d is after its chunks", ErrHeader, obj.Size, obj.ID)
+	}
+	page := make([]byte, e.Cut.PageSize)
+	written := int64(0)
+	chunks := (obj.Size + int64(e.Cut.PageSize) - 1) / int64(e.Cut.PageSize)
+	for id := uint32(1); int64(id) <= chunks; id++ {
+		n := min(int64(e.Cut.PageSize), obj.Size-written)
+		c, ok := obj.chunks[id]
+		if !ok {
+			// hole in file
+			clear(page)
+		} else if _, err := e.input.ReadAt(page, c.offset); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		} else if int64(c.nbytes) < n {
+			clear(page[c.nbytes:])
+		}
+		if _, err := w.Write(page[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return nil
+}
+
+func (e *Extractor) tags(spare []byte) (Tags, bool) {
+	var t Tags
+	b := spare[e.Config.TagsOffset : e.Config.TagsOffset+tagsSize]
+	order := e.order()
+	t.SeqNumber = order.Uint32(b[0:4])
+	t.ObjectID = order.Uint32(b[4:8])
+	t.ChunkID = order.Uint32(b[8:12])
+	t.NBytes = order.Uint32(b[12:16])
+	if t.SeqNumber == 0 || t.SeqNumber == erased || t.ObjectID == 0 {
+		return t, false
+	}
+	if t.ChunkID&extraHeaderInfoFlag != 0 {
+		t.ChunkID = 0
+		t.NBytes = 0
+		t.ObjectID &^= extraObjectTypeMask
+	}
+	return t, true
+}
+
+func (e *Extractor) order() binary.ByteOrder {
+	if e.Config.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (e *Extractor) checkGeometry() error {
+	if e.Cut.PageSize < headerSize {
+		return fmt.Errorf("%w: page size should be at least %d", ErrGeometry, headerSize)
+	}
+	need := e.Config.TagsOffset + tagsSize
+	if e.Config.TagsECC {
+		need += tagsECCSize
+	}
+	if e.Config.TagsOffset < 0 || e.Cut.SkipSize < need {
+		return fmt.Errorf("%w: need %d bytes, spare is %d", ErrTags, need, e.Cut.SkipSize)
+	}
+	return nil
+}
+
+// parseHeader decodes yaffs_obj_hdr, it's stored in the same byte order as tags.
+func (o *Object) parseHeader(b []byte, order binary.ByteOrder) error {
+	t := ObjectType(order.Uint32(b[0:4]))
+	if t == TypeUnknown || t > TypeSpecial {
+		return fmt.Errorf("%w: type %d", ErrHeader, t)
+	}
+	o.Type = t
+	o.ParentID = order.Uint32(b[4:8])
+	o.Name = cString(b[10 : 10+nameSize])
+	o.Mode = order.Uint32(b[268:272])
+	o.Size = int64(order.Uint32(b[292:296]))
+	if high := order.Uint32(b[496:500]); high != erased {
+		o.Size |= int64(high) << 32
+	}
+	o.EquivID = order.Uint32(b[296:300])
+	o.Alias = cString(b[300 : 300+aliasSize])
+	return nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// path returns path of object relative to root, objects in unlinked or
+// deleted directories and objects with unsafe names haven't path.
+func path(objects map[uint32]*Object, obj *Object) (string, bool) {
+	if !safeName(obj.Name) {
+		return "", false
+	}
+	p := obj.Name
+	for depth := 0; depth < maxDepth; depth++ {
+		switch obj.ParentID {
+		case rootID:
+			return p, true
+		case unlinkedID, deletedID:
+			return "", false
+		}
+		parent, ok := objects[obj.ParentID]
+		if !ok || parent.Type != TypeDirectory {
+			// orphan objects are placed in lost+found
+			return filepath.Join("lost+found", p), true
+		}
+		obj = parent
+		if !safeName(obj.Name) {
+			return "", false
+		}
+		p = filepath.Join(obj.Name, p)
+	}
+	return "", false
+}
+
+// safeName rejects names, which can escape from output directory
+func safeName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+}
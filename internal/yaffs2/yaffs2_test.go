@@ -0,0 +1,182 @@
+package yaffs2
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Nexadis/fw-tools/internal/config"
+)
+
+const (
+	testPage  = 512
+	testSpare = 32
+)
+
+type testChunk struct {
+	seq     uint32
+	obj     uint32
+	chunk   uint32
+	nbytes  uint32
+	payload []byte
+}
+
+func header(t ObjectType, parent uint32, name string, size uint32) []byte {
+	b := make([]byte, testPage)
+	binary.LittleEndian.PutUint32(b[0:4], uint32(t))
+	binary.LittleEndian.PutUint32(b[4:8], parent)
+	copy(b[10:], name)
+	binary.LittleEndian.PutUint32(b[292:296], size)
+	binary.LittleEndian.PutUint32(b[496:500], erased)
+	return b
+}
+
+func symlink(parent uint32, name, alias string) []byte {
+	b := header(TypeSymlink, parent, name, 0)
+	copy(b[300:], alias)
+	return b
+}
+
+func image(chunks []testChunk, tagsOffset int) []byte {
+	buf := &bytes.Buffer{}
+	for _, c := range chunks {
+		page := make([]byte, testPage+testSpare)
+		copy(page, c.payload)
+		spare := page[testPage+tagsOffset:]
+		binary.LittleEndian.PutUint32(spare[0:4], c.seq)
+		binary.LittleEndian.PutUint32(spare[4:8], c.obj)
+		binary.LittleEndian.PutUint32(spare[8:12], c.chunk)
+		binary.LittleEndian.PutUint32(spare[12:16], c.nbytes)
+		buf.Write(page)
+	}
+	// erased page
+	buf.Write(bytes.Repeat([]byte{0xFF}, testPage+testSpare))
+	return buf.Bytes()
+}
+
+func TestExtractor_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Yaffs2
+		chunks  []testChunk
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"File in directory",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{1, 257, 0, 0, header(TypeDirectory, rootID, "etc", 0)},
+				{1, 258, 0, 0, header(TypeFile, 257, "passwd", 5)},
+				{1, 258, 1, 5, []byte("root\n")},
+			},
+			map[string]string{"etc/passwd": "root\n"},
+			false,
+		},
+		{
+			"Newest chunk wins",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{2, 258, 1, 3, []byte("new")},
+				{1, 258, 0, 0, header(TypeFile, rootID, "version", 3)},
+				{1, 258, 1, 3, []byte("old")},
+			},
+			map[string]string{"version": "new"},
+			false,
+		},
+		{
+			"Header info in chunk id",
+			config.Yaffs2{TagsOffset: 0, TagsECC: true},
+			[]testChunk{
+				{1, 258 | uint32(TypeFile)<<28, extraHeaderInfoFlag | rootID, 0, header(TypeFile, rootID, "a", 1)},
+				{1, 258, 1, 1, []byte("b")},
+			},
+			map[string]string{"a": "b"},
+			false,
+		},
+		{
+			"Deleted file is skipped",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{1, 258, 0, 0, header(TypeFile, rootID, "gone", 1)},
+				{1, 258, 1, 1, []byte("x")},
+				{2, 258, 0, 0, header(TypeFile, deletedID, "gone", 1)},
+			},
+			map[string]string{},
+			false,
+		},
+		{
+			"Unsafe names are skipped",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{1, 257, 0, 0, header(TypeDirectory, rootID, "..", 0)},
+				{1, 258, 0, 0, header(TypeFile, 257, "escaped", 1)},
+				{1, 258, 1, 1, []byte("x")},
+				{1, 259, 0, 0, header(TypeFile, rootID, "../escaped", 1)},
+				{1, 259, 1, 1, []byte("x")},
+			},
+			map[string]string{},
+			false,
+		},
+		{
+			"File isn't written through symlink",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{1, 257, 0, 0, symlink(rootID, "config", "../escaped")},
+				{1, 258, 0, 0, header(TypeFile, rootID, "config", 1)},
+				{1, 258, 1, 1, []byte("x")},
+			},
+			map[string]string{"config": "x"},
+			false,
+		},
+		{
+			"Size after chunks",
+			config.Yaffs2{TagsOffset: 2},
+			[]testChunk{
+				{1, 258, 0, 0, header(TypeFile, rootID, "huge", 1<<20)},
+				{1, 258, 1, 1, []byte("x")},
+			},
+			nil,
+			true,
+		},
+		{
+			"Tags don't fit",
+			config.Yaffs2{TagsOffset: 20},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image(tt.chunks, tt.cfg.TagsOffset)
+			e := New(tt.cfg, config.Cut{PageSize: testPage, SkipSize: testSpare})
+			e.input = bytes.NewReader(img)
+			e.size = int64(len(img))
+			e.Config.Output = filepath.Join(t.TempDir(), "out")
+			err := e.Run(context.TODO())
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got := map[string]string{}
+			filepath.Walk(e.Config.Output, func(p string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				data, err := os.ReadFile(p)
+				rel, _ := filepath.Rel(e.Config.Output, p)
+				got[filepath.ToSlash(rel)] = string(data)
+				return err
+			})
+			require.Equal(t, tt.want, got)
+			require.NoFileExists(t, filepath.Join(e.Config.Output, "..", "escaped"))
+		})
+	}
+}